@@ -0,0 +1,200 @@
+package bitwire
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "errors"
+  "fmt"
+  "math/big"
+  "strings"
+)
+
+// Returned by ValidateAddress, wrapped with the reason
+var (
+  ErrInvalidAddress = errors.New("invalid bitcoin address")
+  ErrWrongNetwork   = errors.New("bitcoin address is for the wrong network")
+)
+
+// Bitcoin network the payment addresses of a mode belong to
+type network struct {
+  Name       string
+  PubKeyHash byte   // Base58 version byte of P2PKH addresses
+  ScriptHash byte   // Base58 version byte of P2SH addresses
+  HRP        string // Bech32 human readable part of segwit addresses
+}
+
+var (
+  mainnet = network{"mainnet", 0x00, 0x05, "bc"}
+  testnet = network{"testnet", 0x6f, 0xc4, "tb"}
+)
+
+// Sandbox transfers are paid in testnet coins
+func networkFor(mode Mode) network {
+  if mode == SANDBOX {
+    return testnet
+  }
+  return mainnet
+}
+
+// Checks the checksum of a base58 or bech32 bitcoin address, and that it belongs
+// to the network of the mode: mainnet in production and testnet in sandbox.
+// Errors match ErrInvalidAddress or ErrWrongNetwork with errors.Is.
+func ValidateAddress(address string, mode Mode) error {
+  net := networkFor(mode)
+  if address == "" {
+    return fmt.Errorf("%w: empty", ErrInvalidAddress)
+  }
+  if i := strings.LastIndexByte(address, '1'); i > 0 && isBech32HRP(strings.ToLower(address[:i])) {
+    return validateSegwit(address, net)
+  }
+  return validateBase58(address, net)
+}
+
+// Tells if the prefix is the human readable part of a known network's segwit addresses
+func isBech32HRP(hrp string) bool {
+  return hrp == mainnet.HRP || hrp == testnet.HRP
+}
+
+// Validates the payment address, see ValidateAddress
+func (b BTC) Validate(mode Mode) error {
+  return ValidateAddress(b.Address, mode)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func validateBase58(address string, net network) error {
+  data, err := decodeBase58(address)
+  if err != nil {
+    return err
+  }
+  if len(data) != 25 {
+    return fmt.Errorf("%w: wrong length", ErrInvalidAddress)
+  }
+  payload, checksum := data[:21], data[21:]
+  first := sha256.Sum256(payload)
+  second := sha256.Sum256(first[:])
+  if !bytes.Equal(second[:4], checksum) {
+    return fmt.Errorf("%w: checksum mismatch", ErrInvalidAddress)
+  }
+  switch payload[0] {
+  case net.PubKeyHash, net.ScriptHash:
+    return nil
+  case mainnet.PubKeyHash, mainnet.ScriptHash, testnet.PubKeyHash, testnet.ScriptHash:
+    return fmt.Errorf("%w: expected a %s address", ErrWrongNetwork, net.Name)
+  default:
+    return fmt.Errorf("%w: unknown version %d", ErrInvalidAddress, payload[0])
+  }
+}
+
+// Decodes a base58 string, keeping leading zero bytes
+func decodeBase58(s string) ([]byte, error) {
+  n := new(big.Int)
+  radix := big.NewInt(58)
+  for _, r := range s {
+    i := strings.IndexRune(base58Alphabet, r)
+    if i < 0 {
+      return nil, fmt.Errorf("%w: bad character %q", ErrInvalidAddress, r)
+    }
+    n.Mul(n, radix)
+    n.Add(n, big.NewInt(int64(i)))
+  }
+  zeros := 0
+  for zeros < len(s) && s[zeros] == '1' {
+    zeros++
+  }
+  return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Checksum constants of bech32 (BIP 173) and bech32m (BIP 350)
+const (
+  bech32Const  = 1
+  bech32mConst = 0x2bc830a3
+)
+
+func validateSegwit(address string, net network) error {
+  if address != strings.ToLower(address) && address != strings.ToUpper(address) {
+    return fmt.Errorf("%w: mixed case", ErrInvalidAddress)
+  }
+  address = strings.ToLower(address)
+  if len(address) > 90 {
+    return fmt.Errorf("%w: too long", ErrInvalidAddress)
+  }
+  sep := strings.LastIndexByte(address, '1')
+  hrp, rest := address[:sep], address[sep+1:]
+  if len(rest) < 7 {
+    return fmt.Errorf("%w: too short", ErrInvalidAddress)
+  }
+  values := make([]byte, len(rest))
+  for i := range rest {
+    v := strings.IndexByte(bech32Charset, rest[i])
+    if v < 0 {
+      return fmt.Errorf("%w: bad character %q", ErrInvalidAddress, rest[i])
+    }
+    values[i] = byte(v)
+  }
+  checksum := bech32Polymod(append(bech32ExpandHRP(hrp), values...))
+  data := values[:len(values)-6]
+  version := data[0]
+  switch {
+  case version == 0 && checksum != bech32Const, version > 0 && checksum != bech32mConst:
+    return fmt.Errorf("%w: checksum mismatch", ErrInvalidAddress)
+  case version > 16:
+    return fmt.Errorf("%w: unknown witness version %d", ErrInvalidAddress, version)
+  }
+  program, ok := convertBits(data[1:], 5, 8)
+  if !ok || len(program) < 2 || len(program) > 40 || version == 0 && len(program) != 20 && len(program) != 32 {
+    return fmt.Errorf("%w: bad witness program", ErrInvalidAddress)
+  }
+  if hrp != net.HRP {
+    return fmt.Errorf("%w: expected a %s address", ErrWrongNetwork, net.Name)
+  }
+  return nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+  generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+  chk := uint32(1)
+  for _, v := range values {
+    top := chk >> 25
+    chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+    for i := 0; i < 5; i++ {
+      if (top>>uint(i))&1 == 1 {
+        chk ^= generator[i]
+      }
+    }
+  }
+  return chk
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+  expanded := make([]byte, 0, len(hrp)*2+1)
+  for i := range hrp {
+    expanded = append(expanded, hrp[i]>>5)
+  }
+  expanded = append(expanded, 0)
+  for i := range hrp {
+    expanded = append(expanded, hrp[i]&31)
+  }
+  return expanded
+}
+
+// Regroups 5-bit values into bytes, rejecting non-zero padding
+func convertBits(data []byte, from, to uint) ([]byte, bool) {
+  var acc, bits uint
+  var out []byte
+  maxv := uint(1)<<to - 1
+  for _, v := range data {
+    acc = acc<<from | uint(v)
+    bits += from
+    for bits >= to {
+      bits -= to
+      out = append(out, byte(acc>>bits&maxv))
+    }
+  }
+  if bits >= from || acc<<(to-bits)&maxv != 0 {
+    return nil, false
+  }
+  return out, true
+}
@@ -0,0 +1,87 @@
+package bitwire
+
+import "context"
+
+//go:generate go run ./tools/schemadoc -md cmd/schema.md -json cmd/schema.json
+
+// Methods of RatesService, for substituting it in tests
+type RatesAPI interface {
+  All() (AllRates, error)
+  Fx() (Rates, error)
+  Btc() (Rates, error)
+}
+
+// Methods of BanksService, for substituting it in tests
+type BanksAPI interface {
+  List() ([]Bank, error)
+}
+
+// Methods of RecipientsService, for substituting it in tests
+type RecipientsAPI interface {
+  List() ([]Recipient, error)
+  Find(query string) ([]Recipient, error)
+}
+
+// Methods of TransfersService, for substituting it in tests
+type TransfersAPI interface {
+  Estimate(amount Decimal, currency string) (Estimate, error)
+  Currencies() ([]string, error)
+  ValidateCurrency(currency string) error
+  List(opts *TransferListOptions) ([]Transfer, error)
+  Get(id string) (Transfer, error)
+  Create(transfer CreateTransfer) (Transfer, error)
+  Cancel(id string) (Transfer, error)
+}
+
+// Methods of LimitsService, for substituting it in tests
+type LimitsAPI interface {
+  Get() (Limits, error)
+}
+
+// Methods of FeesService, for substituting it in tests
+type FeesAPI interface {
+  Get() (Fees, error)
+}
+
+// Methods of UsersService, for substituting it in tests
+type UsersAPI interface {
+  Me() (User, error)
+  Verification() (Verification, error)
+}
+
+// Methods of Client, for substituting it in tests.
+// The bitwiretest package has an in-memory implementation.
+type BitwireAPI interface {
+  Token() Token
+  Authenticate(credentials LoginCredentials) (Token, error)
+  TokenAuthenticate(credentials LoginCredentials, token Token) (Token, error)
+  RefreshToken() (Token, error)
+  RevokeToken() (bool, error)
+  LastResponse() Response
+  Do(ctx context.Context, method Method, path string, params interface{}, result interface{}) error
+
+  GetAllRates() (AllRates, error)
+  GetFxRates() (Rates, error)
+  GetBtcRates() (Rates, error)
+  GetBanks() ([]Bank, error)
+  GetRecipients() ([]Recipient, error)
+  GetTransfers() ([]Transfer, error)
+  GetTransfer(id string) (Transfer, error)
+  CreateTransfer(transfer CreateTransfer) (Transfer, error)
+  CancelTransfer(id string) (Transfer, error)
+  GetLimits() (Limits, error)
+  GetFees() (Fees, error)
+  GetMe() (User, error)
+  GetVerificationStatus() (Verification, error)
+}
+
+var (
+  _ BitwireAPI    = (*Client)(nil)
+  _ RatesAPI      = (*RatesService)(nil)
+  _ BanksAPI      = (*BanksService)(nil)
+  _ RecipientsAPI = (*RecipientsService)(nil)
+  _ TransfersAPI  = (*TransfersService)(nil)
+  _ LimitsAPI     = (*LimitsService)(nil)
+  _ FeesAPI       = (*FeesService)(nil)
+  _ UsersAPI      = (*UsersService)(nil)
+)
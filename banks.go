@@ -0,0 +1,36 @@
+package bitwire
+
+type BanksRes struct {
+  Res
+  Banks []Bank `json:"banks"`
+}
+
+type Bank struct {
+  Id          int    `json:"id"`
+  Number      string `json:"number"`
+  DisplayName string `json:"display_name"`
+  Name        string `json:"name"`
+  NameKo      string `json:"name_ko"`
+}
+
+// Returns the Korean name for the "ko" language, if set, and the English name otherwise
+func (b Bank) LocalName(lang string) string {
+  if lang == "ko" && b.NameKo != "" {
+    return b.NameKo
+  }
+  return b.Name
+}
+
+// Handles the list of banks supported as payout destinations
+// https://developers.bitwire.co/api/v1/#banks
+type BanksService service
+
+func (s *BanksService) List() ([]Bank, error) {
+  banksRes := new(BanksRes)
+  err := callApi(GET, "banks", nil, s.client, false, banksRes)
+  if err != nil {
+    return nil, err
+  } else {
+    return banksRes.Banks, nil
+  }
+}
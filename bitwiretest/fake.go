@@ -0,0 +1,498 @@
+// Package bitwiretest provides an in-memory fake of the Bitwire API client for unit tests
+// of code using the bitwire package, so that they do not need the sandbox.
+package bitwiretest
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Fake of bitwire.Client, with the same services. Fill in the Data of the services
+// the code under test reads before using it, and call SetErr to make calls fail.
+// Calls are safe for concurrent use, but Data must not be changed while the fake is used.
+type Fake struct {
+  Rates      *Rates
+  Banks      *Banks
+  Recipients *Recipients
+  Transfers  *Transfers
+  Limits     *Limits
+  Users      *Users
+  Fees       *Fees
+
+  // Handles Client.Do calls, which fail with ErrNotImplemented if nil
+  DoFunc func(ctx context.Context, method bitwire.Method, path string, params interface{}, result interface{}) error
+
+  state *state
+}
+
+// Returned by Fake.Do when no DoFunc is set
+var ErrNotImplemented = errors.New("bitwiretest: endpoint not implemented by the fake")
+
+// Bank account the fake gives for depositing to bank_to_btc transfers
+var DepositAccount = bitwire.Deposit{BankName: "Shinhan Bank", AccountNumber: "140-012-345678", AccountHolder: "Bitwire Inc."}
+
+// Data shared by the fake and its services, guarded by mu
+type state struct {
+  mu     sync.Mutex
+  err    error
+  token  bitwire.Token
+  nextId int
+}
+
+// Data of the rates endpoints
+type Rates struct {
+  state *state
+  Data  bitwire.AllRates
+}
+
+// Data of the banks endpoint
+type Banks struct {
+  state *state
+  Data  []bitwire.Bank
+}
+
+// Data of the recipients endpoint
+type Recipients struct {
+  state *state
+  Data  []bitwire.Recipient
+}
+
+// Transfers stored by the fake. Created transfers are appended to Data.
+type Transfers struct {
+  state      *state
+  rates      *Rates
+  recipients *Recipients
+  fees       *Fees
+  Data       []bitwire.Transfer
+}
+
+// Data of the limits endpoint
+type Limits struct {
+  state *state
+  Data  bitwire.Limits
+}
+
+// Data of the fees endpoint. Created transfers are charged by it.
+type Fees struct {
+  state *state
+  Data  bitwire.Fees
+}
+
+// Data of the user profile endpoint
+type Users struct {
+  state              *state
+  Data               bitwire.User
+  VerificationStatus bitwire.Verification
+}
+
+var (
+  _ bitwire.BitwireAPI    = (*Fake)(nil)
+  _ bitwire.RatesAPI      = (*Rates)(nil)
+  _ bitwire.BanksAPI      = (*Banks)(nil)
+  _ bitwire.RecipientsAPI = (*Recipients)(nil)
+  _ bitwire.TransfersAPI  = (*Transfers)(nil)
+  _ bitwire.LimitsAPI     = (*Limits)(nil)
+  _ bitwire.FeesAPI       = (*Fees)(nil)
+  _ bitwire.UsersAPI      = (*Users)(nil)
+)
+
+// Returns a fake with no data
+func New() *Fake {
+  s := &state{nextId: 1}
+  f := &Fake{state: s}
+  f.Rates = &Rates{state: s, Data: bitwire.AllRates{BTC: bitwire.Rates{}, FX: bitwire.Rates{}}}
+  f.Banks = &Banks{state: s}
+  f.Recipients = &Recipients{state: s}
+  f.Fees = &Fees{state: s}
+  f.Transfers = &Transfers{state: s, rates: f.Rates, recipients: f.Recipients, fees: f.Fees}
+  f.Limits = &Limits{state: s}
+  f.Users = &Users{state: s}
+  return f
+}
+
+// Makes every following call fail with err, or succeed again if err is nil
+func (f *Fake) SetErr(err error) {
+  f.state.mu.Lock()
+  defer f.state.mu.Unlock()
+  f.state.err = err
+}
+
+// Locks the state, returning the error set with SetErr. The caller must unlock it.
+func (s *state) lock() error {
+  s.mu.Lock()
+  return s.err
+}
+
+func (s *state) unlock() {
+  s.mu.Unlock()
+}
+
+// Returned for unknown IDs, like the API does
+func notFound(what string) error {
+  return &bitwire.APIError{Code: http.StatusNotFound, ErrorType: "not_found", Message: what + " not found.", HTTPStatus: http.StatusNotFound}
+}
+
+func (f *Fake) Token() bitwire.Token {
+  f.state.mu.Lock()
+  defer f.state.mu.Unlock()
+  return f.state.token
+}
+
+// Accepts any credentials and returns a new token valid for an hour
+func (f *Fake) Authenticate(credentials bitwire.LoginCredentials) (bitwire.Token, error) {
+  return f.newToken()
+}
+
+func (f *Fake) TokenAuthenticate(credentials bitwire.LoginCredentials, token bitwire.Token) (bitwire.Token, error) {
+  return f.newToken()
+}
+
+func (f *Fake) RefreshToken() (bitwire.Token, error) {
+  return f.newToken()
+}
+
+// Discards the token, which the server then rejects like a revoked one
+func (f *Fake) RevokeToken() (bool, error) {
+  err := f.state.lock()
+  defer f.state.unlock()
+  if err != nil {
+    return false, err
+  }
+  f.state.token = bitwire.Token{}
+  return true, nil
+}
+
+func (f *Fake) newToken() (bitwire.Token, error) {
+  err := f.state.lock()
+  defer f.state.unlock()
+  if err != nil {
+    return bitwire.Token{}, err
+  }
+  id := f.state.nextId
+  f.state.nextId++
+  f.state.token = bitwire.Token{TokenType: "Bearer", AccessToken: fmt.Sprintf("access_%d", id),
+    RefreshToken: fmt.Sprintf("refresh_%d", id), ExpiresIn: 3600, ValidUntil: time.Now().Unix() + 3600}
+  return f.state.token, nil
+}
+
+// Returns a successful response without headers
+func (f *Fake) LastResponse() bitwire.Response {
+  return bitwire.Response{HTTPStatus: http.StatusOK, Header: http.Header{}}
+}
+
+func (f *Fake) Do(ctx context.Context, method bitwire.Method, path string, params interface{}, result interface{}) error {
+  if err := f.state.lock(); err != nil {
+    f.state.unlock()
+    return err
+  }
+  f.state.unlock()
+  if f.DoFunc == nil {
+    return ErrNotImplemented
+  }
+  return f.DoFunc(ctx, method, path, params, result)
+}
+
+func (f *Fake) GetAllRates() (bitwire.AllRates, error) {
+  return f.Rates.All()
+}
+
+func (f *Fake) GetFxRates() (bitwire.Rates, error) {
+  return f.Rates.Fx()
+}
+
+func (f *Fake) GetBtcRates() (bitwire.Rates, error) {
+  return f.Rates.Btc()
+}
+
+func (f *Fake) GetBanks() ([]bitwire.Bank, error) {
+  return f.Banks.List()
+}
+
+func (f *Fake) GetRecipients() ([]bitwire.Recipient, error) {
+  return f.Recipients.List()
+}
+
+func (f *Fake) GetTransfers() ([]bitwire.Transfer, error) {
+  return f.Transfers.List(nil)
+}
+
+func (f *Fake) GetTransfer(id string) (bitwire.Transfer, error) {
+  return f.Transfers.Get(id)
+}
+
+func (f *Fake) CreateTransfer(transfer bitwire.CreateTransfer) (bitwire.Transfer, error) {
+  return f.Transfers.Create(transfer)
+}
+
+func (f *Fake) CancelTransfer(id string) (bitwire.Transfer, error) {
+  return f.Transfers.Cancel(id)
+}
+
+func (f *Fake) GetLimits() (bitwire.Limits, error) {
+  return f.Limits.Get()
+}
+
+func (f *Fake) GetFees() (bitwire.Fees, error) {
+  return f.Fees.Get()
+}
+
+func (f *Fake) GetMe() (bitwire.User, error) {
+  return f.Users.Me()
+}
+
+func (f *Fake) GetVerificationStatus() (bitwire.Verification, error) {
+  return f.Users.Verification()
+}
+
+func (r *Rates) All() (bitwire.AllRates, error) {
+  err := r.state.lock()
+  defer r.state.unlock()
+  if err != nil {
+    return bitwire.AllRates{}, err
+  }
+  return r.Data, nil
+}
+
+func (r *Rates) Fx() (bitwire.Rates, error) {
+  all, err := r.All()
+  return all.FX, err
+}
+
+func (r *Rates) Btc() (bitwire.Rates, error) {
+  all, err := r.All()
+  return all.BTC, err
+}
+
+func (b *Banks) List() ([]bitwire.Bank, error) {
+  err := b.state.lock()
+  defer b.state.unlock()
+  if err != nil {
+    return nil, err
+  }
+  return append([]bitwire.Bank(nil), b.Data...), nil
+}
+
+func (r *Recipients) List() ([]bitwire.Recipient, error) {
+  err := r.state.lock()
+  defer r.state.unlock()
+  if err != nil {
+    return nil, err
+  }
+  return append([]bitwire.Recipient(nil), r.Data...), nil
+}
+
+func (r *Recipients) Find(query string) ([]bitwire.Recipient, error) {
+  recipients, err := r.List()
+  if err != nil {
+    return nil, err
+  }
+  var found []bitwire.Recipient
+  for _, recipient := range recipients {
+    if recipient.Matches(query) {
+      found = append(found, recipient)
+    }
+  }
+  return found, nil
+}
+
+// Estimates the BTC amount from Rates, like the client does
+func (t *Transfers) Estimate(amount bitwire.Decimal, currency string) (bitwire.Estimate, error) {
+  if amount.Sign() <= 0 {
+    return bitwire.Estimate{}, errors.New("Amount must be positive")
+  }
+  rates, err := t.rates.Btc()
+  if err != nil {
+    return bitwire.Estimate{}, err
+  }
+  pair := "BTC" + strings.ToUpper(currency)
+  rate, ok := rates[pair]
+  if !ok || rate.Sign() <= 0 {
+    return bitwire.Estimate{}, errors.New("No BTC rate for " + currency)
+  }
+  return bitwire.Estimate{Amount: amount, Currency: strings.ToUpper(currency), Pair: pair, Rate: rate,
+    BTC: amount.QuoUp(rate, 8)}, nil
+}
+
+// Returns the currencies Rates has a BTC rate for
+func (t *Transfers) Currencies() ([]string, error) {
+  rates, err := t.rates.Btc()
+  if err != nil {
+    return nil, err
+  }
+  return rates.Currencies(), nil
+}
+
+func (t *Transfers) ValidateCurrency(currency string) error {
+  currencies, err := t.Currencies()
+  if err != nil {
+    return err
+  }
+  for _, c := range currencies {
+    if strings.EqualFold(c, currency) {
+      return nil
+    }
+  }
+  return fmt.Errorf("%w: %s (supported: %s)", bitwire.ErrUnsupportedCurrency, currency, strings.Join(currencies, ", "))
+}
+
+// Lists the transfers matching the options, like the API filters them
+func (t *Transfers) List(opts *bitwire.TransferListOptions) ([]bitwire.Transfer, error) {
+  err := t.state.lock()
+  defer t.state.unlock()
+  if err != nil {
+    return nil, err
+  }
+  var found []bitwire.Transfer
+  for _, tx := range t.Data {
+    if opts == nil || opts.Matches(tx) {
+      found = append(found, tx)
+    }
+  }
+  return found, nil
+}
+
+func (t *Transfers) Get(id string) (bitwire.Transfer, error) {
+  err := t.state.lock()
+  defer t.state.unlock()
+  if err != nil {
+    return bitwire.Transfer{}, err
+  }
+  for _, tx := range t.Data {
+    if tx.Id == id {
+      return tx, nil
+    }
+  }
+  return bitwire.Transfer{}, notFound("Transfer")
+}
+
+// Creates a pending transfer to one of the Recipients, or a bank_to_btc transfer to the address
+// with DepositAccount to deposit to. The BTC amount is estimated from Rates if they have a rate
+// for the currency, and zero otherwise.
+func (t *Transfers) Create(transfer bitwire.CreateTransfer) (bitwire.Transfer, error) {
+  estimate, estimateErr := t.Estimate(transfer.Amount, transfer.Currency)
+  tx := bitwire.Transfer{Type: transfer.Type, Memo: transfer.Memo, Currency: "BTC", Status: bitwire.StatusPending}
+  if transfer.Type == bitwire.TypeBankToBtc {
+    tx.BTC.Address = transfer.Address
+    tx.Deposit = DepositAccount
+    tx.Deposit.Amount, tx.Deposit.Currency = transfer.Amount, transfer.Currency
+  } else {
+    recipients, err := t.recipients.List()
+    if err != nil {
+      return bitwire.Transfer{}, err
+    }
+    found := false
+    for _, r := range recipients {
+      if r.Id == transfer.RecipientId {
+        tx.Recipient.Recipient, found = r, true
+      }
+    }
+    if !found {
+      return bitwire.Transfer{}, notFound("Recipient")
+    }
+    tx.Recipient.Amount, tx.Recipient.Currency = transfer.Amount, transfer.Currency
+  }
+  if estimateErr == nil {
+    tx.Amount = estimate.BTC
+  }
+  fees, err := t.fees.Get()
+  if err != nil {
+    return bitwire.Transfer{}, err
+  }
+  tx.Fees = chargeFees(fees, transfer)
+  tx.CreatedAt = time.Now().UTC().Truncate(time.Second)
+  tx.Date = tx.CreatedAt.Format(time.RFC3339)
+
+  err = t.state.lock()
+  defer t.state.unlock()
+  if err != nil {
+    return bitwire.Transfer{}, err
+  }
+  tx.Id = fmt.Sprintf("tx_%d", t.state.nextId)
+  t.state.nextId++
+  if tx.IsBankToBtc() {
+    tx.Deposit.Reference = fmt.Sprintf("BW%d", t.state.nextId-1)
+  }
+  t.Data = append(t.Data, tx)
+  return tx, nil
+}
+
+// Returns the fees of the schedule for the transfer, none if the schedule is empty
+func chargeFees(fees bitwire.Fees, transfer bitwire.CreateTransfer) bitwire.TransferFees {
+  if fees.Currency == "" {
+    return bitwire.TransferFees{}
+  }
+  percent := bitwire.MustParseDecimal("0.01")
+  charged := bitwire.TransferFees{Currency: fees.Currency}
+  if transfer.Type != bitwire.TypeBankToBtc {
+    charged.Network = fees.Network
+  }
+  charged.Service = transfer.Amount.Mul(fees.ServicePercent).Mul(percent)
+  if charged.Service.Cmp(fees.ServiceMin) < 0 {
+    charged.Service = fees.ServiceMin
+  }
+  charged.FXSpread = transfer.Amount.Mul(fees.FXSpreadPercent).Mul(percent)
+  return charged
+}
+
+// Cancels a pending transfer
+func (t *Transfers) Cancel(id string) (bitwire.Transfer, error) {
+  err := t.state.lock()
+  defer t.state.unlock()
+  if err != nil {
+    return bitwire.Transfer{}, err
+  }
+  for i := range t.Data {
+    if t.Data[i].Id != id {
+      continue
+    }
+    if t.Data[i].IsFinal() {
+      return bitwire.Transfer{}, &bitwire.APIError{Code: http.StatusBadRequest, ErrorType: "validation_error",
+        Message: "Transfer cannot be canceled.", HTTPStatus: http.StatusBadRequest}
+    }
+    t.Data[i].Status = bitwire.StatusCanceled
+    return t.Data[i], nil
+  }
+  return bitwire.Transfer{}, notFound("Transfer")
+}
+
+func (l *Limits) Get() (bitwire.Limits, error) {
+  err := l.state.lock()
+  defer l.state.unlock()
+  if err != nil {
+    return bitwire.Limits{}, err
+  }
+  return l.Data, nil
+}
+
+func (u *Users) Me() (bitwire.User, error) {
+  err := u.state.lock()
+  defer u.state.unlock()
+  if err != nil {
+    return bitwire.User{}, err
+  }
+  return u.Data, nil
+}
+
+func (u *Users) Verification() (bitwire.Verification, error) {
+  err := u.state.lock()
+  defer u.state.unlock()
+  if err != nil {
+    return bitwire.Verification{}, err
+  }
+  return u.VerificationStatus, nil
+}
+
+func (f *Fees) Get() (bitwire.Fees, error) {
+  err := f.state.lock()
+  defer f.state.unlock()
+  if err != nil {
+    return bitwire.Fees{}, err
+  }
+  return f.Data, nil
+}
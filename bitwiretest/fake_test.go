@@ -0,0 +1,62 @@
+package bitwiretest
+
+import (
+  "errors"
+  "github.com/dworznik/bitwire"
+  "github.com/stretchr/testify/assert"
+  "testing"
+)
+
+func TestFakeTransfers(t *testing.T) {
+  fake := New()
+  fake.Rates.Data.BTC["BTCKRW"] = bitwire.MustParseDecimal("1000000")
+  fake.Recipients.Data = []bitwire.Recipient{{Id: 42, Name: "Kim Minsu", NameKo: "김민수"}}
+
+  var transfers bitwire.TransfersAPI = fake.Transfers
+  tx, err := transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("100000"), Currency: "KRW", RecipientId: 42})
+  assert.Nil(t, err)
+  assert.Equal(t, "0.10000000", tx.Amount.String())
+  assert.Equal(t, "Kim Minsu", tx.Recipient.Name)
+  assert.Equal(t, bitwire.StatusPending, tx.Status)
+
+  got, err := transfers.Get(tx.Id)
+  assert.Nil(t, err)
+  assert.Equal(t, tx, got)
+  listed, err := transfers.List(&bitwire.TransferListOptions{Status: bitwire.StatusPending})
+  assert.Nil(t, err)
+  assert.Equal(t, []bitwire.Transfer{tx}, listed)
+
+  canceled, err := transfers.Cancel(tx.Id)
+  assert.Nil(t, err)
+  assert.Equal(t, bitwire.StatusCanceled, canceled.Status)
+  _, err = transfers.Cancel(tx.Id)
+  assert.NotNil(t, err)
+
+  _, err = transfers.Get("missing")
+  var apiErr *bitwire.APIError
+  assert.True(t, errors.As(err, &apiErr))
+  assert.Equal(t, "not_found", apiErr.ErrorType)
+  _, err = transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("100000"), Currency: "KRW", RecipientId: 7})
+  assert.NotNil(t, err)
+}
+
+func TestFakeErr(t *testing.T) {
+  fake := New()
+  var api bitwire.BitwireAPI = fake
+  failure := errors.New("down")
+  fake.SetErr(failure)
+  _, err := api.GetBanks()
+  assert.Equal(t, failure, err)
+  _, err = api.Authenticate(bitwire.LoginCredentials{})
+  assert.Equal(t, failure, err)
+
+  fake.SetErr(nil)
+  token, err := api.Authenticate(bitwire.LoginCredentials{})
+  assert.Nil(t, err)
+  assert.Equal(t, token, api.Token())
+  assert.Equal(t, ErrNotImplemented, api.Do(nil, bitwire.GET, "users/me", nil, nil))
+  revoked, err := api.RevokeToken()
+  assert.True(t, revoked)
+  assert.Nil(t, err)
+  assert.Equal(t, bitwire.Token{}, api.Token())
+}
@@ -0,0 +1,198 @@
+package bitwiretest
+
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "io/ioutil"
+  "net/http"
+  "net/url"
+  "os"
+  "path/filepath"
+  "sync"
+)
+
+type RecorderMode int
+
+const (
+  Replay RecorderMode = iota // Serves the recorded responses, failing the requests that were not recorded
+  Record                     // Sends the requests and records the responses
+)
+
+// Set to record the fixtures again, e.g. BITWIRE_RECORD=1 go test ./...
+const RecordEnv = "BITWIRE_RECORD"
+
+// Record if the BITWIRE_RECORD environment variable is set, Replay otherwise
+func ModeFromEnv() RecorderMode {
+  if os.Getenv(RecordEnv) != "" {
+    return Record
+  }
+  return Replay
+}
+
+// Returned for a replayed request that does not match any unused recorded one
+var ErrNotRecorded = errors.New("bitwiretest: request not recorded")
+
+// Request and response pair of a fixture file. Credentials and tokens are redacted.
+type Interaction struct {
+  Method      string      `json:"method"`
+  Path        string      `json:"path"` // Path and query of the request URL
+  RequestBody string      `json:"request_body,omitempty"`
+  Status      int         `json:"status"`
+  Header      http.Header `json:"header,omitempty"`
+  Body        string      `json:"body"`
+}
+
+// Response headers kept in fixture files
+var recordedHeaders = []string{"Content-Type", "Retry-After", "X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset"}
+
+// HTTP transport recording real responses, e.g. of the sandbox, to a fixture file and replaying them
+// in tests. Requests are matched by method, path and redacted body, each recorded request once and in
+// order, so that a test can get a transfer before and after changing it.
+// Set it as a client's transport with Client, and call Save when done recording.
+type Recorder struct {
+  Path      string
+  Mode      RecorderMode
+  Transport http.RoundTripper // Sends the requests when recording, http.DefaultTransport if nil
+
+  mu           sync.Mutex // Guards the fields below
+  interactions []Interaction
+  used         []bool
+}
+
+// Returns a recorder of the fixture file, read when replaying
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+  r := &Recorder{Path: path, Mode: mode}
+  if mode == Record {
+    return r, nil
+  }
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  if err := json.Unmarshal(data, &r.interactions); err != nil {
+    return nil, fmt.Errorf("bitwiretest: reading %s: %s", path, err)
+  }
+  r.used = make([]bool, len(r.interactions))
+  return r, nil
+}
+
+// Returns an HTTP client sending the requests through the recorder, for bitwire.Client's HTTPClient
+func (r *Recorder) Client() *http.Client {
+  return &http.Client{Transport: r}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+  var reqBody []byte
+  if req.Body != nil {
+    var err error
+    reqBody, err = ioutil.ReadAll(req.Body)
+    req.Body.Close()
+    if err != nil {
+      return nil, err
+    }
+    req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+  }
+  path := redactQuery(req.URL)
+  body := bitwire.Redact(reqBody)
+  if r.Mode == Record {
+    return r.record(req, path, body)
+  }
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  for i, in := range r.interactions {
+    if !r.used[i] && in.Method == req.Method && in.Path == path && in.RequestBody == body {
+      r.used[i] = true
+      return in.response(req), nil
+    }
+  }
+  return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, path)
+}
+
+func (r *Recorder) record(req *http.Request, path, reqBody string) (*http.Response, error) {
+  transport := r.Transport
+  if transport == nil {
+    transport = http.DefaultTransport
+  }
+  resp, err := transport.RoundTrip(req)
+  if err != nil {
+    return nil, err
+  }
+  body, err := ioutil.ReadAll(resp.Body)
+  resp.Body.Close()
+  if err != nil {
+    return nil, err
+  }
+  resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+  in := Interaction{Method: req.Method, Path: path, RequestBody: reqBody, Status: resp.StatusCode, Header: http.Header{}, Body: redactResponse(body)}
+  for _, name := range recordedHeaders {
+    if value := resp.Header.Get(name); value != "" {
+      in.Header.Set(name, value)
+    }
+  }
+  r.mu.Lock()
+  r.interactions = append(r.interactions, in)
+  r.used = append(r.used, true)
+  r.mu.Unlock()
+  return resp, nil
+}
+
+// Writes the recorded interactions to the fixture file. Does nothing when replaying.
+func (r *Recorder) Save() error {
+  if r.Mode != Record {
+    return nil
+  }
+  r.mu.Lock()
+  data, err := json.MarshalIndent(r.interactions, "", "  ")
+  r.mu.Unlock()
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(filepath.Dir(r.Path), 0700); err != nil {
+    return err
+  }
+  return ioutil.WriteFile(r.Path, append(data, '\n'), 0600)
+}
+
+// Returns the recorded interactions
+func (r *Recorder) Interactions() []Interaction {
+  r.mu.Lock()
+  defer r.mu.Unlock()
+  return append([]Interaction(nil), r.interactions...)
+}
+
+func (in Interaction) response(req *http.Request) *http.Response {
+  header := http.Header{}
+  for name, values := range in.Header {
+    header[name] = append([]string(nil), values...)
+  }
+  return &http.Response{
+    Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+    StatusCode:    in.Status,
+    Proto:         "HTTP/1.1",
+    ProtoMajor:    1,
+    ProtoMinor:    1,
+    Header:        header,
+    Body:          ioutil.NopCloser(bytes.NewReader([]byte(in.Body))),
+    ContentLength: int64(len(in.Body)),
+    Request:       req,
+  }
+}
+
+// Returns the path and query of the URL with the secret query parameters redacted
+func redactQuery(u *url.URL) string {
+  if u.RawQuery == "" {
+    return u.Path
+  }
+  return u.Path + "?" + bitwire.Redact([]byte(u.RawQuery))
+}
+
+// Redacts the tokens of a JSON response body, keeping other bodies as they are
+func redactResponse(body []byte) string {
+  if len(body) > 0 && (body[0] == '{' || body[0] == '[') {
+    return bitwire.Redact(body)
+  }
+  return string(body)
+}
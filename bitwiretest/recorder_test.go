@@ -0,0 +1,62 @@
+package bitwiretest
+
+import (
+  "errors"
+  "github.com/dworznik/bitwire"
+  "github.com/stretchr/testify/assert"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+func TestRecorder(t *testing.T) {
+  dir, err := ioutil.TempDir("", "bitwiretest")
+  assert.Nil(t, err)
+  defer os.RemoveAll(dir)
+  path := filepath.Join(dir, "testdata", "transfers.json")
+
+  calls := func(client *bitwire.Client, creds bitwire.LoginCredentials) ([]bitwire.Transfer, bitwire.Transfer, error) {
+    if _, err := client.Authenticate(creds); err != nil {
+      return nil, bitwire.Transfer{}, err
+    }
+    txs, err := client.Transfers.List(&bitwire.TransferListOptions{Status: bitwire.StatusPending})
+    if err != nil {
+      return nil, bitwire.Transfer{}, err
+    }
+    canceled, err := client.Transfers.Cancel(txs[0].Id)
+    return txs, canceled, err
+  }
+
+  server := NewServer()
+  server.ClientSecret, server.Username, server.Password = "s3cr3t", "alice", "hunter2"
+  recorder, err := NewRecorder(path, Record)
+  assert.Nil(t, err)
+  client := server.APIClient()
+  client.HTTPClient = recorder.Client()
+  recordedTxs, recordedTx, err := calls(client, server.LoginCredentials())
+  assert.Nil(t, err)
+  assert.Nil(t, recorder.Save())
+  server.Close()
+
+  data, err := ioutil.ReadFile(path)
+  assert.Nil(t, err)
+  for _, secret := range []string{"s3cr3t", "alice", "hunter2", client.Token().AccessToken, client.Token().RefreshToken} {
+    assert.False(t, strings.Contains(string(data), secret), secret)
+  }
+  assert.Contains(t, string(data), "[REDACTED]")
+
+  replayer, err := NewRecorder(path, Replay)
+  assert.Nil(t, err)
+  client = server.APIClient()
+  client.HTTPClient = replayer.Client()
+  txs, tx, err := calls(client, server.LoginCredentials())
+  assert.Nil(t, err)
+  assert.Equal(t, recordedTxs, txs)
+  assert.Equal(t, recordedTx, tx)
+  assert.Equal(t, bitwire.StatusCanceled, tx.Status)
+
+  _, err = client.Transfers.Cancel(tx.Id)
+  assert.True(t, errors.Is(err, ErrNotRecorded))
+}
@@ -0,0 +1,313 @@
+package bitwiretest
+
+import (
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire"
+  "net/http"
+  "net/http/httptest"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Credentials accepted by the fake server unless changed
+const (
+  ClientId     = "test_client"
+  ClientSecret = "test_secret"
+  Username     = "test@example.com"
+  Password     = "password"
+)
+
+// Fake Bitwire API server serving the data of a Fake over HTTP, including the OAuth token flows.
+// Point a client at it with APIClient, or set a client's BaseURL to APIURL.
+type Server struct {
+  *httptest.Server
+  Fake *Fake
+
+  // Accepted by direct authentication and token refreshes
+  ClientId     string
+  ClientSecret string
+  Username     string
+  Password     string
+}
+
+// Starts a server with the fixtures loaded. Close it when done.
+func NewServer() *Server {
+  s := &Server{Fake: New(), ClientId: ClientId, ClientSecret: ClientSecret, Username: Username, Password: Password}
+  s.Fake.LoadFixtures()
+  s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+  return s
+}
+
+// Base URL of the API, for bitwire.Client's BaseURL
+func (s *Server) APIURL() string {
+  return s.URL + "/api/v1/"
+}
+
+// Returns a sandbox client of the server without a token
+func (s *Server) APIClient() *bitwire.Client {
+  client, _ := bitwire.New(bitwire.SANDBOX)
+  client.BaseURL = s.APIURL()
+  return client
+}
+
+// Returns a sandbox client of the server authenticated with the server's credentials
+func (s *Server) AuthenticatedClient() (*bitwire.Client, error) {
+  client := s.APIClient()
+  _, err := client.Authenticate(s.LoginCredentials())
+  return client, err
+}
+
+// Credentials accepted by the server's direct authentication
+func (s *Server) LoginCredentials() bitwire.LoginCredentials {
+  credentials := bitwire.Credentials{ClientId: s.ClientId, ClientSecret: s.ClientSecret, GrantType: "password"}
+  return bitwire.LoginCredentials{Credentials: credentials, Username: s.Username, Password: s.Password}
+}
+
+// Fills the fake with a few rates, banks, recipients, transfers, limits, fees, the user profile and verification status
+func (f *Fake) LoadFixtures() {
+  f.Rates.Data = bitwire.AllRates{
+    BTC: bitwire.Rates{"BTCKRW": bitwire.MustParseDecimal("1000000"), "BTCUSD": bitwire.MustParseDecimal("900")},
+    FX:  bitwire.Rates{"USDKRW": bitwire.MustParseDecimal("1175.50")},
+  }
+  f.Banks.Data = []bitwire.Bank{
+    {Id: 1, Number: "004", DisplayName: "KB Kookmin Bank", Name: "Kookmin Bank", NameKo: "국민은행"},
+    {Id: 2, Number: "088", DisplayName: "Shinhan Bank", Name: "Shinhan Bank", NameKo: "신한은행"},
+  }
+  kim := bitwire.Recipient{Id: 42, Name: "Kim Minjun", NameKo: "김민준", Email: "minjun@example.com"}
+  kim.Bank = bitwire.RecipientBank{Bank: f.Banks.Data[0], AccountNumber: "123-456-789", AccountName: "Kim Minjun"}
+  lee := bitwire.Recipient{Id: 43, Name: "Lee Seoyeon", NameKo: "이서연", Email: "seoyeon@example.com"}
+  lee.Bank = bitwire.RecipientBank{Bank: f.Banks.Data[1], AccountNumber: "987-654-321", AccountName: "Lee Seoyeon"}
+  f.Recipients.Data = []bitwire.Recipient{kim, lee}
+
+  created := time.Date(2017, 1, 18, 10, 0, 0, 0, time.UTC)
+  completed := bitwire.Transfer{Id: "tx_completed", Type: "btc_to_bank", Amount: bitwire.MustParseDecimal("0.10000000"),
+    Currency: "BTC", Status: bitwire.StatusCompleted, Date: created.Format(time.RFC3339), CreatedAt: created}
+  completed.Recipient = bitwire.TransferRecipient{Recipient: kim, Currency: "KRW", Amount: bitwire.MustParseDecimal("100000")}
+  completed.BTC.Address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+  completed.BTC.Received = completed.Amount
+  completed.Payout = bitwire.Payout{Reference: "KB20170118-0001", Date: created.Add(time.Hour).Format(time.RFC3339),
+    PayerName: "BITWIRE", PaidAt: created.Add(time.Hour)}
+  completed.Fees = bitwire.TransferFees{Network: bitwire.MustParseDecimal("0.0001"), Service: bitwire.MustParseDecimal("1000"),
+    FXSpread: bitwire.MustParseDecimal("300"), Currency: "KRW"}
+  pending := bitwire.Transfer{Id: "tx_pending", Type: "btc_to_bank", Amount: bitwire.MustParseDecimal("0.05000000"),
+    Currency: "BTC", Status: bitwire.StatusPending, Date: created.AddDate(0, 0, 1).Format(time.RFC3339), CreatedAt: created.AddDate(0, 0, 1)}
+  pending.Recipient = bitwire.TransferRecipient{Recipient: lee, Currency: "KRW", Amount: bitwire.MustParseDecimal("50000")}
+  pending.BTC.Address = "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"
+  pending.BTC.Link = "bitcoin:" + pending.BTC.Address + "?amount=0.05"
+  f.Transfers.Data = []bitwire.Transfer{completed, pending}
+
+  limits := bitwire.Limits{}
+  limits.KRW.Min = bitwire.MustParseDecimal("10000")
+  limits.KRW.Daily = bitwire.KrwLimits{Used: bitwire.MustParseDecimal("150000"), Left: bitwire.MustParseDecimal("850000"), Limit: bitwire.MustParseDecimal("1000000")}
+  limits.KRW.Weekly = bitwire.KrwLimits{Used: bitwire.MustParseDecimal("150000"), Left: bitwire.MustParseDecimal("4850000"), Limit: bitwire.MustParseDecimal("5000000")}
+  limits.BTC.Min = bitwire.MustParseDecimal("0.001")
+  limits.Transfers.Pending.Total.Used, limits.Transfers.Pending.Total.Limit = 1, 3
+  limits.Transfers.Completed.Daily.Used, limits.Transfers.Completed.Daily.Limit = 1, 10
+  f.Limits.Data = limits
+  f.Fees.Data = bitwire.Fees{Network: bitwire.MustParseDecimal("0.0001"), ServicePercent: bitwire.MustParseDecimal("0.5"),
+    ServiceMin: bitwire.MustParseDecimal("1000"), FXSpreadPercent: bitwire.MustParseDecimal("0.3"), Currency: "KRW"}
+  f.Users.Data = bitwire.User{Id: 91, Email: Username, Name: "Test User", Level: 1, KYCStatus: "verified"}
+  f.Users.VerificationStatus = bitwire.Verification{Level: 1, KYCStatus: "verified", NextLevel: 2, Requirements: []bitwire.Requirement{
+    {Type: "id_document", Description: "Photo of an ID card or passport", Status: bitwire.RequirementApproved, Level: 1},
+    {Type: "proof_of_address", Description: "Utility bill or bank statement from the last 3 months", Status: bitwire.RequirementRequired, Level: 2},
+    {Type: "source_of_funds", Description: "Statement of the source of the transferred funds", Status: bitwire.RequirementPending, Level: 2},
+  }}
+}
+
+// Writes the body of a successful response
+func writeJSON(w http.ResponseWriter, body map[string]interface{}) {
+  body["code"] = http.StatusOK
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(body)
+}
+
+// Writes an error response the way the API does
+func writeError(w http.ResponseWriter, err error) {
+  apiErr := &bitwire.APIError{}
+  if !errors.As(err, &apiErr) {
+    apiErr = &bitwire.APIError{ErrorType: "server_error", Message: err.Error(), HTTPStatus: http.StatusInternalServerError}
+  }
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(apiErr.HTTPStatus)
+  json.NewEncoder(w).Encode(map[string]interface{}{"code": apiErr.HTTPStatus, "errorType": apiErr.ErrorType, "message": apiErr.Message})
+}
+
+var (
+  errInvalidToken = &bitwire.APIError{Code: http.StatusUnauthorized, ErrorType: "Unauthorized", Message: "Invalid token.", HTTPStatus: http.StatusUnauthorized}
+  errExpiredToken = &bitwire.APIError{Code: http.StatusUnauthorized, ErrorType: "Unauthorized", Message: "Token expired.", HTTPStatus: http.StatusUnauthorized}
+  errInvalidGrant = &bitwire.APIError{Code: http.StatusBadRequest, ErrorType: "invalid_grant", Message: "Invalid credentials.", HTTPStatus: http.StatusBadRequest}
+  errNoEndpoint   = &bitwire.APIError{Code: http.StatusNotFound, ErrorType: "not_found", Message: "Endpoint not found.", HTTPStatus: http.StatusNotFound}
+)
+
+// Checks the bearer token against the last token issued
+func (s *Server) authorize(r *http.Request) error {
+  token := s.Fake.Token()
+  if token.AccessToken == "" || r.Header.Get("Authorization") != "Bearer "+token.AccessToken {
+    return errInvalidToken
+  }
+  if time.Now().Unix() >= token.ValidUntil {
+    return errExpiredToken
+  }
+  return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+  path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+  switch {
+  case path == "oauth/tokens" && r.Method == http.MethodPost:
+    s.serveToken(w, r)
+    return
+  case path == "oauth/revoke" && r.Method == http.MethodPost:
+    s.serveRevoke(w, r)
+    return
+  case path == "rates" && r.Method == http.MethodGet:
+    rates, err := s.Fake.Rates.All()
+    respond(w, err, map[string]interface{}{"rates": rates})
+    return
+  case path == "rates/fx" && r.Method == http.MethodGet:
+    rates, err := s.Fake.Rates.Fx()
+    respond(w, err, map[string]interface{}{"rates": rates})
+    return
+  case path == "rates/btc" && r.Method == http.MethodGet:
+    rates, err := s.Fake.Rates.Btc()
+    respond(w, err, map[string]interface{}{"rates": rates})
+    return
+  case path == "banks" && r.Method == http.MethodGet:
+    banks, err := s.Fake.Banks.List()
+    respond(w, err, map[string]interface{}{"banks": banks})
+    return
+  case path == "fees" && r.Method == http.MethodGet:
+    fees, err := s.Fake.Fees.Get()
+    respond(w, err, map[string]interface{}{"fees": fees})
+    return
+  }
+
+  if err := s.authorize(r); err != nil {
+    writeError(w, err)
+    return
+  }
+  id := strings.TrimPrefix(path, "transfers/")
+  switch {
+  case path == "users/me" && r.Method == http.MethodGet:
+    user, err := s.Fake.Users.Me()
+    respond(w, err, map[string]interface{}{"user": user})
+  case path == "users/verification" && r.Method == http.MethodGet:
+    verification, err := s.Fake.Users.Verification()
+    respond(w, err, map[string]interface{}{"verification": verification})
+  case path == "users/limits" && r.Method == http.MethodGet:
+    limits, err := s.Fake.Limits.Get()
+    respond(w, err, map[string]interface{}{"limits": limits})
+  case path == "recipients" && r.Method == http.MethodGet:
+    recipients, err := s.Fake.Recipients.List()
+    respond(w, err, map[string]interface{}{"recipients": recipients})
+  case path == "transfers" && r.Method == http.MethodGet:
+    opts, err := listOptions(r)
+    if err != nil {
+      writeError(w, err)
+      return
+    }
+    transfers, err := s.Fake.Transfers.List(&opts)
+    if transfers == nil {
+      transfers = []bitwire.Transfer{}
+    }
+    respond(w, err, map[string]interface{}{"transfers": transfers})
+  case path == "transfers" && r.Method == http.MethodPost:
+    create := bitwire.CreateTransfer{}
+    if err := json.NewDecoder(r.Body).Decode(&create); err != nil {
+      writeError(w, &bitwire.APIError{Code: http.StatusBadRequest, ErrorType: "validation_error", Message: "Invalid request body.", HTTPStatus: http.StatusBadRequest})
+      return
+    }
+    transfer, err := s.Fake.Transfers.Create(create)
+    respond(w, err, map[string]interface{}{"transfer": transfer})
+  case id != path && r.Method == http.MethodGet:
+    transfer, err := s.Fake.Transfers.Get(id)
+    respond(w, err, map[string]interface{}{"transfer": transfer})
+  case id != path && r.Method == http.MethodDelete:
+    transfer, err := s.Fake.Transfers.Cancel(id)
+    respond(w, err, map[string]interface{}{"transfer": transfer})
+  default:
+    writeError(w, errNoEndpoint)
+  }
+}
+
+func respond(w http.ResponseWriter, err error, body map[string]interface{}) {
+  if err != nil {
+    writeError(w, err)
+  } else {
+    writeJSON(w, body)
+  }
+}
+
+// Issues tokens for the password and refresh_token grants
+func (s *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+  if err := r.ParseForm(); err != nil {
+    writeError(w, errInvalidGrant)
+    return
+  }
+  if r.PostForm.Get("client_id") != s.ClientId || r.PostForm.Get("client_secret") != s.ClientSecret {
+    writeError(w, &bitwire.APIError{Code: http.StatusUnauthorized, ErrorType: "invalid_client", Message: "Invalid client.", HTTPStatus: http.StatusUnauthorized})
+    return
+  }
+  switch r.PostForm.Get("grant_type") {
+  case "password":
+    if r.PostForm.Get("username") != s.Username || r.PostForm.Get("password") != s.Password {
+      writeError(w, errInvalidGrant)
+      return
+    }
+  case "refresh_token":
+    if refresh := s.Fake.Token().RefreshToken; refresh == "" || r.PostForm.Get("refresh_token") != refresh {
+      writeError(w, errInvalidGrant)
+      return
+    }
+  default:
+    writeError(w, errInvalidGrant)
+    return
+  }
+  token, err := s.Fake.RefreshToken()
+  respond(w, err, map[string]interface{}{"token_type": token.TokenType, "access_token": token.AccessToken,
+    "refresh_token": token.RefreshToken, "expires_in": token.ExpiresIn})
+}
+
+// Revokes the last token issued when it is the one sent. Like RFC 7009 requires, unknown tokens are not an error.
+func (s *Server) serveRevoke(w http.ResponseWriter, r *http.Request) {
+  if err := r.ParseForm(); err != nil {
+    writeError(w, errInvalidGrant)
+    return
+  }
+  if r.PostForm.Get("client_id") != s.ClientId || r.PostForm.Get("client_secret") != s.ClientSecret {
+    writeError(w, &bitwire.APIError{Code: http.StatusUnauthorized, ErrorType: "invalid_client", Message: "Invalid client.", HTTPStatus: http.StatusUnauthorized})
+    return
+  }
+  var err error
+  if token := s.Fake.Token(); r.PostForm.Get("token") != "" &&
+    (r.PostForm.Get("token") == token.AccessToken || r.PostForm.Get("token") == token.RefreshToken) {
+    _, err = s.Fake.RevokeToken()
+  }
+  respond(w, err, map[string]interface{}{})
+}
+
+// Reads the transfer list filters from the query
+func listOptions(r *http.Request) (bitwire.TransferListOptions, error) {
+  query := r.URL.Query()
+  opts := bitwire.TransferListOptions{Status: query.Get("status"), Type: query.Get("type")}
+  invalid := &bitwire.APIError{Code: http.StatusBadRequest, ErrorType: "validation_error", Message: "Invalid filter.", HTTPStatus: http.StatusBadRequest}
+  var err error
+  if v := query.Get("recipient_id"); v != "" {
+    if opts.RecipientId, err = strconv.Atoi(v); err != nil {
+      return opts, invalid
+    }
+  }
+  if v := query.Get("since"); v != "" {
+    if opts.Since, err = time.Parse(time.RFC3339, v); err != nil {
+      return opts, invalid
+    }
+  }
+  if v := query.Get("until"); v != "" {
+    if opts.Until, err = time.Parse(time.RFC3339, v); err != nil {
+      return opts, invalid
+    }
+  }
+  return opts, nil
+}
@@ -1,13 +1,44 @@
 package bitwire
 
 import (
+  "context"
   "errors"
   "github.com/dghubble/sling"
+  "go.opentelemetry.io/otel/trace"
+  "log/slog"
+  "net/http"
+  "strconv"
+  "strings"
+  "sync"
   "time"
 )
 
-const baseURL = "https://www.bitwire.co/api/v1/"
-const sandboxBaseURL = "https://sandbox.bitwire.co/api/v1/"
+// Hosts of the API in each mode
+const (
+  ProductionURL = "https://www.bitwire.co"
+  SandboxURL    = "https://sandbox.bitwire.co"
+)
+
+// API version used unless the client's APIVersion is set
+const DefaultAPIVersion = "v1"
+
+// Returns the base URL of the API version in the mode, e.g. https://sandbox.bitwire.co/api/v1/
+func APIBaseURL(mode Mode, version string) string {
+  host := ProductionURL
+  if mode == SANDBOX {
+    host = SandboxURL
+  }
+  if version == "" {
+    version = DefaultAPIVersion
+  }
+  return host + "/api/" + version + "/"
+}
+
+// Default upper bound for honoring a Retry-After header
+const DefaultMaxRetryWait = 30 * time.Second
+
+// How many times a rate limited call is retried
+const maxRetries = 3
 
 type Res struct {
   Code int `json:"code"`
@@ -23,145 +54,6 @@ type Error struct {
   ErrorType string `json:"errorType"`
 }
 
-type AllRatesRes struct {
-  Res
-  Rates AllRates `json:"rates"`
-}
-
-type Rates map[string]string
-
-type BtcRatesRes struct {
-  Res
-  Rates Rates `json:"rates"`
-}
-
-type FxRatesRes struct {
-  Res
-  Rates Rates `json:"rates"`
-}
-
-type AllRates struct {
-  BTC Rates `json:"btc"`
-  FX  Rates `json:"fx"`
-}
-
-type BanksRes struct {
-  Res
-  Banks []Bank `json:"banks"`
-}
-
-type Bank struct {
-  Id          int    `json:"id"`
-  Number      string `json:"number"`
-  DisplayName string `json:"display_name"`
-  Name        string `json:"name"`
-  NameKo      string `json:"name_ko"`
-}
-
-type RecipientsRes struct {
-  Res
-  Recipients []Recipient `json:"recipients"`
-}
-
-type TransferRes struct {
-  Res
-  Transfer Transfer
-}
-
-type TransfersRes struct {
-  Res
-  Transfers []Transfer
-}
-
-type Transfer struct {
-  Id        string            `json:"id"`
-  Sender    Sender            `json:"sender"`
-  Type      string            `json:"type"`
-  Memo      string            `json:"memo"`
-  Amount    string            `json:"amount"`
-  Currency  string            `json:"currency"`
-  Status    string            `json:"status"`
-  Date      string            `json:"date"`
-  BTC       BTC               `json:"btc"`
-  Recipient TransferRecipient `json:"recipient"`
-}
-
-type CreateTransfer struct {
-  Amount      string `json:"amount"`
-  Currency    string `json:"currency"`
-  RecipientId int    `json:"recipient_id"`
-  Memo        string `json:"memo"`
-  Type        string `json:"type"`
-}
-
-type Sender struct {
-  Amount   string `json:"amount"`
-  Currency string `json:"currency"`
-}
-
-type Recipient struct {
-  Id    int           `json:"id"`
-  Name  string        `json:"name"`
-  Email string        `json:"email"`
-  Bank  RecipientBank `json:"bank"`
-}
-
-type TransferRecipient struct {
-  Recipient
-  Currency string `json:"currency"`
-  Amount   string `json:"amount"`
-}
-
-type BTC struct {
-  Address    string `json:"address"`
-  Link       string `json:"link"`
-  Expiration int    `json:"expiration"`
-}
-
-type RecipientBank struct {
-  Bank
-  AccountNumber string `json:"account_number"`
-  AccountName   string `json:"account_name"`
-}
-
-type LimitsRes struct {
-  Res
-  Limits Limits `json:"limits"`
-}
-
-type Limits struct {
-  Transfers TransferLimits `json:"transfers"`
-  KRW       struct {
-    Min    string    `json:"min"`
-    Daily  KrwLimits `json:"daily"`
-    Weekly KrwLimits `json:"weekly"`
-  } `json:"krw"`
-  BTC struct {
-    Min string `json:"min"`
-  }
-}
-
-type KrwLimits struct {
-  Used  string `json:"used"`
-  Left  string `json:"left"`
-  Limit string `json"limit"`
-}
-
-type TransferLimits struct {
-  Pending struct {
-    Total struct {
-      Used  int `json:"used"`
-      Limit int `json:"limit"`
-    } `json:"total"`
-  } `json:"pending"`
-  Completed struct {
-    Daily struct {
-      Used  int `json:"used"`
-      Limit int `json:"limit"`
-    } `json:"daily"`
-  } `json:"completed"`
-}
-
 type TokenRes struct {
   Res
   Token
@@ -205,9 +97,79 @@ type Config struct {
 }
 
 type Client struct {
-  Mode        Mode
-  token       Token
-  credentials Credentials
+  Mode    Mode
+  BaseURL string // Overrides the base URL of the mode, e.g. to call a fake server, a mock or a proxy
+  // Version in the path of the mode's base URL, DefaultAPIVersion if empty. Not used when BaseURL is set.
+  APIVersion string
+  // Sends the HTTP requests when set, e.g. to record or replay them in tests.
+  // http.DefaultClient otherwise, or a client with a FetchTransport in js/wasm.
+  HTTPClient *http.Client
+
+  mu           sync.Mutex // Guards the fields below
+  token        Token
+  credentials  Credentials
+  lastResponse Response
+  refreshing   *refreshCall    // Token refresh in progress, if any
+  deprecations map[string]bool // Deprecation warnings already passed to OnWarning
+
+  // Longest Retry-After wait honored when the API responds with 429.
+  // Rate limited calls fail right away if the wait is longer. Zero disables retries.
+  MaxRetryWait time.Duration
+  // Called before sleeping to retry a rate limited call
+  OnRetry func(wait time.Duration, attempt int)
+  // Called with non-fatal conditions detected while serving a call
+  OnWarning func(Warning)
+  // Run in order before every request is sent, including retries and token refreshes
+  BeforeRequest []RequestHook
+  // Run in order after every response is received, or sending the request failed
+  AfterResponse []ResponseHook
+  // Receives a debug record with the method, URL, status and latency of every HTTP call when set
+  Logger *slog.Logger
+  // Adds the request and response bodies, with credentials and tokens redacted, to the debug records
+  LogBodies bool
+  // Starts a span for every API call when set
+  TracerProvider trace.TracerProvider
+  // Records request counts, errors, latencies and token refreshes when set
+  Metrics *Metrics
+  // Called with the endpoint, status and duration of every finished API call when set
+  OnCall func(CallTiming)
+  // Calls taking longer are passed to OnWarning as a SlowCallWarning. Zero disables the warning.
+  SlowCallThreshold time.Duration
+  // Receives the token after each authentication and refresh when set
+  TokenStore TokenStore
+  // Called when the refresh token is rejected with invalid_grant, e.g. once it expired, to get the login to
+  // authenticate with again, e.g. by prompting for the password. The refresh fails as before when it returns an error.
+  ReauthFunc func(err error) (LoginCredentials, error)
+
+  ratesCache *ratesCache // Set by WithRatesCache
+
+  // API areas, each backed by the same client
+  Rates      *RatesService
+  Banks      *BanksService
+  Recipients *RecipientsService
+  Transfers  *TransfersService
+  Limits     *LimitsService
+  Users      *UsersService
+  Fees       *FeesService
+}
+
+// Hook run before a request is sent, e.g. to add headers or log it. An error cancels the call.
+type RequestHook func(req *http.Request) error
+
+// Hook run after a request, e.g. to log or measure it. resp is nil if err is set.
+// The response body has been read and closed already.
+type ResponseHook func(req *http.Request, resp *http.Response, err error)
+
+// Token refresh shared by all the goroutines waiting for it
+type refreshCall struct {
+  done  chan struct{}
+  token Token
+  err   error
+}
+
+// Shared by all the API services
+type service struct {
+  client *Client
 }
 
 type Method string
@@ -219,13 +181,26 @@ const (
   DELETE    Method = "DELETE"
 )
 
+// Creates a client and wires up its services
+func newClient(mode Mode, token Token, credentials Credentials) *Client {
+  c := &Client{Mode: mode, token: token, credentials: credentials, MaxRetryWait: DefaultMaxRetryWait}
+  c.Rates = &RatesService{c}
+  c.Banks = &BanksService{c}
+  c.Recipients = &RecipientsService{c}
+  c.Transfers = &TransfersService{c}
+  c.Limits = &LimitsService{c}
+  c.Users = &UsersService{c}
+  c.Fees = &FeesService{c}
+  return c
+}
+
 func New(mode Mode) (*Client, error) {
   return NewWithToken(mode, Token{})
 }
 
 func NewWithToken(mode Mode, token Token) (*Client, error) {
   if mode == SANDBOX || mode == PRODUCTION {
-    return &Client{mode, token, Credentials{}}, nil
+    return newClient(mode, token, Credentials{}), nil
   } else {
     return nil, errors.New("Invalid mode")
   }
@@ -238,7 +213,7 @@ func NewWithToken(mode Mode, token Token) (*Client, error) {
 //  https://developers.bitwire.co/api/v1/#refresh-token
 func NewFromConfig(mode Mode, config Config) (*Client, error) {
   if mode == SANDBOX || mode == PRODUCTION {
-    return &Client{mode, config.Token, config.Credentials}, nil
+    return newClient(mode, config.Token, config.Credentials), nil
   } else {
     return nil, errors.New("Invalid mode")
   }
@@ -246,27 +221,43 @@ func NewFromConfig(mode Mode, config Config) (*Client, error) {
 
 // Returns the token
 func (c *Client) Token() Token {
+  c.mu.Lock()
+  defer c.mu.Unlock()
   return c.token
 }
 
+// Returns the base URL the client calls, ending with a slash so that the API paths are resolved under it
+func (c *Client) URL() string {
+  if c.BaseURL != "" {
+    if !strings.HasSuffix(c.BaseURL, "/") {
+      return c.BaseURL + "/"
+    }
+    return c.BaseURL
+  }
+  return APIBaseURL(c.Mode, c.APIVersion)
+}
+
 // Returns a Sling http clients configured with the base URL path
 func (c *Client) http() *sling.Sling {
-  switch c.Mode {
-  case SANDBOX:
-    return sling.New().Base(sandboxBaseURL)
-  default:
-    return sling.New().Base(baseURL)
+  return sling.New().Base(c.URL())
+}
+
+// Returns the HTTP client sending the requests
+func (c *Client) httpClient() *http.Client {
+  if c.HTTPClient != nil {
+    return c.HTTPClient
   }
+  return defaultHTTPClient
 }
 
 // Refreshes the token if it expires
 func checkToken(c *Client) error {
-  if c.token == (Token{}) {
-    return errors.New("Missing auth token")
+  token := c.Token()
+  if token == (Token{}) {
+    return ErrMissingToken
   }
-  now := time.Now().Unix()
-  if now >= c.token.ValidUntil-30 {
-    _, err := c.RefreshToken()
+  if tokenExpiring(token) {
+    _, err := refreshStale(c, token)
     if err != nil {
       return err
     }
@@ -274,10 +265,46 @@ func checkToken(c *Client) error {
   return nil
 }
 
+// Tells if the token expires within 30 seconds
+func tokenExpiring(token Token) bool {
+  return time.Now().Unix() >= token.ValidUntil-30
+}
+
 // General function for calling API method
 // - sets auth headers
 // - refreshes the token if necessary and parses error responses
 func callApi(method Method, path string, params interface{}, c *Client, auth bool, res interface{}) error {
+  return callApiContext(context.Background(), method, path, params, c, auth, res)
+}
+
+// Like callApi, but gives up sending, or waiting to retry, the request when ctx is done
+func callApiContext(ctx context.Context, method Method, path string, params interface{}, c *Client, auth bool, res interface{}) error {
+  var span trace.Span
+  if c.TracerProvider != nil {
+    ctx, span = c.startSpan(ctx, method, path)
+  }
+  stats := new(callStats)
+  start := time.Now()
+  err := sendApi(ctx, method, path, params, c, auth, res, stats)
+  if span != nil {
+    endSpan(span, stats, err)
+  }
+  duration := time.Since(start)
+  if c.Metrics != nil {
+    c.Metrics.observeCall(method, path, stats, duration, err)
+  }
+  c.finishCall(method, path, stats, duration, err)
+  return err
+}
+
+// Outcome of an API call beyond its error
+type callStats struct {
+  Status  int // HTTP status of the last response, zero if none was received
+  Retries int // Times the call was retried after being rate limited
+}
+
+// Sends the request of an API call, retrying it while rate limited, and records the outcome in stats
+func sendApi(ctx context.Context, method Method, path string, params interface{}, c *Client, auth bool, res interface{}, stats *callStats) error {
   var req *sling.Sling
   errorRes := new(ErrorRes)
   switch method {
@@ -295,7 +322,7 @@ func callApi(method Method, path string, params interface{}, c *Client, auth boo
     if err != nil {
       return err
     }
-    req.Set("Authorization", "Bearer "+c.token.AccessToken)
+    req.Set("Authorization", "Bearer "+c.Token().AccessToken)
   }
   if params != nil {
     switch method {
@@ -309,114 +336,170 @@ func callApi(method Method, path string, params interface{}, c *Client, auth boo
 
   }
 
-  _, httpErr := req.Receive(res, errorRes)
+  if c.Logger != nil {
+    req = req.Doer(loggingDoer{c})
+  } else {
+    req = req.Doer(c.httpClient())
+  }
+
+  var resp *http.Response
+  var httpErr error
+  for attempt := 1; ; attempt++ {
+    httpReq, err := req.Request()
+    if err != nil {
+      return err
+    }
+    httpReq = httpReq.WithContext(ctx)
+    for _, hook := range c.BeforeRequest {
+      if err := hook(httpReq); err != nil {
+        return err
+      }
+    }
+    resp, httpErr = req.Do(httpReq, res, errorRes)
+    for _, hook := range c.AfterResponse {
+      hook(httpReq, resp, httpErr)
+    }
+    stats.Retries = attempt - 1
+    var lastResponse Response
+    if resp != nil {
+      stats.Status = resp.StatusCode
+      lastResponse = newResponse(resp)
+      c.mu.Lock()
+      c.lastResponse = lastResponse
+      c.mu.Unlock()
+      if !lastResponse.Deprecation.IsZero() {
+        c.warnDeprecation(method, path, lastResponse.Deprecation)
+      }
+    }
+    if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+      break
+    }
+    now := time.Now()
+    wait, ok := retryAfter(resp.Header.Get("Retry-After"), now)
+    if !ok || wait > c.MaxRetryWait || attempt > maxRetries {
+      rateErr := &RateLimitError{APIError{errorRes.Code, "Rate limited", "Too many requests.", resp.StatusCode}, wait, lastResponse.RateLimit.Reset}
+      if ok && rateErr.Reset.IsZero() {
+        rateErr.Reset = now.Add(wait)
+      }
+      return rateErr
+    }
+    if c.OnRetry != nil {
+      c.OnRetry(wait, attempt)
+    }
+    select {
+    case <-ctx.Done():
+      return ctx.Err()
+    case <-time.After(wait):
+    }
+    *errorRes = ErrorRes{}
+  }
   if httpErr != nil {
     return httpErr
   } else if *errorRes != (ErrorRes{}) {
-    return errors.New(errorRes.ErrorType + ": " + errorRes.Message)
+    return &APIError{Code: errorRes.Code, ErrorType: errorRes.ErrorType, Message: errorRes.Message, HTTPStatus: resp.StatusCode}
   } else {
     return nil
   }
 }
 
-func (c *Client) GetAllRates() (AllRates, error) {
-  ratesRes := new(AllRatesRes)
-  err := callApi(GET, "rates", nil, c, false, ratesRes)
-  if err != nil {
-    return AllRates{}, err
-  } else {
-    return ratesRes.Rates, nil
+// Calls an API endpoint the client does not wrap yet and decodes the response into result, if not nil.
+// The path is relative to the API base URL, e.g. "users/limits". params are sent as the query string
+// for GET and DELETE, as a form for POST and as a JSON body for JSON_POST.
+// The call is authenticated, refreshing the token when needed, if the client has a token.
+// Error responses are returned as *APIError, like for the other methods.
+func (c *Client) Do(ctx context.Context, method Method, path string, params interface{}, result interface{}) error {
+  if ctx == nil {
+    ctx = context.Background()
   }
+  auth := c.Token() != (Token{})
+  return callApiContext(ctx, method, strings.TrimPrefix(path, "/"), params, c, auth, result)
 }
 
-func (c *Client) GetFxRates() (Rates, error) {
-  ratesRes := new(FxRatesRes)
-  err := callApi(GET, "rates/fx", nil, c, false, ratesRes)
-  if err != nil {
-    return nil, err
+// Parses a Retry-After header given either in seconds or as an HTTP date
+func retryAfter(header string, now time.Time) (time.Duration, bool) {
+  if header == "" {
+    return 0, false
+  }
+  if secs, err := strconv.Atoi(header); err == nil {
+    if secs < 0 {
+      return 0, false
+    }
+    return time.Duration(secs) * time.Second, true
+  }
+  if date, err := http.ParseTime(header); err == nil {
+    wait := date.Sub(now)
+    if wait < 0 {
+      wait = 0
+    }
+    return wait, true
   } else {
-    return ratesRes.Rates, nil
+    return 0, false
   }
 }
 
+// Returns the profile of the user the token belongs to, like c.Users.Me
+func (c *Client) GetMe() (User, error) {
+  return c.Users.Me()
+}
+
+// Returns the verification level and outstanding requirements, like c.Users.Verification
+func (c *Client) GetVerificationStatus() (Verification, error) {
+  return c.Users.Verification()
+}
+
+// Returns the fee schedule of transfers, like c.Fees.Get
+func (c *Client) GetFees() (Fees, error) {
+  return c.Fees.Get()
+}
+
+// Deprecated: use c.Rates.All
+func (c *Client) GetAllRates() (AllRates, error) {
+  return c.Rates.All()
+}
+
+// Deprecated: use c.Rates.Fx
+func (c *Client) GetFxRates() (Rates, error) {
+  return c.Rates.Fx()
+}
+
+// Deprecated: use c.Rates.Btc
 func (c *Client) GetBtcRates() (Rates, error) {
-  ratesRes := new(BtcRatesRes)
-  err := callApi(GET, "rates/btc", nil, c, false, ratesRes)
-  if err != nil {
-    return nil, err
-  } else {
-    return ratesRes.Rates, nil
-  }
+  return c.Rates.Btc()
 }
 
+// Deprecated: use c.Banks.List
 func (c *Client) GetBanks() ([]Bank, error) {
-  banksRes := new(BanksRes)
-  err := callApi(GET, "banks", nil, c, false, banksRes)
-  if err != nil {
-    return nil, err
-  } else {
-    return banksRes.Banks, nil
-  }
+  return c.Banks.List()
 }
 
+// Deprecated: use c.Recipients.List
 func (c *Client) GetRecipients() ([]Recipient, error) {
-  recipientsRes := new(RecipientsRes)
-  err := callApi(GET, "recipients", nil, c, true, recipientsRes)
-  if err != nil {
-    return nil, err
-  } else {
-    return recipientsRes.Recipients, nil
-  }
+  return c.Recipients.List()
 }
 
+// Deprecated: use c.Transfers.List
 func (c *Client) GetTransfers() ([]Transfer, error) {
-  transfersRes := new(TransfersRes)
-  err := callApi(GET, "transfers", nil, c, true, transfersRes)
-  if err != nil {
-    return nil, err
-  } else {
-    return transfersRes.Transfers, nil
-  }
+  return c.Transfers.List(nil)
 }
 
+// Deprecated: use c.Transfers.Get
 func (c *Client) GetTransfer(id string) (Transfer, error) {
-  transferRes := new(TransferRes)
-  err := callApi(GET, "transfers/"+id, nil, c, true, transferRes)
-  if err != nil {
-    return Transfer{}, err
-  } else {
-    return transferRes.Transfer, nil
-  }
+  return c.Transfers.Get(id)
 }
 
+// Deprecated: use c.Transfers.Create
 func (c *Client) CreateTransfer(transfer CreateTransfer) (Transfer, error) {
-  transferRes := new(TransferRes)
-  err := callApi(JSON_POST, "transfers", transfer, c, true, transferRes)
-  if err != nil {
-    return Transfer{}, err
-  } else {
-    return transferRes.Transfer, nil
-  }
+  return c.Transfers.Create(transfer)
 }
 
+// Deprecated: use c.Transfers.Cancel
 func (c *Client) CancelTransfer(id string) (Transfer, error) {
-  transferRes := new(TransferRes)
-  err := callApi(DELETE, "transfers/"+id, nil, c, true, transferRes)
-  if err != nil {
-    return Transfer{}, err
-  } else {
-    return transferRes.Transfer, nil
-  }
+  return c.Transfers.Cancel(id)
 }
 
+// Deprecated: use c.Limits.Get
 func (c *Client) GetLimits() (Limits, error) {
-  limitsRes := new(LimitsRes)
-  err := callApi(GET, "users/limits", nil, c, true, limitsRes)
-  if err != nil {
-    return Limits{}, err
-  } else {
-    return limitsRes.Limits, nil
-  }
+  return c.Limits.Get()
 }
 
 // Calls direct auth method with username and password
@@ -450,13 +533,123 @@ func refreshToken(c *Client, credentials TokenCredentials) (Token, error) {
   }
 }
 
+// Refreshes the token. Concurrent calls share a single refresh request.
 func (c *Client) RefreshToken() (Token, error) {
+  return refreshStale(c, Token{})
+}
+
+// Checks, before a long run such as a batch of payouts, that the token lasts for the estimated duration of the run,
+// so that it does not fail halfway. A token expiring sooner is refreshed, which also shows that it can be refreshed
+// again mid-run, as the calls do when it expires. Returns ErrTokenTooShort when it cannot be refreshed.
+func (c *Client) PreflightToken(run time.Duration) (Token, error) {
+  c.mu.Lock()
+  token, refreshable := c.token, c.token.RefreshToken != "" && c.credentials.ClientSecret != ""
+  c.mu.Unlock()
+  if token == (Token{}) {
+    return Token{}, ErrMissingToken
+  }
+  if time.Now().Add(run).Unix() < token.ValidUntil-30 {
+    return token, nil
+  }
+  if !refreshable {
+    return token, ErrTokenTooShort
+  }
+  return refreshStale(c, token)
+}
+
+// Refreshes the token unless another goroutine has already replaced the stale one.
+// Callers arriving while a refresh is in progress wait for its result instead of sending another one.
+func refreshStale(c *Client, stale Token) (Token, error) {
+  c.mu.Lock()
+  if stale != (Token{}) && c.token.AccessToken != stale.AccessToken && !tokenExpiring(c.token) {
+    token := c.token
+    c.mu.Unlock()
+    return token, nil
+  }
+  if call := c.refreshing; call != nil {
+    c.mu.Unlock()
+    <-call.done
+    return call.token, call.err
+  }
+  call := &refreshCall{done: make(chan struct{})}
+  c.refreshing = call
   creds := TokenCredentials{c.credentials, c.token.RefreshToken}
-  token, err := refreshToken(c, creds)
-  if err == nil {
-    c.token = token
+  c.mu.Unlock()
+
+  call.token, call.err = refreshToken(c, creds)
+  if c.Metrics != nil {
+    c.Metrics.observeRefresh(call.err)
+  }
+  var apiErr *APIError
+  if c.ReauthFunc != nil && errors.As(call.err, &apiErr) && apiErr.ErrorType == "invalid_grant" {
+    call.token, call.err = reauthenticate(c, call.err)
+  }
+
+  c.mu.Lock()
+  if call.err == nil {
+    c.token = call.token
+  }
+  c.refreshing = nil
+  c.mu.Unlock()
+  if call.err == nil {
+    c.saveToken(call.token)
+  }
+  close(call.done)
+  return call.token, call.err
+}
+
+// Token revocation request, https://www.rfc-editor.org/rfc/rfc7009
+type revokeParams struct {
+  ClientId      string `url:"client_id"`
+  ClientSecret  string `url:"client_secret"`
+  Token         string `url:"token"`
+  TokenTypeHint string `url:"token_type_hint"`
+}
+
+// Revokes the token at the OAuth revocation endpoint, e.g. on logout, and discards it, also from the token store.
+// Revoking the refresh token revokes the access tokens issued with it. Where the API has no revocation endpoint,
+// the token is only discarded and stays valid until it expires, and revoked is false. The token is discarded
+// even if the revocation fails.
+func (c *Client) RevokeToken() (revoked bool, err error) {
+  c.mu.Lock()
+  token, creds := c.token, c.credentials
+  c.mu.Unlock()
+  if token == (Token{}) {
+    return false, ErrMissingToken
+  }
+  params := revokeParams{creds.ClientId, creds.ClientSecret, token.RefreshToken, "refresh_token"}
+  if token.RefreshToken == "" {
+    params.Token, params.TokenTypeHint = token.AccessToken, "access_token"
+  }
+  err = callApi(POST, "oauth/revoke", params, c, false, nil)
+  var apiErr *APIError
+  switch {
+  case errors.As(err, &apiErr) && apiErr.HTTPStatus == http.StatusNotFound:
+    err = nil
+  case err == nil:
+    revoked = true
+  }
+  c.mu.Lock()
+  c.token = Token{}
+  c.mu.Unlock()
+  c.saveToken(Token{})
+  return revoked, err
+}
+
+// Authenticates with the login of ReauthFunc after the refresh token was rejected with refreshErr
+func reauthenticate(c *Client, refreshErr error) (Token, error) {
+  login, err := c.ReauthFunc(refreshErr)
+  if err != nil {
+    return Token{}, refreshErr
+  }
+  token, err := getToken(c, login)
+  if err != nil {
+    return Token{}, err
   }
-  return token, err
+  c.mu.Lock()
+  c.credentials = Credentials{login.ClientId, login.ClientSecret, "refresh_token"}
+  c.mu.Unlock()
+  return token, nil
 }
 
 func (c *Client) Authenticate(credentials LoginCredentials) (Token, error) {
@@ -464,8 +657,11 @@ func (c *Client) Authenticate(credentials LoginCredentials) (Token, error) {
   if err != nil {
     return Token{}, err
   } else {
+    c.mu.Lock()
     c.credentials = Credentials{credentials.ClientId, credentials.ClientSecret, "refresh_token"}
     c.token = token
+    c.mu.Unlock()
+    c.saveToken(token)
     return token, nil
   }
 }
@@ -0,0 +1,1122 @@
+// Package cmd implements the bitwire command line interface.
+// Other programs can embed the commands with NewApp, injecting their own I/O and API client.
+package cmd
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/dworznik/bitwire/explorer"
+  "github.com/dworznik/cli"
+  "io"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "strings"
+  "text/template"
+  "time"
+)
+
+const Version = "0.0.3"
+
+// External dependencies of the commands. Zero fields fall back to the process defaults.
+type Deps struct {
+  Stdin  io.Reader
+  Stdout io.Writer
+  Stderr io.Writer
+  Home   string // Directory containing the .bitwire config directory, used when ConfigDir is not set
+  // Directory of the config files and local data. Home/.bitwire if Home is set, and otherwise bitwire in the user
+  // config directory, see defaultConfigDir.
+  ConfigDir string
+  // Creates the API client. conf is empty for commands that need no authentication.
+  NewClient func(mode bitwire.Mode, conf bitwire.Config) (*bitwire.Client, error)
+  // Creates the block explorer client used to follow transfer payments
+  NewExplorer func(mode bitwire.Mode) *explorer.Client
+  // Terminal features of Stdout and Stderr, NoTerminal on platforms without them
+  Terminal Terminal
+  // Keeps the client secrets and tokens of the config files, NoKeyring on platforms without one
+  Keyring Keyring
+  // Directory where the project file is looked up, the working directory if empty
+  WorkDir string
+
+  legacyConfigDir string // ~/.bitwire to move to the default ConfigDir
+}
+
+func newDefaultClient(mode bitwire.Mode, conf bitwire.Config) (*bitwire.Client, error) {
+  if conf == (bitwire.Config{}) {
+    return bitwire.New(mode)
+  } else {
+    return bitwire.NewFromConfig(mode, conf)
+  }
+}
+
+func (d Deps) withDefaults() Deps {
+  if d.Stdin == nil {
+    d.Stdin = os.Stdin
+  }
+  if d.Stdout == nil {
+    d.Stdout = os.Stdout
+  }
+  if d.Stderr == nil {
+    d.Stderr = os.Stderr
+  }
+  if d.ConfigDir == "" && d.Home != "" {
+    d.ConfigDir = filepath.Join(d.Home, ConfDir)
+  } else if d.ConfigDir == "" {
+    d.ConfigDir, d.legacyConfigDir = defaultConfigDir()
+  }
+  if d.NewClient == nil {
+    d.NewClient = newDefaultClient
+  }
+  if d.NewExplorer == nil {
+    d.NewExplorer = explorer.New
+  }
+  if d.Terminal == nil {
+    d.Terminal = defaultTerminal()
+  }
+  if d.Keyring == nil {
+    d.Keyring = defaultKeyring()
+  }
+  if d.WorkDir == "" {
+    d.WorkDir, _ = os.Getwd()
+  }
+  return d
+}
+
+// State of a single CLI run
+type runner struct {
+  Deps
+  mode        bitwire.Mode
+  sandbox     bool
+  json        bool
+  noBanner    bool
+  quiet       bool   // Set when informational messages should not be printed
+  debug       bool   // Log API calls to stderr
+  debugBodies bool   // Log API request and response bodies too
+  offline     bool   // Read transfers and recipients from the synced store instead of the API
+  apiURL      string // Overrides the API base URL of the mode
+  credsFrom   string // Reference of the secret with the credentials, used instead of the config file
+  insecure    bool   // Keep the client secret and tokens in the config file instead of the OS keyring
+  journaling  bool   // Record the API calls of the run
+  lang        string // Language of names in tables, "ko" or "en"
+  plain       bool   // Print label: value lines without tables, colors or QR codes
+  noColor     bool   // Print no ANSI colors, set by --no-color or NO_COLOR
+  output      string // table, json, yaml, ndjson or csv
+  csv         bool   // Print CSV instead of tables
+  format      string // Go template applied to each result item
+  template    *template.Template
+  refRates    bitwire.ReferenceRateProvider // Set by transfer list --reference-rates
+  slowCall    time.Duration                 // API calls taking longer are warned about
+  runTime     time.Duration                 // Estimated duration of the run, the token must last or be refreshable
+  envFlag     string                        // Environment selected by --env
+  env         string                        // Name of a defined environment, empty for production and sandbox
+  profile     string                        // Credential profile selected by --profile, empty for the default one
+
+  progressJSON bool   // Write progress events of batch and watch commands to stderr
+  progressCmd  string // Command reporting progress, set by progressBegin
+
+  prefs    preferences    // Set in app.Before()
+  conf     bitwire.Config // Set in app.Before()
+  confErr  error
+  confFile bool                  // Set when the config file has the client secret and tokens, not the OS keyring
+  envAuth  bool                  // Set when the token is from BITWIRE_ACCESS_TOKEN or BITWIRE_REFRESH_TOKEN, never saved
+  client   *bitwire.Client       // Set in newClient()
+  journal  *bitwiretest.Recorder // Set in setupClient() when journaling
+  stdin    *bufio.Reader         // Set by stdinReader()
+
+  project     projectConfig // Set in app.Before() from the project file, if any
+  projectPath string
+
+  limitsRecorded bool // Set once this run stored the limits
+  ratesRecorded  bool // Set once this run stored the rates
+}
+
+// Commands that need the credentials from the config file
+var authCommands = map[string]bool{"transfers": true, "transfer": true,
+  "limits": true, "recipients": true, "tr": true, "create": true,
+  "cancel": true, "list": true, "show": true, "watch": true, "quote": true, "validate": true, "rpc": true, "sync": true, "whoami": true,
+  "verification": true, "dashboard": true, "cancel-stale": true}
+
+func (r *runner) printfErr(format string, v ...interface{}) (int, error) {
+  if !r.colored(r.Stderr) {
+    return fmt.Fprint(r.Stderr, stripANSI(fmt.Sprintf(format, v...)))
+  }
+  return fmt.Fprintf(r.Stderr, format, v...)
+}
+
+// Tells if output to w can have ANSI colors: w is a terminal and neither --plain, --no-color, NO_COLOR nor CSV output is set
+func (r *runner) colored(w io.Writer) bool {
+  return !r.plain && !r.noColor && !r.csv && r.Terminal.IsTerminal(w)
+}
+
+// Wraps s in the ANSI color code if output to w is colored
+func (r *runner) colorize(w io.Writer, code, s string) string {
+  if s == "" || !r.colored(w) {
+    return s
+  }
+  return code + s + RESET
+}
+
+// Prints an informational message to stderr unless running quietly
+func (r *runner) printfInfo(format string, v ...interface{}) {
+  if !r.quiet {
+    r.printfErr(format, v...)
+  }
+}
+
+// Returns "ko" if the locale environment selects Korean, and "en" otherwise
+func localeLang() string {
+  for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+    if value := os.Getenv(name); value != "" {
+      if strings.HasPrefix(value, "ko") {
+        return "ko"
+      }
+      return "en"
+    }
+  }
+  return "en"
+}
+
+// Builds the help description listing errors a command commonly fails with
+func commonErrors(errorTypes ...string) string {
+  lines := []string{"Common errors:"}
+  for _, t := range errorTypes {
+    if hint, ok := bitwire.HintFor(t); ok {
+      lines = append(lines, fmt.Sprintf("     %s - %s", hint.ErrorType, hint.Summary))
+      lines = append(lines, fmt.Sprintf("       %s", hint.Action))
+    }
+  }
+  return strings.Join(lines, "\n")
+}
+
+// Wires the client's callbacks to CLI feedback
+func (r *runner) setupClient(c *bitwire.Client) *bitwire.Client {
+  c.OnRetry = func(wait time.Duration, attempt int) {
+    r.printfErr("rate limited, retrying in %s…\n", wait)
+  }
+  c.OnWarning = func(w bitwire.Warning) {
+    if w.Kind == bitwire.DeprecatedWarning && !r.deprecationDue(w.Message, time.Now()) {
+      return
+    }
+    r.printfErr("%sNote: %s%s\n", YELLOW, w, RESET)
+  }
+  c.SlowCallThreshold = r.slowCall
+  c.OnCall = r.recordCall
+  if r.credsFrom == "" && !r.envAuth && r.Terminal.IsTerminal(r.Stderr) { // Nobody to answer the prompts in scripts
+    c.ReauthFunc = r.reauthenticate
+  }
+  if r.apiURL != "" {
+    c.BaseURL = r.apiURL
+  }
+  if r.journaling {
+    r.journal = r.startJournal()
+    c.HTTPClient = r.journal.Client()
+  }
+  if r.debug || r.debugBodies {
+    c.Logger = slog.New(slog.NewTextHandler(r.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+    c.LogBodies = r.debugBodies
+  }
+  return c
+}
+
+// newClient creates a new bitwire client for running a command
+// Returns an error if the command requires authentication and it cannot read credentials from the config file
+func (r *runner) newClient(cmd string) (*bitwire.Client, error) {
+  conf := bitwire.Config{}
+  if authCommands[cmd] && r.credsFrom != "" {
+    c, err := r.newProvidedClient()
+    if err != nil {
+      return nil, err
+    }
+    return c, r.preflightToken(c, r.runTime)
+  }
+  if authCommands[cmd] {
+    if r.conf == (bitwire.Config{}) {
+      return nil, configError(r.confErr)
+    }
+    conf = r.conf
+  }
+  c, err := r.NewClient(r.mode, conf)
+  if err != nil {
+    return nil, err
+  }
+  r.client = r.setupClient(c)
+  if authCommands[cmd] {
+    if err := r.preflightToken(r.client, r.runTime); err != nil {
+      return nil, err
+    }
+  }
+  return r.client, nil
+}
+
+// Checks before a long run, e.g. sync or a batch of cancels, that the token lasts for it or can be refreshed mid-run,
+// refreshing it now if it expires sooner, so that the run does not fail halfway
+func (r *runner) preflightToken(client *bitwire.Client, run time.Duration) error {
+  if run <= 0 {
+    return nil
+  }
+  token, err := client.PreflightToken(run)
+  if err == bitwire.ErrTokenTooShort {
+    return fmt.Errorf("The token expires at %s, before the run of %s ends, and cannot be refreshed\n"+
+      "Set BITWIRE_REFRESH_TOKEN and the client credentials, or use a token valid for longer",
+      time.Unix(token.ValidUntil, 0).Format(time.RFC3339), run)
+  }
+  return err
+}
+
+// Prints the error, with a hint for known API errors
+func (r *runner) reportError(err error) {
+  if hint, ok := bitwire.HintForError(err); ok {
+    r.printfErr("Error: %s\n%s\n", hint.Summary, hint.Action)
+    r.printfErr("API response: %s\n", err)
+  } else {
+    r.printfErr("%s\n", err)
+  }
+}
+
+// Read config from the file before running a command
+func (r *runner) before(c *cli.Context) error {
+  moved := r.migrateConfigDir()
+  var err error
+  r.prefs, err = r.readPreferences()
+  if err != nil {
+    r.printfErr("Could not read preferences: %s\n", err)
+  }
+  if r.project, r.projectPath, err = r.readProject(); err != nil {
+    return err
+  }
+  r.applyProject(c)
+  r.quiet = r.noBanner || r.prefs.QuietBanner || r.project.QuietBanner || r.progressJSON || !r.Terminal.IsTerminal(r.Stderr)
+  if moved != "" {
+    r.printfInfo("Moved the config directory %s to %s\n", moved, r.ConfigDir)
+  }
+  r.plain = r.plain || r.prefs.Plain
+  r.noColor = r.noColor || os.Getenv("NO_COLOR") != "" // https://no-color.org
+  if err := r.applyOutput(); err != nil {
+    return err
+  }
+  r.lang = localeLang()
+  if err := r.selectEnv(); err != nil {
+    return err
+  }
+  if err := r.checkProjectGuards(); err != nil {
+    return err
+  }
+  if err := r.selectProfile(); err != nil {
+    return err
+  }
+  if r.projectPath != "" {
+    r.printfInfo("Using the project settings of %s\n", r.projectPath)
+  }
+  if r.apiURL != "" {
+    r.printfInfo("Calling the API at %s\n", r.apiURL)
+  }
+  r.conf, r.confFile, r.confErr = r.readConfig(r.envName())
+  if token := bitwire.TokenFromEnv(); token != (bitwire.Token{}) && r.credsFrom == "" {
+    // For CI jobs and serverless functions without a config file
+    creds := bitwire.Credentials{os.Getenv(bitwire.EnvClientId), os.Getenv(bitwire.EnvClientSecret), "refresh_token"}
+    r.conf, r.confErr, r.envAuth = bitwire.Config{creds, token}, nil, true
+  }
+  return nil
+}
+
+// Sets up the output of --output and --format, or -o of the get commands
+func (r *runner) applyOutput() error {
+  var err error
+  switch r.output {
+  case "", outputFormatTable:
+  case outputFormatJSON, outputFormatYAML, outputFormatNDJSON:
+    r.json = true
+  case outputFormatCSV:
+    if r.json {
+      return errors.New("Use either --json or --output csv")
+    }
+    r.csv = true
+  default:
+    return fmt.Errorf("Invalid output format: %s, expected table, json, yaml, ndjson or csv", r.output)
+  }
+  if r.format != "" {
+    if r.csv {
+      return errors.New("Use either --format or --output csv")
+    }
+    if r.template, err = newFormatTemplate(r.format); err != nil {
+      return err
+    }
+    r.json = true
+  }
+  return nil
+}
+
+// Update token in the config file after running a command
+func (r *runner) after(c *cli.Context) error {
+  r.snapshotLimits()
+  r.snapshotRates()
+  r.saveJournal()
+  r.applyRetention()
+  if r.client != nil && r.credsFrom == "" && !r.envAuth {
+    token := r.client.Token()
+    if token.AccessToken != "" && r.conf.Token.AccessToken != token.AccessToken {
+      r.conf = bitwire.Config{bitwire.Credentials{r.conf.ClientId, r.conf.ClientSecret, r.conf.GrantType}, token}
+      return r.writeConfig(r.conf, r.envName())
+    }
+  }
+  return nil
+}
+
+func (r *runner) app() *cli.App {
+  app := cli.NewApp()
+  app.Name = "bitwire"
+  app.Version = Version
+  app.Usage = "Bitwire command line interface"
+  app.Writer = r.Stdout
+  app.ErrWriter = r.Stderr
+  app.Flags = []cli.Flag{
+    cli.BoolFlag{
+      Name:        "sandbox, s",
+      Usage:       "run in sandbox mode",
+      Destination: &r.sandbox,
+    },
+    cli.StringFlag{
+      Name:        "env",
+      Usage:       "run in an environment defined in ~/.bitwire/preferences.json, e.g. staging, or production or sandbox",
+      EnvVar:      "BITWIRE_ENV,BITWIRE_MODE",
+      Destination: &r.envFlag,
+    },
+    cli.StringFlag{
+      Name:        "profile",
+      Usage:       "use the credentials and local data of a profile in ~/.bitwire/profiles, e.g. business, for another account",
+      EnvVar:      "BITWIRE_PROFILE",
+      Destination: &r.profile,
+    },
+    cli.BoolFlag{
+      Name:        "json, j",
+      Usage:       "print out JSON",
+      Destination: &r.json,
+    },
+    cli.StringFlag{
+      Name:        "output",
+      Value:       outputFormatTable,
+      Usage:       "output format: table, json, yaml, ndjson (one JSON object per line) or csv",
+      EnvVar:      "BITWIRE_OUTPUT",
+      Destination: &r.output,
+    },
+    cli.StringFlag{
+      Name:        "format",
+      Usage:       "print each result with a Go template, e.g. '{{.Id}} {{.Status}}'",
+      EnvVar:      "BITWIRE_FORMAT",
+      Destination: &r.format,
+    },
+    cli.BoolFlag{
+      Name:        "no-banner, quiet-banner",
+      Usage:       "do not print informational messages to stderr (default when stderr is not a terminal)",
+      EnvVar:      "BITWIRE_NO_BANNER",
+      Destination: &r.noBanner,
+    },
+    cli.BoolFlag{
+      Name:        "plain",
+      Usage:       "print label: value lines for screen readers, without tables, colors or QR codes",
+      EnvVar:      "BITWIRE_PLAIN",
+      Destination: &r.plain,
+    },
+    cli.BoolFlag{
+      Name:        "no-color",
+      Usage:       "print no colors (default when NO_COLOR is set or output is not a terminal)",
+      Destination: &r.noColor,
+    },
+    cli.BoolFlag{
+      Name:        "progress-json",
+      Usage:       "write progress events of sync, watch, --follow and db purge to stderr as JSON lines",
+      EnvVar:      "BITWIRE_PROGRESS_JSON",
+      Destination: &r.progressJSON,
+    },
+    cli.BoolFlag{
+      Name:        "offline",
+      Usage:       "list and show transfers and recipients stored by `bitwire sync`, without calling the API",
+      EnvVar:      "BITWIRE_OFFLINE",
+      Destination: &r.offline,
+    },
+    cli.StringFlag{
+      Name:        "api-url",
+      Usage:       "call the API at the base URL, e.g. a mock server or a proxy, instead of the mode's one",
+      EnvVar:      "BITWIRE_API_URL",
+      Destination: &r.apiURL,
+    },
+    cli.StringFlag{
+      Name:        "credentials-from",
+      Usage:       "authenticate with the credentials in a secret manager instead of the config file, e.g. vault:secret/data/bitwire",
+      EnvVar:      "BITWIRE_CREDENTIALS_FROM",
+      Destination: &r.credsFrom,
+    },
+    cli.BoolFlag{
+      Name:        "insecure-file",
+      Usage:       "keep the client secret and tokens in the config file, readable only by you, instead of the OS keyring",
+      EnvVar:      "BITWIRE_INSECURE_FILE",
+      Destination: &r.insecure,
+    },
+    cli.BoolFlag{
+      Name:        "journal",
+      Usage:       "record the API calls, credentials redacted, for `bitwire journal bundle`",
+      EnvVar:      "BITWIRE_JOURNAL",
+      Destination: &r.journaling,
+    },
+    cli.DurationFlag{
+      Name:        "run-time",
+      Usage:       "estimated duration of the run, e.g. 3h for a long sync, checking before it starts that the token lasts or can be refreshed",
+      EnvVar:      "BITWIRE_RUN_TIME",
+      Destination: &r.runTime,
+    },
+    cli.DurationFlag{
+      Name:        "slow-call",
+      Value:       3 * time.Second,
+      Usage:       "warn about API calls taking longer, 0 to disable",
+      EnvVar:      "BITWIRE_SLOW_CALL",
+      Destination: &r.slowCall,
+    },
+    cli.BoolFlag{
+      Name:        "debug",
+      Usage:       "log API calls to stderr",
+      EnvVar:      "BITWIRE_DEBUG",
+      Destination: &r.debug,
+    },
+    cli.BoolFlag{
+      Name:        "debug-bodies",
+      Usage:       "log API calls to stderr with request and response bodies, credentials redacted",
+      Destination: &r.debugBodies,
+    },
+  }
+  app.Before = r.before
+  app.After = r.after
+
+  app.OnUsageError = func(context *cli.Context, err error, isSubcommand bool) error {
+    return nil
+  }
+
+  app.Action = func(c *cli.Context) error {
+    if c.Args().Present() {
+      app.CommandNotFound(c, c.Args().First())
+      return nil
+    }
+    cli.ShowAppHelp(c)
+    return nil
+  }
+
+  app.CommandNotFound = func(c *cli.Context, cmd string) {
+    r.printfErr("Unrecognized command: %s\n", cmd)
+    c.App.Writer = r.Stderr // The help is a diagnostic here, not the output
+    cli.ShowAppHelp(c)
+  }
+
+  app.Commands = r.commands()
+  return app
+}
+
+// Returns the bitwire CLI app. Errors of its commands are returned by app.Run.
+func NewApp(deps Deps) *cli.App {
+  r := &runner{Deps: deps.withDefaults(), mode: bitwire.PRODUCTION}
+  return r.app()
+}
+
+// Runs the CLI with the arguments, including the program name, and returns the exit code
+func Run(deps Deps, args []string) int {
+  r := &runner{Deps: deps.withDefaults(), mode: bitwire.PRODUCTION}
+  if err := r.app().Run(args); err != nil {
+    var exit *exitError
+    if errors.As(err, &exit) {
+      if exit.err != nil {
+        r.reportError(exit.err)
+        r.logError(exit.err)
+      }
+      return exit.code
+    }
+    r.reportError(err)
+    r.logError(err)
+    return 1
+  }
+  return 0
+}
+
+func (r *runner) commands() []cli.Command {
+  return []cli.Command{
+    {
+      Name:   "commands",
+      Usage:  "list all commands and flags",
+      Action: r.commandsAction,
+      Flags: []cli.Flag{
+        cli.BoolFlag{
+          Name:  "json",
+          Usage: "print the command tree as JSON",
+        },
+      },
+    },
+    {
+      Name:  "config",
+      Usage: "configure Bitwire API access",
+      Description: "Values not given by flags or environment variables are prompted for. BITWIRE_PASSWORD sets the password.\n\n   " +
+        commonErrors("invalid_grant", "invalid_client"),
+      Action: r.configAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:   "username",
+          Usage:  "username of the Bitwire account",
+          EnvVar: "BITWIRE_USERNAME",
+        },
+        cli.StringFlag{
+          Name:   "client-id",
+          Usage:  "API client ID",
+          EnvVar: "BITWIRE_CLIENT_ID",
+        },
+        cli.StringFlag{
+          Name:   "client-secret",
+          Usage:  "API client secret",
+          EnvVar: "BITWIRE_CLIENT_SECRET",
+        },
+        cli.BoolFlag{
+          Name:  "password-stdin",
+          Usage: "read the password from stdin, without prompts, e.g. in containers and CI",
+        },
+      },
+    },
+    {
+      Name:  "logout",
+      Usage: "revoke the token and delete the stored credentials of the environment",
+      Description: "Deletes the client secret and the token from the OS keyring and overwrites the config file\n" +
+        "   before deleting it. The token is revoked first where the API supports it.",
+      Action: r.logoutAction,
+      Flags: []cli.Flag{
+        cli.BoolFlag{
+          Name:  "all-profiles",
+          Usage: "log out of every environment of every profile",
+        },
+      },
+    },
+    {
+      Name:      "rpc",
+      Usage:     "serve JSON-RPC 2.0 requests over stdin and stdout",
+      UsageText: "bitwire rpc < requests.jsonl",
+      Description: "Reads one request per line, e.g.\n" +
+        `     {"jsonrpc": "2.0", "id": 1, "method": "transfers.get", "params": {"id": "..."}}` + "\n" +
+        "   Methods: rates.all, rates.fx, rates.btc, banks.list, recipients.list, transfers.list,\n" +
+        "   transfers.get, transfers.create, transfers.cancel, limits.get",
+      Action: r.rpcAction,
+    },
+    {
+      Name:        "docs",
+      Usage:       "show the schema of the API responses",
+      ArgsUsage:   "[type]",
+      Description: "Prints the fields, JSON names and types of every response type, or of the given one.\n   With --json, prints the schema as JSON.",
+      Action:      r.docsAction,
+    },
+    {
+      Name:   "listen",
+      Usage:  "receive webhooks and print their events as JSON lines, and serve metrics and the rate stream",
+      Action: r.listenAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "addr",
+          Value: "localhost:4242",
+          Usage: "address to listen on",
+        },
+        cli.StringFlag{
+          Name:  "path",
+          Value: "/",
+          Usage: "path receiving the webhooks",
+        },
+        cli.StringFlag{
+          Name:   "secret",
+          Usage:  "webhook signing secret",
+          EnvVar: "BITWIRE_WEBHOOK_SECRET",
+        },
+        cli.StringFlag{
+          Name:  "forward",
+          Usage: "relay verified events to this URL, e.g. http://localhost:3000/webhooks",
+        },
+        cli.BoolFlag{
+          Name:  "metrics",
+          Usage: "serve Prometheus metrics of the API calls and the money in flight at /metrics (needs the credentials)",
+        },
+        cli.DurationFlag{
+          Name:  "metrics-interval",
+          Value: time.Minute,
+          Usage: "how often to update the money in flight metrics",
+        },
+        cli.BoolFlag{
+          Name:  "rates-stream",
+          Usage: "stream rate updates at /rates/stream as server-sent events, e.g. to dashboards and overlays; needs no --secret",
+        },
+        cli.DurationFlag{
+          Name:  "rates-interval",
+          Value: 5 * time.Second,
+          Usage: "how often to poll the rates for the stream, at least 1s",
+        },
+      },
+    },
+    {
+      Name:        "dashboard",
+      Usage:       "show the money in flight: transfers not completed yet, their KRW value and the BTC awaiting confirmation",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.dashboardAction,
+    },
+    {
+      Name:        "sync",
+      Usage:       "store the transfers created or changed since the last sync, and the recipients, for --offline use",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.syncAction,
+    },
+    {
+      Name:  "journal",
+      Usage: "API call journals recorded with --journal",
+      Subcommands: []cli.Command{
+        {
+          Name:        "bundle",
+          Usage:       "zip the journals and diagnostics for a support ticket",
+          Description: "The journals have credentials and tokens redacted, but contain transfer and recipient details.",
+          Action:      r.journalBundleAction,
+          Flags: []cli.Flag{
+            cli.StringFlag{
+              Name:  "out, o",
+              Usage: "path of the zip file, bitwire-support-<time>.zip by default",
+            },
+          },
+        },
+      },
+    },
+    {
+      Name:        "stats",
+      Usage:       "show the number of API calls and their latency percentiles by endpoint",
+      Description: "Every API call is recorded in the local store of the mode, removed by `bitwire db purge` like the other records.",
+      Action:      r.statsAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "since",
+          Value: "7d",
+          Usage: "age of the oldest calls, e.g. 1d, 4w or 6m",
+        },
+      },
+    },
+    {
+      Name:        "support-bundle",
+      Usage:       "write a gzipped bundle of diagnostics, local checks, recent errors and journals for a support request",
+      Description: "The contents are shown for review before writing, unless --yes is set.",
+      Action:      r.supportBundleAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "out, o",
+          Usage: "path of the bundle, bitwire-support-<time>.tar.gz by default",
+        },
+        cli.IntFlag{
+          Name:  "journals",
+          Value: 5,
+          Usage: "number of the latest journals recorded with --journal to include",
+        },
+        cli.BoolFlag{
+          Name:  "yes, y",
+          Usage: "write the bundle without review",
+        },
+      },
+    },
+    {
+      Name:  "db",
+      Usage: "local store operations",
+      Subcommands: []cli.Command{
+        {
+          Name:        "purge",
+          Usage:       "remove the stored transfers, recipients, syncs and limits older than the age, in both modes",
+          Description: "Transfers are aged by their creation date. Set \"retention\" in ~/.bitwire/preferences.json, e.g. to \"2y\",\n   to purge after every command.",
+          Action:      r.dbPurgeAction,
+          Flags: []cli.Flag{
+            cli.StringFlag{
+              Name:  "older-than",
+              Usage: "age of the records to remove, e.g. 90d, 12w, 6m or 2y",
+            },
+          },
+        },
+      },
+    },
+    {
+      Name:   "rates",
+      Usage:  "list current rates",
+      Action: r.ratesAction,
+      Subcommands: []cli.Command{
+        {
+          Name:   "history",
+          Usage:  "show the daily low, high and closing rate of a pair recorded by previous runs",
+          Action: r.ratesHistoryAction,
+          Flags: []cli.Flag{
+            cli.StringFlag{
+              Name:  "pair",
+              Value: "BTCKRW",
+              Usage: "rate pair, e.g. BTCKRW or USDKRW",
+            },
+            cli.IntFlag{
+              Name:  "days",
+              Value: 30,
+              Usage: "number of days to show",
+            },
+            cli.BoolFlag{
+              Name:  "sparkline",
+              Usage: "print the closing rates as a one-line chart instead of a table",
+            },
+          },
+        },
+        {
+          Name:  "watch",
+          Usage: "poll a rate and alert when it crosses a threshold",
+          Description: "Prints a line, and beeps on a terminal, when the rate goes above --above or below --below.\n" +
+            "   The alert fires again once the rate is back within the thresholds and crosses one again.\n" +
+            "   --exec runs the command with BITWIRE_PAIR, BITWIRE_RATE, BITWIRE_THRESHOLD and\n" +
+            "   BITWIRE_CROSSED (above or below) set in its environment.",
+          Action: r.ratesWatchAction,
+          Flags: []cli.Flag{
+            cli.StringFlag{
+              Name:  "pair",
+              Value: "BTCKRW",
+              Usage: "rate pair, e.g. BTCKRW or USDKRW",
+            },
+            cli.StringFlag{
+              Name:  "above",
+              Usage: "alert when the rate goes above the value",
+            },
+            cli.StringFlag{
+              Name:  "below",
+              Usage: "alert when the rate goes below the value",
+            },
+            cli.DurationFlag{
+              Name:  "interval",
+              Value: time.Minute,
+              Usage: "how often to poll the rates",
+            },
+            cli.StringFlag{
+              Name:  "exec",
+              Usage: "shell command to run on each alert",
+            },
+            cli.BoolFlag{
+              Name:  "once",
+              Usage: "exit after the first alert",
+            },
+          },
+        },
+      },
+    },
+    {
+      Name:   "banks",
+      Usage:  "list banks",
+      Action: r.banksAction,
+    },
+    {
+      Name:  "apply",
+      Usage: "set the local labels, groups, payout templates and budgets declared in a YAML or JSON file",
+      Description: "The file replaces what was applied before in the environment, unless --merge is set.\n" +
+        "   Applying the same file again changes nothing, so it can be kept in version control and shared.",
+      Action: r.applyAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "f",
+          Usage: "path of the file, - for stdin",
+        },
+        cli.BoolFlag{
+          Name:  "merge",
+          Usage: "add and update the entries of the file, keeping the others",
+        },
+        cli.BoolFlag{
+          Name:  "dry-run",
+          Usage: "list the changes without applying them",
+        },
+      },
+    },
+    {
+      Name:  "exposure",
+      Usage: "show the BTC needed for the scheduled payout templates of the next days, at the current rates and moved ones",
+      Description: "Counts the payouts of the daily, weekly and monthly templates set by apply within --days,\n" +
+        "   a started week or month as a whole, and prices them at the current BTC rates, and at the rates\n" +
+        "   --band percent lower and higher, to pre-fund the wallet for the worst case.",
+      Action: r.exposureAction,
+      Flags: []cli.Flag{
+        cli.IntFlag{
+          Name:  "days",
+          Value: 30,
+          Usage: "number of days ahead",
+        },
+        cli.IntFlag{
+          Name:  "band",
+          Value: 5,
+          Usage: "rate move in percent, down and up",
+        },
+      },
+    },
+    {
+      Name:        "get",
+      Usage:       "list or show transfers, recipients, banks, rates or limits, e.g. get transfers -o json",
+      Subcommands: r.getCommands(),
+    },
+    {
+      Name:        "recipient",
+      Usage:       "recipient operations",
+      Subcommands: r.recipientCommands(),
+    },
+    {
+      Name:        "transfer",
+      Usage:       "transfer operations",
+      Subcommands: r.transferCommands(),
+    },
+    {
+      Name:        "whoami",
+      Usage:       "show the account the saved token belongs to",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.whoamiAction,
+    },
+    {
+      Name:        "verification",
+      Usage:       "show the verification level and the steps required to raise the limits",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.verificationAction,
+    },
+    {
+      Name:        "limits",
+      Usage:       "list limits",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.limitsAction,
+      Subcommands: []cli.Command{
+        {
+          Name:   "history",
+          Usage:  "show the utilization of the KRW limits recorded by previous runs",
+          Action: r.limitsHistoryAction,
+          Flags: []cli.Flag{
+            cli.BoolFlag{
+              Name:  "weekly",
+              Usage: "show the weekly limit per week instead of the daily limit per day",
+            },
+            cli.IntFlag{
+              Name:  "days",
+              Value: 30,
+              Usage: "how many days back to show",
+            },
+          },
+        },
+      },
+    },
+  }
+}
+
+func (r *runner) commandsAction(c *cli.Context) error {
+  tree := describeApp(c.App)
+  if r.json || c.Bool("json") {
+    return r.printOut(tree, true)
+  } else {
+    printCommandTree(r.Stdout, tree.Commands, "")
+    return nil
+  }
+}
+
+func (r *runner) configAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  conf, login, err := r.config(r.mode, configValues{c.String("username"), os.Getenv("BITWIRE_PASSWORD"),
+    c.String("client-id"), c.String("client-secret"), c.Bool("password-stdin")})
+  if err != nil {
+    return err
+  }
+  token, err := client.Authenticate(login)
+  if err != nil {
+    return err
+  } else {
+    conf.Token = token
+    r.conf = conf // So that after() does not save the token again with the previous credentials
+    defer r.printfErr("Configuration saved\n")
+    return r.writeConfig(conf, r.envName())
+  }
+}
+
+func (r *runner) rpcAction(c *cli.Context) error {
+  name := c.Command.Name
+  if r.conf == (bitwire.Config{}) { // Not configured: serve the public methods only
+    name = ""
+  }
+  client, err := r.newClient(name)
+  if err != nil {
+    return err
+  }
+  return serveRpc(client, r.Stdin, r.Stdout)
+}
+
+func (r *runner) listenAction(c *cli.Context) error {
+  metricsInterval := time.Duration(0)
+  if c.Bool("metrics") {
+    metricsInterval = c.Duration("metrics-interval")
+  }
+  ratesInterval := time.Duration(0)
+  if c.Bool("rates-stream") {
+    ratesInterval = c.Duration("rates-interval")
+  }
+  return r.listen(c.String("addr"), c.String("path"), c.String("secret"), c.String("forward"), metricsInterval, ratesInterval)
+}
+
+func (r *runner) ratesAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  } else {
+    rates, err := client.Rates.All()
+    if err != nil {
+      return err
+    } else {
+      r.recordRates(rates)
+      return r.printOut(rates, r.json)
+    }
+  }
+}
+
+func (r *runner) banksAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  } else {
+    banks, err := client.Banks.List()
+    if err != nil {
+      return err
+    } else {
+      return r.printOut(banks, r.json)
+    }
+  }
+}
+
+func (r *runner) recipientCommands() []cli.Command {
+  return []cli.Command{
+    {
+      Name:        "list",
+      Usage:       "list recipients",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.recipientListAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "name",
+          Usage: "show recipients whose romanized or Korean name contains the text only",
+        },
+        cli.StringFlag{
+          Name:  "columns",
+          Usage: "comma-separated columns to show, in order: id, name, email, bank, account",
+        },
+        cli.StringFlag{
+          Name:  "sort",
+          Usage: "sort by a column, e.g. name or id:desc",
+        },
+      },
+    },
+    {
+      Name:        "show",
+      Usage:       "show a recipient",
+      ArgsUsage:   "[recipient_id or name]",
+      Description: "With --pick, choose the recipient from a list searched by name, email or bank.\n\n   " + commonErrors("Unauthorized"),
+      Action:      r.recipientShowAction,
+      Flags: []cli.Flag{
+        cli.BoolFlag{
+          Name:  "pick, p",
+          Usage: "pick the recipient interactively, starting with the matches of the argument, if any",
+        },
+      },
+    },
+  }
+}
+
+func (r *runner) recipientListAction(c *cli.Context) error {
+  columns := defaultRecipientColumns
+  var err error
+  if s := c.String("columns"); s != "" {
+    if columns, err = parseColumns(s, recipientHeaders); err != nil {
+      return err
+    }
+  }
+  var order sortOrder
+  if s := c.String("sort"); s != "" {
+    if order, err = parseSortOrder(s, recipientHeaders); err != nil {
+      return err
+    }
+  }
+  var recipients []bitwire.Recipient
+  if r.offline {
+    recipients, err = r.offlineRecipients(c.String("name"))
+  } else {
+    var client *bitwire.Client
+    if client, err = r.newClient(c.Command.Name); err == nil {
+      if name := c.String("name"); name != "" {
+        recipients, err = client.Recipients.Find(name)
+      } else {
+        recipients, err = client.Recipients.List()
+      }
+    }
+  }
+  if err != nil {
+    return err
+  }
+  if order.Column != "" {
+    sortRecipients(recipients, order, r.lang)
+  }
+  return r.printOutRecipients(recipients, columns, r.json)
+}
+
+func (r *runner) recipientShowAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  recipient, err := r.findRecipient(client, c.Args().Get(0), c.Bool("pick"))
+  if err != nil {
+    return err
+  }
+  return r.printOut(*recipient, r.json)
+}
+
+func (r *runner) whoamiAction(c *cli.Context) error {
+  client, err := r.newClient("whoami")
+  if err != nil {
+    return err
+  } else {
+    user, err := client.Users.Me()
+    if err != nil {
+      return err
+    } else {
+      return r.printOut(user, r.json)
+    }
+  }
+}
+
+func (r *runner) verificationAction(c *cli.Context) error {
+  client, err := r.newClient("verification")
+  if err != nil {
+    return err
+  }
+  verification, err := client.Users.Verification()
+  if err != nil {
+    return err
+  }
+  if err := r.printOut(verification, r.json); err != nil {
+    return err
+  }
+  if !r.json {
+    if steps := verification.Outstanding(); len(steps) > 0 {
+      fmt.Fprintf(r.Stdout, "Next steps to reach level %d:\n", verification.NextLevel)
+      for _, step := range steps {
+        fmt.Fprintf(r.Stdout, "  - %s (%s)\n", step.Description, step.Status)
+      }
+    }
+  }
+  return nil
+}
+
+func (r *runner) limitsAction(c *cli.Context) error {
+  client, err := r.newClient("limits")
+  if err != nil {
+    return err
+  } else {
+    limits, err := client.Limits.Get()
+    if err != nil {
+      return err
+    } else {
+      r.recordLimits(limits)
+      return r.printOut(limits, r.json)
+    }
+  }
+}
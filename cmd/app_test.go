@@ -0,0 +1,1903 @@
+package cmd
+
+import (
+  "archive/tar"
+  "archive/zip"
+  "bufio"
+  "bytes"
+  "compress/gzip"
+  "context"
+  "encoding/csv"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/dworznik/bitwire/explorer"
+  "github.com/dworznik/bitwire/secrets"
+  "github.com/stretchr/testify/assert"
+  "io"
+  "io/ioutil"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "runtime"
+  "strings"
+  "testing"
+  "time"
+)
+
+// Runs the CLI in a temporary home directory and returns exit code, stdout and stderr
+func run(t *testing.T, home string, args ...string) (int, string, string) {
+  stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+  deps := Deps{Stdin: new(bytes.Buffer), Stdout: stdout, Stderr: stderr, Home: home, Keyring: memKeyring{}}
+  code := Run(deps, append([]string{"bitwire"}, args...))
+  return code, stdout.String(), stderr.String()
+}
+
+// Returns the dependencies of a runner created without Run, with the config directory in home
+func homeDeps(home string) Deps {
+  return Deps{Home: home, ConfigDir: filepath.Join(home, ConfDir)}
+}
+
+func tempHome(t *testing.T) string {
+  home, err := ioutil.TempDir("", "bitwire")
+  if err != nil {
+    t.Fatal(err)
+  }
+  return home
+}
+
+// Keyring in memory, keyed by service and user
+type memKeyring map[string]string
+
+func (k memKeyring) Get(service, user string) (string, error) {
+  if secret, ok := k[service+"/"+user]; ok {
+    return secret, nil
+  }
+  return "", ErrKeyringNotFound
+}
+
+func (k memKeyring) Set(service, user, secret string) error {
+  k[service+"/"+user] = secret
+  return nil
+}
+
+func (k memKeyring) Delete(service, user string) error {
+  delete(k, service+"/"+user)
+  return nil
+}
+
+func writeTestConfig(t *testing.T, home string, mode bitwire.Mode) {
+  token := bitwire.Token{"Bearer", "access", "refresh", 3600, time.Now().Unix() + 3600}
+  conf := bitwire.Config{bitwire.Credentials{"id", "secret", "refresh_token"}, token}
+  data, _ := json.Marshal(conf)
+  os.MkdirAll(filepath.Join(home, ConfDir), 0700)
+  r := &runner{Deps: homeDeps(home)}
+  assert.Nil(t, ioutil.WriteFile(r.configPath(string(mode)), data, 0600))
+}
+
+func TestCommandsJSON(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, stdout, stderr := run(t, home, "commands", "--json")
+  assert.Equal(t, 0, code)
+  assert.Empty(t, stderr)
+  tree := commandInfo{}
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &tree))
+  assert.Equal(t, "bitwire", tree.Name)
+  assert.Contains(t, stdout, `"name": "transfer"`)
+}
+
+func TestMissingConfig(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, stdout, stderr := run(t, home, "transfer", "list")
+  assert.Equal(t, 1, code)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "production.json")
+}
+
+func TestTransferCreateDryRun(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  writeTestConfig(t, home, bitwire.SANDBOX)
+  code, stdout, _ := run(t, home, "-s", "transfer", "create", "--recipient", "42", "--amount", "100000", "--dry-run")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, `{"amount":"100000","currency":"KRW","recipient_id":42,"memo":"","type":"btc_to_bank"}`+"\n", stdout)
+
+  code, stdout, _ = run(t, home, "-s", "transfer", "create", "--recipient", "42", "--amount", "500", "--currency", "usd", "--dry-run")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"currency":"USD"`)
+
+  code, stdout, _ = run(t, home, "-s", "transfer", "create", "--type", "bank-to-btc", "--amount", "100000",
+    "--address", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", "--dry-run")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, `{"amount":"100000","currency":"KRW","memo":"","type":"bank_to_btc","address":"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"}`+"\n", stdout)
+
+  code, _, stderr := run(t, home, "-s", "transfer", "create", "--type", "bank-to-btc", "--amount", "100000",
+    "--address", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "--dry-run")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "wrong network")
+  code, _, stderr = run(t, home, "-s", "transfer", "create", "--type", "bank-to-btc", "--recipient", "42", "--amount", "100000")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "has no recipient")
+}
+
+func TestFollowViewLines(t *testing.T) {
+  now := time.Unix(1484800000, 0)
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Amount: bitwire.MustParseDecimal("0.01")}
+  tx.BTC.Address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+  tx.BTC.ExpiresAt = now.Add(90 * time.Second)
+  lines := followView{Transfer: tx}.lines(now, true)
+  assert.Contains(t, lines, "Payment    waiting for 0.01 BTC to 2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF")
+  assert.Contains(t, lines, "Expires    in 1m30s")
+  assert.Contains(t, lines, "Payout     pending")
+  assert.NotContains(t, lines, "Network    "+testnetLabel)
+  lines = followView{Transfer: tx, Testnet: true}.lines(now, true)
+  assert.Contains(t, lines, "Network    "+testnetLabel)
+
+  tx.Status = "completed"
+  tx.Recipient.Name, tx.Recipient.Amount, tx.Recipient.Currency = "Kim", bitwire.MustParseDecimal("100000"), "KRW"
+  payment := &explorer.Payment{TxId: "a", Amount: bitwire.MustParseDecimal("0.01000000"), Confirmations: 2}
+  lines = followView{Transfer: tx, Payment: payment}.lines(now, true)
+  assert.Equal(t, []string{"Transfer   tx_1", "Status     completed", "Payment    0.01000000 BTC received, 2 confirmation(s)",
+    "Payout     100000 KRW paid to Kim"}, lines)
+}
+
+func TestHumanize(t *testing.T) {
+  assert.Equal(t, "0s", humanDuration(300*time.Millisecond))
+  assert.Equal(t, "1m30s", humanDuration(90*time.Second))
+  assert.Equal(t, "14m", humanDuration(14*time.Minute+200*time.Millisecond))
+  assert.Equal(t, "2h", humanDuration(2*time.Hour+20*time.Second))
+  assert.Equal(t, "1d2h", humanDuration(26*time.Hour+5*time.Minute))
+
+  now := time.Date(2017, 1, 18, 12, 0, 0, 0, time.UTC)
+  assert.Equal(t, "2h ago", ago(now.Add(-2*time.Hour), now))
+  assert.Equal(t, "in 14m", ago(now.Add(14*time.Minute), now))
+  assert.Equal(t, "just now", ago(now, now))
+  assert.Equal(t, "", ago(time.Time{}, now))
+  assert.Equal(t, "in 14m", expiresIn(now.Add(14*time.Minute), now))
+  assert.Equal(t, "expired", expiresIn(now.Add(-time.Minute), now))
+
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-18T10:00:00Z", CreatedAt: now.Add(-2 * time.Hour)}
+  tx.BTC.ExpiresAt = now.Add(14 * time.Minute)
+  assert.Equal(t, "2h ago", fieldData(tx, "date", "", bitwire.SANDBOX, now))
+  assert.Equal(t, tx.CreatedAt.Local().Format(timestampLayout), fieldData(tx, "created", "", bitwire.SANDBOX, now))
+  assert.Equal(t, "in 14m", fieldData(tx, "expires", "", bitwire.SANDBOX, now))
+  assert.Equal(t, "2h ago ("+tx.CreatedAt.Local().Format(timestampLayout)+")", agoAt(tx.CreatedAt, now, tx.Date))
+  tx.CreatedAt = time.Time{}
+  assert.Equal(t, "2017-01-18T10:00:00Z", fieldData(tx, "date", "", bitwire.SANDBOX, now))
+}
+
+func TestTransferDetailLines(t *testing.T) {
+  now := time.Now()
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-19T04:00:00Z", Amount: bitwire.MustParseDecimal("0.1")}
+  tx.Recipient.Name, tx.Recipient.Amount, tx.Recipient.Currency = "Kim", bitwire.MustParseDecimal("100000"), "KRW"
+  tx.Recipient.Bank.DisplayName = "Shinhan"
+  tx.BTC.Address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+  tx.BTC.ExpiresAt = now.Add(90 * time.Second)
+  detail := transferDetail{Transfer: tx, Mode: bitwire.SANDBOX}
+  var titles []string
+  for _, s := range detail.sections(now) {
+    titles = append(titles, s.Title)
+  }
+  assert.Equal(t, []string{"Amounts & rate", "Recipient & bank", "BTC payment", "Timeline"}, titles)
+  fees := bitwire.TransferFees{Network: bitwire.MustParseDecimal("0.0001"), Service: bitwire.MustParseDecimal("1000"),
+    FXSpread: bitwire.MustParseDecimal("300"), Currency: "KRW"}
+  assert.Equal(t, "0.0001 BTC + 1300 KRW", feesSummary(fees))
+  withFees := detail
+  withFees.Transfer.Fees = fees
+  sections := withFees.sections(now)
+  assert.Equal(t, "Fees", sections[1].Title)
+  assert.Equal(t, [][2]string{{"Network Fee", "0.0001 BTC"}, {"Service Fee", "1000 KRW"}, {"FX Spread", "300 KRW"}}, sections[1].Rows)
+
+  lines := detail.lines(now, false)
+  assert.Equal(t, "Transfer tx_1  pending", lines[0])
+  assert.Contains(t, lines, "  Rate            1000000 KRW/BTC")
+  assert.Contains(t, lines, "  Expires         in 1m30s")
+  assert.Contains(t, lines, "  Network         "+testnetLabel)
+  styled := detail.lines(now, true)
+  assert.Equal(t, BOLD+"Transfer tx_1"+RESET+"  "+YELLOW+"pending"+RESET, styled[0])
+
+  uri, err := payableURI(tx, bitwire.SANDBOX)
+  assert.Nil(t, err)
+  assert.Contains(t, uri, tx.BTC.Address)
+  tx.Status = "expired"
+  _, err = payableURI(tx, bitwire.SANDBOX)
+  assert.NotNil(t, err)
+  assert.NotContains(t, strings.Join(transferDetail{Transfer: tx}.lines(now, false), "\n"), "in 1m30s")
+}
+
+func TestLimitsHistory(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  day := time.Now().AddDate(0, 0, -2)
+  for i, used := range []string{"100000", "850000", "200000"} {
+    limits := bitwire.Limits{}
+    limits.KRW.Daily = bitwire.KrwLimits{Used: bitwire.MustParseDecimal(used), Limit: bitwire.MustParseDecimal("1000000")}
+    limits.KRW.Weekly = bitwire.KrwLimits{Used: bitwire.MustParseDecimal(used), Limit: bitwire.MustParseDecimal("5000000")}
+    snapshotTime := day.Add(time.Duration(i) * time.Minute)
+    if i == 2 {
+      snapshotTime = time.Now().Add(-time.Hour)
+    }
+    assert.Nil(t, r.appendRecord(limitsStore, limitsSnapshot{snapshotTime, limits}))
+  }
+
+  code, stdout, stderr := run(t, home, "-s", "limits", "history")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, day.Format("2006-01-02")+"  [#################---]  85%  850000 / 1000000 KRW")
+  assert.Contains(t, stdout, "[####----------------]  20%")
+  assert.Contains(t, stderr, "consider requesting a limit increase")
+
+  code, stdout, _ = run(t, home, "-s", "-j", "limits", "history", "--days", "1")
+  assert.Equal(t, 0, code)
+  var periods []utilization
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &periods))
+  assert.Len(t, periods, 1)
+  assert.Equal(t, 20, periods[0].Percent)
+
+  code, stdout, _ = run(t, home, "limits", "history")
+  assert.Equal(t, 0, code)
+  assert.Empty(t, stdout)
+}
+
+func TestRatesHistory(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  now := time.Now()
+  noon := func(days int) time.Time {
+    return time.Date(now.Year(), now.Month(), now.Day()-days, 12, 0, 0, 0, time.Local)
+  }
+  samples := []struct {
+    time time.Time
+    rate string
+  }{{noon(3), "50000000"}, {noon(3).Add(time.Hour), "52000000"}, {noon(2), "51000000"}, {noon(1), "54000000"}}
+  for _, s := range samples {
+    rates := bitwire.AllRates{BTC: bitwire.Rates{"BTCKRW": bitwire.MustParseDecimal(s.rate)}, FX: bitwire.Rates{"USDKRW": bitwire.MustParseDecimal("1100")}}
+    assert.Nil(t, r.appendRecord(ratesStore, ratesSnapshot{s.time, rates}))
+  }
+
+  history, err := r.readRatesHistory()
+  assert.Nil(t, err)
+  days := pairHistory(history, "BTCKRW", noon(30))
+  assert.Len(t, days, 3)
+  assert.Equal(t, dailyRate{noon(3).Format(dateLayout), bitwire.MustParseDecimal("50000000"), bitwire.MustParseDecimal("52000000"),
+    bitwire.MustParseDecimal("52000000"), 2}, days[0])
+  assert.Equal(t, "▃▁█", sparkline(days))
+
+  code, stdout, stderr := run(t, home, "-s", "rates", "history", "--sparkline")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "BTCKRW "+noon(3).Format(dateLayout)+".."+noon(1).Format(dateLayout)+"  ▃▁█  52000000 -> 54000000\n", stdout)
+
+  code, stdout, _ = run(t, home, "-s", "-j", "rates", "history", "--pair", "USDKRW")
+  assert.Equal(t, 0, code)
+  var fx []dailyRate
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &fx))
+  assert.Len(t, fx, 3)
+  assert.Equal(t, "1100", fx[2].Close.String())
+
+  code, stdout, stderr = run(t, home, "rates", "history")
+  assert.Equal(t, 0, code)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "No BTCKRW rates recorded")
+}
+
+func TestOffline(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, stderr := run(t, home, "-s", "--offline", "transfer", "list")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "run `bitwire sync` first")
+
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  pending := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-18T10:00:00Z"}
+  completed := pending
+  completed.Status = "completed"
+  other := bitwire.Transfer{Id: "tx_2", Status: "expired", Date: "2017-01-19T10:00:00Z"}
+  for _, tx := range []bitwire.Transfer{pending, other, completed} {
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  }
+  assert.Nil(t, r.appendRecord(recipientsStore, recipientsSnapshot{synced, []bitwire.Recipient{{Id: 42, Name: "Kim Minsu"}}}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 2, Recipients: 1}))
+
+  code, stdout, stderr := run(t, home, "-s", "-j", "--offline", "transfer", "list")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stderr, "Offline: showing data synced 5m")
+  var txs []bitwire.Transfer
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &txs))
+  assert.Equal(t, []string{"tx_2", "tx_1"}, []string{txs[0].Id, txs[1].Id})
+  assert.Equal(t, "completed", txs[1].Status)
+
+  code, stdout, _ = run(t, home, "-s", "-j", "--offline", "transfer", "list", "--status", "completed")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"id": "tx_1"`)
+  assert.NotContains(t, stdout, `"id": "tx_2"`)
+
+  code, stdout, _ = run(t, home, "-s", "--offline", "transfer", "show", "tx_1")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "Transfer tx_1  completed")
+  assert.Contains(t, stdout, "Timeline")
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "show", "--qr-only", "tx_1")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Transfer tx_1 has no payment address")
+
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "show", "tx_3")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Transfer tx_3 not synced")
+
+  code, stdout, _ = run(t, home, "-s", "-j", "--offline", "recipient", "list", "--name", "minsu")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "Kim Minsu")
+}
+
+func TestPlain(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-18T10:00:00Z", Amount: bitwire.MustParseDecimal("0.05")}
+  tx.BTC.Address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 1}))
+
+  code, stdout, stderr := run(t, home, "-s", "--plain", "--offline", "transfer", "show", "tx_1")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "Transfer: tx_1\nStatus: pending\n")
+  assert.Contains(t, stdout, "Pay to address: 2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF\nPay amount: 0.05 BTC\n")
+  assert.Contains(t, stdout, "Payment URI: bitcoin:2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF")
+  assert.NotContains(t, stdout, "\033")
+  assert.NotContains(t, stderr, "\033")
+
+  code, stdout, _ = run(t, home, "-s", "--offline", "transfer", "list", "-f", "id", "-f", "status")
+  assert.Equal(t, 0, code)
+  assert.NotContains(t, stdout, "ID: tx_1")
+  assert.Nil(t, os.MkdirAll(filepath.Join(home, ConfDir), 0700))
+  assert.Nil(t, ioutil.WriteFile(filepath.Join(home, PrefsPath), []byte(`{"plain": true}`), 0600))
+  code, stdout, _ = run(t, home, "-s", "--offline", "transfer", "list", "-f", "id", "-f", "status")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "ID: tx_1\nStatus: pending\n\n", stdout)
+
+  table := &plainTable{w: new(bytes.Buffer)}
+  table.SetHeader([]string{"", "Rate"})
+  table.Append([]string{"BTCKRW", "1000000"})
+  table.Append([]string{"", ""})
+  table.Append([]string{"USDKRW", "1100"})
+  table.Render()
+  assert.Equal(t, "BTCKRW: 1000000\nUSDKRW: 1100\n\n", table.w.(*bytes.Buffer).String())
+}
+
+// Terminal reporting every writer as interactive, without QR codes
+type fakeTerminal struct {
+  NoTerminal
+}
+
+func (fakeTerminal) IsTerminal(w io.Writer) bool { return true }
+
+// Terminal with only stderr interactive, as when stdout is piped
+type stderrTerminal struct {
+  NoTerminal
+  stderr io.Writer
+}
+
+func (t stderrTerminal) IsTerminal(w io.Writer) bool { return w == t.stderr }
+
+func TestTerminal(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-18T10:00:00Z", Amount: bitwire.MustParseDecimal("0.05")}
+  tx.BTC.Address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 1}))
+  args := []string{"bitwire", "-s", "--offline", "transfer", "show", "tx_1"}
+
+  stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+  code := Run(Deps{Stdout: stdout, Stderr: stderr, Home: home, Terminal: NoTerminal{}}, args)
+  assert.Equal(t, 0, code, stderr.String())
+  assert.Contains(t, stdout.String(), "Payment URI: bitcoin:2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF")
+  assert.NotContains(t, stdout.String(), "\033")
+  assert.Contains(t, stderr.String(), "Offline: showing data synced")
+  assert.NotContains(t, stderr.String(), "\033")
+
+  stdout, stderr = new(bytes.Buffer), new(bytes.Buffer)
+  code = Run(Deps{Stdout: stdout, Stderr: stderr, Home: home, Terminal: fakeTerminal{}}, args)
+  assert.Equal(t, 0, code, stderr.String())
+  assert.Contains(t, stdout.String(), BOLD+"Transfer tx_1"+RESET)
+  assert.Contains(t, stderr.String(), YELLOW+"Offline: showing data synced")
+  assert.Contains(t, stdout.String(), "Payment URI:")
+}
+
+func TestColors(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  for id, status := range map[string]string{"tx_1": "pending", "tx_2": "completed", "tx_3": "expired"} {
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, bitwire.Transfer{Id: id, Status: status}}))
+  }
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 3}))
+  list := func(args ...string) string {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    args = append(append([]string{"bitwire", "-s", "--offline"}, args...), "transfer", "list", "-f", "id", "-f", "status")
+    code := Run(Deps{Stdout: stdout, Stderr: stderr, Home: home, Terminal: fakeTerminal{}}, args)
+    assert.Equal(t, 0, code, stderr.String())
+    return stdout.String()
+  }
+
+  stdout := list()
+  assert.Contains(t, stdout, YELLOW+"pending"+RESET)
+  assert.Contains(t, stdout, GREEN+"completed"+RESET)
+  assert.Contains(t, stdout, RED+"expired"+RESET)
+  assert.NotContains(t, list("--no-color"), "\033[")
+  os.Setenv("NO_COLOR", "1")
+  defer os.Unsetenv("NO_COLOR")
+  assert.NotContains(t, list(), "\033[")
+
+  assert.Equal(t, GREEN, utilizationColor(15))
+  assert.Equal(t, YELLOW, utilizationColor(highUtilization))
+  assert.Equal(t, RED, utilizationColor(100))
+}
+
+func TestWatchExitCode(t *testing.T) {
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending"}
+  _, done := watchExitCode(tx)
+  assert.False(t, done)
+  now := time.Now()
+  tx.BTC.ExpiresAt = now.Add(time.Minute)
+  assert.Equal(t, "tx_1  pending  expires in 1m", watchLine(tx, now, true))
+  assert.Equal(t, "tx_1  pending", watchLine(tx, now, false))
+  tx.Amount, tx.BTC.Received = bitwire.MustParseDecimal("0.01"), bitwire.MustParseDecimal("0.004")
+  assert.Equal(t, "tx_1  pending  Underpaid: 0.004 of 0.01 BTC received, 0.006 BTC missing", watchLine(tx, now, false))
+  tx.BTC.Received = bitwire.Decimal{}
+
+  for status, want := range map[string]int{"completed": ExitCompleted, "PAID_COMPLETED": ExitCompleted,
+    "expired": ExitExpired, "canceled": ExitCanceled} {
+    code, done := watchExitCode(bitwire.Transfer{Status: status})
+    assert.True(t, done, status)
+    assert.Equal(t, want, code, status)
+  }
+  tx.BTC.ExpiresAt = now.Add(-time.Minute)
+  code, done := watchExitCode(tx)
+  assert.True(t, done)
+  assert.Equal(t, ExitExpired, code)
+}
+
+func TestDbPurge(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, stderr := run(t, home, "db", "purge", "--older-than", "2x")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid age: 2x")
+
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  old := time.Now().AddDate(-3, 0, 0)
+  recent := time.Now().Add(-time.Hour)
+  oldTx := bitwire.Transfer{Id: "tx_1", Status: "completed", Date: old.UTC().Format(time.RFC3339)}
+  newTx := bitwire.Transfer{Id: "tx_2", Status: "completed", Date: recent.UTC().Format(time.RFC3339)}
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{recent, oldTx}))
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{recent, newTx}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: old.Add(-time.Hour)}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: old, Cursor: bitwire.SyncCursor{Since: old}}))
+  assert.Nil(t, r.appendRecord(limitsStore, limitsSnapshot{old, bitwire.Limits{}}))
+
+  code, stdout, _ := run(t, home, "db", "purge", "--older-than", "2y")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "Removed 1 sandbox transfers records")
+  assert.Contains(t, stdout, "Removed 1 sandbox sync records")
+  assert.Contains(t, stdout, "Removed 1 sandbox limits records")
+
+  txs, err := r.syncedTransfers()
+  assert.Nil(t, err)
+  assert.Len(t, txs, 1)
+  assert.Equal(t, "tx_2", txs[0].Id)
+  last, err := r.lastSync()
+  assert.Nil(t, err)
+  assert.True(t, last.Cursor.Since.Equal(old))
+
+  code, stdout, _ = run(t, home, "db", "purge", "--older-than", "2y")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "No records older than")
+
+  assert.Nil(t, ioutil.WriteFile(filepath.Join(home, ConfDir, "preferences.json"), []byte(`{"retention": "0d"}`), 0600))
+  run(t, home, "commands")
+  txs, err = r.syncedTransfers()
+  assert.Nil(t, err)
+  assert.Empty(t, txs)
+}
+
+func TestAPIURL(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    assert.True(t, strings.HasPrefix(r.URL.Path, "/api/v2/"), r.URL.Path)
+    if r.URL.Path != "/api/v2/banks" { // The rates snapshot after the command
+      http.NotFound(w, r)
+      return
+    }
+    fmt.Fprint(w, `{"code":200,"banks":[{"id":1,"name":"Shinhan Bank","name_ko":"신한은행"}]}`)
+  }))
+  defer server.Close()
+  code, stdout, _ := run(t, home, "-s", "-j", "--api-url", server.URL+"/api/v2", "banks")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "Shinhan Bank")
+}
+
+type staticCredentials struct{ login bitwire.LoginCredentials }
+
+func (s staticCredentials) Credentials(ctx context.Context) (bitwire.LoginCredentials, error) {
+  return s.login, nil
+}
+
+func TestCredentialsFrom(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("cmdtest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    assert.Equal(t, "bitwire/sandbox", name)
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+
+  code, stdout, stderr := run(t, home, "-s", "-j", "--api-url", server.APIURL(), "--credentials-from", "cmdtest:bitwire/sandbox", "transfer", "list")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, `"id"`)
+  r := &runner{Deps: homeDeps(home)}
+  _, err := os.Stat(r.configPath(string(bitwire.SANDBOX)))
+  assert.True(t, os.IsNotExist(err))
+
+  code, _, stderr = run(t, home, "--credentials-from", "gcp:bitwire", "transfer", "list")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "No gcp secret provider in this build")
+}
+
+func TestWhoami(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("whoamitest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "whoamitest:bitwire", "whoami")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, bitwiretest.Username)
+  assert.Contains(t, stdout, "KYC Status")
+}
+
+func TestVerification(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("verificationtest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "verificationtest:bitwire", "verification")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "Next steps to reach level 2:")
+  assert.Contains(t, stdout, "Utility bill or bank statement from the last 3 months (required)")
+  assert.NotContains(t, stdout, "Photo of an ID card")
+
+  server.Fake.SetErr(&bitwire.APIError{Code: 403, ErrorType: "verification_required", Message: "Verify your address to transfer more.", HTTPStatus: 403})
+  code, _, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "verificationtest:bitwire", "limits")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "bitwire verification")
+}
+
+func TestTransferValidate(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("validatetest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  path := filepath.Join(home, "payouts.csv")
+  validate := func(content string) (int, string, string) {
+    assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+    return run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "validatetest:bitwire", "transfer", "validate", path)
+  }
+
+  code, stdout, stderr := validate("recipient,amount\n42,100000\n")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "1 transfers OK, 100000 KRW in total")
+
+  code, stdout, _ = validate("recipient,amount,currency\n42,100000,KRW\nLee,50000,\n42,100000,KRW\n99,12.5,KRW\n43,100,USD\n43,5000,krw\n")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stdout, path+":4: Duplicate of line 2")
+  assert.Contains(t, stdout, path+":5: recipient: Recipient 99 not found")
+  assert.Contains(t, stdout, path+":5: amount: 12.5 has more decimals than KRW amounts have (0)")
+  assert.Contains(t, stdout, path+":7: amount: 5000 KRW is below the minimum transfer of 10000 KRW")
+  assert.Contains(t, stdout, path+": 6 transfers, but only 2 more can be pending")
+  assert.NotContains(t, stdout, ":3:")
+  assert.NotContains(t, stdout, ":6:")
+  assert.Contains(t, stdout, "6 problems in 6 transfers. Nothing was created.")
+  assert.Equal(t, 2, len(server.Fake.Transfers.Data), "no transfer is created")
+
+  code, _, stderr = validate("name,amount\n42,100000\n")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "The header has no recipient column")
+}
+
+func TestColumnsAndSort(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  for i, amount := range []string{"0.2", "0.1", "0.3"} {
+    tx := bitwire.Transfer{Id: fmt.Sprintf("tx_%d", i+1), Status: "pending", Amount: bitwire.MustParseDecimal(amount),
+      Currency: "BTC", Date: synced.Add(time.Duration(-i) * time.Hour).UTC().Format(time.RFC3339)}
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  }
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 3}))
+
+  code, stdout, stderr := run(t, home, "-s", "--offline", "--output", "csv", "transfer", "list", "--columns", "id, sent", "--sort", "sent:desc")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "id,sent\ntx_3,0.3\ntx_1,0.2\ntx_2,0.1\n", stdout)
+  code, stdout, _ = run(t, home, "-s", "--offline", "--output", "csv", "transfer", "list", "--columns", "id", "--sort", "date")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "id\ntx_3\ntx_2\ntx_1\n", stdout)
+
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "list", "--columns", "id,colour")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Unknown column: colour")
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "list", "--sort", "date:down")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid sort direction: down")
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "list", "-f", "id", "--columns", "id")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Use either -f or --columns")
+
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("columnstest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  code, stdout, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "columnstest:bitwire",
+    "--output", "csv", "recipient", "list", "--columns", "name,id", "--sort", "id:desc")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "name,id\nLee Seoyeon,43\nKim Minjun,42\n", stdout)
+}
+
+func TestReferenceRates(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  created := time.Date(2024, 1, 6, 12, 0, 0, 0, time.Local)
+  for _, currency := range []string{"USD", "KRW"} {
+    tx := bitwire.Transfer{Id: "tx_" + currency, Status: "completed", Amount: bitwire.MustParseDecimal("0.01"),
+      Date: created.Format(time.RFC3339)}
+    tx.Recipient.Amount, tx.Recipient.Currency = bitwire.MustParseDecimal("420.50"), currency
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  }
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 2}))
+  path := filepath.Join(home, "reference.csv")
+  assert.Nil(t, ioutil.WriteFile(path, []byte("date,base,quote,rate\n2024-01-05,USD,KRW,1312.1\n"), 0600))
+
+  code, stdout, stderr := run(t, home, "-s", "--offline", "--output", "csv", "transfer", "list",
+    "--columns", "id,rate,ref-rate", "--sort", "id", "--reference-rates", "file:"+path)
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "id,rate,ref-rate\ntx_KRW,42050,\ntx_USD,42050.00,1312.1\n", stdout)
+
+  code, _, stderr = run(t, home, "-s", "--offline", "transfer", "list", "--columns", "id,ref-rate")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "The ref-rate column needs --reference-rates")
+}
+
+func TestDashboard(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("dashboardtest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "--credentials-from", "dashboardtest:bitwire",
+    "--output", "csv", "dashboard")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "In flight,Value\nTransfers,1\nUnfunded transfers,1\nKRW value,50000\nBTC awaiting confirmation,0\n", stdout)
+}
+
+func TestCancelStale(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  expires := time.Now().Add(5 * time.Minute)
+  server.Fake.Transfers.Data[1].BTC.Expiration, server.Fake.Transfers.Data[1].BTC.ExpiresAt = int(expires.Unix()), expires
+  secrets.Register("staletest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  args := []string{"-s", "--api-url", server.APIURL(), "--credentials-from", "staletest:bitwire", "--output", "csv", "transfer", "cancel-stale"}
+
+  code, stdout, stderr := run(t, home, append(args, "--dry-run")...)
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "tx_pending")
+  assert.Equal(t, bitwire.StatusPending, server.Fake.Transfers.Data[1].Status)
+
+  code, stdout, _ = run(t, home, append(args, "--exclude-recipient", "43")...)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "", stdout)
+  server.Fake.Transfers.Data[1].Memo = "rent #keep"
+  code, stdout, _ = run(t, home, append(args, "--exclude-label", "#keep")...)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "", stdout)
+  code, stdout, _ = run(t, home, append(args, "--before", "1m")...)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "", stdout)
+
+  code, stdout, stderr = run(t, home, args...)
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "tx_pending")
+  assert.Equal(t, bitwire.StatusCanceled, server.Fake.Transfers.Data[1].Status)
+}
+
+// Decodes the progress events among the stderr lines
+func progressEvents(t *testing.T, stderr string) []progressEvent {
+  var events []progressEvent
+  for _, line := range strings.Split(stderr, "\n") {
+    if strings.HasPrefix(line, "{") {
+      e := progressEvent{}
+      assert.Nil(t, json.Unmarshal([]byte(line), &e))
+      events = append(events, e)
+    }
+  }
+  return events
+}
+
+func TestRatesWatch(t *testing.T) {
+  thresholds := rateThresholds{Above: bitwire.MustParseDecimal("950"), Below: bitwire.MustParseDecimal("800")}
+  for rate, want := range map[string]string{"951": rateAbove, "950": rateWithin, "800": rateWithin, "799.5": rateBelow} {
+    assert.Equal(t, want, thresholds.side(bitwire.MustParseDecimal(rate)), rate)
+  }
+  assert.Equal(t, rateWithin, rateThresholds{Below: bitwire.MustParseDecimal("800")}.side(bitwire.MustParseDecimal("2000")))
+
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, stderr := run(t, home, "-s", "rates", "watch")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Missing --above or --below rate")
+  code, _, stderr = run(t, home, "-s", "rates", "watch", "--above", "lots")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid --above rate: lots")
+
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+  args := []string{"bitwire", "-s", "--api-url", server.APIURL(), "rates", "watch", "--above", "900000", "--once",
+    "--exec", "echo alert $BITWIRE_PAIR $BITWIRE_CROSSED $BITWIRE_THRESHOLD at $BITWIRE_RATE"}
+  code = Run(Deps{Stdout: out, Stderr: errOut, Home: home, Terminal: stderrTerminal{stderr: errOut}}, args)
+  assert.Equal(t, 0, code, errOut.String())
+  assert.Contains(t, errOut.String(), "Watching BTCKRW, now 1000000")
+  assert.Contains(t, out.String(), "BTCKRW 1000000 is above 900000")
+  assert.Contains(t, out.String(), "alert BTCKRW above 900000 at 1000000")
+  assert.NotContains(t, out.String(), "\a", "no bell on piped stdout")
+
+  code, _, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "rates", "watch", "--pair", "BTCXYZ", "--below", "1")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Unknown rate pair: BTCXYZ")
+}
+
+func TestCSV(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  created := time.Date(2017, 1, 18, 10, 0, 0, 0, time.UTC)
+  tx := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: created.Format(time.RFC3339), CreatedAt: created,
+    Amount: bitwire.MustParseDecimal("0.1"), Currency: "BTC"}
+  tx.Recipient.Name, tx.Recipient.Amount, tx.Recipient.Currency = `Kim "MJ", Minjun`, bitwire.MustParseDecimal("100000"), "KRW"
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 1}))
+
+  code, stdout, stderr := run(t, home, "-s", "--offline", "--output", "csv", "transfer", "list",
+    "-f", "id", "-f", "recipient", "-f", "sent", "-f", "received", "-f", "date")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "id,recipient,sent,received,currency,date\n"+
+    `tx_1,"Kim ""MJ"", Minjun",0.1,100000,KRW,2017-01-18T10:00:00Z`+"\n", stdout)
+
+  code, stdout, _ = run(t, home, "-s", "--offline", "--output", "csv", "transfer", "show", "tx_1")
+  assert.Equal(t, 0, code)
+  assert.True(t, strings.HasPrefix(stdout, "id,recipient,sent,received,currency,date,status,address\n"), stdout)
+
+  // Tables of label and value rows get a header
+  buf := new(bytes.Buffer)
+  table := &csvTable{w: csv.NewWriter(buf)}
+  table.Append([]string{"ID", "tx_1"})
+  table.Append([]string{"", ""})
+  table.Append([]string{"Status", "pending"})
+  table.Render()
+  assert.Equal(t, "Field,Value\nID,tx_1\nStatus,pending\n", buf.String())
+
+  code, _, stderr = run(t, home, "--output", "xml", "rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid output format: xml")
+  code, _, stderr = run(t, home, "-j", "--output", "csv", "rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Use either --json or --output csv")
+
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  code, stdout, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "--output", "csv", "rates")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "Pair,Rate\nBTCKRW,1000000\nBTCUSD,900\nUSDKRW,1175.50\n", stdout)
+  code, stdout, _ = run(t, home, "-s", "--api-url", server.APIURL(), "--output", "json", "banks")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"display_name": "KB Kookmin Bank"`)
+}
+
+func TestYAMLAndNDJSON(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "--output", "ndjson", "banks")
+  assert.Equal(t, 0, code, stderr)
+  lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+  assert.True(t, len(lines) > 1, stdout)
+  for _, line := range lines {
+    bank := map[string]interface{}{}
+    assert.Nil(t, json.Unmarshal([]byte(line), &bank), line)
+  }
+  assert.Contains(t, stdout, `"display_name":"KB Kookmin Bank"`)
+
+  code, stdout, _ = run(t, home, "-s", "--api-url", server.APIURL(), "--output", "ndjson", "rates")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, 1, strings.Count(stdout, "\n"), stdout)
+
+  code, stdout, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "--output", "yaml", "banks")
+  assert.Equal(t, 0, code, stderr)
+  assert.True(t, strings.HasPrefix(stdout, "- "), stdout)
+  assert.Contains(t, stdout, "display_name: KB Kookmin Bank\n")
+}
+
+func TestFormat(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-5 * time.Minute)
+  for _, id := range []string{"tx_1", "tx_2"} {
+    tx := bitwire.Transfer{Id: id, Status: "pending", Amount: bitwire.MustParseDecimal("0.1")}
+    tx.BTC.Address = "addr_" + id
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  }
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 2}))
+
+  code, stdout, stderr := run(t, home, "-s", "--offline", "--format", "{{.Id}} {{.Status}} {{.BTC.Address}}", "transfer", "list")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "tx_1 pending addr_tx_1\n")
+  assert.Contains(t, stdout, "tx_2 pending addr_tx_2\n")
+  assert.Equal(t, 2, strings.Count(stdout, "\n"), stdout)
+
+  code, stdout, _ = run(t, home, "-s", "--offline", "--format", "{{.Amount | json}}", "transfer", "show", "tx_1")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "\"0.1\"\n", stdout)
+
+  code, _, stderr = run(t, home, "--format", "{{.Id", "rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid --format template")
+  code, _, stderr = run(t, home, "--format", "{{.Id}}", "--output", "csv", "rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Use either --format or --output csv")
+}
+
+func TestDeprecationWarning(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Deprecation", "true")
+    w.Header().Set("Sunset", "Mon, 30 Jun 2025 00:00:00 GMT")
+    fmt.Fprint(w, `{"code":200,"rates":{"btc":{"BTCKRW":"1000000"},"fx":{}}}`)
+  }))
+  defer server.Close()
+  message := "GET rates is deprecated and will stop working on 2025-06-30"
+
+  code, _, stderr := run(t, home, "-s", "--api-url", server.URL+"/", "rates")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Note: "+message)
+  // Shown once a day
+  code, _, stderr = run(t, home, "-s", "--api-url", server.URL+"/", "rates")
+  assert.Equal(t, 0, code, stderr)
+  assert.NotContains(t, stderr, "deprecated")
+
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  assert.False(t, r.deprecationDue(message, time.Now().Add(time.Hour)))
+  assert.True(t, r.deprecationDue(message, time.Now().Add(25*time.Hour)))
+  assert.True(t, r.deprecationDue("GET banks will stop working on 2025-06-30", time.Now()))
+}
+
+func TestDocs(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, stdout, _ := run(t, home, "docs")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "## Responses")
+  assert.Contains(t, stdout, "## TransferRes")
+
+  code, stdout, _ = run(t, home, "docs", "transfer")
+  assert.Equal(t, 0, code)
+  assert.True(t, strings.HasPrefix(stdout, "## Transfer\n"), stdout)
+  assert.Contains(t, stdout, "| `recipient` | [TransferRecipient](#transferrecipient) |")
+  assert.NotContains(t, stdout, "## TransferRes")
+
+  code, stdout, _ = run(t, home, "-j", "docs", "Bank")
+  assert.Equal(t, 0, code)
+  var bank schemaType
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &bank))
+  assert.Equal(t, "Bank", bank.Name)
+  assert.Contains(t, bank.Fields, schemaField{Name: "name_ko", Type: "string"})
+
+  code, _, stderr := run(t, home, "docs", "Nope")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Unknown type: Nope")
+}
+
+func TestProgressJSON(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, stderr := run(t, home, "--progress-json", "db", "purge", "--older-than", "1y")
+  assert.Equal(t, 0, code)
+  events := progressEvents(t, stderr)
+  assert.Len(t, events, 2+2*len(storeNames))
+  assert.Equal(t, progressEvent{Time: events[0].Time, Command: "db purge", Event: progressStart, Total: 2 * len(storeNames)}, events[0])
+  assert.Equal(t, "production/limits", events[1].Step)
+  assert.Equal(t, progressDone, events[len(events)-1].Event)
+
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("progresstest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  args := []string{"-s", "--progress-json", "--api-url", server.APIURL(), "--credentials-from", "progresstest:bitwire"}
+  code, _, stderr = run(t, home, append(args, "sync")...)
+  assert.Equal(t, 0, code, stderr)
+  events = progressEvents(t, stderr)
+  assert.Equal(t, "sync", events[0].Command)
+  assert.Equal(t, progressEvent{Time: events[2].Time, Command: "sync", Event: progressStep, Step: "transfers", Current: 2}, events[2])
+  assert.Equal(t, "recipients", events[3].Step)
+  assert.Equal(t, progressDone, events[4].Event)
+
+  code, _, stderr = run(t, home, append(args, "transfer", "watch", "missing")...)
+  assert.Equal(t, 1, code)
+  events = progressEvents(t, stderr)
+  assert.Len(t, events, 2)
+  assert.Equal(t, progressFailed, events[1].Event)
+  assert.Equal(t, 1, events[1].Code)
+  assert.Contains(t, events[1].Message, "not_found")
+
+  code, _, stderr = run(t, home, append(args, "transfer", "watch", "tx_completed")...)
+  assert.Equal(t, 0, code, stderr)
+  events = progressEvents(t, stderr)
+  assert.Equal(t, []string{progressStart, progressStep, progressDone}, []string{events[0].Event, events[1].Event, events[2].Event})
+  assert.Equal(t, bitwire.StatusCompleted, events[1].Status)
+}
+
+func TestJournal(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  code, _, stderr := run(t, home, "-s", "--journal", "--api-url", server.APIURL(), "banks")
+  server.Close()
+  assert.Equal(t, 0, code, stderr)
+
+  files, err := filepath.Glob(filepath.Join(home, JournalDir, "*-sandbox.json"))
+  assert.Nil(t, err)
+  assert.Len(t, files, 1)
+  replay, err := bitwiretest.NewRecorder(files[0], bitwiretest.Replay)
+  assert.Nil(t, err)
+  client, _ := bitwire.New(bitwire.SANDBOX)
+  client.BaseURL = server.APIURL()
+  client.HTTPClient = replay.Client()
+  banks, err := client.Banks.List()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, banks)
+
+  out := filepath.Join(home, "support.zip")
+  code, stdout, stderr := run(t, home, "journal", "bundle", "--out", out)
+  assert.Equal(t, 0, code)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "Bundled 1 journal files")
+  archive, err := zip.OpenReader(out)
+  assert.Nil(t, err)
+  defer archive.Close()
+  var names []string
+  for _, f := range archive.File {
+    names = append(names, f.Name)
+  }
+  assert.Equal(t, []string{"diagnostics.json", "journal/" + filepath.Base(files[0])}, names)
+}
+
+func TestSupportBundle(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, _ := run(t, home, "-s", "transfer", "cancel")
+  assert.Equal(t, 1, code)
+  bundle := func(input string, args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: stdout, Stderr: stderr, Home: home}
+    return Run(deps, append([]string{"bitwire", "support-bundle"}, args...)), stdout.String(), stderr.String()
+  }
+  out := filepath.Join(home, "support.tar.gz")
+
+  code, _, stderr := bundle("n\n", "--out", out)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "doctor.json")
+  assert.Contains(t, stderr, "Support bundle not written")
+  _, err := os.Stat(out)
+  assert.True(t, os.IsNotExist(err))
+
+  code, stdout, stderr := bundle("y\n", "--out", out)
+  assert.Equal(t, 0, code, stderr)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "Wrote the support bundle to "+out)
+  file, err := os.Open(out)
+  assert.Nil(t, err)
+  defer file.Close()
+  zr, err := gzip.NewReader(file)
+  assert.Nil(t, err)
+  contents := map[string]string{}
+  archive := tar.NewReader(zr)
+  for {
+    hdr, err := archive.Next()
+    if err != nil {
+      break
+    }
+    data, _ := ioutil.ReadAll(archive)
+    contents[hdr.Name] = string(data)
+  }
+  assert.Equal(t, 3, len(contents))
+  assert.Contains(t, contents["diagnostics.json"], `"version": "`+Version+`"`)
+  assert.Contains(t, contents["doctor.json"], `"check": "config sandbox"`)
+  // The errors of the earlier runs, the declined review included
+  logged := []loggedError{}
+  assert.Nil(t, json.Unmarshal([]byte(contents["errors.json"]), &logged))
+  assert.Equal(t, 2, len(logged))
+  assert.Equal(t, "Support bundle not written", logged[1].Error)
+}
+
+func TestChangedSinceLastRun(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  synced := time.Now().Add(-time.Minute)
+  pending := bitwire.Transfer{Id: "tx_1", Status: "pending", Date: "2017-01-18T10:00:00Z"}
+  other := bitwire.Transfer{Id: "tx_2", Status: "pending", Date: "2017-01-19T10:00:00Z"}
+  for _, tx := range []bitwire.Transfer{pending, other} {
+    assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, tx}))
+  }
+  assert.Nil(t, r.appendRecord(syncStore, syncRecord{Time: synced, Transfers: 2}))
+  args := []string{"-s", "--offline", "transfer", "list", "--changed-since-last-run", "-f", "id", "-f", "status"}
+
+  code, stdout, _ := run(t, home, args...)
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "tx_1")
+  assert.Contains(t, stdout, "tx_2")
+
+  code, stdout, _ = run(t, home, args...)
+  assert.Equal(t, 0, code)
+  assert.Empty(t, stdout)
+
+  other.Status = "completed"
+  assert.Nil(t, r.appendRecord(transfersStore, syncedTransfer{synced, other}))
+  code, stdout, _ = run(t, home, args...)
+  assert.Equal(t, 0, code)
+  assert.NotContains(t, stdout, "tx_1")
+  assert.Contains(t, stdout, "completed")
+}
+
+func TestRankRecipients(t *testing.T) {
+  kim := bitwire.Recipient{Id: 42, Name: "Kim Minjun", Email: "minjun@example.com"}
+  lee := bitwire.Recipient{Id: 43, Name: "Lee Seoyeon", Email: "seoyeon@example.com"}
+  kim.Bank.DisplayName, lee.Bank.DisplayName = "Kookmin Bank", "Shinhan Bank"
+  _, ok := fuzzyScore("kmj", "Kim Minjun")
+  assert.True(t, ok)
+  _, ok = fuzzyScore("jmk", "Kim Minjun")
+  assert.False(t, ok)
+  prefix, _ := fuzzyScore("min", "Kim Minjun")
+  scattered, _ := fuzzyScore("min", "Kim Seoyeon")
+  assert.True(t, prefix > scattered)
+
+  assert.Equal(t, []bitwire.Recipient{lee}, rankRecipients([]bitwire.Recipient{kim, lee}, "shin"))
+  assert.Equal(t, []bitwire.Recipient{lee, kim}, rankRecipients([]bitwire.Recipient{kim, lee}, "on"))
+  assert.Equal(t, []bitwire.Recipient{kim, lee}, rankRecipients([]bitwire.Recipient{kim, lee}, ""))
+  assert.Empty(t, rankRecipients([]bitwire.Recipient{kim, lee}, "xyz"))
+}
+
+func TestPickRecipient(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("picktest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  pick := func(input string, args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: stdout, Stderr: stderr, Home: home}
+    args = append([]string{"bitwire", "-s", "--api-url", server.APIURL(), "--credentials-from", "picktest:bitwire"}, args...)
+    return Run(deps, args), stdout.String(), stderr.String()
+  }
+
+  code, stdout, stderr := pick("2\n", "recipient", "show", "--pick")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, " 1) Kim Minjun")
+  assert.Contains(t, stdout, "seoyeon@example.com")
+  // Searching again with a query that has a single match picks it
+  code, stdout, stderr = pick("lee\n", "recipient", "show", "--pick")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "987-654-321")
+  code, stdout, _ = pick("", "recipient", "show", "--pick", "minjun")
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "123-456-789")
+  code, _, stderr = pick("\n", "recipient", "show", "--pick")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "No recipient picked")
+
+  code, _, stderr = pick("1\ny\n", "transfer", "create", "--pick", "--amount", "100000")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, 3, len(server.Fake.Transfers.Data))
+  assert.Equal(t, 42, server.Fake.Transfers.Data[2].Recipient.Id)
+}
+
+func TestStats(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+
+  code, _, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "--slow-call", "1ns", "banks")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Note: banks took ")
+  assert.Contains(t, stderr, "the API may be degraded")
+  code, _, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "banks")
+  assert.Equal(t, 0, code)
+  assert.NotContains(t, stderr, "degraded")
+
+  code, stdout, stderr := run(t, home, "-s", "--json", "stats")
+  assert.Equal(t, 0, code, stderr)
+  stats := []endpointStats{}
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &stats))
+  assert.Equal(t, 2, len(stats))
+  assert.Equal(t, "GET banks", stats[0].Endpoint)
+  assert.Equal(t, 2, stats[0].Calls)
+  assert.Equal(t, "GET rates", stats[1].Endpoint, "the rates snapshot after the first command")
+  code, stdout, _ = run(t, home, "stats")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "", stdout, "calls are recorded by mode")
+
+  calls := []callRecord{{Endpoint: "rates", Method: "GET", Millis: 100}, {Endpoint: "rates", Method: "GET", Millis: 4200, Failed: true}}
+  for i := 1; i <= 8; i++ {
+    calls = append(calls, callRecord{Endpoint: "rates", Method: "GET", Millis: int64(i * 10)})
+  }
+  assert.Equal(t, []endpointStats{{"GET rates", 10, 1, 50, 100, 4200, 4200}}, callStats(calls))
+}
+
+func TestConfirm(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("confirmtest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  confirm := func(input string, args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: stdout, Stderr: stderr, Home: home}
+    args = append([]string{"bitwire", "-s", "--api-url", server.APIURL(), "--credentials-from", "confirmtest:bitwire"}, args...)
+    return Run(deps, args), stdout.String(), stderr.String()
+  }
+  create := []string{"transfer", "create", "--recipient", "42", "--amount", "100000"}
+
+  code, _, stderr := confirm("n\n", create...)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Send 100,000 KRW to Kim Minjun (")
+  assert.Contains(t, stderr, " ****6789) for about 0.1")
+  assert.Contains(t, stderr, " BTC at 1,000,000 KRW/BTC? [y/N] ")
+  assert.Contains(t, stderr, "Not confirmed, nothing was done")
+  code, _, _ = confirm("", create...)
+  assert.Equal(t, 1, code, "no answer is no")
+  assert.Equal(t, 2, len(server.Fake.Transfers.Data))
+  code, _, stderr = confirm("", append(create, "--yes")...)
+  assert.Equal(t, 0, code, stderr)
+  assert.NotContains(t, stderr, "[y/N]")
+  assert.Equal(t, 3, len(server.Fake.Transfers.Data))
+
+  code, _, stderr = confirm("y\n", "transfer", "cancel", "tx_pending")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Cancel transfer tx_pending of 50,000 KRW to Lee Seoyeon? [y/N] ")
+  assert.Equal(t, bitwire.StatusCanceled, server.Fake.Transfers.Data[1].Status)
+
+  assert.Equal(t, "1,234,567.5", groupDigits("1234567.5"))
+  assert.Equal(t, "-100", groupDigits("-100"))
+  assert.Equal(t, "****6789", maskAccount("123-456-789"))
+}
+
+func TestEnvironments(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("envtest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  prefs := fmt.Sprintf(`{"environments": [
+    {"name": "staging", "base_url": %q, "mode": "sandbox"},
+    {"name": "partner", "base_url": %q, "credentials_from": "envtest:bitwire"},
+    {"name": "broken", "base_url": %q, "mode": "testnet"}]}`, server.APIURL(), server.APIURL(), server.APIURL())
+  assert.Nil(t, os.MkdirAll(filepath.Join(home, ConfDir), 0700))
+  assert.Nil(t, ioutil.WriteFile(filepath.Join(home, PrefsPath), []byte(prefs), 0600))
+
+  code, stdout, stderr := run(t, home, "--env", "staging", "banks")
+  assert.Equal(t, 0, code, stderr)
+  assert.NotEmpty(t, stdout)
+  _, err := os.Stat(filepath.Join(home, HistoryDir, "staging-calls.jsonl"))
+  assert.Nil(t, err, "the environment has its own store")
+  code, _, stderr = run(t, home, "--env", "staging", "whoami")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "staging.json")
+
+  code, stdout, stderr = run(t, home, "--env", "partner", "whoami")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "production")
+  assert.Contains(t, stdout, "partner")
+
+  code, _, stderr = run(t, home, "-s", "--env", "staging", "banks")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Use either --sandbox or --env")
+  code, _, stderr = run(t, home, "--env", "qa", "banks")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Unknown environment: qa")
+  assert.Contains(t, stderr, "staging, partner, broken")
+  code, _, stderr = run(t, home, "--env", "broken", "banks")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid mode of the broken environment: testnet")
+}
+
+func TestConfigNonInteractive(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  keyring := memKeyring{}
+  config := func(input string, args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: stdout, Stderr: stderr, Home: home, Keyring: keyring}
+    return Run(deps, append([]string{"bitwire", "-s", "--api-url", server.APIURL(), "config"}, args...)), stdout.String(), stderr.String()
+  }
+
+  code, _, stderr := config(login.Password+"\n", "--username", login.Username, "--password-stdin")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "--password-stdin needs --username, --client-id and --client-secret")
+
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+  code, stdout, stderr := config(login.Password, "--username", login.Username, "--client-id", login.ClientId, "--password-stdin")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "", stdout, "no prompts")
+  assert.Contains(t, stderr, "Configuration saved")
+  r := &runner{Deps: homeDeps(home)}
+  r.Keyring = keyring
+  conf, _, err := r.readConfig(string(bitwire.SANDBOX))
+  assert.Nil(t, err)
+  assert.Equal(t, login.ClientId, conf.ClientId)
+  assert.NotEmpty(t, conf.Token.AccessToken)
+
+  // Values not given are prompted for
+  code, stdout, stderr = config(login.Password+"\n"+login.ClientId+"\n", "--username", login.Username)
+  assert.Equal(t, 0, code, stderr)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "Password: Client ID: ")
+}
+
+func TestGet(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("gettest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  args := []string{"-s", "--api-url", server.APIURL(), "--credentials-from", "gettest:bitwire", "get"}
+
+  code, stdout, stderr := run(t, home, append(args, "transfers", "--status", "pending", "-o", "json")...)
+  assert.Equal(t, 0, code, stderr)
+  txs := []bitwire.Transfer{}
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &txs))
+  assert.Equal(t, 1, len(txs))
+  assert.Equal(t, "tx_pending", txs[0].Id)
+  code, stdout, _ = run(t, home, append(args, "transfers", "tx_completed", "-o", "json")...)
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"id": "tx_completed"`)
+
+  code, stdout, _ = run(t, home, append(args, "recipients", "--columns", "id,name", "-o", "csv")...)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "id,name\n42,Kim Minjun\n43,Lee Seoyeon\n", stdout)
+  code, stdout, _ = run(t, home, append(args, "recipients", "43", "-o", "yaml")...)
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, "email: seoyeon@example.com")
+
+  code, stdout, _ = run(t, home, append(args, "rates", "btckrw", "-o", "json")...)
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"BTCKRW": "1000000"`)
+  assert.NotContains(t, stdout, "USDKRW")
+  code, _, stderr = run(t, home, append(args, "rates", "ETHKRW")...)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "No rate for ETHKRW")
+
+  code, stdout, _ = run(t, home, append(args, "limits", "-o", "json")...)
+  assert.Equal(t, 0, code)
+  assert.Contains(t, stdout, `"left": "850000"`)
+  code, _, stderr = run(t, home, append(args, "limits", "x")...)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Unexpected argument: x")
+  code, _, stderr = run(t, home, append(args, "banks", "-o", "xml")...)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid output format: xml")
+}
+
+// Terminal reading the secrets in order without echo
+type secretTerminal struct {
+  fakeTerminal
+  secrets *[]string
+}
+
+func (t secretTerminal) ReadSecret(r io.Reader) (string, error) {
+  secret := (*t.secrets)[0]
+  *t.secrets = (*t.secrets)[1:]
+  return secret, nil
+}
+
+func TestConfigSecretInput(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  secrets := []string{login.Password, login.ClientSecret}
+  stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+  deps := Deps{Stdin: strings.NewReader(login.Username + "\n" + login.ClientId + "\n"), Stdout: stdout, Stderr: stderr,
+    Home: home, Terminal: secretTerminal{secrets: &secrets}, Keyring: memKeyring{}}
+  code := Run(deps, []string{"bitwire", "-s", "--api-url", server.APIURL(), "config"})
+  assert.Equal(t, 0, code, stderr.String())
+  assert.Empty(t, stdout.String())
+  assert.Contains(t, stderr.String(), "Username: Password: \nClient ID: Client secret: \n")
+  assert.Empty(t, secrets)
+  assert.NotContains(t, stderr.String(), login.Password)
+}
+
+func TestApply(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  file := filepath.Join(home, "organization.yaml")
+  write := func(content string) {
+    assert.Nil(t, ioutil.WriteFile(file, []byte(content), 0600))
+  }
+  write(`
+labels:
+  "42": [family]
+groups:
+  family: [42, 43]
+templates:
+  rent:
+    recipient: 42
+    amount: "500000"
+    schedule: monthly
+budgets:
+  family:
+    amount: 2000000
+    group: family
+`)
+  code, stdout, stderr := run(t, home, "-s", "apply", "-f", file, "--dry-run")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, "+ label 42\n+ group family\n+ template rent\n+ budget family\n", stdout)
+  _, err := os.Stat(filepath.Join(home, ConfDir, "sandbox-organization.json"))
+  assert.True(t, os.IsNotExist(err))
+
+  code, _, stderr = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 0, code, stderr)
+  r := &runner{Deps: homeDeps(home), mode: bitwire.SANDBOX}
+  org, err := r.readOrganization()
+  assert.Nil(t, err)
+  assert.Equal(t, "KRW", org.Templates["rent"].Currency)
+  assert.Equal(t, "monthly", org.Budgets["family"].Period)
+  code, stdout, _ = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "", stdout, "applying again changes nothing")
+
+  write(`
+templates:
+  rent: {recipient: 42, amount: "550000", schedule: monthly}
+`)
+  code, stdout, _ = run(t, home, "-s", "apply", "-f", file, "--merge")
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "~ template rent\n", stdout)
+  code, stdout, _ = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 0, code)
+  assert.Equal(t, "- label 42\n- group family\n- budget family\n", stdout)
+
+  write("budgets:\n  family: {amount: 100, group: friends}\n")
+  code, _, stderr = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "budgets: unknown group of family: friends")
+  write("templates:\n  rent: {recipient: 42, amount: 1, every: monthly}\n")
+  code, _, stderr = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "unknown field")
+}
+
+// Keyring failing like one of a headless server without Secret Service
+type lockedKeyring struct{ memKeyring }
+
+func (lockedKeyring) Set(service, user, secret string) error { return errors.New("keyring is locked") }
+
+func TestKeyring(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  config := func(keyring Keyring, args ...string) (int, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(login.Password), Stdout: stdout, Stderr: stderr, Home: home, Keyring: keyring}
+    args = append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)
+    args = append(args, "config", "--username", login.Username, "--client-id", login.ClientId, "--password-stdin")
+    return Run(deps, args), stderr.String()
+  }
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+  r := &runner{Deps: homeDeps(home)}
+  path := r.configPath(string(bitwire.SANDBOX))
+
+  keyring := memKeyring{}
+  code, stderr := config(keyring)
+  assert.Equal(t, 0, code, stderr)
+  data, _ := ioutil.ReadFile(path)
+  assert.Contains(t, string(data), `"keyring": true`)
+  assert.NotContains(t, string(data), login.ClientSecret)
+  info, _ := os.Stat(path)
+  assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+  r.Keyring = keyring
+  conf, inFile, err := r.readConfig(string(bitwire.SANDBOX))
+  assert.Nil(t, err)
+  assert.False(t, inFile)
+  assert.Equal(t, login.ClientSecret, conf.ClientSecret)
+  assert.NotEmpty(t, conf.Token.AccessToken)
+
+  // Secrets in the keyring are needed to read the config
+  r.Keyring = memKeyring{}
+  _, _, err = r.readConfig(string(bitwire.SANDBOX))
+  assert.Contains(t, err.Error(), "Could not read the credentials of sandbox from the OS keyring")
+
+  // Without a keyring, the secrets go to the file only with --insecure-file
+  os.Remove(path)
+  code, stderr = config(lockedKeyring{})
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Could not keep the credentials in the OS keyring: keyring is locked")
+  assert.Contains(t, stderr, "Use --insecure-file")
+  code, stderr = config(keyring, "--insecure-file")
+  assert.Equal(t, 0, code, stderr)
+  data, _ = ioutil.ReadFile(path)
+  assert.Contains(t, string(data), login.ClientSecret)
+  assert.Empty(t, keyring, "stale secrets removed")
+
+  // Secrets already in the file stay there when the keyring fails
+  code, stderr = config(lockedKeyring{})
+  assert.Equal(t, 0, code, stderr)
+  data, _ = ioutil.ReadFile(path)
+  assert.Contains(t, string(data), login.ClientSecret)
+}
+
+func TestRatesStream(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := bitwire.New(bitwire.SANDBOX)
+  assert.Nil(t, err)
+  client.BaseURL = server.APIURL()
+  stream := httptest.NewServer(newRateStream(client, time.Second))
+  defer stream.Close()
+
+  // Every client gets the latest rates from the shared polling
+  for i := 0; i < 2; i++ {
+    res, err := http.Get(stream.URL)
+    assert.Nil(t, err)
+    assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+    reader := bufio.NewReader(res.Body)
+    event, _ := reader.ReadString('\n')
+    data, _ := reader.ReadString('\n')
+    res.Body.Close()
+    assert.Equal(t, "event: rates\n", event)
+    rates := bitwire.AllRates{}
+    assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &rates))
+    assert.Equal(t, "1000000", rates.BTC["BTCKRW"].String())
+  }
+
+  res, err := http.Post(stream.URL, "text/plain", nil)
+  assert.Nil(t, err)
+  res.Body.Close()
+  assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  code, _, stderr := run(t, home, "listen", "--rates-stream", "--path", ratesStreamPath, "--secret", "s")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "The webhook path cannot be /rates/stream")
+}
+
+func TestProfiles(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  keyring := memKeyring{}
+  bw := func(input string, args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: stdout, Stderr: stderr, Home: home, Keyring: keyring}
+    return Run(deps, append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)), stdout.String(), stderr.String()
+  }
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+
+  code, _, stderr := bw(login.Password, "--profile", "business", "config", "--username", login.Username,
+    "--client-id", login.ClientId, "--password-stdin")
+  assert.Equal(t, 0, code, stderr)
+  _, err := os.Stat(filepath.Join(home, ProfilesDir, "business", "sandbox.json"))
+  assert.Nil(t, err)
+  assert.NotEmpty(t, keyring[KeyringService+"/business/sandbox"])
+
+  code, stdout, stderr := bw("", "--profile", "business", "whoami")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "business")
+  _, err = os.Stat(filepath.Join(home, ProfilesDir, "business", "history", "sandbox-calls.jsonl"))
+  assert.Nil(t, err, "the profile has its own store")
+
+  code, _, stderr = bw("", "whoami")
+  assert.Equal(t, 1, code, "the default profile is not configured")
+  assert.Contains(t, stderr, "sandbox.json")
+  code, _, stderr = bw("", "--profile", "../personal", "whoami")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid profile name: ../personal")
+}
+
+// Only data goes to stdout, so that it can be piped: banners, prompts, progress and warnings go to stderr
+func TestStdoutIsData(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("stdouttest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  for _, args := range [][]string{
+    {"rates"}, {"banks"}, {"limits"}, {"whoami"}, {"verification"}, {"dashboard"},
+    {"transfer", "list"}, {"transfer", "show", "tx_pending"}, {"recipient", "list"}, {"get", "recipients", "42"},
+    {"sync"}, {"--offline", "transfer", "list"}, {"stats"}, {"db", "purge", "--older-than", "10y"},
+    {"transfer", "create", "--recipient", "42", "--amount", "100000", "--yes"},
+  } {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    // A terminal stderr gets the banners
+    deps := Deps{Stdin: strings.NewReader("y\n"), Stdout: stdout, Stderr: stderr, Home: home, Terminal: fakeTerminal{},
+      Keyring: memKeyring{}}
+    code := Run(deps, append([]string{"bitwire", "-s", "-j", "--api-url", server.APIURL(),
+      "--credentials-from", "stdouttest:bitwire"}, args...))
+    name := strings.Join(args, " ")
+    assert.Equal(t, 0, code, name+": "+stderr.String())
+    assert.True(t, json.Valid(stdout.Bytes()), name+": "+stdout.String())
+    assert.Contains(t, stderr.String(), "Running in sandbox mode", name)
+  }
+
+  // Prompts of config and of the confirmations
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "config", "--username", "nobody")
+  assert.Equal(t, 1, code)
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "Password: ")
+  stdout2, stderr2 := new(bytes.Buffer), new(bytes.Buffer)
+  deps := Deps{Stdin: strings.NewReader("n\n"), Stdout: stdout2, Stderr: stderr2, Home: home, Keyring: memKeyring{}}
+  code = Run(deps, []string{"bitwire", "-s", "--api-url", server.APIURL(), "--credentials-from", "stdouttest:bitwire",
+    "transfer", "cancel", "tx_pending"})
+  assert.Equal(t, 1, code)
+  assert.Empty(t, stdout2.String())
+  assert.Contains(t, stderr2.String(), "Cancel transfer tx_pending")
+
+  code, stdout, stderr = run(t, home, "transfers-please")
+  assert.Empty(t, stdout)
+  assert.Contains(t, stderr, "Unrecognized command: transfers-please")
+}
+
+func TestConfigDir(t *testing.T) {
+  if runtime.GOOS == "windows" {
+    t.Skip("the user config directory is not set by environment variables")
+  }
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  for name, value := range map[string]string{"HOME": home, "XDG_CONFIG_HOME": filepath.Join(home, "xdg")} {
+    saved, set := os.LookupEnv(name)
+    os.Setenv(name, value)
+    if set {
+      defer os.Setenv(name, saved)
+    } else {
+      defer os.Unsetenv(name)
+    }
+  }
+  base, err := os.UserConfigDir()
+  assert.Nil(t, err)
+  legacy := filepath.Join(home, ConfDir)
+  assert.Nil(t, os.MkdirAll(legacy, 0700))
+  assert.Nil(t, ioutil.WriteFile(filepath.Join(legacy, "preferences.json"), []byte(`{"plain": true}`), 0600))
+
+  banks := func(args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: new(bytes.Buffer), Stdout: stdout, Stderr: stderr, Keyring: memKeyring{}, Terminal: fakeTerminal{}}
+    args = append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)
+    return Run(deps, append(args, "banks")), stdout.String(), stderr.String()
+  }
+  code, stdout, stderr := banks()
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Moved the config directory "+legacy+" to "+filepath.Join(base, "bitwire"))
+  assert.NotContains(t, stdout, "+--", "preferences read from the moved directory")
+  _, err = os.Stat(filepath.Join(base, "bitwire", "history", "sandbox-calls.jsonl"))
+  assert.Nil(t, err)
+  _, err = os.Stat(legacy)
+  assert.True(t, os.IsNotExist(err))
+
+  code, _, stderr = banks()
+  assert.Equal(t, 0, code, stderr)
+  assert.NotContains(t, stderr, "Moved")
+
+  assert.Nil(t, os.Rename(filepath.Join(base, "bitwire"), legacy))
+  code, _, stderr = banks("--no-banner")
+  assert.Equal(t, 0, code, stderr)
+  assert.NotContains(t, stderr, "Moved", "the notice is quiet")
+  _, err = os.Stat(legacy)
+  assert.True(t, os.IsNotExist(err))
+}
+
+// CI jobs run from environment variables alone, without a config file
+func TestEnvAuth(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  os.Setenv("BITWIRE_MODE", "sandbox")
+  defer os.Unsetenv("BITWIRE_MODE")
+  os.Setenv("BITWIRE_ACCESS_TOKEN", client.Token().AccessToken)
+  defer os.Unsetenv("BITWIRE_ACCESS_TOKEN")
+
+  code, stdout, stderr := run(t, home, "--api-url", server.APIURL(), "-j", "transfer", "show", "tx_pending")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "tx_pending")
+  for _, name := range []string{"sandbox.json", "production.json"} {
+    _, err := os.Stat(filepath.Join(home, ConfDir, name))
+    assert.True(t, os.IsNotExist(err), name+" is not written")
+  }
+
+  os.Setenv("BITWIRE_ACCESS_TOKEN", "revoked")
+  code, _, stderr = run(t, home, "--api-url", server.APIURL(), "transfer", "show", "tx_pending")
+  assert.Equal(t, 1, code)
+  assert.NotContains(t, stderr, "sandbox.json", "no config file is asked for")
+}
+
+func TestRunTime(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  keyring := memKeyring{}
+  bw := func(input string, args ...string) (int, string) {
+    stderr := new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: new(bytes.Buffer), Stderr: stderr, Home: home, Keyring: keyring}
+    return Run(deps, append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)), stderr.String()
+  }
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+  code, stderr := bw(login.Password, "config", "--username", login.Username, "--client-id", login.ClientId, "--password-stdin")
+  assert.Equal(t, 0, code, stderr)
+  stored := keyring[KeyringService+"/sandbox"]
+
+  // The token lasts an hour
+  code, stderr = bw("", "--run-time", "30m", "sync")
+  assert.Equal(t, 0, code, stderr)
+  assert.Equal(t, stored, keyring[KeyringService+"/sandbox"])
+  code, stderr = bw("", "--run-time", "3h", "sync")
+  assert.Equal(t, 0, code, stderr)
+  assert.NotEqual(t, stored, keyring[KeyringService+"/sandbox"], "refreshed before the run")
+}
+
+func TestExposure(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  code, _, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "exposure")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "No payout templates")
+
+  file := filepath.Join(home, "organization.yaml")
+  assert.Nil(t, ioutil.WriteFile(file, []byte(`
+templates:
+  rent: {recipient: 42, amount: "500000", schedule: monthly}
+  allowance: {recipient: 43, amount: "100000", schedule: weekly}
+  gift: {recipient: 43, amount: "50000"}
+`), 0600))
+  code, _, stderr = run(t, home, "-s", "apply", "-f", file)
+  assert.Equal(t, 0, code, stderr)
+
+  // At 1,000,000 KRW/BTC: a rent and 5 weekly allowances, started weeks counted whole
+  code, stdout, stderr := run(t, home, "-s", "--api-url", server.APIURL(), "-j", "exposure")
+  assert.Equal(t, 0, code, stderr)
+  exp := exposure{}
+  assert.Nil(t, json.Unmarshal([]byte(stdout), &exp))
+  assert.Len(t, exp.Templates, 2, "the on-demand gift is left out")
+  assert.Equal(t, "allowance", exp.Templates[0].Template)
+  assert.Equal(t, 5, exp.Templates[0].Payouts)
+  assert.Equal(t, "500000", exp.Templates[0].Amount.String())
+  assert.Equal(t, "1.00000000", exp.BTC.String())
+  assert.Equal(t, "1.05263158", exp.BTCRateDown.String())
+  assert.Equal(t, "0.95238096", exp.BTCRateUp.String())
+
+  code, stdout, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "exposure", "--days", "7", "--band", "10")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stdout, "-10%")
+  assert.Contains(t, stdout, "Next 7 days")
+  code, _, stderr = run(t, home, "-s", "--api-url", server.APIURL(), "exposure", "--band", "100")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Invalid --band: 100")
+
+  assert.Equal(t, 31, scheduledPayouts("daily", 31))
+  assert.Equal(t, 2, scheduledPayouts("monthly", 31))
+  assert.Equal(t, 0, scheduledPayouts("", 31))
+}
+
+func TestLogout(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  keyring := memKeyring{}
+  bw := func(input string, args ...string) (int, string) {
+    stderr := new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: new(bytes.Buffer), Stderr: stderr, Home: home, Keyring: keyring}
+    return Run(deps, append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)), stderr.String()
+  }
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+  for _, profile := range []string{"", "business"} {
+    code, stderr := bw(login.Password, "--profile", profile, "config", "--username", login.Username,
+      "--client-id", login.ClientId, "--password-stdin")
+    assert.Equal(t, 0, code, stderr)
+  }
+
+  code, stderr := bw("", "logout")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Logged out of sandbox")
+  _, err := os.Stat(filepath.Join(home, ConfDir, "sandbox.json"))
+  assert.True(t, os.IsNotExist(err))
+  assert.Empty(t, keyring[KeyringService+"/sandbox"])
+  assert.NotEmpty(t, keyring[KeyringService+"/business/sandbox"])
+  code, stderr = bw("", "whoami")
+  assert.Equal(t, 1, code)
+  code, stderr = bw("", "logout")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Not logged in to sandbox")
+
+  code, stderr = bw("", "logout", "--all-profiles")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Logged out of business/sandbox")
+  assert.Equal(t, bitwire.Token{}, server.Fake.Token(), "the token of business was revoked")
+  assert.Empty(t, keyring)
+  _, err = os.Stat(filepath.Join(home, ProfilesDir, "business", "sandbox.json"))
+  assert.True(t, os.IsNotExist(err))
+}
+
+// A rejected refresh token asks to log in again in a terminal, instead of failing until `bitwire config` is run
+func TestReauthenticate(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  login := server.LoginCredentials()
+  keyring := memKeyring{}
+  bw := func(terminal Terminal, input string, args ...string) (int, string) {
+    stderr := new(bytes.Buffer)
+    deps := Deps{Stdin: strings.NewReader(input), Stdout: new(bytes.Buffer), Stderr: stderr, Home: home, Keyring: keyring,
+      Terminal: terminal}
+    return Run(deps, append([]string{"bitwire", "-s", "--api-url", server.APIURL()}, args...)), stderr.String()
+  }
+  os.Setenv("BITWIRE_CLIENT_SECRET", login.ClientSecret)
+  defer os.Unsetenv("BITWIRE_CLIENT_SECRET")
+  code, stderr := bw(nil, login.Password, "config", "--username", login.Username, "--client-id", login.ClientId,
+    "--password-stdin")
+  assert.Equal(t, 0, code, stderr)
+  // An expired token, its refresh token rejected as the server issued another one since
+  kept := keyringSecrets{}
+  assert.Nil(t, json.Unmarshal([]byte(keyring[KeyringService+"/sandbox"]), &kept))
+  kept.Token.ValidUntil = time.Now().Unix() - 10
+  _, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  data, _ := json.Marshal(kept)
+  keyring[KeyringService+"/sandbox"] = string(data)
+
+  code, stderr = bw(nil, "", "whoami")
+  assert.Equal(t, 1, code, "no terminal to ask")
+  assert.Contains(t, stderr, "invalid_grant")
+
+  passwords := []string{login.Password}
+  code, stderr = bw(secretTerminal{secrets: &passwords}, login.Username+"\n", "whoami")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "The session has expired")
+  assert.Empty(t, passwords)
+  code, stderr = bw(nil, "", "whoami")
+  assert.Equal(t, 0, code, "the new token was saved: "+stderr)
+}
+
+func TestProjectFile(t *testing.T) {
+  home := tempHome(t)
+  defer os.RemoveAll(home)
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  secrets.Register("projecttest", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return staticCredentials{server.LoginCredentials()}, nil
+  })
+  repo := filepath.Join(home, "repo")
+  workDir := filepath.Join(repo, "scripts", "payouts")
+  assert.Nil(t, os.MkdirAll(workDir, 0700))
+  assert.Nil(t, os.MkdirAll(filepath.Join(repo, ".git"), 0700))
+  write := func(content string) {
+    assert.Nil(t, ioutil.WriteFile(filepath.Join(repo, ProjectFile), []byte(content), 0600))
+  }
+  bw := func(args ...string) (int, string, string) {
+    stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+    deps := Deps{Stdin: new(bytes.Buffer), Stdout: stdout, Stderr: stderr, Home: home, Keyring: memKeyring{}, WorkDir: workDir}
+    code := Run(deps, append([]string{"bitwire", "--api-url", server.APIURL(), "--credentials-from", "projecttest:bitwire"}, args...))
+    return code, stdout.String(), stderr.String()
+  }
+
+  write("env: sandbox\noutput: json\nguards:\n  sandbox_only: true\n  max_amount: {krw: 200000}\n")
+  assert.Equal(t, filepath.Join(repo, ProjectFile), findProjectFile(workDir))
+  code, stdout, stderr := bw("rates")
+  assert.Equal(t, 0, code, stderr)
+  assert.True(t, json.Valid([]byte(stdout)), stdout)
+  code, stdout, _ = bw("--output", "csv", "rates")
+  assert.Equal(t, 0, code)
+  assert.False(t, json.Valid([]byte(stdout)), "flags take precedence")
+
+  code, _, stderr = bw("transfer", "create", "--recipient", "42", "--amount", "500000", "--yes")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "The amount 500,000 KRW is over the maximum of 200,000 KRW")
+  code, _, stderr = bw("transfer", "create", "--recipient", "42", "--amount", "100000", "--yes")
+  assert.Equal(t, 0, code, stderr)
+  code, _, stderr = bw("--env", "production", "rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "allow the sandbox only")
+
+  write("env: sandbox\nteam: payouts\n")
+  code, _, stderr = bw("rates")
+  assert.Equal(t, 0, code, stderr)
+  assert.Contains(t, stderr, "Warning: ignoring settings")
+  write("version: 2\nenv: sandbox\n")
+  code, _, stderr = bw("rates")
+  assert.Equal(t, 1, code)
+  assert.Contains(t, stderr, "Upgrade bitwire")
+
+  // Not looked up past the git root
+  assert.Nil(t, os.Rename(filepath.Join(repo, ProjectFile), filepath.Join(home, ProjectFile)))
+  assert.Equal(t, "", findProjectFile(workDir))
+}
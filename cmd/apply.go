@@ -0,0 +1,247 @@
+package cmd
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sigs.k8s.io/yaml"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// Local conventions for organizing payouts, set by `bitwire apply` and kept per environment and profile,
+// as recipient IDs differ between them
+type organization struct {
+  Labels    map[string][]string       `json:"labels,omitempty"`    // By recipient ID
+  Groups    map[string][]int          `json:"groups,omitempty"`    // Recipient IDs by group name
+  Templates map[string]payoutTemplate `json:"templates,omitempty"` // By name
+  Budgets   map[string]budget         `json:"budgets,omitempty"`   // By name
+}
+
+// Payout made again and again, on demand or on a schedule
+type payoutTemplate struct {
+  Recipient int             `json:"recipient"`
+  Amount    bitwire.Decimal `json:"amount"`
+  Currency  string          `json:"currency"`
+  Schedule  string          `json:"schedule,omitempty"` // daily, weekly or monthly, on demand if empty
+}
+
+// KRW meant to be paid out per period, to the recipients of a group or in all
+type budget struct {
+  Amount bitwire.Decimal `json:"amount"`
+  Period string          `json:"period"` // weekly or monthly
+  Group  string          `json:"group,omitempty"`
+}
+
+var templateSchedules = map[string]bool{"": true, "daily": true, "weekly": true, "monthly": true}
+
+func (r *runner) organizationPath() string {
+  return r.profilePath(ConfDir + "/" + r.envName() + "-organization.json")
+}
+
+// Reads the applied organization. Missing file means nothing applied yet.
+func (r *runner) readOrganization() (organization, error) {
+  org := organization{}
+  data, err := ioutil.ReadFile(r.organizationPath())
+  if os.IsNotExist(err) {
+    return org, nil
+  } else if err != nil {
+    return org, err
+  }
+  return org, json.Unmarshal(data, &org)
+}
+
+func (r *runner) writeOrganization(org organization) error {
+  data, err := json.MarshalIndent(org, "", "  ")
+  if err != nil {
+    return err
+  }
+  if err := os.MkdirAll(filepath.Dir(r.organizationPath()), 0700); err != nil {
+    return err
+  }
+  return ioutil.WriteFile(r.organizationPath(), append(data, '\n'), 0600)
+}
+
+// Parses an organization file, YAML or JSON, rejecting unknown fields so that typos are not silently ignored
+func parseOrganization(data []byte) (organization, error) {
+  org := organization{}
+  if err := yaml.UnmarshalStrict(data, &org); err != nil {
+    return org, err
+  }
+  for name, t := range org.Templates {
+    t.Currency = strings.ToUpper(t.Currency)
+    if t.Currency == "" {
+      t.Currency = "KRW"
+    }
+    org.Templates[name] = t
+  }
+  for name, b := range org.Budgets {
+    if b.Period == "" {
+      b.Period = "monthly"
+    }
+    org.Budgets[name] = b
+  }
+  return org, nil
+}
+
+// Checks the organization as a whole, after a merge
+func (org organization) validate() error {
+  for id, labels := range org.Labels {
+    if n, err := strconv.Atoi(id); err != nil || n <= 0 {
+      return fmt.Errorf("labels: invalid recipient ID %s", id)
+    }
+    for _, label := range labels {
+      if strings.TrimSpace(label) == "" {
+        return fmt.Errorf("labels: empty label of recipient %s", id)
+      }
+    }
+  }
+  for name, ids := range org.Groups {
+    for _, id := range ids {
+      if id <= 0 {
+        return fmt.Errorf("groups: invalid recipient ID %d in %s", id, name)
+      }
+    }
+  }
+  for name, t := range org.Templates {
+    switch {
+    case t.Recipient <= 0:
+      return fmt.Errorf("templates: missing recipient of %s", name)
+    case t.Amount.Sign() <= 0:
+      return fmt.Errorf("templates: amount of %s must be positive", name)
+    case !templateSchedules[t.Schedule]:
+      return fmt.Errorf("templates: invalid schedule of %s: %s, expected daily, weekly or monthly", name, t.Schedule)
+    }
+  }
+  for name, b := range org.Budgets {
+    switch {
+    case b.Amount.Sign() <= 0:
+      return fmt.Errorf("budgets: amount of %s must be positive", name)
+    case b.Period != "weekly" && b.Period != "monthly":
+      return fmt.Errorf("budgets: invalid period of %s: %s, expected weekly or monthly", name, b.Period)
+    case b.Group != "" && org.Groups[b.Group] == nil:
+      return fmt.Errorf("budgets: unknown group of %s: %s", name, b.Group)
+    }
+  }
+  return nil
+}
+
+// Returns the entries of a section as JSON by key, to compare them
+func sectionEntries(section interface{}) map[string]string {
+  raw := map[string]json.RawMessage{}
+  data, _ := json.Marshal(section)
+  json.Unmarshal(data, &raw)
+  entries := make(map[string]string, len(raw))
+  for key, value := range raw {
+    entries[key] = string(value)
+  }
+  return entries
+}
+
+// Lists the created (+), changed (~) and removed (-) entries of the sections, sorted by key
+func organizationChanges(current, desired organization) []string {
+  var changes []string
+  sections := []struct {
+    name             string
+    current, desired interface{}
+  }{
+    {"label", current.Labels, desired.Labels},
+    {"group", current.Groups, desired.Groups},
+    {"template", current.Templates, desired.Templates},
+    {"budget", current.Budgets, desired.Budgets},
+  }
+  for _, s := range sections {
+    before, after := sectionEntries(s.current), sectionEntries(s.desired)
+    var lines []string
+    for key, value := range after {
+      if old, ok := before[key]; !ok {
+        lines = append(lines, fmt.Sprintf("+ %s %s", s.name, key))
+      } else if old != value {
+        lines = append(lines, fmt.Sprintf("~ %s %s", s.name, key))
+      }
+    }
+    for key := range before {
+      if _, ok := after[key]; !ok {
+        lines = append(lines, fmt.Sprintf("- %s %s", s.name, key))
+      }
+    }
+    sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+    changes = append(changes, lines...)
+  }
+  return changes
+}
+
+// Adds the entries of the patch to the organization, replacing those with the same keys
+func (org organization) merge(patch organization) organization {
+  merged := organization{map[string][]string{}, map[string][]int{}, map[string]payoutTemplate{}, map[string]budget{}}
+  for _, o := range []organization{org, patch} {
+    for k, v := range o.Labels {
+      merged.Labels[k] = v
+    }
+    for k, v := range o.Groups {
+      merged.Groups[k] = v
+    }
+    for k, v := range o.Templates {
+      merged.Templates[k] = v
+    }
+    for k, v := range o.Budgets {
+      merged.Budgets[k] = v
+    }
+  }
+  return merged
+}
+
+// Sets the labels, groups, templates and budgets declared in the file. Applying the same file again changes nothing.
+func (r *runner) applyAction(c *cli.Context) error {
+  path := c.String("f")
+  if path == "" {
+    return errors.New("Missing -f\nUsage: apply -f organization.yaml")
+  }
+  var data []byte
+  var err error
+  if path == "-" {
+    data, err = ioutil.ReadAll(r.Stdin)
+  } else {
+    data, err = ioutil.ReadFile(path)
+  }
+  if err != nil {
+    return err
+  }
+  desired, err := parseOrganization(data)
+  if err != nil {
+    return fmt.Errorf("%s: %s", path, err)
+  }
+  current, err := r.readOrganization()
+  if err != nil {
+    return err
+  }
+  if c.Bool("merge") {
+    desired = current.merge(desired)
+  }
+  if err := desired.validate(); err != nil {
+    return fmt.Errorf("%s: %s", path, err)
+  }
+  changes := organizationChanges(current, desired)
+  for _, change := range changes {
+    fmt.Fprintln(r.Stdout, change)
+  }
+  switch {
+  case len(changes) == 0:
+    r.printfInfo("No changes\n")
+    return nil
+  case c.Bool("dry-run"):
+    r.printfInfo("%d changes not applied (--dry-run)\n", len(changes))
+    return nil
+  }
+  if err := r.writeOrganization(desired); err != nil {
+    return err
+  }
+  r.printfInfo("Applied %d changes\n", len(changes))
+  return nil
+}
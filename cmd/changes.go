@@ -0,0 +1,48 @@
+package cmd
+
+import (
+  "encoding/json"
+  "github.com/dworznik/bitwire"
+  "time"
+)
+
+const listStateStore = "list-state"
+
+// Statuses of the transfers seen by `transfer list --changed-since-last-run`
+type listState struct {
+  Time     time.Time         `json:"time"`
+  Statuses map[string]string `json:"statuses"` // By transfer ID
+}
+
+// Returns the statuses seen by the last run, empty if there was none
+func (r *runner) lastListState() (listState, error) {
+  last := listState{}
+  err := r.readRecords(listStateStore, func(data []byte) error {
+    return json.Unmarshal(data, &last)
+  })
+  return last, err
+}
+
+// Returns the transfers that are new or whose status changed since the last run, and stores their statuses.
+// All the transfers are new on the first run.
+func (r *runner) changedTransfers(txs []bitwire.Transfer) ([]bitwire.Transfer, error) {
+  last, err := r.lastListState()
+  if err != nil {
+    return nil, err
+  }
+  next := listState{time.Now(), map[string]string{}}
+  for id, status := range last.Statuses {
+    next.Statuses[id] = status
+  }
+  var changed []bitwire.Transfer
+  for _, tx := range txs {
+    if status, ok := last.Statuses[tx.Id]; !ok || status != tx.Status {
+      changed = append(changed, tx)
+    }
+    next.Statuses[tx.Id] = tx.Status
+  }
+  if len(changed) == 0 {
+    return nil, nil
+  }
+  return changed, r.appendRecord(listStateStore, next)
+}
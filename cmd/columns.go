@@ -0,0 +1,141 @@
+package cmd
+
+import (
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+var defaultRecipientColumns = []string{"id", "name", "email", "bank", "account"}
+var recipientHeaders = map[string]string{"id": "ID", "name": "Name", "email": "Email", "bank": "Bank", "account": "Account"}
+
+func recipientFieldData(recipient bitwire.Recipient, field, lang string) string {
+  switch field {
+  case "id":
+    return strconv.Itoa(recipient.Id)
+  case "name":
+    return recipient.LocalName(lang)
+  case "email":
+    return recipient.Email
+  case "bank":
+    return recipient.Bank.DisplayName
+  case "account":
+    return recipient.Bank.AccountNumber
+  }
+  return ""
+}
+
+// Column and direction of --sort, e.g. date:desc
+type sortOrder struct {
+  Column string
+  Desc   bool
+}
+
+// Names of the columns in the headers map, sorted
+func columnNames(headers map[string]string) string {
+  names := make([]string, 0, len(headers))
+  for name := range headers {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return strings.Join(names, ", ")
+}
+
+// Parses the comma-separated --columns, in the order given. Unknown columns are an error, unlike with -f.
+func parseColumns(s string, headers map[string]string) ([]string, error) {
+  var columns []string
+  for _, column := range strings.Split(s, ",") {
+    column = strings.ToLower(strings.TrimSpace(column))
+    if column == "" {
+      continue
+    }
+    if headers[column] == "" {
+      return nil, fmt.Errorf("Unknown column: %s\nUse any of: %s", column, columnNames(headers))
+    }
+    columns = append(columns, column)
+  }
+  if len(columns) == 0 {
+    return nil, fmt.Errorf("No columns given\nUse any of: %s", columnNames(headers))
+  }
+  return columns, nil
+}
+
+// Parses --sort column[:asc|desc]. The order is ascending by default.
+func parseSortOrder(s string, headers map[string]string) (sortOrder, error) {
+  column, direction := s, "asc"
+  if i := strings.LastIndexByte(s, ':'); i >= 0 {
+    column, direction = s[:i], strings.ToLower(s[i+1:])
+  }
+  order := sortOrder{Column: strings.ToLower(strings.TrimSpace(column)), Desc: direction == "desc"}
+  if direction != "asc" && direction != "desc" {
+    return order, fmt.Errorf("Invalid sort direction: %s, expected asc or desc", direction)
+  }
+  if headers[order.Column] == "" {
+    return order, fmt.Errorf("Unknown sort column: %s\nUse any of: %s", order.Column, columnNames(headers))
+  }
+  return order, nil
+}
+
+// Sorts the rows with cmp, which compares rows i and j like strings.Compare, keeping the order of equal rows
+func (o sortOrder) sort(rows interface{}, cmp func(i, j int) int) {
+  sort.SliceStable(rows, func(i, j int) bool {
+    if o.Desc {
+      return cmp(i, j) > 0
+    }
+    return cmp(i, j) < 0
+  })
+}
+
+// Returns when the transfer was created, from the creation time or else the date string
+func transferTime(tx bitwire.Transfer) time.Time {
+  if !tx.CreatedAt.IsZero() {
+    return tx.CreatedAt
+  }
+  t, _ := time.Parse(time.RFC3339, tx.Date)
+  return t
+}
+
+func compareTimes(a, b time.Time) int {
+  switch {
+  case a.Before(b):
+    return -1
+  case a.After(b):
+    return 1
+  }
+  return 0
+}
+
+// Sorts amounts and times by value and the other columns by their text, ignoring case
+func sortTransfers(txs []bitwire.Transfer, order sortOrder, lang string, mode bitwire.Mode) {
+  order.sort(txs, func(i, j int) int {
+    a, b := txs[i], txs[j]
+    switch order.Column {
+    case "sent":
+      return a.Amount.Cmp(b.Amount)
+    case "received":
+      return a.Recipient.Amount.Cmp(b.Recipient.Amount)
+    case "date", "created":
+      return compareTimes(transferTime(a), transferTime(b))
+    case "expires":
+      return compareTimes(a.ExpiresAt(), b.ExpiresAt())
+    }
+    now := time.Now()
+    return strings.Compare(strings.ToLower(fieldData(a, order.Column, lang, mode, now)),
+      strings.ToLower(fieldData(b, order.Column, lang, mode, now)))
+  })
+}
+
+// Sorts IDs as numbers and the other columns by their text, ignoring case
+func sortRecipients(recipients []bitwire.Recipient, order sortOrder, lang string) {
+  order.sort(recipients, func(i, j int) int {
+    a, b := recipients[i], recipients[j]
+    if order.Column == "id" {
+      return a.Id - b.Id
+    }
+    return strings.Compare(strings.ToLower(recipientFieldData(a, order.Column, lang)),
+      strings.ToLower(recipientFieldData(b, order.Column, lang)))
+  })
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+  "fmt"
+  "github.com/dworznik/cli"
+  "io"
+  "strings"
+)
+
+// Description of a command for tooling
+type commandInfo struct {
+  Name        string        `json:"name"`
+  Aliases     []string      `json:"aliases,omitempty"`
+  Usage       string        `json:"usage"`
+  ArgsUsage   string        `json:"args_usage,omitempty"`
+  Description string        `json:"description,omitempty"`
+  Flags       []flagInfo    `json:"flags,omitempty"`
+  Commands    []commandInfo `json:"commands,omitempty"`
+}
+
+// Description of a flag for tooling
+type flagInfo struct {
+  Name    string   `json:"name"`
+  Aliases []string `json:"aliases,omitempty"`
+  Type    string   `json:"type"`
+  Usage   string   `json:"usage"`
+  EnvVar  string   `json:"env_var,omitempty"`
+}
+
+// Splits cli's "name, alias" flag and command names
+func splitNames(names string) (string, []string) {
+  parts := strings.Split(names, ",")
+  for i := range parts {
+    parts[i] = strings.TrimSpace(parts[i])
+  }
+  return parts[0], parts[1:]
+}
+
+func describeFlag(f cli.Flag) flagInfo {
+  info := flagInfo{}
+  var names string
+  switch v := f.(type) {
+  case cli.BoolFlag:
+    names, info.Type, info.Usage, info.EnvVar = v.Name, "bool", v.Usage, v.EnvVar
+  case cli.StringFlag:
+    names, info.Type, info.Usage, info.EnvVar = v.Name, "string", v.Usage, v.EnvVar
+  case cli.IntFlag:
+    names, info.Type, info.Usage, info.EnvVar = v.Name, "int", v.Usage, v.EnvVar
+  case cli.DurationFlag:
+    names, info.Type, info.Usage, info.EnvVar = v.Name, "duration", v.Usage, v.EnvVar
+  case cli.StringSliceFlag:
+    names, info.Type, info.Usage, info.EnvVar = v.Name, "string_slice", v.Usage, v.EnvVar
+  default:
+    names, info.Type, info.Usage = f.GetName(), "unknown", f.String()
+  }
+  info.Name, info.Aliases = splitNames(names)
+  return info
+}
+
+func describeFlags(flags []cli.Flag) []flagInfo {
+  var infos []flagInfo
+  for _, f := range flags {
+    infos = append(infos, describeFlag(f))
+  }
+  return infos
+}
+
+func describeCommands(commands []cli.Command) []commandInfo {
+  var infos []commandInfo
+  for _, cmd := range commands {
+    if cmd.Hidden {
+      continue
+    }
+    infos = append(infos, commandInfo{
+      Name:        cmd.Name,
+      Aliases:     cmd.Aliases,
+      Usage:       cmd.Usage,
+      ArgsUsage:   cmd.ArgsUsage,
+      Description: cmd.Description,
+      Flags:       describeFlags(cmd.Flags),
+      Commands:    describeCommands(cmd.Subcommands),
+    })
+  }
+  return infos
+}
+
+// Describes the whole command tree of the app, starting with the global flags
+func describeApp(app *cli.App) commandInfo {
+  return commandInfo{
+    Name:     app.Name,
+    Usage:    app.Usage,
+    Flags:    describeFlags(app.Flags),
+    Commands: describeCommands(app.Commands),
+  }
+}
+
+// Prints the command tree as an indented list
+func printCommandTree(w io.Writer, commands []commandInfo, indent string) {
+  for _, cmd := range commands {
+    fmt.Fprintf(w, "%s%s\t%s\n", indent, strings.TrimSpace(cmd.Name+" "+cmd.ArgsUsage), cmd.Usage)
+    for _, f := range cmd.Flags {
+      fmt.Fprintf(w, "%s  --%s (%s)\t%s\n", indent, f.Name, f.Type, f.Usage)
+    }
+    printCommandTree(w, cmd.Commands, indent+"  ")
+  }
+}
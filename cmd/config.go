@@ -0,0 +1,380 @@
+package cmd
+
+import (
+  "bufio"
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/secrets"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+const (
+  ConfDir         = ".bitwire"
+  ConfPath        = ConfDir + "/" + "production.json"
+  SandboxConfPath = ConfDir + "/" + "sandbox.json"
+  PrefsPath       = ConfDir + "/" + "preferences.json"
+  HistoryDir      = ConfDir + "/" + "history"
+  JournalDir      = ConfDir + "/" + "journal"
+  ErrorLogPath    = ConfDir + "/" + "errors.log"
+  ProfilesDir     = ConfDir + "/" + "profiles"
+)
+
+// Service of the OS keyring items, one per environment named after it
+const KeyringService = "bitwire"
+
+// Contents of a config file. With Keyring set, the client secret and the token are kept in the OS keyring
+// and are empty in the file.
+type storedConfig struct {
+  bitwire.Config
+  Keyring bool `json:"keyring,omitempty"`
+}
+
+// Secrets of a config kept in the OS keyring
+type keyringSecrets struct {
+  ClientSecret string        `json:"client_secret"`
+  Token        bitwire.Token `json:"token"`
+}
+
+// CLI preferences shared by both modes
+type preferences struct {
+  QuietBanner bool   `json:"quiet_banner"` // Do not print the mode banner
+  Retention   string `json:"retention"`    // Age of the local store records removed after each command, e.g. 2y
+  Plain       bool   `json:"plain"`        // Print output as with --plain
+
+  Environments []environment `json:"environments,omitempty"` // Selected with --env
+}
+
+// Named API environment beyond production and sandbox, e.g. a staging server or a partner gateway.
+// Its credentials are kept by `bitwire --env <name> config`, in the OS keyring or ~/.bitwire/<name>.json, or read from CredentialsFrom.
+type environment struct {
+  Name            string       `json:"name"`
+  BaseURL         string       `json:"base_url"`
+  Mode            bitwire.Mode `json:"mode,omitempty"`             // Rules of the API, e.g. testnet addresses in sandbox. Production if empty.
+  CredentialsFrom string       `json:"credentials_from,omitempty"` // As with --credentials-from
+}
+
+// Returns the environment with the name, checking its definition
+func (p preferences) environment(name string) (environment, error) {
+  var names []string
+  for _, env := range p.Environments {
+    if env.Name != name {
+      names = append(names, env.Name)
+      continue
+    }
+    if !validName(env.Name) || env.Name == "preferences" {
+      return env, fmt.Errorf("Invalid environment name: %s, use letters, digits, - and _", env.Name)
+    }
+    if env.BaseURL == "" {
+      return env, fmt.Errorf("Missing base_url of the %s environment in %s", env.Name, PrefsPath)
+    }
+    switch env.Mode {
+    case "":
+      env.Mode = bitwire.PRODUCTION
+    case bitwire.PRODUCTION, bitwire.SANDBOX:
+    default:
+      return env, fmt.Errorf("Invalid mode of the %s environment: %s, expected production or sandbox", env.Name, env.Mode)
+    }
+    return env, nil
+  }
+  return environment{}, fmt.Errorf("Unknown environment: %s\nUse production, sandbox or one defined in %s: %s",
+    name, PrefsPath, strings.Join(names, ", "))
+}
+
+// Tells if the name of an environment or profile has only letters, digits, - and _, as it names files
+func validName(name string) bool {
+  return name != "" && strings.Trim(strings.ToLower(name), "abcdefghijklmnopqrstuvwxyz0123456789-_") == ""
+}
+
+// Returns the names of the built-in and defined environments
+func (r *runner) envNames() []string {
+  names := []string{string(bitwire.PRODUCTION), string(bitwire.SANDBOX)}
+  for _, env := range r.prefs.Environments {
+    names = append(names, env.Name)
+  }
+  return names
+}
+
+// Returns the name of the selected environment, which names its config file and local store
+func (r *runner) envName() string {
+  if r.env != "" {
+    return r.env
+  }
+  return string(r.mode)
+}
+
+// Selects the mode and environment of --sandbox or --env. A defined environment sets the API URL and
+// the credentials secret unless --api-url or --credentials-from are set.
+func (r *runner) selectEnv() error {
+  name := r.envFlag
+  if r.sandbox {
+    if name != "" && name != string(bitwire.SANDBOX) {
+      return errors.New("Use either --sandbox or --env")
+    }
+    name = string(bitwire.SANDBOX)
+  }
+  switch name {
+  case "", string(bitwire.PRODUCTION):
+    r.printfInfo("Running in production mode\n")
+    return nil
+  case string(bitwire.SANDBOX):
+    r.mode = bitwire.SANDBOX
+    r.printfInfo("Running in sandbox mode\n")
+    return nil
+  }
+  env, err := r.prefs.environment(name)
+  if err != nil {
+    return err
+  }
+  r.env, r.mode = env.Name, env.Mode
+  if r.apiURL == "" {
+    r.apiURL = env.BaseURL
+  }
+  if r.credsFrom == "" {
+    r.credsFrom = env.CredentialsFrom
+  }
+  r.printfInfo("Running in the %s environment, in %s mode\n", env.Name, env.Mode)
+  return nil
+}
+
+// Reads the preferences file. Missing file means default preferences.
+func (r *runner) readPreferences() (preferences, error) {
+  prefs := preferences{}
+  data, err := ioutil.ReadFile(r.confPath(PrefsPath))
+  if os.IsNotExist(err) {
+    return prefs, nil
+  } else if err != nil {
+    return prefs, err
+  } else {
+    err := json.Unmarshal(data, &prefs)
+    return prefs, err
+  }
+}
+
+// Returns the path of account data, given relative to the home directory like HistoryDir. The data of a --profile
+// is kept in its own directory in ProfilesDir, e.g. ~/.bitwire/profiles/business/history for HistoryDir.
+func (r *runner) profilePath(path string) string {
+  if r.profile != "" {
+    path = ProfilesDir + "/" + r.profile + "/" + strings.TrimPrefix(path, ConfDir+"/")
+  }
+  return r.confPath(path)
+}
+
+// Selects the profile of --profile, checking its name
+func (r *runner) selectProfile() error {
+  if r.profile != "" && !validName(r.profile) {
+    return fmt.Errorf("Invalid profile name: %s, use letters, digits, - and _", r.profile)
+  }
+  if r.profile != "" {
+    r.printfInfo("Using the %s profile\n", r.profile)
+  }
+  return nil
+}
+
+// Returns the config file of the environment in the profile: ConfPath, SandboxConfPath, or <name>.json next to them
+func (r *runner) configPath(env string) string {
+  if env == "" {
+    panic("Missing environment")
+  }
+  return r.profilePath(ConfDir + "/" + env + ".json")
+}
+
+// Returns the user of the keyring item of the environment in the profile
+func (r *runner) keyringUser(env string) string {
+  if r.profile != "" {
+    return r.profile + "/" + env
+  }
+  return env
+}
+
+// Reads a line of stdin without echo when it is a terminal that supports it, and like other input otherwise
+func (r *runner) readSecret() string {
+  if terminal, ok := r.Terminal.(SecretReader); ok {
+    secret, err := terminal.ReadSecret(r.Stdin)
+    if err == nil {
+      fmt.Fprintln(r.Stderr) // The newline typed was not echoed either
+      return secret
+    } else if err != ErrNoSecretInput {
+      r.printfErr("Could not read without echo: %s\n", err)
+    }
+  }
+  secret, _ := readStdin(r.stdinReader())
+  return secret
+}
+
+func readStdin(reader *bufio.Reader) (string, error) {
+  val, err := reader.ReadString('\n')
+  if err != nil {
+    return val, err
+  } else {
+    return strings.TrimRight(val, "\n"), nil
+  }
+}
+
+// Prompts for the username and password when the refresh token was rejected, to log in again without `bitwire config`.
+// The new token is saved after the command like a refreshed one.
+func (r *runner) reauthenticate(err error) (bitwire.LoginCredentials, error) {
+  r.printfErr("%sThe session has expired (%s), log in again%s\n", YELLOW, err, RESET)
+  fmt.Fprint(r.Stderr, "Username: ")
+  username, _ := readStdin(r.stdinReader())
+  fmt.Fprint(r.Stderr, "Password: ")
+  password := r.readSecret()
+  if username == "" || password == "" {
+    return bitwire.LoginCredentials{}, errors.New("Missing username or password")
+  }
+  creds := bitwire.Credentials{r.conf.ClientId, r.conf.ClientSecret, "password"}
+  return bitwire.LoginCredentials{creds, username, password}, nil
+}
+
+// Values of `config` given by flags or environment variables. The missing ones are prompted for.
+type configValues struct {
+  Username      string
+  Password      string
+  ClientId      string
+  ClientSecret  string
+  PasswordStdin bool // Read the password from the first line of stdin, with no prompts
+}
+
+func (r *runner) config(mode bitwire.Mode, given configValues) (bitwire.Config, bitwire.LoginCredentials, error) {
+  r.printfErr("Configuring bitwire in %s mode\n", mode)
+  reader := r.stdinReader()
+  username, password, clientId, clientSecret := given.Username, given.Password, given.ClientId, given.ClientSecret
+  if given.PasswordStdin {
+    if password != "" {
+      return bitwire.Config{}, bitwire.LoginCredentials{}, errors.New("Use either --password-stdin or BITWIRE_PASSWORD")
+    }
+    if username == "" || clientId == "" || clientSecret == "" {
+      return bitwire.Config{}, bitwire.LoginCredentials{}, errors.New("--password-stdin needs --username, --client-id and --client-secret," +
+        " or BITWIRE_USERNAME, BITWIRE_CLIENT_ID and BITWIRE_CLIENT_SECRET")
+    }
+    line, _ := reader.ReadString('\n')
+    if password = strings.TrimRight(line, "\r\n"); password == "" {
+      return bitwire.Config{}, bitwire.LoginCredentials{}, errors.New("Missing password on stdin")
+    }
+  }
+  prompt := func(label string, value *string, secret bool) {
+    if *value == "" {
+      fmt.Fprint(r.Stderr, label+": ")
+      if secret {
+        *value = r.readSecret()
+      } else {
+        *value, _ = readStdin(reader)
+      }
+    }
+  }
+  prompt("Username", &username, false)
+  prompt("Password", &password, true)
+  prompt("Client ID", &clientId, false)
+  prompt("Client secret", &clientSecret, true)
+  tokenCreds := bitwire.Credentials{clientId, clientSecret, "refresh_token"}
+  passwordCreds := bitwire.Credentials{clientId, clientSecret, "password"}
+  conf := bitwire.Config{tokenCreds, bitwire.Token{}}
+  login := bitwire.LoginCredentials{passwordCreds, username, password}
+  return conf, login, nil
+}
+
+// Reads the config of the environment, with the secrets from the OS keyring if they are kept there.
+// inFile tells if the secrets were in the file.
+func (r *runner) readConfig(env string) (config bitwire.Config, inFile bool, err error) {
+  data, err := ioutil.ReadFile(r.configPath(env))
+  if err != nil {
+    return bitwire.Config{}, false, err
+  }
+  stored := storedConfig{}
+  if err := json.Unmarshal(data, &stored); err != nil {
+    return bitwire.Config{}, false, err
+  }
+  if !stored.Keyring {
+    return stored.Config, true, nil
+  }
+  secret, err := r.Keyring.Get(KeyringService, r.keyringUser(env))
+  if err != nil {
+    return bitwire.Config{}, false, fmt.Errorf("Could not read the credentials of %s from the OS keyring: %s\n"+
+      "Run `bitwire config` again, with --insecure-file if there is no keyring", env, err)
+  }
+  kept := keyringSecrets{}
+  if err := json.Unmarshal([]byte(secret), &kept); err != nil {
+    return bitwire.Config{}, false, fmt.Errorf("Invalid credentials of %s in the OS keyring: %s", env, err)
+  }
+  stored.ClientSecret, stored.Token = kept.ClientSecret, kept.Token
+  return stored.Config, false, nil
+}
+
+// Writes the config of the environment, keeping the client secret and the token in the OS keyring unless
+// --insecure-file is set. Secrets already in the file stay there when the keyring cannot be used.
+func (r *runner) writeConfig(config bitwire.Config, env string) error {
+  configPath := r.configPath(env)
+  stored := storedConfig{config, false}
+  if r.insecure {
+    r.Keyring.Delete(KeyringService, r.keyringUser(env)) // Not to leave stale secrets behind
+  } else if err := r.writeKeyring(config, env); err == nil {
+    stored = storedConfig{bitwire.Config{bitwire.Credentials{config.ClientId, "", config.GrantType}, bitwire.Token{}}, true}
+  } else if r.confFile {
+    r.printfInfo("Could not keep the credentials in the OS keyring: %s\nThey stay in %s\n", err, configPath)
+  } else {
+    return fmt.Errorf("Could not keep the credentials in the OS keyring: %s\n"+
+      "Use --insecure-file to keep them in %s, readable only by you", err, configPath)
+  }
+  if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+    return err
+  }
+  file, err := os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  if err := file.Chmod(0600); err != nil { // Written 0666 by earlier versions
+    return err
+  }
+  str, err := formatJson(stored)
+  if err != nil {
+    return err
+  }
+  _, err = file.WriteString(str)
+  return err
+}
+
+func (r *runner) writeKeyring(config bitwire.Config, env string) error {
+  data, err := json.Marshal(keyringSecrets{config.ClientSecret, config.Token})
+  if err != nil {
+    return err
+  }
+  return r.Keyring.Set(KeyringService, r.keyringUser(env), string(data))
+}
+
+// Returns the error for commands that need credentials when the config file could not be read
+func configError(confErr error) error {
+  if confErr != nil {
+    return confErr
+  } else {
+    return errors.New("Configuration error")
+  }
+}
+
+// Creates a client authenticated with the credentials of the --credentials-from secret.
+// The token is kept in memory only, so that nothing is written to the config file.
+func (r *runner) newProvidedClient() (*bitwire.Client, error) {
+  ctx := context.Background()
+  provider, err := secrets.Open(ctx, r.credsFrom)
+  if err != nil {
+    return nil, err
+  }
+  creds, err := provider.Credentials(ctx)
+  if err != nil {
+    return nil, err
+  }
+  c, err := r.NewClient(r.mode, bitwire.Config{})
+  if err != nil {
+    return nil, err
+  }
+  r.client = r.setupClient(c)
+  if _, err := c.Authenticate(creds); err != nil {
+    return nil, err
+  }
+  return c, nil
+}
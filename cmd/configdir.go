@@ -0,0 +1,57 @@
+package cmd
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// Returns bitwire in the user config directory: $XDG_CONFIG_HOME or ~/.config on Linux, ~/Library/Application Support
+// on macOS and %AppData% on Windows, and the legacy ~/.bitwire to move there if it exists and the new one does not.
+// Falls back to ~/.bitwire if there is no user config directory.
+func defaultConfigDir() (dir string, legacy string) {
+  home, err := os.UserHomeDir()
+  if err == nil {
+    legacy = filepath.Join(home, ConfDir)
+  }
+  base, err := os.UserConfigDir()
+  if err != nil {
+    return legacy, ""
+  }
+  dir = filepath.Join(base, "bitwire")
+  if legacy == "" {
+    return dir, ""
+  }
+  if _, err := os.Stat(legacy); err != nil {
+    return dir, ""
+  }
+  if _, err := os.Stat(dir); err == nil {
+    return dir, ""
+  }
+  return dir, legacy
+}
+
+// Moves the legacy config directory to the default one, or keeps using it if it cannot be moved.
+// Returns the moved directory, empty if none.
+func (r *runner) migrateConfigDir() string {
+  if r.legacyConfigDir == "" {
+    return ""
+  }
+  legacy := r.legacyConfigDir
+  r.legacyConfigDir = ""
+  err := os.MkdirAll(filepath.Dir(r.ConfigDir), 0700)
+  if err == nil {
+    err = os.Rename(legacy, r.ConfigDir)
+  }
+  if err != nil {
+    r.printfErr("Could not move %s to %s, still using it: %s\n", legacy, r.ConfigDir, err)
+    r.ConfigDir = legacy
+    return ""
+  }
+  return legacy
+}
+
+// Returns the path of a file in the config directory, given relative to the home directory like PrefsPath
+func (r *runner) confPath(path string) string {
+  return filepath.Join(r.ConfigDir, filepath.FromSlash(strings.TrimPrefix(path, ConfDir+"/")))
+}
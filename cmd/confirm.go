@@ -0,0 +1,96 @@
+package cmd
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "strings"
+)
+
+var errNotConfirmed = errors.New("Not confirmed, nothing was done\nUse --yes to skip the confirmation, e.g. in scripts")
+
+// Reader of stdin shared by the prompts of a run, so that input buffered by one is not lost to the next
+func (r *runner) stdinReader() *bufio.Reader {
+  if r.stdin == nil {
+    r.stdin = bufio.NewReader(r.Stdin)
+  }
+  return r.stdin
+}
+
+// Asks the question on stderr and tells if the answer is yes. No answer, e.g. at the end of piped input, is no.
+func (r *runner) confirm(question string) bool {
+  r.printfErr("%s [y/N] ", question)
+  answer, _ := readStdin(r.stdinReader())
+  answer = strings.ToLower(strings.TrimSpace(answer))
+  return answer == "y" || answer == "yes"
+}
+
+// Groups the digits of the integer part of a decimal number by thousands, e.g. 1,000,000.5
+func groupDigits(s string) string {
+  sign, frac := "", ""
+  if strings.HasPrefix(s, "-") {
+    sign, s = "-", s[1:]
+  }
+  if i := strings.IndexByte(s, '.'); i >= 0 {
+    s, frac = s[:i], s[i:]
+  }
+  for i := len(s) - 3; i > 0; i -= 3 {
+    s = s[:i] + "," + s[i:]
+  }
+  return sign + s + frac
+}
+
+// Masks all but the last 4 digits of a bank account number
+func maskAccount(number string) string {
+  digits := strings.Map(func(c rune) rune {
+    if c >= '0' && c <= '9' {
+      return c
+    }
+    return -1
+  }, number)
+  if len(digits) > 4 {
+    digits = digits[len(digits)-4:]
+  }
+  return "****" + digits
+}
+
+// Asks to confirm the new transfer, showing the recipient and the estimated BTC of a btc-to-bank transfer
+func (r *runner) confirmCreate(client *bitwire.Client, trans bitwire.CreateTransfer) error {
+  amount := groupDigits(trans.Amount.String()) + " " + trans.Currency
+  question := fmt.Sprintf("Buy BTC to %s with a bank deposit of %s?", trans.Address, amount)
+  if trans.Type == bitwire.TypeBtcToBank {
+    recipient, err := r.resolveRecipient(client, fmt.Sprintf("%d", trans.RecipientId))
+    if err != nil {
+      return err
+    }
+    estimate, err := client.Transfers.Estimate(trans.Amount, trans.Currency)
+    if err != nil {
+      return err
+    }
+    question = fmt.Sprintf("Send %s to %s (%s %s) for about %s BTC at %s %s/BTC?", amount, recipient.LocalName(r.lang),
+      recipient.Bank.DisplayName, maskAccount(recipient.Bank.AccountNumber), estimate.BTC, groupDigits(estimate.Rate.String()),
+      estimate.Currency)
+  }
+  if !r.confirm(question) {
+    return errNotConfirmed
+  }
+  return nil
+}
+
+// Asks to confirm canceling the transfer, showing its amount and recipient
+func (r *runner) confirmCancel(client *bitwire.Client, id string) error {
+  tx, err := client.Transfers.Get(id)
+  if err != nil {
+    return err
+  }
+  question := fmt.Sprintf("Cancel transfer %s of %s BTC?", tx.Id, tx.Amount)
+  if tx.Type != bitwire.TypeBankToBtc {
+    question = fmt.Sprintf("Cancel transfer %s of %s %s to %s?", tx.Id, groupDigits(tx.Recipient.Amount.String()),
+      tx.Recipient.Currency, tx.Recipient.LocalName(r.lang))
+  }
+  if !r.confirm(question) {
+    return errNotConfirmed
+  }
+  return nil
+}
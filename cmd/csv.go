@@ -0,0 +1,106 @@
+package cmd
+
+import (
+  "encoding/csv"
+  "github.com/dworznik/bitwire"
+  "time"
+)
+
+// Values of --output
+const (
+  outputFormatTable  = "table"
+  outputFormatJSON   = "json"
+  outputFormatYAML   = "yaml"
+  outputFormatNDJSON = "ndjson" // One JSON object per line
+  outputFormatCSV    = "csv"
+)
+
+// Writes the rows as CSV records after a header row, for --output csv.
+// Tables of label and value rows without a header get a Field,Value one. Blank separator rows are left out.
+type csvTable struct {
+  w      *csv.Writer
+  header []string
+  rows   [][]string
+}
+
+func (t *csvTable) SetHeader(keys []string) { t.header = keys }
+func (t *csvTable) SetRowLine(line bool)    {}
+func (t *csvTable) SetAlignment(align int)  {}
+func (t *csvTable) Append(row []string)     { t.rows = append(t.rows, row) }
+
+func (t *csvTable) Render() {
+  header := t.header
+  if header == nil && len(t.rows) > 0 && len(t.rows[0]) == 2 {
+    header = []string{"Field", "Value"}
+  }
+  if header != nil {
+    t.w.Write(header)
+  }
+  for _, row := range t.rows {
+    if !blankRow(row) {
+      t.w.Write(row)
+    }
+  }
+  t.w.Flush()
+}
+
+func blankRow(row []string) bool {
+  for _, value := range row {
+    if value != "" {
+      return false
+    }
+  }
+  return true
+}
+
+// Returns the transfer fields of a CSV, with the currency after the received amount unless it is already there,
+// since CSV amounts have no currency
+func csvFields(fields []string) []string {
+  var withCurrency []string
+  for _, f := range fields {
+    if f == "currency" {
+      return fields
+    }
+  }
+  for _, f := range fields {
+    withCurrency = append(withCurrency, f)
+    if f == "received" {
+      withCurrency = append(withCurrency, "currency")
+    }
+  }
+  return withCurrency
+}
+
+// Returns the value of the field for a CSV: timestamps are RFC 3339 and amounts are plain numbers
+func csvFieldData(transfer bitwire.Transfer, field, lang string, mode bitwire.Mode, now time.Time) string {
+  switch field {
+  case "sent":
+    return transfer.Amount.String()
+  case "received":
+    return transfer.Recipient.Amount.String()
+  case "rate":
+    if transfer.Recipient.Amount.Sign() <= 0 || transfer.Amount.Sign() <= 0 {
+      return ""
+    }
+    return transfer.Recipient.Amount.QuoUp(transfer.Amount, bitwire.CurrencyScale(transfer.Recipient.Currency)).String()
+  case "date", "created":
+    if transfer.CreatedAt.IsZero() {
+      return transfer.Date
+    }
+    return transfer.CreatedAt.UTC().Format(time.RFC3339)
+  case "expires":
+    if expires := transfer.ExpiresAt(); !expires.IsZero() {
+      return expires.UTC().Format(time.RFC3339)
+    }
+    return ""
+  }
+  return fieldData(transfer, field, lang, mode, now)
+}
+
+func csvTransferData(transfer bitwire.Transfer, fields []string, lang string, mode bitwire.Mode, now time.Time) []string {
+  var values []string
+  for _, f := range fields {
+    values = append(values, csvFieldData(transfer, f, lang, mode, now))
+  }
+  return values
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
+  "net/http"
+  "time"
+)
+
+var tableInFlightHeader = []string{"In flight", "Value"}
+
+func (r *runner) dashboardAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  inFlight, err := client.Transfers.InFlight()
+  if err != nil {
+    return err
+  }
+  return r.printOut(inFlight, r.json)
+}
+
+// Serves the API call and in flight metrics of the account at /metrics on the mux,
+// updating the in flight gauges every interval until the process exits
+func (r *runner) serveMetrics(mux *http.ServeMux, interval time.Duration) error {
+  client, err := r.newClient("dashboard")
+  if err != nil {
+    return err
+  }
+  reg := prometheus.NewRegistry()
+  metrics, err := bitwire.NewMetrics(reg)
+  if err != nil {
+    return err
+  }
+  client.Metrics = metrics
+  update := func() {
+    inFlight, err := client.Transfers.InFlight()
+    if err != nil {
+      r.printfErr("Could not update the in flight metrics: %s\n", err)
+      return
+    }
+    metrics.SetInFlight(inFlight)
+  }
+  update()
+  go func() {
+    for range time.Tick(interval) {
+      update()
+    }
+  }()
+  mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+  return nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+  "encoding/json"
+  "time"
+)
+
+const deprecationsStore = "deprecations"
+
+// How long the CLI waits before repeating a deprecation warning
+const deprecationInterval = 24 * time.Hour
+
+// Deprecation warning shown to the user
+type deprecationNotice struct {
+  Time    time.Time `json:"time"`
+  Message string    `json:"message"`
+}
+
+// Tells if the deprecation warning was not shown in the last day, and records that it is shown now
+func (r *runner) deprecationDue(message string, now time.Time) bool {
+  due := true
+  r.readRecords(deprecationsStore, func(data []byte) error {
+    notice := deprecationNotice{}
+    if json.Unmarshal(data, &notice) == nil && notice.Message == message && now.Sub(notice.Time) < deprecationInterval {
+      due = false
+    }
+    return nil
+  })
+  if due {
+    r.appendRecord(deprecationsStore, deprecationNotice{now, message})
+  }
+  return due
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "strings"
+  "time"
+)
+
+const (
+  BOLD  = "\033[1m"
+  DIM   = "\033[2m"
+  GREEN = "\033[32m"
+  RED   = "\033[31m"
+)
+
+// Group of label and value rows of the transfer show layout
+type detailSection struct {
+  Title string
+  Rows  [][2]string
+}
+
+func (s *detailSection) add(label, value string) {
+  if value != "" {
+    s.Rows = append(s.Rows, [2]string{label, value})
+  }
+}
+
+// Transfer shown by transfer show, grouped into sections
+type transferDetail struct {
+  Transfer bitwire.Transfer
+  Lang     string
+  Mode     bitwire.Mode
+}
+
+// Returns the amounts & rate, fees, recipient & bank (or deposit account), BTC payment and timeline sections.
+// The expiry is a countdown from now.
+func (d transferDetail) sections(now time.Time) []detailSection {
+  tx := d.Transfer
+  amounts := detailSection{Title: "Amounts & rate"}
+  fees := detailSection{Title: "Fees"}
+  bank := detailSection{Title: "Recipient & bank"}
+  payment := detailSection{Title: "BTC payment"}
+  timeline := detailSection{Title: "Timeline"}
+
+  var addrErr error
+  if tx.BTC.Address != "" {
+    addrErr = tx.BTC.Validate(d.Mode)
+  }
+  address := tx.BTC.Address
+  if addrErr != nil {
+    address = fmt.Sprintf("%s (%s)", address, addrErr)
+  }
+
+  if tx.IsBankToBtc() {
+    amounts.add("Deposit", fmt.Sprintf("%s %s", tx.Deposit.Amount, tx.Deposit.Currency))
+    amounts.add("Received", fmt.Sprintf("%s BTC", tx.Amount))
+    amounts.add("Rate", rate(tx.Deposit.Amount, tx.Amount, tx.Deposit.Currency))
+    bank.Title = "Deposit account"
+    bank.add("Bank", tx.Deposit.BankName)
+    bank.add("Account Number", tx.Deposit.AccountNumber)
+    bank.add("Account Holder", tx.Deposit.AccountHolder)
+    bank.add("Depositor Name", tx.Deposit.Reference)
+    payment.Title = "BTC delivery"
+    payment.add("Receive Address", address)
+  } else {
+    amounts.add("Sent", fmt.Sprintf("%s BTC", tx.Amount))
+    amounts.add("Received", fmt.Sprintf("%s %s", tx.Recipient.Amount, tx.Recipient.Currency))
+    amounts.add("Rate", rate(tx.Recipient.Amount, tx.Amount, tx.Recipient.Currency))
+    bank.add("Recipient", tx.Recipient.LocalName(d.Lang))
+    bank.add("Bank", tx.Recipient.Bank.DisplayName)
+    bank.add("Account Number", tx.Recipient.Bank.AccountNumber)
+    bank.add("Statement Name", tx.Payout.PayerName)
+    payment.add("Pay Address", address)
+    if addrErr == nil {
+      payment.add("Pay URL", tx.BTC.Link)
+    }
+  }
+  if !tx.Fees.IsZero() {
+    fees.add("Network Fee", fmt.Sprintf("%s BTC", tx.Fees.Network))
+    fees.add("Service Fee", fmt.Sprintf("%s %s", tx.Fees.Service, tx.Fees.Currency))
+    fees.add("FX Spread", fmt.Sprintf("%s %s", tx.Fees.FXSpread, tx.Fees.Currency))
+  }
+  if tx.BTC.Received.Sign() > 0 {
+    payment.add("Paid", fmt.Sprintf("%s BTC", tx.BTC.Received))
+  }
+  payment.add("Funding", fundingNote(tx))
+  expires := tx.ExpiresAt()
+  if !expires.IsZero() && !tx.IsFinal() {
+    payment.add("Expires", expiresIn(expires, now))
+  }
+  if d.Mode == bitwire.SANDBOX {
+    payment.add("Network", testnetLabel)
+  }
+
+  timeline.add("Created", agoAt(tx.CreatedAt, now, tx.Date))
+  if !expires.IsZero() {
+    timeline.add("Address Expiry", expires.Local().Format(timestampLayout))
+  }
+  timeline.add("Paid Out", agoAt(tx.Payout.PaidAt, now, tx.Payout.Date))
+  timeline.add("Payout Reference", tx.Payout.Reference)
+  timeline.add("Status", tx.Status)
+
+  var sections []detailSection
+  for _, s := range []detailSection{amounts, fees, bank, payment, timeline} {
+    if len(s.Rows) > 0 {
+      sections = append(sections, s)
+    }
+  }
+  return sections
+}
+
+// Describes the exchange rate of the amounts, empty if either is zero
+func rate(fiat, btc bitwire.Decimal, currency string) string {
+  if fiat.Sign() <= 0 || btc.Sign() <= 0 {
+    return ""
+  }
+  return fmt.Sprintf("%s %s/BTC", fiat.QuoUp(btc, 0), currency)
+}
+
+// Renders the sections under a header line with the ID and status, ANSI-styled if styled is set
+func (d transferDetail) lines(now time.Time, styled bool) []string {
+  style := func(code, s string) string {
+    if !styled {
+      return s
+    }
+    return code + s + RESET
+  }
+  status := style(statusColor(d.Transfer), d.Transfer.Status)
+  lines := []string{style(BOLD, "Transfer "+d.Transfer.Id) + "  " + status}
+  sections := d.sections(now)
+  width := 0
+  for _, s := range sections {
+    for _, row := range s.Rows {
+      if len(row[0]) > width {
+        width = len(row[0])
+      }
+    }
+  }
+  for _, s := range sections {
+    lines = append(lines, "", style(BOLD, s.Title))
+    for _, row := range s.Rows {
+      lines = append(lines, fmt.Sprintf("  %s  %s", style(DIM, fmt.Sprintf("%-*s", width, row[0])), row[1]))
+    }
+  }
+  return lines
+}
+
+// Renders the sections as label: value lines, for --plain
+func (d transferDetail) plainLines(now time.Time) []string {
+  lines := []string{"Transfer: " + d.Transfer.Id, "Status: " + d.Transfer.Status}
+  for _, s := range d.sections(now) {
+    lines = append(lines, "", s.Title)
+    for _, row := range s.Rows {
+      lines = append(lines, row[0]+": "+row[1])
+    }
+  }
+  return lines
+}
+
+// Green for completed transfers, red for expired or canceled ones and yellow otherwise
+func statusColor(tx bitwire.Transfer) string {
+  switch {
+  case strings.Contains(strings.ToLower(tx.Status), bitwire.StatusCompleted):
+    return GREEN
+  case tx.IsFinal():
+    return RED
+  default:
+    return YELLOW
+  }
+}
+
+// Returns the BIP21 URI to pay the transfer, or an error telling why it cannot be paid
+func payableURI(tx bitwire.Transfer, mode bitwire.Mode) (string, error) {
+  switch {
+  case tx.IsBankToBtc():
+    return "", fmt.Errorf("Transfer %s is paid by a bank deposit, not in BTC", tx.Id)
+  case tx.BTC.Address == "":
+    return "", fmt.Errorf("Transfer %s has no payment address", tx.Id)
+  case tx.IsExpired():
+    return "", fmt.Errorf("The payment address of transfer %s expired", tx.Id)
+  }
+  if err := tx.BTC.Validate(mode); err != nil {
+    return "", fmt.Errorf("Transfer %s has an invalid payment address, %s", tx.Id, err)
+  }
+  return tx.PaymentURI(mode), nil
+}
+
+// Prints the transfer show layout followed by the payment QR code, or only the QR code if qrOnly is set
+func (r *runner) printTransferDetail(tx bitwire.Transfer, qrOnly bool) error {
+  uri, uriErr := payableURI(tx, r.mode)
+  if qrOnly {
+    if uriErr != nil {
+      return uriErr
+    }
+    return r.printQr(uri)
+  }
+  detail := transferDetail{Transfer: tx, Lang: r.lang, Mode: r.mode}
+  lines := detail.lines(time.Now(), r.colored(r.Stdout))
+  if r.plain {
+    lines = detail.plainLines(time.Now())
+  }
+  fmt.Fprintln(r.Stdout, strings.Join(lines, "\n"))
+  if note := fundingNote(tx); note != "" {
+    r.printfErr("%sWarning: transfer %s is %s%s\n", YELLOW, tx.Id, note, RESET)
+  }
+  if uriErr != nil {
+    if err := tx.BTC.Validate(r.mode); tx.BTC.Address != "" && err != nil {
+      r.printfErr("%sWarning: not showing the payment QR code, %s%s\n", YELLOW, err, RESET)
+    }
+    return nil
+  }
+  fmt.Fprintln(r.Stdout)
+  return r.printQr(uri)
+}
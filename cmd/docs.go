@@ -0,0 +1,76 @@
+package cmd
+
+import (
+  _ "embed"
+  "encoding/json"
+  "fmt"
+  "github.com/dworznik/cli"
+  "strings"
+)
+
+// Schema of the API responses, generated from the bitwire package by go generate
+var (
+  //go:embed schema.md
+  schemaMarkdown string
+  //go:embed schema.json
+  schemaJSON []byte
+)
+
+type schemaType struct {
+  Name        string        `json:"name"`
+  Response    bool          `json:"response"`
+  Description string        `json:"description,omitempty"`
+  Fields      []schemaField `json:"fields"`
+}
+
+type schemaField struct {
+  Name        string `json:"name"`
+  Type        string `json:"type"`
+  Description string `json:"description,omitempty"`
+}
+
+// Returns the section of the type in the markdown schema, ignoring case
+func schemaSection(name string) (string, bool) {
+  for _, section := range strings.Split(schemaMarkdown, "\n## ")[1:] {
+    title := strings.SplitN(section, "\n", 2)[0]
+    if strings.EqualFold(title, name) {
+      return "## " + strings.TrimSpace(section) + "\n", true
+    }
+  }
+  return "", false
+}
+
+func (r *runner) docsAction(c *cli.Context) error {
+  name := c.Args().Get(0)
+  if r.json {
+    var schema struct {
+      Types []schemaType `json:"types"`
+    }
+    if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+      return err
+    }
+    var v interface{} = schema
+    if name != "" {
+      v = nil
+      for _, t := range schema.Types {
+        if strings.EqualFold(t.Name, name) {
+          v = t
+        }
+      }
+      if v == nil {
+        return fmt.Errorf("Unknown type: %s", name)
+      }
+    }
+    return r.printData(v)
+  }
+  if name == "" {
+    fmt.Fprint(r.Stdout, schemaMarkdown)
+    return nil
+  }
+  section, ok := schemaSection(name)
+  if !ok {
+    return fmt.Errorf("Unknown type: %s", name)
+  }
+  fmt.Fprint(r.Stdout, section)
+  return nil
+}
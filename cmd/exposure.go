@@ -0,0 +1,111 @@
+package cmd
+
+import (
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "sort"
+)
+
+var tableExposureHeader = []string{"Template", "Recipient", "Schedule", "Payouts", "Total", "Rate", "BTC"}
+
+// BTC needed for the scheduled payouts of a template over the horizon
+type templateExposure struct {
+  Template    string          `json:"template"`
+  Recipient   int             `json:"recipient"`
+  Schedule    string          `json:"schedule"`
+  Payouts     int             `json:"payouts"`
+  Amount      bitwire.Decimal `json:"amount"` // Total of the payouts
+  Currency    string          `json:"currency"`
+  Rate        bitwire.Decimal `json:"rate"`
+  BTC         bitwire.Decimal `json:"btc"`           // At the current rate
+  BTCRateDown bitwire.Decimal `json:"btc_rate_down"` // With the rate down by the band, the most BTC needed
+  BTCRateUp   bitwire.Decimal `json:"btc_rate_up"`
+}
+
+// BTC needed for the scheduled payouts of the next days, with the totals at rates moved by the band
+type exposure struct {
+  Days        int                `json:"days"`
+  Band        int                `json:"band_percent"`
+  Templates   []templateExposure `json:"templates"`
+  BTC         bitwire.Decimal    `json:"btc"`
+  BTCRateDown bitwire.Decimal    `json:"btc_rate_down"`
+  BTCRateUp   bitwire.Decimal    `json:"btc_rate_up"`
+}
+
+// Returns the number of payouts on the schedule within the days, counting a started week or month as a whole,
+// so that the exposure errs on the side of more BTC
+func scheduledPayouts(schedule string, days int) int {
+  switch schedule {
+  case "daily":
+    return days
+  case "weekly":
+    return (days + 6) / 7
+  case "monthly":
+    return (days + 29) / 30
+  }
+  return 0
+}
+
+// Computes the exposure of the scheduled templates, sorted by name. On-demand templates are left out.
+func templatesExposure(templates map[string]payoutTemplate, rates bitwire.Rates, days, band int) (exposure, error) {
+  exp := exposure{Days: days, Band: band, Templates: []templateExposure{}}
+  down, up := bitwire.NewDecimal(int64(100-band), 2), bitwire.NewDecimal(int64(100+band), 2)
+  for name, t := range templates {
+    payouts := scheduledPayouts(t.Schedule, days)
+    if payouts == 0 {
+      continue
+    }
+    rate, ok := rates["BTC"+t.Currency]
+    if !ok || rate.Sign() <= 0 {
+      return exp, fmt.Errorf("No BTC rate for %s of template %s", t.Currency, name)
+    }
+    total := t.Amount.Mul(bitwire.NewDecimal(int64(payouts), 0))
+    exp.Templates = append(exp.Templates, templateExposure{name, t.Recipient, t.Schedule, payouts, total, t.Currency, rate,
+      total.QuoUp(rate, 8), total.QuoUp(rate.Mul(down), 8), total.QuoUp(rate.Mul(up), 8)})
+  }
+  sort.Slice(exp.Templates, func(i, j int) bool { return exp.Templates[i].Template < exp.Templates[j].Template })
+  for _, t := range exp.Templates {
+    exp.BTC = exp.BTC.Add(t.BTC)
+    exp.BTCRateDown = exp.BTCRateDown.Add(t.BTCRateDown)
+    exp.BTCRateUp = exp.BTCRateUp.Add(t.BTCRateUp)
+  }
+  return exp, nil
+}
+
+// Shows the BTC to fund the scheduled payout templates set by apply with, at the current rates and at rates moved by --band
+func (r *runner) exposureAction(c *cli.Context) error {
+  days, band := c.Int("days"), c.Int("band")
+  if days <= 0 {
+    return fmt.Errorf("Invalid --days: %d", days)
+  }
+  if band < 0 || band >= 100 {
+    return fmt.Errorf("Invalid --band: %d, expected a percentage from 0 to 99", band)
+  }
+  org, err := r.readOrganization()
+  if err != nil {
+    return err
+  }
+  if len(org.Templates) == 0 {
+    return errors.New("No payout templates\nDeclare them with a schedule in the file of `bitwire apply`")
+  }
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  rates, err := client.Rates.All()
+  if err != nil {
+    return err
+  }
+  r.recordRates(rates)
+  exp, err := templatesExposure(org.Templates, rates.BTC, days, band)
+  if err != nil {
+    return err
+  }
+  if len(exp.Templates) == 0 && !r.json {
+    r.printfInfo("No scheduled payout templates\n")
+    return nil
+  }
+  return r.printOut(exp, r.json)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/explorer"
+  "strings"
+  "time"
+)
+
+// Snapshot of a transfer's lifecycle shown by transfer show --follow
+type followView struct {
+  Transfer   bitwire.Transfer  `json:"transfer"`
+  Payment    *explorer.Payment `json:"payment"`
+  PaymentErr string            `json:"payment_error,omitempty"`
+  Testnet    bool              `json:"testnet,omitempty"` // Set in sandbox mode
+}
+
+// Renders the view. The expiry is a countdown from now if relative is set,
+// and a fixed time otherwise, so that piped output only changes with the transfer.
+func (v followView) lines(now time.Time, relative bool) []string {
+  tx := v.Transfer
+  lines := []string{
+    fmt.Sprintf("Transfer   %s", tx.Id),
+    fmt.Sprintf("Status     %s", tx.Status),
+  }
+  switch {
+  case tx.BTC.Address == "":
+  case v.PaymentErr != "":
+    lines = append(lines, fmt.Sprintf("Payment    unavailable: %s", v.PaymentErr))
+  case v.Payment == nil:
+    lines = append(lines, fmt.Sprintf("Payment    waiting for %s BTC to %s", tx.Amount, tx.BTC.Address))
+    if v.Testnet {
+      lines = append(lines, "Network    "+testnetLabel)
+    }
+  default:
+    line := fmt.Sprintf("Payment    %s BTC received, %d confirmation(s)", v.Payment.Amount, v.Payment.Confirmations)
+    if v.Payment.Amount.Cmp(tx.Amount) < 0 {
+      line += fmt.Sprintf(", %s BTC expected", tx.Amount)
+    }
+    lines = append(lines, line)
+  }
+  if note := fundingNote(tx); note != "" {
+    lines = append(lines, "Funding    "+note)
+  }
+  if expires := tx.ExpiresAt(); v.Payment == nil && !tx.IsFinal() && !expires.IsZero() {
+    if !relative {
+      lines = append(lines, fmt.Sprintf("Expires    at %s", expires.Local().Format(timestampLayout)))
+    } else {
+      lines = append(lines, "Expires    "+expiresIn(expires, now))
+    }
+  }
+  status := strings.ToLower(tx.Status)
+  switch {
+  case strings.Contains(status, bitwire.StatusCompleted):
+    line := fmt.Sprintf("Payout     %s %s paid to %s", tx.Recipient.Amount, tx.Recipient.Currency, tx.Recipient.Name)
+    if tx.Payout.Reference != "" {
+      line += ", reference " + tx.Payout.Reference
+    }
+    lines = append(lines, line)
+  case tx.IsFinal():
+    lines = append(lines, "Payout     none")
+  default:
+    lines = append(lines, "Payout     pending")
+  }
+  return lines
+}
+
+// Polls the transfer and its funding payment, re-rendering the view until the transfer
+// completes, expires or is canceled. On a terminal the view is redrawn in place,
+// otherwise a new view is printed whenever it changes.
+func (r *runner) followTransfer(client *bitwire.Client, id string, interval time.Duration) error {
+  if id == "" {
+    return errors.New("Missing transfer ID\nUsage: transfer show --follow id")
+  }
+  if interval < time.Second {
+    interval = time.Second
+  }
+  chain := r.NewExplorer(r.mode)
+  terminal := r.Terminal.IsTerminal(r.Stdout) && !r.plain
+  var shown []string
+  for poll := 1; ; poll++ {
+    tx, err := client.Transfers.Get(id)
+    if err != nil {
+      return err
+    }
+    r.progress(progressEvent{Event: progressStep, Step: "poll", Current: poll, Status: tx.Status, Message: fundingNote(tx)})
+    view := followView{Transfer: tx, Testnet: r.mode == bitwire.SANDBOX}
+    if tx.BTC.Address != "" {
+      view.Payment, err = chain.Funding(tx.BTC.Address)
+      if err != nil {
+        view.PaymentErr = err.Error()
+      }
+    }
+    lines := view.lines(time.Now(), terminal && !r.json)
+    if r.json {
+      if !equalLines(lines, shown) {
+        b, err := json.Marshal(view)
+        if err != nil {
+          return err
+        }
+        fmt.Fprintln(r.Stdout, string(b))
+      }
+    } else if terminal {
+      if shown != nil {
+        fmt.Fprintf(r.Stdout, "\033[%dA\033[J", len(shown)) // Move up and clear the previous view
+      }
+      fmt.Fprintln(r.Stdout, strings.Join(lines, "\n"))
+    } else if !equalLines(lines, shown) {
+      if shown != nil {
+        fmt.Fprintln(r.Stdout)
+      }
+      fmt.Fprintln(r.Stdout, strings.Join(lines, "\n"))
+    }
+    shown = lines
+    if tx.IsFinal() {
+      return nil
+    }
+    time.Sleep(interval)
+  }
+}
+
+func equalLines(a, b []string) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "strings"
+  "text/template"
+)
+
+// Functions of --format templates besides the builtin ones
+var formatFuncs = template.FuncMap{
+  "json": func(v interface{}) (string, error) {
+    b, err := json.Marshal(v)
+    return string(b), err
+  },
+  "upper": strings.ToUpper,
+  "lower": strings.ToLower,
+}
+
+// Parses the template of --format. Fields are those of the Go types, e.g. {{.Id}} {{.BTC.Address}}.
+func newFormatTemplate(format string) (*template.Template, error) {
+  tmpl, err := template.New("format").Funcs(formatFuncs).Parse(format)
+  if err != nil {
+    return nil, fmt.Errorf("Invalid --format template: %s", err)
+  }
+  return tmpl, nil
+}
+
+// Executes the template on each element of a slice, or else on the value, ending each with a newline
+func writeTemplate(w io.Writer, tmpl *template.Template, v interface{}) error {
+  return eachItem(v, func(item interface{}) error {
+    if err := tmpl.Execute(w, item); err != nil {
+      return err
+    }
+    _, err := fmt.Fprintln(w)
+    return err
+  })
+}
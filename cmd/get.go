@@ -0,0 +1,136 @@
+package cmd
+
+import (
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "strconv"
+  "strings"
+)
+
+// -o of the get commands, which can follow the resource unlike the global --output, as in `bitwire get transfers -o json`
+var getOutputFlag = cli.StringFlag{
+  Name:  "output, o",
+  Usage: "output format: table, json, yaml, ndjson or csv, overriding the global --output",
+}
+
+// Returns the flags of the named command, to share them with its get counterpart
+func commandFlags(commands []cli.Command, name string) []cli.Flag {
+  for _, command := range commands {
+    if command.Name == name {
+      return command.Flags
+    }
+  }
+  return nil
+}
+
+// Uniform entry point to the resources: get <resource> lists them, get <resource> <id> shows one
+func (r *runner) getCommands() []cli.Command {
+  transferFlags := append(commandFlags(r.transferCommands(), "list"), commandFlags(r.transferCommands(), "show")...)
+  recipientFlags := append(commandFlags(r.recipientCommands(), "list"), commandFlags(r.recipientCommands(), "show")...)
+  return []cli.Command{
+    {
+      Name:        "transfers",
+      Usage:       "list transfers, or show the transfer with the ID, taking the flags of transfer list and show",
+      ArgsUsage:   "[id]",
+      Description: commonErrors("Unauthorized", "not_found"),
+      Action:      r.getAction(r.transferListAction, r.transferShowAction),
+      Flags:       append(transferFlags, getOutputFlag),
+    },
+    {
+      Name:        "recipients",
+      Usage:       "list recipients, or show the recipient with the ID or name, taking the flags of recipient list and show",
+      ArgsUsage:   "[id or name]",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.getAction(r.recipientListAction, r.recipientShowAction),
+      Flags:       append(recipientFlags, getOutputFlag),
+    },
+    {
+      Name:      "banks",
+      Usage:     "list banks, or show the bank with the ID",
+      ArgsUsage: "[id]",
+      Action:    r.getAction(r.banksAction, r.getBankAction),
+      Flags:     []cli.Flag{getOutputFlag},
+    },
+    {
+      Name:      "rates",
+      Usage:     "show all rates, or the rate of the pair, e.g. BTCKRW",
+      ArgsUsage: "[pair]",
+      Action:    r.getAction(r.ratesAction, r.getRateAction),
+      Flags:     []cli.Flag{getOutputFlag},
+    },
+    {
+      Name:        "limits",
+      Usage:       "show transfer limits",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.getAction(r.limitsAction, nil),
+      Flags:       []cli.Flag{getOutputFlag},
+    },
+  }
+}
+
+// Applies -o, then runs list without an argument and show with one or with --pick. A nil show takes no argument.
+func (r *runner) getAction(list, show func(c *cli.Context) error) func(c *cli.Context) error {
+  return func(c *cli.Context) error {
+    if c.IsSet("output") {
+      r.output, r.json, r.csv = c.String("output"), c.GlobalBool("json"), false
+      if err := r.applyOutput(); err != nil {
+        return err
+      }
+    }
+    if c.NArg() == 0 && !c.Bool("pick") {
+      return list(c)
+    }
+    if show == nil {
+      return fmt.Errorf("Unexpected argument: %s\nUsage: get %s", c.Args().Get(0), c.Command.Name)
+    }
+    return show(c)
+  }
+}
+
+func (r *runner) getBankAction(c *cli.Context) error {
+  id, err := strconv.Atoi(c.Args().Get(0))
+  if err != nil {
+    return errors.New("Invalid bank ID: " + c.Args().Get(0))
+  }
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  banks, err := client.Banks.List()
+  if err != nil {
+    return err
+  }
+  for _, bank := range banks {
+    if bank.Id == id {
+      if r.json {
+        return r.printData(bank)
+      }
+      return r.printOut([]bitwire.Bank{bank}, false)
+    }
+  }
+  return fmt.Errorf("Bank %d not found", id)
+}
+
+func (r *runner) getRateAction(c *cli.Context) error {
+  pair := strings.ToUpper(c.Args().Get(0))
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  rates, err := client.Rates.All()
+  if err != nil {
+    return err
+  }
+  r.recordRates(rates)
+  found := bitwire.AllRates{BTC: bitwire.Rates{}, FX: bitwire.Rates{}}
+  if rate, ok := rates.BTC[pair]; ok {
+    found.BTC[pair] = rate
+  } else if rate, ok := rates.FX[pair]; ok {
+    found.FX[pair] = rate
+  } else {
+    return fmt.Errorf("No rate for %s\nUse any of: %s", pair, strings.Join(append(rates.BTC.Pairs(), rates.FX.Pairs()...), ", "))
+  }
+  return r.printOut(found, r.json)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "math/big"
+  "strings"
+  "time"
+)
+
+const limitsStore = "limits"
+
+// How often authenticated commands snapshot the limits
+const snapshotInterval = time.Hour
+
+// Width of the utilization bars of limits history
+const barWidth = 20
+
+// Utilization share from which the limits history suggests a limit increase
+const highUtilization = 80
+
+type limitsSnapshot struct {
+  Time   time.Time      `json:"time"`
+  Limits bitwire.Limits `json:"limits"`
+}
+
+// Highest use of a KRW limit within a day or a week
+type utilization struct {
+  Period  string          `json:"period"` // 2006-01-02 for days, 2006-W01 for ISO weeks
+  Used    bitwire.Decimal `json:"used"`
+  Limit   bitwire.Decimal `json:"limit"`
+  Percent int             `json:"percent"`
+}
+
+func (r *runner) recordLimits(limits bitwire.Limits) error {
+  r.limitsRecorded = true
+  return r.appendRecord(limitsStore, limitsSnapshot{time.Now(), limits})
+}
+
+func (r *runner) readLimitsHistory() ([]limitsSnapshot, error) {
+  var history []limitsSnapshot
+  err := r.readRecords(limitsStore, func(data []byte) error {
+    snapshot := limitsSnapshot{}
+    if err := json.Unmarshal(data, &snapshot); err != nil {
+      return err
+    }
+    history = append(history, snapshot)
+    return nil
+  })
+  return history, err
+}
+
+// Snapshots the limits after an authenticated command that called the API,
+// at most once per snapshotInterval. Failures are ignored, the snapshot is only a convenience.
+func (r *runner) snapshotLimits() {
+  if r.client == nil || r.limitsRecorded || r.client.Token().AccessToken == "" || r.client.LastResponse().HTTPStatus == 0 {
+    return
+  }
+  history, err := r.readLimitsHistory()
+  if err != nil {
+    return
+  }
+  if n := len(history); n > 0 && time.Since(history[n-1].Time) < snapshotInterval {
+    return
+  }
+  r.client.OnWarning = nil // Near-limit notes belong to the limits command
+  if limits, err := r.client.Limits.Get(); err == nil {
+    r.recordLimits(limits)
+  }
+}
+
+// Returns the highest utilization of the daily, or weekly, KRW limit per period since the given time
+func limitsUtilization(history []limitsSnapshot, weekly bool, since time.Time) []utilization {
+  var periods []utilization
+  index := map[string]int{}
+  for _, s := range history {
+    if s.Time.Before(since) {
+      continue
+    }
+    t := s.Time.Local()
+    period, krw := t.Format("2006-01-02"), s.Limits.KRW.Daily
+    if weekly {
+      year, week := t.ISOWeek()
+      period, krw = fmt.Sprintf("%d-W%02d", year, week), s.Limits.KRW.Weekly
+    }
+    u := utilization{period, krw.Used, krw.Limit, percent(krw.Used, krw.Limit)}
+    if i, ok := index[period]; !ok {
+      index[period] = len(periods)
+      periods = append(periods, u)
+    } else if u.Used.Cmp(periods[i].Used) > 0 {
+      periods[i] = u
+    }
+  }
+  return periods
+}
+
+// Returns used as a whole percentage of limit, rounded down
+func percent(used, limit bitwire.Decimal) int {
+  if limit.Sign() <= 0 {
+    return 0
+  }
+  share := new(big.Rat).Quo(used.Rat(), limit.Rat())
+  share.Mul(share, big.NewRat(100, 1))
+  return int(new(big.Int).Quo(share.Num(), share.Denom()).Int64())
+}
+
+// Green below highUtilization, yellow up to the limit and red at or over it
+func utilizationColor(percent int) string {
+  switch {
+  case percent >= 100:
+    return RED
+  case percent >= highUtilization:
+    return YELLOW
+  default:
+    return GREEN
+  }
+}
+
+func utilizationBar(percent int) string {
+  filled := percent * barWidth / 100
+  if filled > barWidth {
+    filled = barWidth
+  } else if filled < 0 {
+    filled = 0
+  }
+  return "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]"
+}
+
+func (r *runner) limitsHistoryAction(c *cli.Context) error {
+  history, err := r.readLimitsHistory()
+  if err != nil {
+    return err
+  }
+  weekly := c.Bool("weekly")
+  since := time.Now().AddDate(0, 0, -c.Int("days"))
+  periods := limitsUtilization(history, weekly, since)
+  if r.json {
+    if periods == nil {
+      periods = []utilization{}
+    }
+    return r.printData(periods)
+  }
+  name := "day"
+  if weekly {
+    name = "week"
+  }
+  if len(periods) == 0 {
+    r.printfErr("No limits recorded in %s mode in the last %d days. Limits are recorded when running authenticated commands.\n", r.mode, c.Int("days"))
+    return nil
+  }
+  fmt.Fprintf(r.Stdout, "KRW limit utilization per %s\n", name)
+  high := 0
+  for _, u := range periods {
+    share := r.colorize(r.Stdout, utilizationColor(u.Percent), fmt.Sprintf("%3d%%", u.Percent))
+    fmt.Fprintf(r.Stdout, "%-10s  %s %s  %s / %s KRW\n", u.Period, utilizationBar(u.Percent), share, u.Used, u.Limit)
+    if u.Percent >= highUtilization {
+      high++
+    }
+  }
+  if high > 0 {
+    r.printfErr("%sNote: %d%% or more of the limit was used in %d of %d %ss; consider requesting a limit increase%s\n",
+      YELLOW, highUtilization, high, len(periods), name, RESET)
+  }
+  return nil
+}
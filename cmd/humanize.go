@@ -0,0 +1,72 @@
+package cmd
+
+import (
+  "fmt"
+  "time"
+)
+
+// Layout of absolute timestamps, shown in local time next to or instead of relative ones
+const timestampLayout = "2006-01-02 15:04:05"
+
+var durationUnits = []struct {
+  unit   time.Duration
+  suffix string
+}{{24 * time.Hour, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}}
+
+// Formats the duration with its two largest units, dropping a zero second one, e.g. 1d2h, 14m, 1m30s
+func humanDuration(d time.Duration) string {
+  if d < 0 {
+    d = -d
+  }
+  out, parts := "", 0
+  for _, u := range durationUnits {
+    n := d / u.unit
+    d -= n * u.unit
+    if n > 0 {
+      out += fmt.Sprintf("%d%s", n, u.suffix)
+    }
+    if n > 0 || parts > 0 {
+      parts++
+    }
+    if parts == 2 {
+      break
+    }
+  }
+  if out == "" {
+    return "0s"
+  }
+  return out
+}
+
+// Describes the time relative to now, e.g. 2h ago or in 14m. Empty for the zero time.
+func ago(t, now time.Time) string {
+  if t.IsZero() {
+    return ""
+  }
+  d := now.Sub(t)
+  switch {
+  case d <= -time.Second:
+    return "in " + humanDuration(d)
+  case d < time.Second:
+    return "just now"
+  default:
+    return humanDuration(d) + " ago"
+  }
+}
+
+// Describes the time relative to now followed by the local timestamp, e.g. 2h ago (2017-01-18 10:00:00).
+// Falls back to the date as the API returned it if it could not be parsed.
+func agoAt(t, now time.Time, date string) string {
+  if t.IsZero() {
+    return date
+  }
+  return fmt.Sprintf("%s (%s)", ago(t, now), t.Local().Format(timestampLayout))
+}
+
+// Describes the time left until the payment address expires
+func expiresIn(expires, now time.Time) string {
+  if left := expires.Sub(now); left >= time.Second {
+    return "in " + humanDuration(left)
+  }
+  return "expired"
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+  "archive/zip"
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/dworznik/cli"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "runtime"
+  "sort"
+  "time"
+)
+
+// Journal of the API calls of a run, with credentials and tokens redacted.
+// The file is a bitwiretest fixture, so that the calls can be replayed with bitwiretest.NewRecorder.
+func (r *runner) startJournal() *bitwiretest.Recorder {
+  name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + r.envName() + ".json"
+  recorder, _ := bitwiretest.NewRecorder(filepath.Join(r.journalDir(), name), bitwiretest.Record)
+  return recorder
+}
+
+func (r *runner) journalDir() string {
+  return r.confPath(JournalDir)
+}
+
+// Writes the journal of the run, if it made any call
+func (r *runner) saveJournal() {
+  if r.journal == nil || len(r.journal.Interactions()) == 0 {
+    return
+  }
+  if err := r.journal.Save(); err != nil {
+    r.printfErr("Could not save the journal: %s\n", err)
+  }
+}
+
+// Details of the installation included in a support bundle, without credentials
+type diagnostics struct {
+  Version    string            `json:"version"`
+  OS         string            `json:"os"`
+  Arch       string            `json:"arch"`
+  Go         string            `json:"go"`
+  APIURL     string            `json:"api_url,omitempty"`
+  Configured map[string]bool   `json:"configured"` // Whether the config file of each environment exists
+  LastSync   map[string]string `json:"last_sync"`
+}
+
+func (r *runner) diagnostics() diagnostics {
+  d := diagnostics{Version: Version, OS: runtime.GOOS, Arch: runtime.GOARCH, Go: runtime.Version(), APIURL: r.apiURL,
+    Configured: map[string]bool{}, LastSync: map[string]string{}}
+  saved := r.env
+  for _, env := range r.envNames() {
+    _, err := os.Stat(r.configPath(env))
+    d.Configured[env] = err == nil
+    r.env = env
+    if last, err := r.lastSync(); err == nil && !last.Time.IsZero() {
+      d.LastSync[env] = last.Time.Format(time.RFC3339)
+    }
+  }
+  r.env = saved
+  return d
+}
+
+// Zips the journal files and the diagnostics for a support ticket
+func (r *runner) journalBundleAction(c *cli.Context) error {
+  files, err := filepath.Glob(filepath.Join(r.journalDir(), "*.json"))
+  if err != nil {
+    return err
+  }
+  if len(files) == 0 {
+    return errors.New("No journal to bundle, run the commands to report with --journal first")
+  }
+  sort.Strings(files)
+  out := c.String("out")
+  if out == "" {
+    out = "bitwire-support-" + time.Now().Format("20060102-150405") + ".zip"
+  }
+  file, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  archive := zip.NewWriter(file)
+  info, err := json.MarshalIndent(r.diagnostics(), "", "  ")
+  if err != nil {
+    return err
+  }
+  if err := addToZip(archive, "diagnostics.json", info); err != nil {
+    return err
+  }
+  for _, path := range files {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+      return err
+    }
+    if err := addToZip(archive, "journal/"+filepath.Base(path), data); err != nil {
+      return err
+    }
+  }
+  if err := archive.Close(); err != nil {
+    return err
+  }
+  r.printfErr("Bundled %d journal files in %s\n", len(files), out)
+  return nil
+}
+
+func addToZip(archive *zip.Writer, name string, data []byte) error {
+  w, err := archive.Create(name)
+  if err != nil {
+    return err
+  }
+  _, err = w.Write(data)
+  return err
+}
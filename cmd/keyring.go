@@ -0,0 +1,28 @@
+package cmd
+
+import (
+  "errors"
+)
+
+// Credential store of the OS: macOS Keychain, Secret Service on Linux or Windows Credential Manager.
+// It keeps the client secrets and tokens of the config files. Platforms without one, e.g. js/wasm, get NoKeyring.
+type Keyring interface {
+  // Returns the secret of the user of the service, or ErrKeyringNotFound
+  Get(service, user string) (string, error)
+  Set(service, user, secret string) error
+  Delete(service, user string) error
+}
+
+var (
+  // Returned by keyrings that have no secret of the user
+  ErrKeyringNotFound = errors.New("No secret in the OS keyring")
+  // Returned by NoKeyring
+  ErrNoKeyring = errors.New("The OS keyring is not supported on this platform")
+)
+
+// Keyring of platforms without one
+type NoKeyring struct{}
+
+func (NoKeyring) Get(service, user string) (string, error) { return "", ErrNoKeyring }
+func (NoKeyring) Set(service, user, secret string) error   { return ErrNoKeyring }
+func (NoKeyring) Delete(service, user string) error        { return ErrNoKeyring }
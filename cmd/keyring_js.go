@@ -0,0 +1,9 @@
+//go:build js
+// +build js
+
+package cmd
+
+// Browsers have no OS keyring, secrets stay in the config files
+func defaultKeyring() Keyring {
+  return NoKeyring{}
+}
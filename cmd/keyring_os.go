@@ -0,0 +1,35 @@
+//go:build !js
+// +build !js
+
+package cmd
+
+import (
+  "github.com/zalando/go-keyring"
+)
+
+// Keyring of the OS
+type osKeyring struct{}
+
+func defaultKeyring() Keyring {
+  return osKeyring{}
+}
+
+func (osKeyring) Get(service, user string) (string, error) {
+  secret, err := keyring.Get(service, user)
+  if err == keyring.ErrNotFound {
+    return "", ErrKeyringNotFound
+  }
+  return secret, err
+}
+
+func (osKeyring) Set(service, user, secret string) error {
+  return keyring.Set(service, user, secret)
+}
+
+func (osKeyring) Delete(service, user string) error {
+  err := keyring.Delete(service, user)
+  if err == keyring.ErrNotFound {
+    return ErrKeyringNotFound
+  }
+  return err
+}
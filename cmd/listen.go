@@ -0,0 +1,102 @@
+package cmd
+
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire/webhook"
+  "net/http"
+  "sync"
+  "time"
+)
+
+// Relays a verified webhook payload, with its signature, to a local server
+func forwardEvent(client *http.Client, url string, payload []byte, signature string) error {
+  req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+  if err != nil {
+    return err
+  }
+  req.Header.Set("Content-Type", "application/json")
+  req.Header.Set(webhook.SignatureHeader, signature)
+  res, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  res.Body.Close()
+  if res.StatusCode >= 300 {
+    return errors.New("Forward endpoint responded with " + res.Status)
+  }
+  return nil
+}
+
+// Returns a handler printing verified events to stdout as JSON lines and relaying them to forward, if set
+func (r *runner) listenHandler(secret string, forward string) http.Handler {
+  encoder := json.NewEncoder(r.Stdout)
+  var mu sync.Mutex // Handlers run concurrently, the lines must not interleave
+  client := &http.Client{Timeout: 10 * time.Second}
+  return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+    if req.Method != http.MethodPost {
+      http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+      return
+    }
+    event, payload, err := webhook.ParseRequest(req, secret)
+    if err != nil {
+      r.printfErr("Rejected webhook from %s: %s\n", req.RemoteAddr, err)
+      status := http.StatusBadRequest
+      if err == webhook.ErrMissingSignature || err == webhook.ErrInvalidSignature {
+        status = http.StatusUnauthorized
+      }
+      http.Error(w, err.Error(), status)
+      return
+    }
+    mu.Lock()
+    encoder.Encode(event)
+    mu.Unlock()
+    if forward != "" {
+      if err := forwardEvent(client, forward, payload, req.Header.Get(webhook.SignatureHeader)); err != nil {
+        r.printfErr("Could not forward event %s: %s\n", event.Id, err)
+      }
+    }
+    w.WriteHeader(http.StatusOK)
+  })
+}
+
+// Receives webhooks until the server fails, serving metrics too if metricsInterval is set,
+// and the rate stream if ratesInterval is set. The webhooks need the secret unless only the rate stream is served.
+func (r *runner) listen(addr string, path string, secret string, forward string, metricsInterval, ratesInterval time.Duration) error {
+  if secret == "" && ratesInterval == 0 {
+    return errors.New("Missing webhook secret\nUsage: listen --secret secret [--addr host:port] [--forward url] [--metrics] [--rates-stream]")
+  }
+  mux := http.NewServeMux()
+  if secret != "" {
+    mux.Handle(path, r.listenHandler(secret, forward))
+  }
+  if metricsInterval > 0 {
+    if path == "/metrics" {
+      return errors.New("The webhook path cannot be /metrics with --metrics")
+    }
+    if err := r.serveMetrics(mux, metricsInterval); err != nil {
+      return err
+    }
+    r.printfInfo("Serving metrics on http://%s/metrics\n", addr)
+  }
+  if ratesInterval > 0 {
+    if path == ratesStreamPath {
+      return errors.New("The webhook path cannot be " + ratesStreamPath + " with --rates-stream")
+    }
+    // Rates are public, the stream needs no credentials
+    client, err := r.newClient("rates")
+    if err != nil {
+      return err
+    }
+    mux.Handle(ratesStreamPath, newRateStream(client, ratesInterval))
+    r.printfInfo("Streaming rates every %s on http://%s%s\n", ratesInterval, addr, ratesStreamPath)
+  }
+  if secret != "" {
+    r.printfInfo("Listening for webhooks on http://%s%s\n", addr, path)
+  }
+  if forward != "" {
+    r.printfInfo("Forwarding events to %s\n", forward)
+  }
+  return http.ListenAndServe(addr, mux)
+}
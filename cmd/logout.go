@@ -0,0 +1,136 @@
+package cmd
+
+import (
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "io/ioutil"
+  "os"
+  "sort"
+)
+
+// Returns the profiles with a directory in ProfilesDir, and the default one, named ""
+func (r *runner) profileNames() ([]string, error) {
+  names := []string{""}
+  entries, err := ioutil.ReadDir(r.confPath(ProfilesDir))
+  if os.IsNotExist(err) {
+    return names, nil
+  } else if err != nil {
+    return nil, err
+  }
+  for _, entry := range entries {
+    if entry.IsDir() && validName(entry.Name()) {
+      names = append(names, entry.Name())
+    }
+  }
+  sort.Strings(names[1:])
+  return names, nil
+}
+
+// Returns the mode and API URL of the environment, those of the run, with --api-url, for the selected one.
+// An empty URL is the default of the mode.
+func (r *runner) envEndpoint(env string) (bitwire.Mode, string, error) {
+  switch env {
+  case r.envName():
+    return r.mode, r.apiURL, nil
+  case string(bitwire.PRODUCTION), string(bitwire.SANDBOX):
+    return bitwire.Mode(env), "", nil
+  }
+  e, err := r.prefs.environment(env)
+  return e.Mode, e.BaseURL, err
+}
+
+// Overwrites the file with zeros before removing it, not to leave the secrets behind on filesystems writing in place
+func shredFile(path string) error {
+  info, err := os.Stat(path)
+  if err != nil {
+    return err
+  }
+  file, err := os.OpenFile(path, os.O_WRONLY, 0)
+  if err != nil {
+    return err
+  }
+  _, err = file.Write(make([]byte, info.Size()))
+  if err == nil {
+    err = file.Sync()
+  }
+  file.Close()
+  if err != nil {
+    return err
+  }
+  return os.Remove(path)
+}
+
+// Revokes the token of the environment in the profile, then deletes its credentials from the OS keyring and
+// the config file. A failed revocation is warned about, the credentials are deleted anyway.
+// Tells if there were any.
+func (r *runner) logout(env string) (bool, error) {
+  path := r.configPath(env)
+  if _, err := os.Stat(path); os.IsNotExist(err) {
+    return false, nil
+  }
+  name := r.keyringUser(env)
+  conf, _, err := r.readConfig(env)
+  if err == nil && conf.Token != (bitwire.Token{}) {
+    err = r.revoke(env, conf)
+  }
+  if err != nil {
+    r.printfErr("%sWarning: could not revoke the token of %s: %s%s\n", YELLOW, name, err, RESET)
+  }
+  if err := r.Keyring.Delete(KeyringService, name); err != nil && err != ErrKeyringNotFound && err != ErrNoKeyring {
+    return true, err
+  }
+  if err := shredFile(path); err != nil {
+    return true, err
+  }
+  r.printfErr("Logged out of %s\n", name)
+  return true, nil
+}
+
+func (r *runner) revoke(env string, conf bitwire.Config) error {
+  mode, url, err := r.envEndpoint(env)
+  if err != nil {
+    return err
+  }
+  client, err := r.NewClient(mode, conf)
+  if err != nil {
+    return err
+  }
+  r.setupClient(client)
+  client.BaseURL = url
+  revoked, err := client.RevokeToken()
+  if err == nil && !revoked {
+    r.printfInfo("The API cannot revoke tokens, the token of %s stays valid until it expires\n", r.keyringUser(env))
+  }
+  return err
+}
+
+// Logs out of the selected environment and profile, or of all environments of all profiles with --all-profiles
+func (r *runner) logoutAction(c *cli.Context) error {
+  if !c.Bool("all-profiles") {
+    if found, err := r.logout(r.envName()); err != nil || found {
+      return err
+    }
+    r.printfErr("Not logged in to %s\n", r.keyringUser(r.envName()))
+    return nil
+  }
+  profiles, err := r.profileNames()
+  if err != nil {
+    return err
+  }
+  saved, any := r.profile, false
+  defer func() { r.profile = saved }()
+  for _, profile := range profiles {
+    r.profile = profile
+    for _, env := range r.envNames() {
+      found, err := r.logout(env)
+      if err != nil {
+        return err
+      }
+      any = any || found
+    }
+  }
+  if !any {
+    r.printfErr("Not logged in to any environment\n")
+  }
+  return nil
+}
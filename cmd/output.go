@@ -0,0 +1,508 @@
+package cmd
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/olekukonko/tablewriter"
+  "io"
+  "reflect"
+  "sigs.k8s.io/yaml"
+  "strings"
+  "time"
+)
+
+const (
+  BLACK = "\033[40m  \033[0m"
+  WHITE = "\033[47m  \033[0m"
+)
+
+// Labels payments in sandbox mode, which are made with testnet coins
+const testnetLabel = "TESTNET (sandbox, coins have no value)"
+
+const (
+  YELLOW = "\033[33m"
+  RESET  = "\033[0m"
+)
+
+func (r *runner) printQr(data string) error {
+  if r.csv {
+    return nil
+  }
+  if r.plain {
+    r.printPaymentText(data)
+    return nil
+  }
+  bitmap, err := r.Terminal.QRCode(data)
+  if err == ErrNoQRCode {
+    r.printPaymentText(data)
+    return nil
+  } else if err != nil {
+    return err
+  }
+
+  clip := 3
+  for i, row := range bitmap {
+    if i >= clip && i < len(bitmap)-clip {
+      for j, cell := range row {
+        if j >= clip && j < len(row)-clip {
+          if cell {
+            fmt.Fprint(r.Stdout, BLACK)
+          } else {
+            fmt.Fprint(r.Stdout, WHITE)
+          }
+        }
+      }
+    }
+    fmt.Fprintln(r.Stdout)
+  }
+  return nil
+}
+
+func formatJson(v interface{}) (string, error) {
+  b, err := json.MarshalIndent(v, "", "  ")
+  if err != nil {
+    return "", err
+  } else {
+    return string(b), nil
+  }
+}
+
+// Prints the value as indented JSON, or as YAML or NDJSON with --output yaml or ndjson,
+// or with the --format template. YAML has the JSON field names.
+func (r *runner) printData(v interface{}) error {
+  if r.template != nil {
+    return writeTemplate(r.Stdout, r.template, v)
+  }
+  switch r.output {
+  case outputFormatYAML:
+    b, err := yaml.Marshal(v)
+    if err != nil {
+      return err
+    }
+    _, err = r.Stdout.Write(b)
+    return err
+  case outputFormatNDJSON:
+    return writeNDJSON(r.Stdout, v)
+  }
+  output, err := formatJson(v)
+  if err != nil {
+    return err
+  }
+  fmt.Fprintln(r.Stdout, output)
+  return nil
+}
+
+// Writes each element of a slice, or else the value, as a line of compact JSON
+func writeNDJSON(w io.Writer, v interface{}) error {
+  encoder := json.NewEncoder(w)
+  return eachItem(v, func(item interface{}) error {
+    return encoder.Encode(item)
+  })
+}
+
+// Calls fn with each element of a slice, or else with the value
+func eachItem(v interface{}, fn func(item interface{}) error) error {
+  value := reflect.ValueOf(v)
+  if value.Kind() != reflect.Slice {
+    return fn(v)
+  }
+  for i := 0; i < value.Len(); i++ {
+    if err := fn(value.Index(i).Interface()); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+var defaultFields = []string{"id", "recipient", "sent", "received", "date", "status", "address"}
+var fieldHeaders = map[string]string{"id": "ID", "recipient": "Recipient",
+  "sent": "Sent (BTC)", "received": "Received", "date": "Date", "status": "Status",
+  "address": "Pay address", "link": "Pay link", "account": "Account", "bank": "Bank", "reference": "Payout reference", "fees": "Fees",
+  "created": "Created at", "expires": "Expires", "currency": "Currency", "rate": "Rate", "ref-rate": "Reference rate (KRW)"}
+
+func validateTableTransferHeader(fields []string) ([]string, []string) {
+  var headers []string
+  var validFields []string
+
+  for _, f := range fields {
+    if h := fieldHeaders[f]; h != "" {
+      validFields = append(validFields, f)
+      headers = append(headers, h)
+    }
+  }
+  return validFields, headers
+}
+
+func fieldData(transfer bitwire.Transfer, field, lang string, mode bitwire.Mode, now time.Time) string {
+  switch field {
+  case "id":
+    return transfer.Id
+  case "recipient":
+    return transfer.Recipient.LocalName(lang)
+  case "sent":
+    return fmt.Sprintf("%s %s", transfer.Amount, transfer.Currency)
+  case "received":
+    return fmt.Sprintf("%s %s", transfer.Recipient.Amount, transfer.Recipient.Currency)
+  case "date":
+    if transfer.CreatedAt.IsZero() {
+      return transfer.Date
+    }
+    return ago(transfer.CreatedAt, now)
+  case "created":
+    if transfer.CreatedAt.IsZero() {
+      return transfer.Date
+    }
+    return transfer.CreatedAt.Local().Format(timestampLayout)
+  case "expires":
+    if expires := transfer.ExpiresAt(); !expires.IsZero() && !transfer.IsFinal() {
+      return expiresIn(expires, now)
+    }
+    return ""
+  case "status":
+    return transfer.Status
+  case "address":
+    if transfer.BTC.Address != "" && transfer.BTC.Validate(mode) != nil {
+      return "(invalid address)"
+    }
+    return transfer.BTC.Address
+  case "link":
+    return transfer.BTC.Link
+  case "bank":
+    return transfer.Recipient.Bank.DisplayName
+  case "account":
+    return transfer.Recipient.Bank.AccountNumber
+  case "reference":
+    return transfer.Payout.Reference
+  case "fees":
+    return feesSummary(transfer.Fees)
+  case "currency":
+    return transfer.Recipient.Currency
+  case "rate":
+    return rate(transfer.Recipient.Amount, transfer.Amount, transfer.Recipient.Currency)
+  }
+  return ""
+}
+
+// Returns the reference rate of the received currency in KRW on the day the transfer was created,
+// empty for KRW transfers and when the provider has no rate for the day
+func (r *runner) referenceRate(transfer bitwire.Transfer) (string, error) {
+  currency := transfer.Recipient.Currency
+  created := transferTime(transfer)
+  if currency == "" || strings.EqualFold(currency, "KRW") || created.IsZero() {
+    return "", nil
+  }
+  rate, err := r.refRates.ReferenceRate(context.Background(), currency, "KRW", created.Local())
+  if errors.Is(err, bitwire.ErrNoReferenceRate) {
+    return "", nil
+  }
+  return rate.String(), err
+}
+
+// Describes the network fee in BTC and the service fee and FX spread together in the fiat currency, empty if none were reported
+func feesSummary(fees bitwire.TransferFees) string {
+  var parts []string
+  if fees.Network.Sign() > 0 {
+    parts = append(parts, fmt.Sprintf("%s BTC", fees.Network))
+  }
+  if fiat := fees.Service.Add(fees.FXSpread); fiat.Sign() > 0 {
+    parts = append(parts, fmt.Sprintf("%s %s", fiat, fees.Currency))
+  }
+  return strings.Join(parts, " + ")
+}
+
+// Describes an underpayment or overpayment of the transfer, empty if it is paid the amount asked for or unpaid
+func fundingNote(tx bitwire.Transfer) string {
+  switch tx.Funding() {
+  case bitwire.FundingUnderpaid:
+    if missing := tx.Missing(); missing.Sign() > 0 {
+      return fmt.Sprintf("underpaid: %s of %s BTC received, %s BTC missing", tx.BTC.Received, tx.Amount, missing)
+    }
+    return "underpaid: pay the missing BTC before the address expires, or contact Bitwire support"
+  case bitwire.FundingOverpaid:
+    if excess := tx.Excess(); excess.Sign() > 0 {
+      return fmt.Sprintf("overpaid by %s BTC; contact Bitwire support for a refund of the excess", excess)
+    }
+    return "overpaid; contact Bitwire support for a refund of the excess"
+  }
+  return ""
+}
+
+func tableTransferData(transfer bitwire.Transfer, fields []string, lang string, mode bitwire.Mode, now time.Time) []string {
+  var values []string
+  for _, f := range fields {
+    values = append(values, fieldData(transfer, f, lang, mode, now))
+  }
+  return values
+}
+
+var tableBankHeader = []string{"ID", "Number", "Name"}
+
+func tableBankData(bank bitwire.Bank, lang string) []string {
+  return []string{fmt.Sprintf("%d", bank.Id), bank.Number, bank.LocalName(lang)}
+}
+
+var tableRatesHeader = []string{"Pair", "Rate"}
+
+var tableLimitsHeader = []string{"Limit", "Value (BTW)"}
+
+var tableTransferLimitsHeader = []string{"Limit", "Value"}
+
+var tableUserHeader = []string{"Account", "Value"}
+
+var tableRequirementsHeader = []string{"Requirement", "Level", "Status"}
+
+func (r *runner) printOutTxs(txs []bitwire.Transfer, fields []string, json bool) error {
+  if json {
+    return r.printData(txs)
+  } else {
+    table := r.newRecordTable()
+    validFields, header := validateTableTransferHeader(fields)
+    rowData := tableTransferData
+    if r.csv {
+      validFields, rowData = csvFields(validFields), csvTransferData
+      header = validFields
+    }
+    table.SetHeader(header)
+    now := time.Now()
+    for i := range txs {
+      row := rowData(txs[i], validFields, r.lang, r.mode, now)
+      for j, f := range validFields {
+        switch {
+        case f == "status":
+          row[j] = r.colorize(r.Stdout, statusColor(txs[i]), row[j])
+        case f == "ref-rate" && r.refRates != nil:
+          var err error
+          if row[j], err = r.referenceRate(txs[i]); err != nil {
+            return err
+          }
+        }
+      }
+      table.Append(row)
+    }
+    table.Render()
+  }
+  return nil
+}
+
+// Describes the used amount, followed in tables by the share of the limit colored by utilizationColor
+func (r *runner) limitUsage(limits bitwire.KrwLimits) string {
+  if r.csv || limits.Limit.Sign() <= 0 {
+    return limits.Used.String()
+  }
+  share := percent(limits.Used, limits.Limit)
+  return fmt.Sprintf("%s (%s)", limits.Used, r.colorize(r.Stdout, utilizationColor(share), fmt.Sprintf("%d%%", share)))
+}
+
+func (r *runner) printOutRecipients(recipients []bitwire.Recipient, columns []string, json bool) error {
+  if json {
+    return r.printData(recipients)
+  }
+  table := r.newRecordTable()
+  header := make([]string, len(columns))
+  for i, column := range columns {
+    header[i] = recipientHeaders[column]
+  }
+  if r.csv {
+    header = columns
+  }
+  table.SetHeader(header)
+  for _, recipient := range recipients {
+    row := make([]string, len(columns))
+    for i, column := range columns {
+      row[i] = recipientFieldData(recipient, column, r.lang)
+    }
+    table.Append(row)
+  }
+  table.Render()
+  return nil
+}
+
+func (r *runner) printOut(obj interface{}, json bool) error {
+  if json {
+    return r.printData(obj)
+  } else {
+    table := r.newTable()
+    var qrLink string
+    switch v := obj.(type) {
+    case bitwire.Transfer:
+      // table.SetHeader([]string{"", ""})
+      table.SetRowLine(true)
+      table.SetAlignment(tablewriter.ALIGN_LEFT)
+      table.Append([]string{"ID", v.Id})
+      if v.IsBankToBtc() {
+        table.Append([]string{"Deposit Bank", v.Deposit.BankName})
+        table.Append([]string{"Deposit Account", v.Deposit.AccountNumber})
+        table.Append([]string{"Account Holder", v.Deposit.AccountHolder})
+        table.Append([]string{"Depositor Name", v.Deposit.Reference})
+        table.Append([]string{"Deposit", fmt.Sprintf("%s %s", v.Deposit.Amount, v.Deposit.Currency)})
+      } else {
+        table.Append([]string{"Recipient", v.Recipient.LocalName(r.lang)})
+        table.Append([]string{"Bank", v.Recipient.Bank.DisplayName})
+        table.Append([]string{"Account Number", v.Recipient.Bank.AccountNumber})
+        table.Append([]string{"Received", v.Recipient.Amount.String()})
+      }
+      table.Append([]string{"Date", agoAt(v.CreatedAt, time.Now(), v.Date)})
+      table.Append([]string{"Status", r.colorize(r.Stdout, statusColor(v), v.Status)})
+      var addrErr error
+      if v.BTC.Address != "" {
+        addrErr = v.BTC.Validate(r.mode)
+      }
+      switch {
+      case v.IsBankToBtc():
+        address := v.BTC.Address
+        if addrErr != nil {
+          address = fmt.Sprintf("%s (%s)", address, addrErr)
+        }
+        table.Append([]string{"Receive Address", address})
+        table.Append([]string{"Received (BTC)", v.Amount.String()})
+      case addrErr != nil:
+        table.Append([]string{"Pay Address", fmt.Sprintf("%s (%s)", v.BTC.Address, addrErr)})
+        defer r.printfErr("%sWarning: not showing the payment QR code, %s%s\n", YELLOW, addrErr, RESET)
+      default:
+        table.Append([]string{"Pay Address", v.BTC.Address})
+        table.Append([]string{"Pay URL", v.BTC.Link})
+      }
+      if v.BTC.Received.Sign() > 0 {
+        table.Append([]string{"Paid (BTC)", v.BTC.Received.String()})
+      }
+      if note := fundingNote(v); note != "" {
+        table.Append([]string{"Funding", note})
+        defer r.printfErr("%sWarning: transfer %s is %s%s\n", YELLOW, v.Id, note, RESET)
+      }
+      if v.Payout.Reference != "" {
+        table.Append([]string{"Payout Reference", v.Payout.Reference})
+      }
+      if v.Payout.Date != "" {
+        table.Append([]string{"Paid Out", agoAt(v.Payout.PaidAt, time.Now(), v.Payout.Date)})
+      }
+      if v.Payout.PayerName != "" {
+        table.Append([]string{"Statement Name", v.Payout.PayerName})
+      }
+      if expires := v.ExpiresAt(); !expires.IsZero() && !v.IsFinal() {
+        table.Append([]string{"Expires", expiresIn(expires, time.Now())})
+      }
+      if r.mode == bitwire.SANDBOX {
+        table.Append([]string{"Network", testnetLabel})
+      }
+      if !v.IsExpired() && addrErr == nil { // Do not offer an expired or invalid address for payment
+        qrLink = v.PaymentURI(r.mode)
+      }
+    case quote:
+      table.SetAlignment(tablewriter.ALIGN_LEFT)
+      if v.Recipient != nil {
+        table.Append([]string{"Recipient", v.Recipient.LocalName(r.lang)})
+        table.Append([]string{"Bank", v.Recipient.Bank.DisplayName})
+        table.Append([]string{"Account Number", v.Recipient.Bank.AccountNumber})
+      }
+      table.Append([]string{"Received", fmt.Sprintf("%s %s", v.Amount, v.Currency)})
+      table.Append([]string{"Rate", fmt.Sprintf("%s %s", v.Rate, v.Pair)})
+      table.Append([]string{"Sent (BTC)", v.BTC.String()})
+      if r.mode == bitwire.SANDBOX {
+        table.Append([]string{"Network", testnetLabel})
+      }
+    case bitwire.Recipient:
+      table.SetAlignment(tablewriter.ALIGN_LEFT)
+      table.Append([]string{"ID", fmt.Sprintf("%d", v.Id)})
+      table.Append([]string{"Name", v.Name})
+      if v.NameKo != "" {
+        table.Append([]string{"Korean Name", v.NameKo})
+      }
+      table.Append([]string{"Email", v.Email})
+      table.Append([]string{"Bank", v.Bank.DisplayName})
+      table.Append([]string{"Account Number", v.Bank.AccountNumber})
+      if v.Bank.AccountName != "" {
+        table.Append([]string{"Account Name", v.Bank.AccountName})
+      }
+    case []bitwire.Recipient:
+      return r.printOutRecipients(v, defaultRecipientColumns, false)
+    case []bitwire.Bank:
+      table.SetHeader(tableBankHeader)
+      for i := range v {
+        table.Append(tableBankData(v[i], r.lang))
+      }
+    case bitwire.AllRates:
+      table.SetHeader(tableRatesHeader)
+      for _, pair := range v.BTC.Pairs() {
+        table.Append([]string{pair, v.BTC[pair].String()})
+      }
+      table.Append([]string{"", ""})
+      for _, pair := range v.FX.Pairs() {
+        table.Append([]string{pair, v.FX[pair].String()})
+      }
+    case bitwire.User:
+      table.SetHeader(tableUserHeader)
+      table.Append([]string{"Email", v.Email})
+      table.Append([]string{"Name", v.Name})
+      table.Append([]string{"ID", fmt.Sprintf("%d", v.Id)})
+      table.Append([]string{"Verification Level", fmt.Sprintf("%d", v.Level)})
+      table.Append([]string{"KYC Status", v.KYCStatus})
+      table.Append([]string{"Mode", string(r.mode)})
+      if r.env != "" {
+        table.Append([]string{"Environment", r.env})
+      }
+      if r.profile != "" {
+        table.Append([]string{"Profile", r.profile})
+      }
+    case bitwire.Verification:
+      table.SetHeader(tableUserHeader)
+      table.Append([]string{"Verification Level", fmt.Sprintf("%d", v.Level)})
+      table.Append([]string{"KYC Status", v.KYCStatus})
+      if v.NextLevel > 0 {
+        table.Append([]string{"Next Level", fmt.Sprintf("%d", v.NextLevel)})
+      }
+      if len(v.Requirements) > 0 {
+        table.Render()
+        table = r.newTable()
+        table.SetHeader(tableRequirementsHeader)
+        for _, req := range v.Requirements {
+          table.Append([]string{req.Type, fmt.Sprintf("%d", req.Level), req.Status})
+        }
+      }
+    case []endpointStats:
+      table.SetHeader(tableStatsHeader)
+      for _, s := range v {
+        table.Append([]string{s.Endpoint, fmt.Sprintf("%d", s.Calls), fmt.Sprintf("%d", s.Errors),
+          formatMillis(s.P50), formatMillis(s.P90), formatMillis(s.P99), formatMillis(s.Max)})
+      }
+    case exposure:
+      table.SetHeader(append(tableExposureHeader, fmt.Sprintf("BTC at -%d%%", v.Band), fmt.Sprintf("BTC at +%d%%", v.Band)))
+      for _, t := range v.Templates {
+        table.Append([]string{t.Template, fmt.Sprintf("%d", t.Recipient), t.Schedule, fmt.Sprintf("%d", t.Payouts),
+          groupDigits(t.Amount.String()) + " " + t.Currency, groupDigits(t.Rate.String()), t.BTC.String(),
+          t.BTCRateDown.String(), t.BTCRateUp.String()})
+      }
+      table.Append(make([]string, len(tableExposureHeader)+2))
+      table.Append([]string{fmt.Sprintf("Next %d days", v.Days), "", "", "", "", "", v.BTC.String(),
+        v.BTCRateDown.String(), v.BTCRateUp.String()})
+    case bitwire.InFlight:
+      table.SetHeader(tableInFlightHeader)
+      table.Append([]string{"Transfers", fmt.Sprintf("%d", v.Pending)})
+      table.Append([]string{"Unfunded transfers", fmt.Sprintf("%d", v.Unfunded)})
+      table.Append([]string{"KRW value", v.KRW.String()})
+      table.Append([]string{"BTC awaiting confirmation", v.AwaitingBTC.String()})
+    case bitwire.Limits:
+      table.SetHeader(tableLimitsHeader)
+      table.Append([]string{"Daily used", r.limitUsage(v.KRW.Daily)})
+      table.Append([]string{"Daily left", v.KRW.Daily.Left.String()})
+      table.Append([]string{"Daily limit", v.KRW.Daily.Limit.String()})
+      table.Append([]string{"Weekly used", r.limitUsage(v.KRW.Weekly)})
+      table.Append([]string{"Weekly left", v.KRW.Weekly.Left.String()})
+      table.Append([]string{"Weekly limit", v.KRW.Weekly.Limit.String()})
+      table.Render()
+
+      table = r.newTable()
+      table.SetHeader(tableTransferLimitsHeader)
+      table.Append([]string{"Pending transfers used", fmt.Sprintf("%d", v.Transfers.Pending.Total.Used)})
+      table.Append([]string{"Pending transfers limit", fmt.Sprintf("%d", v.Transfers.Pending.Total.Limit)})
+      table.Append([]string{"Daily transfers used", fmt.Sprintf("%d", v.Transfers.Completed.Daily.Used)})
+      table.Append([]string{"Daily transfers limit", fmt.Sprintf("%d", v.Transfers.Completed.Daily.Limit)})
+    }
+
+    table.Render()
+    r.printQr(qrLink)
+  }
+  return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+  "errors"
+  "github.com/dworznik/bitwire"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+// Matches shown by the recipient picker at a time
+const pickerRows = 10
+
+// Scores text as an fzf-style match of the query: its letters in order, ignoring case and spaces.
+// Consecutive letters and letters starting a word score higher, gaps lower. ok is false if text does not match.
+func fuzzyScore(query, text string) (score int, ok bool) {
+  q := []rune(strings.ToLower(strings.Join(strings.Fields(query), "")))
+  if len(q) == 0 {
+    return 0, true
+  }
+  t := []rune(strings.ToLower(text))
+  qi, last := 0, -1
+  for ti := 0; ti < len(t) && qi < len(q); ti++ {
+    if t[ti] != q[qi] {
+      continue
+    }
+    score++
+    switch {
+    case last >= 0 && ti == last+1:
+      score += 3
+    case last >= 0:
+      score -= ti - last - 1
+    }
+    if ti == 0 || !unicode.IsLetter(t[ti-1]) && !unicode.IsDigit(t[ti-1]) {
+      score += 2
+    }
+    last = ti
+    qi++
+  }
+  return score, qi == len(q)
+}
+
+// Returns the recipients matching the query by name, Korean name, email, bank or account number,
+// the best matches first. An empty query matches all of them in their order.
+func rankRecipients(recipients []bitwire.Recipient, query string) []bitwire.Recipient {
+  type match struct {
+    recipient bitwire.Recipient
+    score     int
+  }
+  var matches []match
+  for _, rec := range recipients {
+    best, found := 0, false
+    for _, field := range []string{rec.Name, rec.NameKo, rec.Email, rec.Bank.DisplayName, rec.Bank.AccountNumber} {
+      if score, ok := fuzzyScore(query, field); ok && field != "" && (!found || score > best) {
+        best, found = score, true
+      }
+    }
+    if found || strings.TrimSpace(query) == "" {
+      matches = append(matches, match{rec, best})
+    }
+  }
+  sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+  ranked := make([]bitwire.Recipient, len(matches))
+  for i, m := range matches {
+    ranked[i] = m.recipient
+  }
+  return ranked
+}
+
+var errNotPicked = errors.New("No recipient picked")
+
+// Lets the user pick a recipient on stderr and stdin: lists the best matches of the query, numbered,
+// and reads either a number or a new query. A single match is picked without asking; an empty line gives up.
+func (r *runner) pickRecipient(recipients []bitwire.Recipient, query string) (*bitwire.Recipient, error) {
+  if len(recipients) == 0 {
+    return nil, errors.New("No recipients, add one on the Bitwire website first")
+  }
+  for {
+    matches := rankRecipients(recipients, query)
+    if len(matches) == 1 && query != "" {
+      return &matches[0], nil
+    }
+    if len(matches) == 0 {
+      r.printfErr("No recipient matches %s\n", query)
+      query = ""
+      continue
+    }
+    if len(matches) > pickerRows {
+      matches = matches[:pickerRows]
+    }
+    for i, rec := range matches {
+      r.printfErr("%2d) %s  %s  %s %s\n", i+1, rec.LocalName(r.lang), rec.Email, rec.Bank.DisplayName, rec.Bank.AccountNumber)
+    }
+    r.printfErr("Pick 1-%d, or type to search by name, email or bank: ", len(matches))
+    line, _ := readStdin(r.stdinReader())
+    line = strings.TrimSpace(line)
+    if line == "" {
+      return nil, errNotPicked
+    }
+    if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(matches) {
+      return &matches[n-1], nil
+    }
+    query = line
+  }
+}
+
+// Returns the recipient with the ID or the only one matching the query, or else lets the user pick one if pick is set
+func (r *runner) findRecipient(client *bitwire.Client, query string, pick bool) (*bitwire.Recipient, error) {
+  if _, err := strconv.Atoi(query); err == nil || !pick {
+    if query == "" {
+      return nil, errors.New("Missing recipient ID or name, or --pick to choose one")
+    }
+    return r.resolveRecipient(client, query)
+  }
+  recipients, err := client.Recipients.List()
+  if err != nil {
+    return nil, err
+  }
+  return r.pickRecipient(recipients, query)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+  "encoding/csv"
+  "fmt"
+  "github.com/olekukonko/tablewriter"
+  "io"
+  "net/url"
+  "regexp"
+)
+
+// Methods of tablewriter.Table the output uses, so that --plain can render the rows as lines
+type outputTable interface {
+  SetHeader(keys []string)
+  SetRowLine(line bool)
+  SetAlignment(align int)
+  Append(row []string)
+  Render()
+}
+
+var _ outputTable = (*tablewriter.Table)(nil)
+
+// Renders screen reader friendly "label: value" lines instead of a table.
+// Two-column rows are a label and a value; rows of wider tables and of listings are records,
+// printed as one line per column and separated by blank lines.
+type plainTable struct {
+  w       io.Writer
+  header  []string
+  rows    [][]string
+  records bool // Rows are records whatever their width
+}
+
+func (t *plainTable) SetHeader(keys []string) { t.header = keys }
+func (t *plainTable) SetRowLine(line bool)    {}
+func (t *plainTable) SetAlignment(align int)  {}
+func (t *plainTable) Append(row []string)     { t.rows = append(t.rows, row) }
+
+func (t *plainTable) Render() {
+  if len(t.rows) == 0 {
+    return
+  }
+  if len(t.rows[0]) == 2 && !t.records {
+    for _, row := range t.rows {
+      if row[0] != "" || row[1] != "" {
+        fmt.Fprintf(t.w, "%s: %s\n", row[0], row[1])
+      }
+    }
+  } else {
+    for i, row := range t.rows {
+      if i > 0 {
+        fmt.Fprintln(t.w)
+      }
+      for j, value := range row {
+        label := fmt.Sprintf("Column %d", j+1)
+        if j < len(t.header) {
+          label = t.header[j]
+        }
+        fmt.Fprintf(t.w, "%s: %s\n", label, value)
+      }
+    }
+  }
+  fmt.Fprintln(t.w)
+}
+
+// Returns a table writing to stdout, CSV with --output csv and plain lines with --plain
+func (r *runner) newTable() outputTable {
+  if r.csv {
+    return &csvTable{w: csv.NewWriter(r.Stdout)}
+  }
+  if r.plain {
+    return &plainTable{w: r.Stdout}
+  }
+  return tablewriter.NewWriter(r.Stdout)
+}
+
+// Returns a table for a listing, of which rows are records even with two columns selected
+func (r *runner) newRecordTable() outputTable {
+  if r.plain && !r.csv {
+    return &plainTable{w: r.Stdout, records: true}
+  }
+  return r.newTable()
+}
+
+var ansiEscape = regexp.MustCompile("\033\\[[0-9;]*[A-Za-z]")
+
+// Removes ANSI colors and cursor movements
+func stripANSI(s string) string {
+  return ansiEscape.ReplaceAllString(s, "")
+}
+
+// Textual alternative to the payment QR code: the address and the URI, each on its own line
+func (r *runner) printPaymentText(uri string) {
+  if uri == "" {
+    return
+  }
+  if u, err := url.Parse(uri); err == nil && u.Opaque != "" {
+    fmt.Fprintf(r.Stdout, "Pay to address: %s\n", u.Opaque)
+    if amount := u.Query().Get("amount"); amount != "" {
+      fmt.Fprintf(r.Stdout, "Pay amount: %s BTC\n", amount)
+    }
+  }
+  fmt.Fprintf(r.Stdout, "Payment URI: %s\n", uri)
+}
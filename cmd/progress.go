@@ -0,0 +1,65 @@
+package cmd
+
+import (
+  "encoding/json"
+  "errors"
+  "time"
+)
+
+// Values of progressEvent.Event
+const (
+  progressStart  = "start"
+  progressStep   = "progress"
+  progressDone   = "done"
+  progressFailed = "error"
+)
+
+// Event written as a JSON line to stderr with --progress-json, for wrappers showing progress bars
+type progressEvent struct {
+  Time    time.Time `json:"time"`
+  Command string    `json:"command"` // e.g. sync or transfer watch
+  Event   string    `json:"event"`   // start, progress, done or error
+  Step    string    `json:"step,omitempty"`
+  Current int       `json:"current,omitempty"`
+  Total   int       `json:"total,omitempty"`  // Zero when not known in advance
+  Status  string    `json:"status,omitempty"` // Status of the watched transfer
+  Message string    `json:"message,omitempty"`
+  Code    int       `json:"exit_code,omitempty"` // Exit code of a done or error event, if not zero
+}
+
+// Emits the start event of the command and names the command of the following events
+func (r *runner) progressBegin(command string, total int) {
+  r.progressCmd = command
+  r.progress(progressEvent{Event: progressStart, Total: total})
+}
+
+// Writes the event to stderr if --progress-json is set and a command began reporting progress
+func (r *runner) progress(e progressEvent) {
+  if !r.progressJSON || r.progressCmd == "" {
+    return
+  }
+  e.Time = time.Now().UTC()
+  e.Command = r.progressCmd
+  if b, err := json.Marshal(e); err == nil {
+    r.Stderr.Write(append(b, '\n'))
+  }
+}
+
+// Emits the done event, or the error event if err is set, and stops reporting progress.
+// A watch ending with the exit code of an expired or canceled transfer is done.
+func (r *runner) progressEnd(err error) {
+  var exit *exitError
+  switch {
+  case err == nil:
+    r.progress(progressEvent{Event: progressDone})
+  case errors.As(err, &exit) && exit.err == nil:
+    r.progress(progressEvent{Event: progressDone, Code: exit.code})
+  default:
+    code := 1
+    if errors.As(err, &exit) {
+      code = exit.code
+    }
+    r.progress(progressEvent{Event: progressFailed, Message: err.Error(), Code: code})
+  }
+  r.progressCmd = ""
+}
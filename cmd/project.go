@@ -0,0 +1,120 @@
+package cmd
+
+import (
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sigs.k8s.io/yaml"
+  "strings"
+)
+
+// Project settings file, looked up like .editorconfig from the working directory up to the git root
+const ProjectFile = ".bitwire.yaml"
+
+// Version of the project file format this CLI reads. Files of a newer version are refused, not to skip their guards.
+const projectVersion = 1
+
+// Settings of a project-local .bitwire.yaml, shared by everyone working in the repository, so that team scripts
+// behave the same on every machine. Flags and environment variables take precedence over them, and they over
+// the preferences of the user.
+type projectConfig struct {
+  Version     int    `json:"version,omitempty"` // projectVersion if not set
+  Profile     string `json:"profile,omitempty"`
+  Env         string `json:"env,omitempty"`    // production, sandbox or a defined environment, as with --env
+  Output      string `json:"output,omitempty"` // As with --output
+  Plain       bool   `json:"plain,omitempty"`
+  NoColor     bool   `json:"no_color,omitempty"`
+  QuietBanner bool   `json:"quiet_banner,omitempty"`
+
+  Guards projectGuards `json:"guards,omitempty"`
+}
+
+// Guard rails of a project, which no flag lifts
+type projectGuards struct {
+  SandboxOnly bool                       `json:"sandbox_only,omitempty"` // Refuse to run in production mode
+  MaxAmount   map[string]bitwire.Decimal `json:"max_amount,omitempty"`   // Largest transfer by currency, e.g. KRW
+}
+
+// Returns the path of the project file in dir or the closest parent, stopping at the git root. Empty if none.
+func findProjectFile(dir string) string {
+  for dir != "" {
+    path := filepath.Join(dir, ProjectFile)
+    if _, err := os.Stat(path); err == nil {
+      return path
+    }
+    if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+      return ""
+    }
+    parent := filepath.Dir(dir)
+    if parent == dir {
+      return ""
+    }
+    dir = parent
+  }
+  return ""
+}
+
+// Reads the project file of the working directory, if any. Unknown settings, e.g. added by a later version
+// of the same format, are warned about and ignored.
+func (r *runner) readProject() (projectConfig, string, error) {
+  project := projectConfig{}
+  path := findProjectFile(r.WorkDir)
+  if path == "" {
+    return project, "", nil
+  }
+  data, err := ioutil.ReadFile(path)
+  if err != nil {
+    return project, path, err
+  }
+  if err := yaml.UnmarshalStrict(data, &project); err != nil {
+    if err := yaml.Unmarshal(data, &project); err != nil {
+      return project, path, fmt.Errorf("%s: %s", path, err)
+    }
+    r.printfErr("%sWarning: ignoring settings of %s: %s%s\n", YELLOW, path, err, RESET)
+  }
+  max := map[string]bitwire.Decimal{}
+  for currency, amount := range project.Guards.MaxAmount {
+    max[strings.ToUpper(currency)] = amount
+  }
+  project.Guards.MaxAmount = max
+  if project.Version > projectVersion {
+    return project, path, fmt.Errorf("%s is of version %d, this bitwire %s reads version %d\n"+
+      "Upgrade bitwire to run in this project", path, project.Version, Version, projectVersion)
+  }
+  return project, path, nil
+}
+
+// Applies the project settings not overridden by flags or environment variables
+func (r *runner) applyProject(c *cli.Context) {
+  if r.profile == "" {
+    r.profile = r.project.Profile
+  }
+  if r.envFlag == "" && !r.sandbox {
+    r.envFlag = r.project.Env
+  }
+  if !c.GlobalIsSet("output") && !r.json && r.format == "" && r.project.Output != "" {
+    r.output = r.project.Output
+  }
+  r.plain = r.plain || r.project.Plain
+  r.noColor = r.noColor || r.project.NoColor
+}
+
+// Enforces the guards of the project that depend on the mode
+func (r *runner) checkProjectGuards() error {
+  if r.project.Guards.SandboxOnly && r.mode != bitwire.SANDBOX {
+    return fmt.Errorf("The project settings of %s allow the sandbox only", r.projectPath)
+  }
+  return nil
+}
+
+// Enforces the largest transfer amount of the project
+func (r *runner) checkMaxAmount(amount bitwire.Decimal, currency string) error {
+  if max, ok := r.project.Guards.MaxAmount[currency]; ok && amount.Cmp(max) > 0 {
+    return fmt.Errorf("The amount %s %s is over the maximum of %s %s set by %s", groupDigits(amount.String()), currency,
+      groupDigits(max.String()), currency, r.projectPath)
+  }
+  return nil
+}
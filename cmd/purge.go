@@ -0,0 +1,152 @@
+package cmd
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/cli"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Files of the local store, in the order they are purged
+var storeNames = []string{limitsStore, ratesStore, transfersStore, recipientsStore, syncStore, listStateStore, deprecationsStore, callsStore}
+
+// Stores whose last record is kept by purges, as the next run continues from it
+var keepLast = map[string]bool{syncStore: true, listStateStore: true}
+
+// Parses an age like 90d, 12w, 6m or 2y and returns the time that long before now
+func ageCutoff(age string, now time.Time) (time.Time, error) {
+  invalid := errors.New("Invalid age: " + age + "\nUse a number of days, weeks, months or years, e.g. 90d, 12w, 6m or 2y")
+  if len(age) < 2 {
+    return time.Time{}, invalid
+  }
+  n, err := strconv.Atoi(age[:len(age)-1])
+  if err != nil || n < 0 {
+    return time.Time{}, invalid
+  }
+  switch strings.ToLower(age[len(age)-1:]) {
+  case "d":
+    return now.AddDate(0, 0, -n), nil
+  case "w":
+    return now.AddDate(0, 0, -7*n), nil
+  case "m":
+    return now.AddDate(0, -n, 0), nil
+  case "y":
+    return now.AddDate(-n, 0, 0), nil
+  }
+  return time.Time{}, invalid
+}
+
+// Time a record is aged by: when a synced transfer was created, and when other records were stored
+func recordTime(name string, data []byte) (time.Time, error) {
+  if name == transfersStore {
+    synced := syncedTransfer{}
+    if err := json.Unmarshal(data, &synced); err != nil {
+      return time.Time{}, err
+    }
+    if !synced.Transfer.CreatedAt.IsZero() {
+      return synced.Transfer.CreatedAt, nil
+    }
+    return synced.Time, nil
+  }
+  record := struct {
+    Time time.Time `json:"time"`
+  }{}
+  err := json.Unmarshal(data, &record)
+  return record.Time, err
+}
+
+// Removed records of a store file
+type purgeCount struct {
+  Mode    string `json:"mode"` // Environment
+  Store   string `json:"store"`
+  Removed int    `json:"removed"`
+}
+
+// Removes the records older than the cutoff from the store of every environment.
+// The last sync is kept, so that the next one continues from its cursor, and so is the last transfer list state.
+func (r *runner) purge(cutoff time.Time) ([]purgeCount, error) {
+  var counts []purgeCount
+  envs := r.envNames()
+  for i, env := range envs {
+    for j, name := range storeNames {
+      r.progress(progressEvent{Event: progressStep, Step: env + "/" + name, Current: i*len(storeNames) + j + 1,
+        Total: len(envs) * len(storeNames)})
+      var last time.Time
+      if keepLast[name] {
+        saved := r.env
+        r.env = env
+        err := r.readRecords(name, func(data []byte) error {
+          var err error
+          last, err = recordTime(name, data)
+          return err
+        })
+        r.env = saved
+        if err != nil {
+          return counts, err
+        }
+      }
+      removed, err := r.rewriteRecords(env, name, func(data []byte) (bool, error) {
+        t, err := recordTime(name, data)
+        if err != nil {
+          return false, err
+        }
+        return !t.Before(cutoff) || (keepLast[name] && t.Equal(last)), nil
+      })
+      if err != nil {
+        return counts, err
+      }
+      if removed > 0 {
+        counts = append(counts, purgeCount{env, name, removed})
+      }
+    }
+  }
+  return counts, nil
+}
+
+// Purges the records older than the retention set in the preferences. Failures are reported but
+// do not fail the command, which has done its job already.
+func (r *runner) applyRetention() {
+  if r.prefs.Retention == "" {
+    return
+  }
+  cutoff, err := ageCutoff(r.prefs.Retention, time.Now())
+  if err == nil {
+    _, err = r.purge(cutoff)
+  }
+  if err != nil {
+    r.printfErr("Could not apply the retention preference: %s\n", err)
+  }
+}
+
+func (r *runner) dbPurgeAction(c *cli.Context) error {
+  age := c.String("older-than")
+  if age == "" {
+    return errors.New("Missing --older-than\nUsage: db purge --older-than 2y")
+  }
+  cutoff, err := ageCutoff(age, time.Now())
+  if err != nil {
+    return err
+  }
+  r.progressBegin("db purge", 2*len(storeNames))
+  counts, err := r.purge(cutoff)
+  r.progressEnd(err)
+  if err != nil {
+    return err
+  }
+  if r.json {
+    if counts == nil {
+      counts = []purgeCount{}
+    }
+    return r.printOut(counts, true)
+  }
+  if len(counts) == 0 {
+    fmt.Fprintf(r.Stdout, "No records older than %s\n", cutoff.Format(dateLayout))
+  }
+  for _, count := range counts {
+    fmt.Fprintf(r.Stdout, "Removed %d %s %s records older than %s\n", count.Removed, count.Mode, count.Store, cutoff.Format(dateLayout))
+  }
+  return nil
+}
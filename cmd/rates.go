@@ -0,0 +1,290 @@
+package cmd
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "math/big"
+  "os"
+  "os/exec"
+  "runtime"
+  "time"
+)
+
+const ratesStore = "rates"
+
+// Sides of the rates watch thresholds a rate is on
+const (
+  rateWithin = ""
+  rateAbove  = "above"
+  rateBelow  = "below"
+)
+
+// Levels of the rates history sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+type ratesSnapshot struct {
+  Time  time.Time        `json:"time"`
+  Rates bitwire.AllRates `json:"rates"`
+}
+
+// Rate of a pair over a day, from the snapshots taken that day
+type dailyRate struct {
+  Day     string          `json:"day"` // 2006-01-02
+  Low     bitwire.Decimal `json:"low"`
+  High    bitwire.Decimal `json:"high"`
+  Close   bitwire.Decimal `json:"close"` // Last rate of the day
+  Samples int             `json:"samples"`
+}
+
+func (r *runner) recordRates(rates bitwire.AllRates) error {
+  r.ratesRecorded = true
+  return r.appendRecord(ratesStore, ratesSnapshot{time.Now(), rates})
+}
+
+func (r *runner) readRatesHistory() ([]ratesSnapshot, error) {
+  var history []ratesSnapshot
+  err := r.readRecords(ratesStore, func(data []byte) error {
+    snapshot := ratesSnapshot{}
+    if err := json.Unmarshal(data, &snapshot); err != nil {
+      return err
+    }
+    history = append(history, snapshot)
+    return nil
+  })
+  return history, err
+}
+
+// Snapshots the rates after a command that called the API, at most once per snapshotInterval,
+// so that rates history fills in without running `bitwire rates`. Failures are ignored.
+func (r *runner) snapshotRates() {
+  if r.client == nil || r.ratesRecorded || r.client.LastResponse().HTTPStatus == 0 {
+    return
+  }
+  history, err := r.readRatesHistory()
+  if err != nil {
+    return
+  }
+  if n := len(history); n > 0 && time.Since(history[n-1].Time) < snapshotInterval {
+    return
+  }
+  if rates, err := r.client.Rates.All(); err == nil {
+    r.recordRates(rates)
+  }
+}
+
+// Returns the rate of a pair such as BTCKRW, or a cross rate such as USDJPY derived from the quoted pairs
+func pairRate(rates bitwire.AllRates, pair string) (bitwire.Decimal, bool) {
+  if len(pair) != 6 {
+    return bitwire.Decimal{}, false
+  }
+  rate, err := rates.Rate(pair[:3], pair[3:])
+  return rate, err == nil
+}
+
+// Returns the low, high and closing rate of the pair per day since the given time
+func pairHistory(history []ratesSnapshot, pair string, since time.Time) []dailyRate {
+  var days []dailyRate
+  for _, s := range history {
+    if s.Time.Before(since) {
+      continue
+    }
+    rate, ok := pairRate(s.Rates, pair)
+    if !ok {
+      continue
+    }
+    day := s.Time.Local().Format(dateLayout)
+    if n := len(days); n > 0 && days[n-1].Day == day {
+      d := &days[n-1]
+      if rate.Cmp(d.Low) < 0 {
+        d.Low = rate
+      }
+      if rate.Cmp(d.High) > 0 {
+        d.High = rate
+      }
+      d.Close = rate
+      d.Samples++
+    } else {
+      days = append(days, dailyRate{day, rate, rate, rate, 1})
+    }
+  }
+  return days
+}
+
+// Draws the closing rates as a line of block characters scaled between the lowest and highest one
+func sparkline(days []dailyRate) string {
+  if len(days) == 0 {
+    return ""
+  }
+  low, high := days[0].Close, days[0].Close
+  for _, d := range days {
+    if d.Close.Cmp(low) < 0 {
+      low = d.Close
+    }
+    if d.Close.Cmp(high) > 0 {
+      high = d.Close
+    }
+  }
+  span := high.Sub(low)
+  top := big.NewRat(int64(len(sparkLevels)-1), 1)
+  line := make([]rune, len(days))
+  for i, d := range days {
+    level := 0
+    if span.Sign() > 0 {
+      scaled := new(big.Rat).Quo(d.Close.Sub(low).Rat(), span.Rat())
+      scaled.Mul(scaled, top)
+      level = int(new(big.Int).Quo(scaled.Num(), scaled.Denom()).Int64())
+    }
+    line[i] = sparkLevels[level]
+  }
+  return string(line)
+}
+
+func (r *runner) ratesHistoryAction(c *cli.Context) error {
+  history, err := r.readRatesHistory()
+  if err != nil {
+    return err
+  }
+  pair := c.String("pair")
+  days := pairHistory(history, pair, time.Now().AddDate(0, 0, -c.Int("days")))
+  if r.json {
+    if days == nil {
+      days = []dailyRate{}
+    }
+    return r.printData(days)
+  }
+  if len(days) == 0 {
+    r.printfErr("No %s rates recorded in %s mode in the last %d days. Rates are recorded by `bitwire rates` and, hourly, by other commands.\n",
+      pair, r.mode, c.Int("days"))
+    return nil
+  }
+  first, last := days[0], days[len(days)-1]
+  if c.Bool("sparkline") && !r.plain {
+    fmt.Fprintf(r.Stdout, "%s %s..%s  %s  %s -> %s\n", pair, first.Day, last.Day, sparkline(days), first.Close, last.Close)
+    return nil
+  }
+  table := r.newTable()
+  table.SetHeader([]string{"Day", "Low", "High", "Close"})
+  for _, d := range days {
+    table.Append([]string{d.Day, d.Low.String(), d.High.String(), d.Close.String()})
+  }
+  table.Render()
+  return nil
+}
+
+// Thresholds of rates watch. A zero threshold is not set.
+type rateThresholds struct {
+  Above bitwire.Decimal
+  Below bitwire.Decimal
+}
+
+// Returns rateAbove or rateBelow if the rate crossed a threshold, rateWithin otherwise
+func (t rateThresholds) side(rate bitwire.Decimal) string {
+  switch {
+  case t.Above.Sign() > 0 && rate.Cmp(t.Above) > 0:
+    return rateAbove
+  case t.Below.Sign() > 0 && rate.Cmp(t.Below) < 0:
+    return rateBelow
+  default:
+    return rateWithin
+  }
+}
+
+func (r *runner) ratesWatchAction(c *cli.Context) error {
+  usage := "Usage: rates watch [--pair BTCKRW] [--above rate] [--below rate]"
+  var thresholds rateThresholds
+  for _, flag := range []struct {
+    name  string
+    value *bitwire.Decimal
+  }{{"above", &thresholds.Above}, {"below", &thresholds.Below}} {
+    if s := c.String(flag.name); s != "" {
+      value, err := bitwire.ParseDecimal(s)
+      if err != nil || value.Sign() <= 0 {
+        return fmt.Errorf("Invalid --%s rate: %s\n%s", flag.name, s, usage)
+      }
+      *flag.value = value
+    }
+  }
+  if thresholds.Above.Sign() == 0 && thresholds.Below.Sign() == 0 {
+    return errors.New("Missing --above or --below rate\n" + usage)
+  }
+  // Rates are public, unlike transfer watch
+  client, err := r.newClient("rates")
+  if err != nil {
+    return err
+  }
+  r.progressBegin("rates watch", 0)
+  err = r.watchRates(client, c.String("pair"), thresholds, c.Duration("interval"), c.String("exec"), c.Bool("once"))
+  r.progressEnd(err)
+  return err
+}
+
+// Polls the rates and alerts each time the rate of the pair crosses a threshold.
+// Returns after the first alert if once is set, otherwise runs until interrupted.
+func (r *runner) watchRates(client *bitwire.Client, pair string, thresholds rateThresholds, interval time.Duration,
+  command string, once bool) error {
+  if interval < time.Second {
+    interval = time.Second
+  }
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  beep := r.Terminal.IsTerminal(r.Stdout) && !r.plain
+  side := rateWithin
+  updates := 0
+  for rates := range client.SubscribeRates(ctx, interval) {
+    rate, ok := pairRate(rates, pair)
+    if !ok {
+      return fmt.Errorf("Unknown rate pair: %s", pair)
+    }
+    r.recordRates(rates)
+    updates++
+    r.progress(progressEvent{Event: progressStep, Step: "rate", Current: updates, Message: rate.String()})
+    if updates == 1 {
+      r.printfInfo("Watching %s, now %s\n", pair, rate)
+    }
+    crossed := thresholds.side(rate)
+    if crossed == side {
+      continue
+    }
+    side = crossed
+    now := time.Now().Local().Format(timestampLayout)
+    if crossed == rateWithin {
+      fmt.Fprintf(r.Stdout, "%s  %s %s is back within the thresholds\n", now, pair, rate)
+      continue
+    }
+    threshold := thresholds.Above
+    if crossed == rateBelow {
+      threshold = thresholds.Below
+    }
+    fmt.Fprintf(r.Stdout, "%s  %s %s is %s %s\n", now, pair, rate, crossed, threshold)
+    if beep {
+      fmt.Fprint(r.Stdout, "\a")
+    }
+    if command != "" {
+      env := []string{"BITWIRE_PAIR=" + pair, "BITWIRE_RATE=" + rate.String(),
+        "BITWIRE_THRESHOLD=" + threshold.String(), "BITWIRE_CROSSED=" + crossed}
+      if err := r.runAlertCommand(command, env); err != nil {
+        r.printfErr("%sWarning: --exec command failed: %s%s\n", YELLOW, err, RESET)
+      }
+    }
+    if once {
+      return nil
+    }
+  }
+  return nil
+}
+
+// Runs the --exec command of rates watch in the shell, with the alert in its environment
+func (r *runner) runAlertCommand(command string, env []string) error {
+  shell, flag := "sh", "-c"
+  if runtime.GOOS == "windows" {
+    shell, flag = "cmd", "/C"
+  }
+  cmd := exec.Command(shell, flag, command)
+  cmd.Env = append(os.Environ(), env...)
+  cmd.Stdout, cmd.Stderr = r.Stdout, r.Stderr
+  return cmd.Run()
+}
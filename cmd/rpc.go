@@ -0,0 +1,178 @@
+package cmd
+
+import (
+  "bufio"
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire"
+  "io"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+  rpcParseError     = -32700
+  rpcInvalidRequest = -32600
+  rpcMethodNotFound = -32601
+  rpcInvalidParams  = -32602
+  rpcAPIError       = -32000
+)
+
+type rpcRequest struct {
+  JSONRPC string          `json:"jsonrpc"`
+  Id      json.RawMessage `json:"id,omitempty"`
+  Method  string          `json:"method"`
+  Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+  JSONRPC string          `json:"jsonrpc"`
+  Id      json.RawMessage `json:"id"`
+  Result  json.RawMessage `json:"result,omitempty"` // Set on success, even if null
+  Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+  Code    int         `json:"code"`
+  Message string      `json:"message"`
+  Data    interface{} `json:"data,omitempty"`
+}
+
+// Params of the methods taking a transfer ID
+type rpcIdParams struct {
+  Id string `json:"id"`
+}
+
+var errInvalidParams = errors.New("Invalid params")
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+  if len(params) == 0 || json.Unmarshal(params, v) != nil {
+    return errInvalidParams
+  }
+  return nil
+}
+
+func decodeId(params json.RawMessage) (string, error) {
+  p := rpcIdParams{}
+  if err := decodeParams(params, &p); err != nil || p.Id == "" {
+    return "", errInvalidParams
+  }
+  return p.Id, nil
+}
+
+// Methods served over RPC, named after the client services
+var rpcMethods = map[string]func(*bitwire.Client, json.RawMessage) (interface{}, error){
+  "rates.all": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Rates.All()
+  },
+  "rates.fx": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Rates.Fx()
+  },
+  "rates.btc": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Rates.Btc()
+  },
+  "banks.list": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Banks.List()
+  },
+  "recipients.list": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Recipients.List()
+  },
+  "transfers.list": func(c *bitwire.Client, params json.RawMessage) (interface{}, error) {
+    opts := bitwire.TransferListOptions{}
+    if len(params) > 0 {
+      if err := decodeParams(params, &opts); err != nil {
+        return nil, err
+      }
+    }
+    return c.Transfers.List(&opts)
+  },
+  "transfers.get": func(c *bitwire.Client, params json.RawMessage) (interface{}, error) {
+    id, err := decodeId(params)
+    if err != nil {
+      return nil, err
+    }
+    return c.Transfers.Get(id)
+  },
+  "transfers.create": func(c *bitwire.Client, params json.RawMessage) (interface{}, error) {
+    trans := bitwire.CreateTransfer{}
+    if err := decodeParams(params, &trans); err != nil {
+      return nil, err
+    }
+    return c.Transfers.Create(trans)
+  },
+  "transfers.estimate": func(c *bitwire.Client, params json.RawMessage) (interface{}, error) {
+    p := struct {
+      Amount   bitwire.Decimal `json:"amount"`
+      Currency string          `json:"currency"`
+    }{Currency: "KRW"}
+    if err := decodeParams(params, &p); err != nil {
+      return nil, err
+    }
+    return c.Transfers.Estimate(p.Amount, p.Currency)
+  },
+  "transfers.cancel": func(c *bitwire.Client, params json.RawMessage) (interface{}, error) {
+    id, err := decodeId(params)
+    if err != nil {
+      return nil, err
+    }
+    return c.Transfers.Cancel(id)
+  },
+  "limits.get": func(c *bitwire.Client, _ json.RawMessage) (interface{}, error) {
+    return c.Limits.Get()
+  },
+}
+
+// Runs a single request. Returns nil for notifications, which get no response.
+func serveRpcRequest(client *bitwire.Client, line []byte) *rpcResponse {
+  req := rpcRequest{}
+  if err := json.Unmarshal(line, &req); err != nil {
+    return &rpcResponse{"2.0", json.RawMessage("null"), nil, &rpcError{rpcParseError, "Parse error", nil}}
+  }
+  res := &rpcResponse{JSONRPC: "2.0", Id: req.Id}
+  if len(req.Id) == 0 {
+    res.Id = json.RawMessage("null")
+  }
+  method, ok := rpcMethods[req.Method]
+  switch {
+  case req.JSONRPC != "2.0" || req.Method == "":
+    res.Error = &rpcError{rpcInvalidRequest, "Invalid request", nil}
+  case !ok:
+    res.Error = &rpcError{rpcMethodNotFound, "Method not found", req.Method}
+  default:
+    result, err := method(client, req.Params)
+    var apiErr *bitwire.APIError
+    switch {
+    case err == errInvalidParams:
+      res.Error = &rpcError{rpcInvalidParams, err.Error(), nil}
+    case errors.As(err, &apiErr):
+      res.Error = &rpcError{rpcAPIError, err.Error(), apiErr}
+    case err != nil:
+      res.Error = &rpcError{rpcAPIError, err.Error(), nil}
+    default:
+      if res.Result, err = json.Marshal(result); err != nil {
+        res.Error = &rpcError{rpcAPIError, err.Error(), nil}
+      }
+    }
+  }
+  if len(req.Id) == 0 && res.Error == nil {
+    return nil
+  }
+  return res
+}
+
+// Serves newline delimited JSON-RPC 2.0 requests until the input is closed
+func serveRpc(client *bitwire.Client, in io.Reader, out io.Writer) error {
+  scanner := bufio.NewScanner(in)
+  scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+  encoder := json.NewEncoder(out)
+  for scanner.Scan() {
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    if res := serveRpcRequest(client, scanner.Bytes()); res != nil {
+      if err := encoder.Encode(res); err != nil {
+        return err
+      }
+    }
+  }
+  return scanner.Err()
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "time"
+)
+
+var staleFields = []string{"id", "recipient", "sent", "received", "status", "expires"}
+
+func (r *runner) transferCancelStaleAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  policy := bitwire.CancelPolicy{Before: c.Duration("before"), ExcludeIds: c.StringSlice("exclude"),
+    ExcludeRecipients: c.IntSlice("exclude-recipient"), ExcludeLabels: c.StringSlice("exclude-label")}
+  every := c.Duration("every")
+  for {
+    if err := r.cancelStale(client, policy, c.Bool("dry-run")); err != nil {
+      return err
+    }
+    if every <= 0 {
+      return nil
+    }
+    time.Sleep(every)
+  }
+}
+
+// Cancels the stale transfers, or with dryRun lists the ones that would be cancelled
+func (r *runner) cancelStale(client *bitwire.Client, policy bitwire.CancelPolicy, dryRun bool) error {
+  if !dryRun {
+    txs, err := client.Transfers.CancelStale(policy)
+    if len(txs) > 0 {
+      r.printfInfo("Cancelled %d stale transfers\n", len(txs))
+      r.printOutTxs(txs, staleFields, r.json)
+    }
+    return err
+  }
+  all, err := client.Transfers.List(nil)
+  if err != nil {
+    return err
+  }
+  var txs []bitwire.Transfer
+  now := time.Now()
+  for _, tx := range all {
+    if policy.Stale(tx, now) {
+      txs = append(txs, tx)
+    }
+  }
+  if len(txs) == 0 {
+    r.printfInfo("No stale transfers\n")
+    return nil
+  }
+  r.printfInfo("Would cancel %d stale transfers\n", len(txs))
+  return r.printOutTxs(txs, staleFields, r.json)
+}
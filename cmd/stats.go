@@ -0,0 +1,106 @@
+package cmd
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "sort"
+  "time"
+)
+
+const callsStore = "calls"
+
+var tableStatsHeader = []string{"Endpoint", "Calls", "Errors", "p50", "p90", "p99", "Max"}
+
+// API call recorded by the OnCall hook of the client
+type callRecord struct {
+  Time     time.Time `json:"time"`
+  Method   string    `json:"method"`
+  Endpoint string    `json:"endpoint"`
+  Status   int       `json:"status"`
+  Millis   int64     `json:"ms"`
+  Failed   bool      `json:"failed,omitempty"`
+}
+
+// Call counts and latency percentiles, in milliseconds, of an endpoint
+type endpointStats struct {
+  Endpoint string `json:"endpoint"` // Method and path, e.g. GET transfers/:id
+  Calls    int    `json:"calls"`
+  Errors   int    `json:"errors"`
+  P50      int64  `json:"p50_ms"`
+  P90      int64  `json:"p90_ms"`
+  P99      int64  `json:"p99_ms"`
+  Max      int64  `json:"max_ms"`
+}
+
+// Records the call in the local store for `bitwire stats`. Failures are ignored, the record is only a convenience.
+func (r *runner) recordCall(call bitwire.CallTiming) {
+  r.appendRecord(callsStore, callRecord{time.Now(), call.Method, call.Endpoint, call.Status,
+    int64(call.Duration / time.Millisecond), call.Err != nil})
+}
+
+// Returns the value at the nearest rank of the percentile in the sorted values
+func percentile(sorted []int64, p int) int64 {
+  rank := (p*len(sorted) + 99) / 100
+  if rank < 1 {
+    rank = 1
+  }
+  return sorted[rank-1]
+}
+
+// Groups the calls by endpoint, the most called first
+func callStats(calls []callRecord) []endpointStats {
+  latencies := map[string][]int64{}
+  errors := map[string]int{}
+  for _, call := range calls {
+    endpoint := call.Method + " " + call.Endpoint
+    latencies[endpoint] = append(latencies[endpoint], call.Millis)
+    if call.Failed {
+      errors[endpoint]++
+    }
+  }
+  stats := []endpointStats{}
+  for endpoint, ms := range latencies {
+    sort.Slice(ms, func(i, j int) bool { return ms[i] < ms[j] })
+    stats = append(stats, endpointStats{endpoint, len(ms), errors[endpoint],
+      percentile(ms, 50), percentile(ms, 90), percentile(ms, 99), ms[len(ms)-1]})
+  }
+  sort.Slice(stats, func(i, j int) bool {
+    if stats[i].Calls != stats[j].Calls {
+      return stats[i].Calls > stats[j].Calls
+    }
+    return stats[i].Endpoint < stats[j].Endpoint
+  })
+  return stats
+}
+
+func (r *runner) statsAction(c *cli.Context) error {
+  since, err := ageCutoff(c.String("since"), time.Now())
+  if err != nil {
+    return err
+  }
+  var calls []callRecord
+  err = r.readRecords(callsStore, func(data []byte) error {
+    call := callRecord{}
+    if err := json.Unmarshal(data, &call); err != nil {
+      return err
+    }
+    if !call.Time.Before(since) {
+      calls = append(calls, call)
+    }
+    return nil
+  })
+  if err != nil {
+    return err
+  }
+  if len(calls) == 0 && !r.json {
+    r.printfInfo("No API calls recorded since %s\n", since.Format("2006-01-02"))
+    return nil
+  }
+  return r.printOut(callStats(calls), r.json)
+}
+
+func formatMillis(ms int64) string {
+  return fmt.Sprintf("%dms", ms)
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+  "bufio"
+  "encoding/json"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+)
+
+// Local store for data the CLI collects across runs, kept as append-only JSON lines files
+// in ~/.bitwire/history, or the history directory of the profile, one file per environment and kind of record
+
+func (r *runner) storePath(name string) string {
+  return r.envStorePath(r.envName(), name)
+}
+
+func (r *runner) envStorePath(env string, name string) string {
+  return r.profilePath(HistoryDir + "/" + env + "-" + name + ".jsonl")
+}
+
+// Appends the record to the named file
+func (r *runner) appendRecord(name string, record interface{}) error {
+  path := r.storePath(name)
+  if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+    return err
+  }
+  data, err := json.Marshal(record)
+  if err != nil {
+    return err
+  }
+  file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  _, err = file.Write(append(data, '\n'))
+  return err
+}
+
+// Calls fn with each record of the named file, the oldest first. Missing file means no records.
+func (r *runner) readRecords(name string, fn func(data []byte) error) error {
+  file, err := os.Open(r.storePath(name))
+  if os.IsNotExist(err) {
+    return nil
+  } else if err != nil {
+    return err
+  }
+  defer file.Close()
+  scanner := bufio.NewScanner(file)
+  for scanner.Scan() {
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    if err := fn(scanner.Bytes()); err != nil {
+      return err
+    }
+  }
+  return scanner.Err()
+}
+
+// Rewrites the named file of the environment with the records keep accepts, and returns how many were removed.
+// The file is replaced at once, so that an interrupted rewrite does not lose records.
+func (r *runner) rewriteRecords(env string, name string, keep func(data []byte) (bool, error)) (int, error) {
+  path := r.envStorePath(env, name)
+  in, err := os.Open(path)
+  if os.IsNotExist(err) {
+    return 0, nil
+  } else if err != nil {
+    return 0, err
+  }
+  defer in.Close()
+  out, err := ioutil.TempFile(filepath.Dir(path), name)
+  if err != nil {
+    return 0, err
+  }
+  defer os.Remove(out.Name())
+  removed := 0
+  scanner := bufio.NewScanner(in)
+  for scanner.Scan() {
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    ok, err := keep(scanner.Bytes())
+    if err != nil {
+      out.Close()
+      return 0, err
+    }
+    if !ok {
+      removed++
+      continue
+    }
+    if _, err := out.Write(append(scanner.Bytes(), '\n')); err != nil {
+      out.Close()
+      return 0, err
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    out.Close()
+    return 0, err
+  }
+  if err := out.Close(); err != nil {
+    return 0, err
+  }
+  if removed == 0 {
+    return 0, nil
+  }
+  return removed, os.Rename(out.Name(), path)
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "net/http"
+  "sync"
+  "time"
+)
+
+// Path of the rate stream served by listen --rates-stream
+const ratesStreamPath = "/rates/stream"
+
+// Fans the rates, polled once for all clients, out to the clients of the rate stream as server-sent events.
+// Polls only while clients are connected. Slow clients skip to the latest rates instead of queueing them.
+type rateStream struct {
+  client   *bitwire.Client
+  interval time.Duration
+
+  mu      sync.Mutex
+  clients map[chan bitwire.AllRates]bool
+  last    *bitwire.AllRates  // Latest rates of the current polling, sent first to new clients
+  cancel  context.CancelFunc // Stops the polling, nil when not polling
+}
+
+func newRateStream(client *bitwire.Client, interval time.Duration) *rateStream {
+  if interval < time.Second {
+    interval = time.Second
+  }
+  return &rateStream{client: client, interval: interval, clients: map[chan bitwire.AllRates]bool{}}
+}
+
+// Adds a client, starting the polling for the first one
+func (s *rateStream) subscribe() chan bitwire.AllRates {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  updates := make(chan bitwire.AllRates, 1)
+  if s.last != nil {
+    updates <- *s.last
+  }
+  s.clients[updates] = true
+  if s.cancel == nil {
+    ctx, cancel := context.WithCancel(context.Background())
+    s.cancel = cancel
+    go s.poll(ctx)
+  }
+  return updates
+}
+
+// Removes a client, stopping the polling after the last one
+func (s *rateStream) unsubscribe(updates chan bitwire.AllRates) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  delete(s.clients, updates)
+  if len(s.clients) == 0 && s.cancel != nil {
+    s.cancel()
+    s.cancel, s.last = nil, nil
+  }
+}
+
+// Polls with the client's SubscribeRates, which sends only changed rates and retries failed polls
+func (s *rateStream) poll(ctx context.Context) {
+  for rates := range s.client.SubscribeRates(ctx, s.interval) {
+    s.publish(ctx, rates)
+  }
+}
+
+func (s *rateStream) publish(ctx context.Context, rates bitwire.AllRates) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  if ctx.Err() != nil { // Polling stopped while the rates were sent
+    return
+  }
+  s.last = &rates
+  for updates := range s.clients {
+    select {
+    case <-updates: // Drop the rates the client has not taken yet
+    default:
+    }
+    updates <- rates
+  }
+}
+
+// Streams the rates as `rates` events with the JSON of the rates command, from the latest ones
+func (s *rateStream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+  if req.Method != http.MethodGet {
+    http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    return
+  }
+  flusher, ok := w.(http.Flusher)
+  if !ok {
+    http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+    return
+  }
+  header := w.Header()
+  header.Set("Content-Type", "text/event-stream")
+  header.Set("Cache-Control", "no-cache")
+  header.Set("Access-Control-Allow-Origin", "*") // Rates are public, and overlays are loaded from other origins
+  w.WriteHeader(http.StatusOK)
+  flusher.Flush()
+  updates := s.subscribe()
+  defer s.unsubscribe(updates)
+  for {
+    select {
+    case <-req.Context().Done():
+      return
+    case rates := <-updates:
+      data, err := json.Marshal(rates)
+      if err != nil {
+        return
+      }
+      if _, err := fmt.Fprintf(w, "event: rates\ndata: %s\n\n", data); err != nil {
+        return
+      }
+      flusher.Flush()
+    }
+  }
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+  "archive/tar"
+  "bufio"
+  "bytes"
+  "compress/gzip"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/cli"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+)
+
+// Errors kept in the error log, the latest last
+const maxLoggedErrors = 20
+
+// Error reported by a run, kept for support bundles
+type loggedError struct {
+  Time    time.Time `json:"time"`
+  Version string    `json:"version"`
+  Error   string    `json:"error"`
+}
+
+func (r *runner) errorLogPath() string {
+  return r.confPath(ErrorLogPath)
+}
+
+// Returns the logged errors, the latest last. Missing log means no errors.
+func (r *runner) loggedErrors() ([]loggedError, error) {
+  data, err := ioutil.ReadFile(r.errorLogPath())
+  if os.IsNotExist(err) {
+    return nil, nil
+  } else if err != nil {
+    return nil, err
+  }
+  var logged []loggedError
+  for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+    e := loggedError{}
+    if json.Unmarshal([]byte(line), &e) == nil {
+      logged = append(logged, e)
+    }
+  }
+  return logged, nil
+}
+
+// Appends the error to the error log, dropping the oldest ones over maxLoggedErrors. Failing to log is ignored.
+func (r *runner) logError(err error) {
+  if r.ConfigDir == "" || r.legacyConfigDir != "" { // Not to create the config directory before the legacy one is moved
+    return
+  }
+  logged, _ := r.loggedErrors()
+  logged = append(logged, loggedError{time.Now().UTC(), Version, err.Error()})
+  if len(logged) > maxLoggedErrors {
+    logged = logged[len(logged)-maxLoggedErrors:]
+  }
+  var buf bytes.Buffer
+  for _, e := range logged {
+    line, _ := json.Marshal(e)
+    buf.Write(append(line, '\n'))
+  }
+  if os.MkdirAll(r.ConfigDir, 0700) == nil {
+    ioutil.WriteFile(r.errorLogPath(), buf.Bytes(), 0600)
+  }
+}
+
+// Result of a local check of the installation
+type doctorCheck struct {
+  Check  string `json:"check"`
+  OK     bool   `json:"ok"`
+  Detail string `json:"detail,omitempty"`
+}
+
+// Checks the config files, preferences and local store of every environment without calling the API
+func (r *runner) doctor() []doctorCheck {
+  var checks []doctorCheck
+  for _, env := range r.envNames() {
+    check := doctorCheck{Check: "config " + env, OK: true}
+    if info, err := os.Stat(r.configPath(env)); os.IsNotExist(err) {
+      check.Detail = "not configured"
+    } else if err != nil {
+      check.OK, check.Detail = false, err.Error()
+    } else if info.Mode().Perm()&0077 != 0 {
+      check.OK, check.Detail = false, fmt.Sprintf("readable by others (%s), expected -rw-------", info.Mode().Perm())
+    }
+    checks = append(checks, check)
+  }
+  check := doctorCheck{Check: "preferences", OK: true}
+  if _, err := r.readPreferences(); err != nil {
+    check.OK, check.Detail = false, err.Error()
+  }
+  checks = append(checks, check)
+  saved := r.env
+  for _, env := range r.envNames() {
+    r.env = env
+    check := doctorCheck{Check: "store " + env, OK: true}
+    if _, err := r.lastSync(); err != nil {
+      check.OK, check.Detail = false, err.Error()
+    }
+    checks = append(checks, check)
+  }
+  r.env = saved
+  return checks
+}
+
+// File of a support bundle
+type bundleFile struct {
+  Name string
+  Data []byte
+}
+
+// Collects the support bundle files: diagnostics, doctor checks, the logged errors and the latest journals
+func (r *runner) supportBundle(journals int) ([]bundleFile, error) {
+  var files []bundleFile
+  add := func(name string, v interface{}) error {
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err == nil {
+      files = append(files, bundleFile{name, data})
+    }
+    return err
+  }
+  if err := add("diagnostics.json", r.diagnostics()); err != nil {
+    return nil, err
+  }
+  if err := add("doctor.json", r.doctor()); err != nil {
+    return nil, err
+  }
+  logged, err := r.loggedErrors()
+  if err != nil {
+    return nil, err
+  }
+  if err := add("errors.json", logged); err != nil {
+    return nil, err
+  }
+  paths, err := filepath.Glob(filepath.Join(r.journalDir(), "*.json"))
+  if err != nil {
+    return nil, err
+  }
+  sort.Strings(paths)
+  if len(paths) > journals {
+    paths = paths[len(paths)-journals:]
+  }
+  for _, path := range paths {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+      return nil, err
+    }
+    files = append(files, bundleFile{"journal/" + filepath.Base(path), data})
+  }
+  return files, nil
+}
+
+func writeTarGz(path string, files []bundleFile) error {
+  file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  zw := gzip.NewWriter(file)
+  tw := tar.NewWriter(zw)
+  now := time.Now()
+  for _, f := range files {
+    hdr := &tar.Header{Name: f.Name, Mode: 0600, Size: int64(len(f.Data)), ModTime: now}
+    if err := tw.WriteHeader(hdr); err != nil {
+      return err
+    }
+    if _, err := tw.Write(f.Data); err != nil {
+      return err
+    }
+  }
+  if err := tw.Close(); err != nil {
+    return err
+  }
+  return zw.Close()
+}
+
+// Writes a gzipped tar of the support bundle files after showing them for review, unless --yes is set
+func (r *runner) supportBundleAction(c *cli.Context) error {
+  if c.Int("journals") < 0 {
+    return fmt.Errorf("Invalid --journals: %d", c.Int("journals"))
+  }
+  files, err := r.supportBundle(c.Int("journals"))
+  if err != nil {
+    return err
+  }
+  out := c.String("out")
+  if out == "" {
+    out = "bitwire-support-" + time.Now().Format("20060102-150405") + ".tar.gz"
+  }
+  if !c.Bool("yes") {
+    r.printfErr("The support bundle contains:\n")
+    for _, f := range files {
+      r.printfErr("  %-50s %6d bytes\n", f.Name, len(f.Data))
+    }
+    for _, f := range files[:3] {
+      r.printfErr("\n%s:\n%s\n", f.Name, f.Data)
+    }
+    r.printfErr("\nThe journals have credentials and tokens redacted, but contain transfer and recipient details.\n")
+    r.printfErr("Write %s? [y/N] ", out)
+    answer, _ := readStdin(bufio.NewReader(r.Stdin))
+    if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+      return errors.New("Support bundle not written")
+    }
+  }
+  if err := writeTarGz(out, files); err != nil {
+    return err
+  }
+  r.printfErr("Wrote the support bundle to %s, attach it to your Bitwire support request\n", out)
+  return nil
+}
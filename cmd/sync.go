@@ -0,0 +1,215 @@
+package cmd
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "sort"
+  "time"
+)
+
+const (
+  transfersStore  = "transfers"
+  recipientsStore = "recipients"
+  syncStore       = "sync"
+)
+
+// Version of a transfer as of a sync
+type syncedTransfer struct {
+  Time     time.Time        `json:"time"`
+  Transfer bitwire.Transfer `json:"transfer"`
+}
+
+type recipientsSnapshot struct {
+  Time       time.Time           `json:"time"`
+  Recipients []bitwire.Recipient `json:"recipients"`
+}
+
+// Outcome of a sync
+type syncRecord struct {
+  Time       time.Time          `json:"time"`
+  Cursor     bitwire.SyncCursor `json:"cursor"` // Where the next sync starts
+  Stats      bitwire.SyncStats  `json:"stats"`
+  Transfers  int                `json:"transfers"` // Transfers stored after the sync
+  Recipients int                `json:"recipients"`
+}
+
+// Returns the last sync, zero if there was none
+func (r *runner) lastSync() (syncRecord, error) {
+  last := syncRecord{}
+  err := r.readRecords(syncStore, func(data []byte) error {
+    return json.Unmarshal(data, &last)
+  })
+  return last, err
+}
+
+// Returns the latest stored version of each transfer, the newest transfer first
+func (r *runner) syncedTransfers() ([]bitwire.Transfer, error) {
+  var txs []bitwire.Transfer
+  index := map[string]int{}
+  err := r.readRecords(transfersStore, func(data []byte) error {
+    synced := syncedTransfer{}
+    if err := json.Unmarshal(data, &synced); err != nil {
+      return err
+    }
+    if i, ok := index[synced.Transfer.Id]; ok {
+      txs[i] = synced.Transfer
+    } else {
+      index[synced.Transfer.Id] = len(txs)
+      txs = append(txs, synced.Transfer)
+    }
+    return nil
+  })
+  sort.SliceStable(txs, func(i, j int) bool {
+    return txs[i].CreatedAt.After(txs[j].CreatedAt)
+  })
+  return txs, err
+}
+
+// Returns the recipients stored by the last sync
+func (r *runner) syncedRecipients() ([]bitwire.Recipient, error) {
+  last := recipientsSnapshot{}
+  err := r.readRecords(recipientsStore, func(data []byte) error {
+    return json.Unmarshal(data, &last)
+  })
+  return last.Recipients, err
+}
+
+// Synced transfers of the mode, appending new versions to the store
+type localTransfers struct {
+  r      *runner
+  time   time.Time
+  byId   map[string]bitwire.Transfer
+  stored int // Transfers stored by this sync
+}
+
+func (l *localTransfers) Transfer(id string) (bitwire.Transfer, bool, error) {
+  tx, ok := l.byId[id]
+  return tx, ok, nil
+}
+
+func (l *localTransfers) Put(tx bitwire.Transfer) error {
+  l.byId[tx.Id] = tx
+  l.stored++
+  l.r.progress(progressEvent{Event: progressStep, Step: "transfers", Current: l.stored})
+  return l.r.appendRecord(transfersStore, syncedTransfer{l.time, tx})
+}
+
+// Syncs the transfers from the cursor persisted by the last sync, and stores the recipients
+func (r *runner) sync(client *bitwire.Client) (syncRecord, error) {
+  last, err := r.lastSync()
+  if err != nil {
+    return syncRecord{}, err
+  }
+  stored, err := r.syncedTransfers()
+  if err != nil {
+    return syncRecord{}, err
+  }
+  local := &localTransfers{r: r, time: time.Now(), byId: map[string]bitwire.Transfer{}}
+  for _, tx := range stored {
+    local.byId[tx.Id] = tx
+  }
+  cursor, stats, err := client.Transfers.Sync(last.Cursor, local)
+  if err != nil {
+    return syncRecord{}, err
+  }
+  recipients, err := client.Recipients.List()
+  if err != nil {
+    return syncRecord{}, err
+  }
+  if err := r.appendRecord(recipientsStore, recipientsSnapshot{local.time, recipients}); err != nil {
+    return syncRecord{}, err
+  }
+  r.progress(progressEvent{Event: progressStep, Step: "recipients", Current: len(recipients)})
+  record := syncRecord{local.time, cursor, stats, len(local.byId), len(recipients)}
+  return record, r.appendRecord(syncStore, record)
+}
+
+func (r *runner) syncAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  r.progressBegin("sync", 0)
+  record, err := r.sync(client)
+  r.progressEnd(err)
+  if err != nil {
+    return err
+  }
+  if r.json {
+    return r.printOut(record, true)
+  }
+  fmt.Fprintf(r.Stdout, "Fetched %d transfers: %d new, %d updated, %d unchanged\n",
+    record.Stats.Fetched, record.Stats.Added, record.Stats.Updated, record.Stats.Unchanged)
+  fmt.Fprintf(r.Stdout, "Stored %d transfers and %d recipients\n", record.Transfers, record.Recipients)
+  return nil
+}
+
+// Tells how old the synced data is before running a command offline.
+// Fails if nothing was synced in the mode.
+func (r *runner) checkOffline() error {
+  last, err := r.lastSync()
+  if err != nil {
+    return err
+  }
+  if last.Time.IsZero() {
+    return fmt.Errorf("Nothing synced in %s mode yet, run `bitwire sync` first", r.envName())
+  }
+  r.printfErr("%sOffline: showing data synced %s%s\n", YELLOW, ago(last.Time, time.Now()), RESET)
+  return nil
+}
+
+// Lists the synced transfers matching the options
+func (r *runner) offlineTransfers(opts bitwire.TransferListOptions) ([]bitwire.Transfer, error) {
+  if err := r.checkOffline(); err != nil {
+    return nil, err
+  }
+  txs, err := r.syncedTransfers()
+  if err != nil {
+    return nil, err
+  }
+  var found []bitwire.Transfer
+  for _, tx := range txs {
+    if opts.Matches(tx) {
+      found = append(found, tx)
+    }
+  }
+  return found, nil
+}
+
+// Returns the synced transfer with the ID
+func (r *runner) offlineTransfer(id string) (bitwire.Transfer, error) {
+  if err := r.checkOffline(); err != nil {
+    return bitwire.Transfer{}, err
+  }
+  txs, err := r.syncedTransfers()
+  if err != nil {
+    return bitwire.Transfer{}, err
+  }
+  for _, tx := range txs {
+    if tx.Id == id {
+      return tx, nil
+    }
+  }
+  return bitwire.Transfer{}, errors.New("Transfer " + id + " not synced")
+}
+
+// Lists the synced recipients, those whose name contains the query only if it is not empty
+func (r *runner) offlineRecipients(query string) ([]bitwire.Recipient, error) {
+  if err := r.checkOffline(); err != nil {
+    return nil, err
+  }
+  recipients, err := r.syncedRecipients()
+  if err != nil || query == "" {
+    return recipients, err
+  }
+  var found []bitwire.Recipient
+  for _, rec := range recipients {
+    if rec.Matches(query) {
+      found = append(found, rec)
+    }
+  }
+  return found, nil
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+  "errors"
+  "io"
+)
+
+// Returned by terminals that cannot draw QR codes
+var ErrNoQRCode = errors.New("QR codes are not supported by this terminal")
+
+// Terminal features of the CLI output. Platforms without them, e.g. js/wasm, get NoTerminal,
+// and the output falls back to plain text: no colors, redraws, countdowns or QR codes.
+type Terminal interface {
+  // Tells if the writer is an interactive terminal, which gets colors, redraws and countdowns
+  IsTerminal(w io.Writer) bool
+  // Returns the modules of a QR code of the data, true for dark ones, or ErrNoQRCode
+  QRCode(data string) ([][]bool, error)
+}
+
+// Terminal that can read secrets without echoing them. Terminals without it, or failing with ErrNoSecretInput,
+// get secrets read like other input.
+type SecretReader interface {
+  // Reads a line from r without echoing it, or returns ErrNoSecretInput if r is not an interactive terminal
+  ReadSecret(r io.Reader) (string, error)
+}
+
+// Returned by terminals that cannot read input without echo
+var ErrNoSecretInput = errors.New("Input without echo is not supported by this terminal")
+
+// Terminal without any features
+type NoTerminal struct{}
+
+func (NoTerminal) IsTerminal(w io.Writer) bool          { return false }
+func (NoTerminal) QRCode(data string) ([][]bool, error) { return nil, ErrNoQRCode }
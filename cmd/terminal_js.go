@@ -0,0 +1,9 @@
+//go:build js
+// +build js
+
+package cmd
+
+// Browsers have no terminal to detect or draw on
+func defaultTerminal() Terminal {
+  return NoTerminal{}
+}
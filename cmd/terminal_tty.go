@@ -0,0 +1,44 @@
+//go:build !js
+// +build !js
+
+package cmd
+
+import (
+  qrcode "github.com/skip2/go-qrcode"
+  "golang.org/x/term"
+  "io"
+  "os"
+)
+
+// Terminal of the process, detected from the character device of stdout and stderr
+type ttyTerminal struct{}
+
+func defaultTerminal() Terminal {
+  return ttyTerminal{}
+}
+
+func (ttyTerminal) IsTerminal(w io.Writer) bool {
+  f, ok := w.(*os.File)
+  if !ok {
+    return false
+  }
+  info, err := f.Stat()
+  return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (ttyTerminal) ReadSecret(r io.Reader) (string, error) {
+  f, ok := r.(*os.File)
+  if !ok || !term.IsTerminal(int(f.Fd())) {
+    return "", ErrNoSecretInput
+  }
+  secret, err := term.ReadPassword(int(f.Fd()))
+  return string(secret), err
+}
+
+func (ttyTerminal) QRCode(data string) ([][]bool, error) {
+  qr, err := qrcode.New(data, qrcode.Medium)
+  if err != nil {
+    return nil, err
+  }
+  return qr.Bitmap(), nil
+}
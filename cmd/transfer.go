@@ -0,0 +1,576 @@
+package cmd
+
+import (
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/refrates"
+  "github.com/dworznik/cli"
+  "strconv"
+  "strings"
+  "time"
+)
+
+func (r *runner) transferCommands() []cli.Command {
+  return []cli.Command{
+    {
+      Name:        "list",
+      Usage:       "list transfers",
+      Description: commonErrors("Unauthorized"),
+      Action:      r.transferListAction,
+      Flags: []cli.Flag{
+        cli.StringSliceFlag{
+          Name:  "f",
+          Usage: "Show selected fields only: id, recipient, sent, received, date, status, address, link, account, bank, reference, fees, created, expires, rate, ref-rate",
+        },
+        cli.BoolFlag{
+          Name:  "wide",
+          Usage: "add the absolute creation time to the relative date column",
+        },
+        cli.StringFlag{
+          Name:  "status",
+          Usage: "show transfers with the status only: pending, completed, expired, canceled",
+        },
+        cli.StringFlag{
+          Name:  "since",
+          Usage: "show transfers created on or after the date (YYYY-MM-DD)",
+        },
+        cli.StringFlag{
+          Name:  "until",
+          Usage: "show transfers created before the date (YYYY-MM-DD)",
+        },
+        cli.IntFlag{
+          Name:  "recipient",
+          Usage: "show transfers to the recipient ID only",
+        },
+        cli.StringFlag{
+          Name:  "type",
+          Usage: "show transfers of the type only, e.g. btc_to_bank",
+        },
+        cli.StringFlag{
+          Name:  "columns",
+          Usage: "comma-separated columns to show, in order, e.g. id,status,sent; takes the same names as -f",
+        },
+        cli.StringFlag{
+          Name:  "sort",
+          Usage: "sort by a column, ascending or with :desc, e.g. date:desc or received",
+        },
+        cli.StringFlag{
+          Name:   "reference-rates",
+          Usage:  "source of the ref-rate column: ecos:<Bank of Korea ECOS API key> or file:<CSV of date,base,quote,rate>",
+          EnvVar: "BITWIRE_REFERENCE_RATES",
+        },
+        cli.BoolFlag{
+          Name:  "changed-since-last-run",
+          Usage: "show only the transfers that are new or changed status since the last run with the flag, and nothing if none did",
+        },
+      },
+    },
+    {
+      Name:        "show",
+      Usage:       "show transfer",
+      ArgsUsage:   "id",
+      Description: commonErrors("Unauthorized", "not_found"),
+      Action:      r.transferShowAction,
+      Flags: []cli.Flag{
+        cli.BoolFlag{
+          Name:  "follow, F",
+          Usage: "keep showing the status, funding payment confirmations and expiry until the transfer completes, expires or is canceled",
+        },
+        cli.DurationFlag{
+          Name:  "interval",
+          Value: 10 * time.Second,
+          Usage: "how often to poll with --follow",
+        },
+        cli.BoolFlag{
+          Name:  "qr-only",
+          Usage: "print only the payment QR code, failing if the transfer cannot be paid",
+        },
+      },
+    },
+    {
+      Name:      "watch",
+      Usage:     "wait for the transfer to complete, expire or be canceled",
+      ArgsUsage: "id",
+      Description: fmt.Sprintf("Exits with %d when the transfer completes, %d when it expires, %d when it is canceled\n"+
+        "   and %d on --timeout.\n\n   %s", ExitCompleted, ExitExpired, ExitCanceled, ExitTimeout, commonErrors("Unauthorized", "not_found")),
+      Action: r.transferWatchAction,
+      Flags: []cli.Flag{
+        cli.DurationFlag{
+          Name:  "interval",
+          Value: 10 * time.Second,
+          Usage: "how often to poll the transfer",
+        },
+        cli.DurationFlag{
+          Name:  "timeout",
+          Usage: "give up after the duration, e.g. 30m; 0 waits forever",
+        },
+      },
+    },
+    {
+      Name:        "quote",
+      Usage:       "estimate the BTC to pay for a recipient to receive the amount, without creating a transfer",
+      ArgsUsage:   "amount [recipient_id or name]",
+      Description: "The estimate uses the current BTC rate. Fees and the payment window are known once the transfer is created.",
+      Action:      r.transferQuoteAction,
+      Flags: []cli.Flag{
+        cli.StringFlag{
+          Name:  "currency, c",
+          Value: "KRW",
+          Usage: "currency of the amount",
+        },
+      },
+    },
+    {
+      Name:      "create",
+      Usage:     "create transfer",
+      ArgsUsage: "--recipient recipient_id --amount amount",
+      Description: "With --type bank-to-btc, deposit KRW to the bank account shown and receive BTC at --address instead.\n\n" +
+        commonErrors("Unauthorized", "limit_exceeded", "pending_limit_exceeded", "validation_error", "not_found"),
+      Action: r.transferCreateAction,
+      Flags: []cli.Flag{
+        cli.IntFlag{
+          Name:  "recipient, r",
+          Usage: "recipient ID",
+        },
+        cli.StringFlag{
+          Name:  "amount, a",
+          Usage: "amount received by the recipient, or deposited for a bank-to-btc transfer",
+        },
+        cli.BoolFlag{
+          Name:  "pick, p",
+          Usage: "pick the recipient interactively, searching by name, email or bank, instead of --recipient",
+        },
+        cli.StringFlag{
+          Name:  "type, t",
+          Value: "btc-to-bank",
+          Usage: "btc-to-bank to pay BTC out to a recipient's bank account, or bank-to-btc to deposit KRW for BTC",
+        },
+        cli.StringFlag{
+          Name:  "address",
+          Usage: "BTC address receiving a bank-to-btc transfer",
+        },
+        cli.StringFlag{
+          Name:  "currency, c",
+          Value: "KRW",
+          Usage: "currency of the amount, one the API has a BTC rate for",
+        },
+        cli.BoolFlag{
+          Name:  "dry-run",
+          Usage: "print the request body without creating the transfer",
+        },
+        cli.BoolFlag{
+          Name:  "yes, y",
+          Usage: "create the transfer without asking for confirmation",
+        },
+      },
+    },
+    {
+      Name:      "validate",
+      Usage:     "check a payouts CSV file against the recipients, limits and amount formats, without creating transfers",
+      ArgsUsage: "payouts.csv",
+      Description: "The file has a header row with recipient (ID or name) and amount columns, and optionally currency (KRW by default).\n" +
+        "   Prints each problem as file:line: column: message and exits with 1 if there are any.",
+      Action: r.transferValidateAction,
+    },
+    {
+      Name:  "cancel-stale",
+      Usage: "cancel transfers still unfunded shortly before their payment address expires, freeing the pending transfer limit",
+      Description: "Run it from cron, or with --every to keep applying the policy. Transfers paid anything are never cancelled.\n\n   " +
+        commonErrors("Unauthorized", "not_found"),
+      Action: r.transferCancelStaleAction,
+      Flags: []cli.Flag{
+        cli.DurationFlag{
+          Name:  "before",
+          Value: 10 * time.Minute,
+          Usage: "cancel transfers unfunded this long before their address expires",
+        },
+        cli.StringSliceFlag{
+          Name:  "exclude",
+          Usage: "ID of a transfer never to cancel; repeat for more",
+        },
+        cli.IntSliceFlag{
+          Name:  "exclude-recipient",
+          Usage: "ID of a recipient whose transfers are never cancelled; repeat for more",
+        },
+        cli.StringSliceFlag{
+          Name:  "exclude-label",
+          Usage: "label, e.g. #keep, sparing the transfers whose memo contains it; repeat for more",
+        },
+        cli.DurationFlag{
+          Name:  "every",
+          Usage: "apply the policy again after the duration until interrupted, e.g. 1m; 0 runs once",
+        },
+        cli.BoolFlag{
+          Name:  "dry-run",
+          Usage: "list the transfers that would be cancelled without cancelling them",
+        },
+      },
+    },
+    {
+      Name:        "cancel",
+      Usage:       "cancel transfer",
+      ArgsUsage:   "transfer_id",
+      Description: commonErrors("Unauthorized", "not_found"),
+      Action:      r.transferCancelAction,
+      Flags: []cli.Flag{
+        cli.BoolFlag{
+          Name:  "yes, y",
+          Usage: "cancel the transfer without asking for confirmation",
+        },
+      },
+    },
+  }
+}
+
+func (r *runner) transferListAction(c *cli.Context) error {
+  fields := c.StringSlice("f")
+  var err error
+  if s := c.String("columns"); s != "" {
+    if len(fields) > 0 {
+      return errors.New("Use either -f or --columns")
+    }
+    if fields, err = parseColumns(s, fieldHeaders); err != nil {
+      return err
+    }
+  }
+  if len(fields) == 0 {
+    fields = defaultFields
+  }
+  if c.Bool("wide") {
+    fields = append(append([]string(nil), fields...), "created")
+  }
+  var order sortOrder
+  if s := c.String("sort"); s != "" {
+    if order, err = parseSortOrder(s, fieldHeaders); err != nil {
+      return err
+    }
+  }
+  if ref := c.String("reference-rates"); ref != "" {
+    if r.refRates, err = refrates.Open(ref); err != nil {
+      return err
+    }
+  } else if hasField(fields, "ref-rate") {
+    return errors.New("The ref-rate column needs --reference-rates ecos:<API key> or file:<path>")
+  }
+  opts, err := transferListOptions(c)
+  if err != nil {
+    return err
+  }
+  var txs []bitwire.Transfer
+  if r.offline {
+    txs, err = r.offlineTransfers(opts)
+  } else {
+    var client *bitwire.Client
+    if client, err = r.newClient(c.Command.Name); err == nil {
+      txs, err = client.Transfers.List(&opts)
+    }
+  }
+  if err != nil {
+    return err
+  }
+  if c.Bool("changed-since-last-run") {
+    if txs, err = r.changedTransfers(txs); err != nil || len(txs) == 0 {
+      return err
+    }
+  }
+  if order.Column != "" {
+    sortTransfers(txs, order, r.lang, r.mode)
+  }
+  return r.printOutTxs(txs, fields, r.json)
+}
+
+const dateLayout = "2006-01-02"
+
+func hasField(fields []string, field string) bool {
+  for _, f := range fields {
+    if f == field {
+      return true
+    }
+  }
+  return false
+}
+
+var transferStatuses = map[string]bool{bitwire.StatusPending: true, bitwire.StatusCompleted: true,
+  bitwire.StatusExpired: true, bitwire.StatusCanceled: true}
+
+// Reads the transfer list filters from the flags
+func transferListOptions(c *cli.Context) (bitwire.TransferListOptions, error) {
+  opts := bitwire.TransferListOptions{Status: c.String("status"), RecipientId: c.Int("recipient"), Type: c.String("type")}
+  if opts.Status != "" && !transferStatuses[opts.Status] {
+    return opts, errors.New("Invalid status: " + opts.Status + "\nUse one of: pending, completed, expired, canceled")
+  }
+  var err error
+  if since := c.String("since"); since != "" {
+    if opts.Since, err = time.Parse(dateLayout, since); err != nil {
+      return opts, errors.New("Invalid --since date, use YYYY-MM-DD")
+    }
+  }
+  if until := c.String("until"); until != "" {
+    if opts.Until, err = time.Parse(dateLayout, until); err != nil {
+      return opts, errors.New("Invalid --until date, use YYYY-MM-DD")
+    }
+  }
+  return opts, nil
+}
+
+func (r *runner) transferShowAction(c *cli.Context) error {
+  if r.offline {
+    if c.Bool("follow") {
+      return errors.New("Cannot follow a transfer offline")
+    }
+    tx, err := r.offlineTransfer(c.Args().Get(0))
+    if err != nil {
+      return err
+    }
+    return r.showTransfer(tx, c.Bool("qr-only"))
+  }
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  } else {
+    id := c.Args().Get(0)
+    if c.Bool("follow") {
+      r.progressBegin("transfer show --follow", 0)
+      err := r.followTransfer(client, id, c.Duration("interval"))
+      r.progressEnd(err)
+      return err
+    }
+    tx, err := client.Transfers.Get(id)
+    if err != nil {
+      return err
+    } else {
+      return r.showTransfer(tx, c.Bool("qr-only"))
+    }
+  }
+}
+
+// Prints the transfer as JSON with --json, in the detail layout otherwise
+func (r *runner) showTransfer(tx bitwire.Transfer, qrOnly bool) error {
+  if r.json && !qrOnly {
+    return r.printOut(tx, true)
+  }
+  if r.csv && !qrOnly {
+    return r.printOutTxs([]bitwire.Transfer{tx}, defaultFields, false)
+  }
+  return r.printTransferDetail(tx, qrOnly)
+}
+
+func (r *runner) transferWatchAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  if timeout := c.Duration("timeout"); timeout > r.runTime {
+    if err := r.preflightToken(client, timeout); err != nil {
+      return err
+    }
+  }
+  r.progressBegin("transfer watch", 0)
+  err = r.watchTransfer(client, c.Args().Get(0), c.Duration("interval"), c.Duration("timeout"))
+  r.progressEnd(err)
+  return err
+}
+
+// Estimate of transfer quote, with the recipient if one was given
+type quote struct {
+  bitwire.Estimate
+  Recipient *bitwire.Recipient `json:"recipient,omitempty"`
+}
+
+func (r *runner) transferQuoteAction(c *cli.Context) error {
+  usage := "Usage: transfer quote amount [recipient_id or name]"
+  amount, err := bitwire.ParseDecimal(c.Args().Get(0))
+  if err != nil || amount.Sign() <= 0 {
+    return errors.New("Invalid amount: " + c.Args().Get(0) + "\n" + usage)
+  }
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  q := quote{}
+  if arg := c.Args().Get(1); arg != "" {
+    if q.Recipient, err = r.resolveRecipient(client, arg); err != nil {
+      return err
+    }
+  }
+  q.Estimate, err = client.Transfers.Estimate(amount, strings.ToUpper(c.String("currency")))
+  if err != nil {
+    return err
+  }
+  return r.printOut(q, r.json)
+}
+
+// Returns the recipient with the ID, or the only one whose romanized or Korean name contains arg
+func (r *runner) resolveRecipient(client *bitwire.Client, arg string) (*bitwire.Recipient, error) {
+  if id, err := strconv.Atoi(arg); err == nil {
+    recipients, err := client.Recipients.List()
+    if err != nil {
+      return nil, err
+    }
+    for i := range recipients {
+      if recipients[i].Id == id {
+        return &recipients[i], nil
+      }
+    }
+    return nil, fmt.Errorf("Recipient %d not found", id)
+  }
+  found, err := client.Recipients.Find(arg)
+  if err != nil {
+    return nil, err
+  }
+  switch len(found) {
+  case 0:
+    return nil, errors.New("No recipient named " + arg)
+  case 1:
+    return &found[0], nil
+  default:
+    names := make([]string, len(found))
+    for i, rec := range found {
+      names[i] = fmt.Sprintf("%d %s", rec.Id, rec.LocalName(r.lang))
+    }
+    return nil, errors.New("Several recipients match " + arg + ", use the ID:\n  " + strings.Join(names, "\n  "))
+  }
+}
+
+func (r *runner) transferCreateAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  var trans bitwire.CreateTransfer
+  currency := strings.ToUpper(c.String("currency"))
+  switch strings.Replace(c.String("type"), "-", "_", -1) {
+  case bitwire.TypeBtcToBank:
+    recId, amount, err := r.transferCreateArgs(c, client)
+    if err != nil {
+      return err
+    }
+    value, err := bitwire.ParseDecimal(amount)
+    if err != nil || value.Sign() <= 0 {
+      return errors.New("Invalid amount: " + amount + "\n" + transferCreateUsage)
+    }
+    trans = bitwire.CreateTransfer{Amount: value, Currency: currency, RecipientId: recId, Type: bitwire.TypeBtcToBank}
+  case bitwire.TypeBankToBtc:
+    if c.IsSet("recipient") || c.NArg() > 0 {
+      return errors.New("A bank-to-btc transfer has no recipient\n" + bankToBtcUsage)
+    }
+    amount := c.String("amount")
+    value, err := bitwire.ParseDecimal(amount)
+    if err != nil || value.Sign() <= 0 {
+      return errors.New("Invalid amount: " + amount + "\n" + bankToBtcUsage)
+    }
+    trans = bitwire.CreateTransfer{Amount: value, Currency: currency, Type: bitwire.TypeBankToBtc, Address: c.String("address")}
+  default:
+    return errors.New("Invalid transfer type: " + c.String("type") + "\nUse btc-to-bank or bank-to-btc")
+  }
+  if err := trans.Validate(r.mode); err != nil {
+    return err
+  }
+  if err := r.checkMaxAmount(trans.Amount, trans.Currency); err != nil {
+    return err
+  }
+  if c.Bool("dry-run") { // Print the request body instead of sending it
+    payload, err := trans.Payload()
+    if err != nil {
+      return err
+    }
+    fmt.Fprint(r.Stdout, string(payload))
+    return nil
+  }
+  if err := client.Transfers.ValidateCurrency(trans.Currency); err != nil {
+    return err
+  }
+  if !c.Bool("yes") {
+    if err := r.confirmCreate(client, trans); err != nil {
+      return err
+    }
+  }
+  tx, err := client.Transfers.Create(trans)
+  if err != nil {
+    return err
+  } else {
+    return r.printOut(tx, r.json)
+  }
+}
+
+func (r *runner) transferCancelAction(c *cli.Context) error {
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  } else {
+    id := c.Args().Get(0)
+    if !c.Bool("yes") {
+      if err := r.confirmCancel(client, id); err != nil {
+        return err
+      }
+    }
+    tx, err := client.Transfers.Cancel(id)
+    if err != nil {
+      return err
+    } else {
+      return r.printOut(tx, r.json)
+    }
+  }
+}
+
+const (
+  transferCreateUsage = "Usage: transfer create --recipient recipient_id --amount amount"
+  bankToBtcUsage      = "Usage: transfer create --type bank-to-btc --amount amount --address btc_address"
+)
+
+// Returns the recipient ID and amount of a new transfer.
+// With --pick, the recipient is picked from the list, searching for the argument if there is one.
+// Otherwise the --recipient and --amount flags take precedence. Positional arguments are still accepted
+// in either order: the integer that is not a valid amount, or that matches an existing recipient, is the recipient ID.
+func (r *runner) transferCreateArgs(c *cli.Context, client *bitwire.Client) (int, string, error) {
+  if c.Bool("pick") {
+    if c.IsSet("recipient") || !c.IsSet("amount") {
+      return 0, "", errors.New("Use --pick with --amount and without --recipient\n" + transferCreateUsage)
+    }
+    recipient, err := r.findRecipient(client, c.Args().Get(0), true)
+    if err != nil {
+      return 0, "", err
+    }
+    return recipient.Id, c.String("amount"), nil
+  }
+  if c.IsSet("recipient") || c.IsSet("amount") {
+    if !c.IsSet("recipient") || !c.IsSet("amount") {
+      return 0, "", errors.New("Both --recipient and --amount are required\n" + transferCreateUsage)
+    }
+    return c.Int("recipient"), c.String("amount"), nil
+  }
+  if c.NArg() < 2 {
+    return 0, "", errors.New("Missing argument\n" + transferCreateUsage)
+  }
+  r.printfErr("%sNote: positional arguments are ambiguous and deprecated; use --recipient and --amount%s\n", YELLOW, RESET)
+  first, second := c.Args().Get(0), c.Args().Get(1)
+  firstId, firstErr := strconv.Atoi(first)
+  secondId, secondErr := strconv.Atoi(second)
+  switch {
+  case firstErr != nil && secondErr != nil:
+    return 0, "", errors.New("Invalid recipient id value")
+  case firstErr != nil:
+    return secondId, first, nil
+  case secondErr != nil:
+    return firstId, second, nil
+  }
+  // Both are integers, look them up in the recipient list
+  recipients, err := client.Recipients.List()
+  if err != nil {
+    return 0, "", err
+  }
+  firstFound, secondFound := false, false
+  for _, r := range recipients {
+    firstFound = firstFound || r.Id == firstId
+    secondFound = secondFound || r.Id == secondId
+  }
+  switch {
+  case firstFound && !secondFound:
+    return firstId, second, nil
+  case secondFound && !firstFound:
+    return secondId, first, nil
+  default:
+    return 0, "", errors.New("Cannot tell the recipient ID from the amount\n" + transferCreateUsage)
+  }
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/cli"
+  "io"
+  "os"
+  "strconv"
+  "strings"
+)
+
+// Row of a payouts file checked by transfer validate
+type payoutRow struct {
+  Line      int
+  Recipient string // Recipient ID or name
+  Amount    string
+  Currency  string // KRW when the file has no currency column
+}
+
+// Problem found in a payouts file, at a line or, when Line is 0, in the file as a whole
+type payoutProblem struct {
+  Line    int    `json:"line,omitempty"`
+  Column  string `json:"column,omitempty"`
+  Message string `json:"message"`
+}
+
+// Result of transfer validate
+type payoutReport struct {
+  File     string          `json:"file"`
+  Rows     int             `json:"rows"`
+  Total    bitwire.Decimal `json:"total_krw"` // KRW equivalent of the rows with a valid amount
+  Problems []payoutProblem `json:"problems"`
+}
+
+func (p *payoutReport) add(line int, column, format string, args ...interface{}) {
+  p.Problems = append(p.Problems, payoutProblem{line, column, fmt.Sprintf(format, args...)})
+}
+
+// Reads the rows of a CSV file with a header naming the recipient, amount and, optionally, currency columns
+func readPayouts(in io.Reader) ([]payoutRow, error) {
+  reader := csv.NewReader(in)
+  reader.FieldsPerRecord = -1
+  reader.TrimLeadingSpace = true
+  header, err := reader.Read()
+  if err == io.EOF {
+    return nil, errors.New("The file is empty, expected a header with recipient and amount columns")
+  } else if err != nil {
+    return nil, err
+  }
+  columns := map[string]int{}
+  for i, name := range header {
+    columns[strings.ToLower(strings.TrimSpace(name))] = i
+  }
+  for _, name := range []string{"recipient", "amount"} {
+    if _, ok := columns[name]; !ok {
+      return nil, fmt.Errorf("The header has no %s column", name)
+    }
+  }
+  field := func(record []string, name string) string {
+    if i, ok := columns[name]; ok && i < len(record) {
+      return strings.TrimSpace(record[i])
+    }
+    return ""
+  }
+  var rows []payoutRow
+  for {
+    record, err := reader.Read()
+    if err == io.EOF {
+      return rows, nil
+    } else if err != nil {
+      return nil, err
+    }
+    line, _ := reader.FieldPos(0)
+    row := payoutRow{line, field(record, "recipient"), field(record, "amount"), strings.ToUpper(field(record, "currency"))}
+    if row.Currency == "" {
+      row.Currency = "KRW"
+    }
+    rows = append(rows, row)
+  }
+}
+
+// Checks the rows against the recipients, the supported currencies and the limits,
+// and reports amounts that cannot be parsed and rows that repeat an earlier one
+func checkPayouts(file string, rows []payoutRow, recipients []bitwire.Recipient, limits bitwire.Limits,
+  rates bitwire.AllRates) payoutReport {
+  report := payoutReport{File: file, Rows: len(rows), Problems: []payoutProblem{}}
+  currencies := map[string]bool{"KRW": true}
+  for _, currency := range rates.BTC.Currencies() {
+    currencies[currency] = true
+  }
+  seen := map[string]int{}
+  for _, row := range rows {
+    recipient, err := matchRecipient(recipients, row.Recipient)
+    if err != nil {
+      report.add(row.Line, "recipient", "%s", err)
+    }
+    if !currencies[row.Currency] {
+      report.add(row.Line, "currency", "Unsupported currency: %s", row.Currency)
+      continue
+    }
+    amount, err := bitwire.ParseDecimal(row.Amount)
+    if err != nil || amount.Sign() <= 0 {
+      report.add(row.Line, "amount", "Invalid amount: %q, expected a positive number such as 100000", row.Amount)
+      continue
+    }
+    if point := strings.IndexByte(row.Amount, '.'); point >= 0 && len(row.Amount)-point-1 > bitwire.CurrencyScale(row.Currency) {
+      report.add(row.Line, "amount", "%s has more decimals than %s amounts have (%d)",
+        row.Amount, row.Currency, bitwire.CurrencyScale(row.Currency))
+    }
+    krw := amount
+    if row.Currency != "KRW" {
+      if krw, err = rates.Convert(row.Currency, "KRW", amount); err != nil {
+        report.add(row.Line, "amount", "%s", err)
+        continue
+      }
+    }
+    if min := limits.KRW.Min; min.Sign() > 0 && krw.Cmp(min) < 0 {
+      report.add(row.Line, "amount", "%s %s is below the minimum transfer of %s KRW", amount, row.Currency, min)
+    }
+    report.Total = report.Total.Add(krw)
+    if recipient != nil {
+      key := fmt.Sprintf("%d %s %s", recipient.Id, amount.Rat().RatString(), row.Currency)
+      if first, ok := seen[key]; ok {
+        report.add(row.Line, "", "Duplicate of line %d: %s %s to recipient %d", first, amount, row.Currency, recipient.Id)
+      } else {
+        seen[key] = row.Line
+      }
+    }
+  }
+  for _, limit := range []struct {
+    name string
+    krw  bitwire.KrwLimits
+  }{{"daily", limits.KRW.Daily}, {"weekly", limits.KRW.Weekly}} {
+    if limit.krw.Limit.Sign() > 0 && report.Total.Cmp(limit.krw.Left) > 0 {
+      report.add(0, "", "The transfers total %s KRW, over the %s KRW left of the %s limit", report.Total, limit.krw.Left, limit.name)
+    }
+  }
+  if pending := limits.Transfers.Pending.Total; pending.Limit > 0 && len(rows) > pending.Limit-pending.Used {
+    report.add(0, "", "%d transfers, but only %d more can be pending", len(rows), pending.Limit-pending.Used)
+  }
+  return report
+}
+
+// Returns the recipient with the ID, or the only one whose name contains query
+func matchRecipient(recipients []bitwire.Recipient, query string) (*bitwire.Recipient, error) {
+  if query == "" {
+    return nil, errors.New("Missing recipient")
+  }
+  if id, err := strconv.Atoi(query); err == nil {
+    for i := range recipients {
+      if recipients[i].Id == id {
+        return &recipients[i], nil
+      }
+    }
+    return nil, fmt.Errorf("Recipient %d not found", id)
+  }
+  var found []*bitwire.Recipient
+  for i := range recipients {
+    if recipients[i].Matches(query) {
+      found = append(found, &recipients[i])
+    }
+  }
+  switch len(found) {
+  case 0:
+    return nil, errors.New("No recipient named " + query)
+  case 1:
+    return found[0], nil
+  default:
+    return nil, fmt.Errorf("Several recipients match %s, use the ID", query)
+  }
+}
+
+func (r *runner) transferValidateAction(c *cli.Context) error {
+  path := c.Args().Get(0)
+  if path == "" {
+    return errors.New("Missing file\nUsage: transfer validate payouts.csv")
+  }
+  file, err := os.Open(path)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  rows, err := readPayouts(file)
+  if err != nil {
+    return fmt.Errorf("%s: %s", path, err)
+  }
+  client, err := r.newClient(c.Command.Name)
+  if err != nil {
+    return err
+  }
+  recipients, err := client.Recipients.List()
+  if err != nil {
+    return err
+  }
+  limits, err := client.Limits.Get()
+  if err != nil {
+    return err
+  }
+  rates, err := client.Rates.All()
+  if err != nil {
+    return err
+  }
+  report := checkPayouts(path, rows, recipients, limits, rates)
+  if r.json {
+    if err := r.printData(report); err != nil {
+      return err
+    }
+  } else {
+    r.printPayoutReport(report)
+  }
+  if len(report.Problems) > 0 {
+    return &exitError{code: 1}
+  }
+  return nil
+}
+
+// Prints each problem prefixed with file:line, like a compiler, followed by a summary line
+func (r *runner) printPayoutReport(report payoutReport) {
+  for _, p := range report.Problems {
+    location := report.File
+    if p.Line > 0 {
+      location = fmt.Sprintf("%s:%d", location, p.Line)
+    }
+    if p.Column != "" {
+      location += ": " + p.Column
+    }
+    fmt.Fprintf(r.Stdout, "%s: %s\n", location, p.Message)
+  }
+  if len(report.Problems) == 0 {
+    fmt.Fprintf(r.Stdout, "%s: %d transfers OK, %s KRW in total. Nothing was created.\n", report.File, report.Rows, report.Total)
+  } else {
+    fmt.Fprintf(r.Stdout, "%s: %d problems in %d transfers. Nothing was created.\n", report.File, len(report.Problems), report.Rows)
+  }
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "strings"
+  "time"
+)
+
+// Exit codes of transfer watch. Other failures exit with 1.
+const (
+  ExitCompleted = 0
+  ExitExpired   = 3
+  ExitCanceled  = 4
+  ExitTimeout   = 5
+)
+
+// Makes Run exit with the code. err is reported if set; a nil err is a
+// status rather than a failure, e.g. a watched transfer that expired.
+type exitError struct {
+  code int
+  err  error
+}
+
+func (e *exitError) Error() string {
+  if e.err == nil {
+    return fmt.Sprintf("exit status %d", e.code)
+  }
+  return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+  return e.err
+}
+
+// Returns the watch exit code of a transfer in a final status, or of a pending one whose address expired
+func watchExitCode(tx bitwire.Transfer) (int, bool) {
+  status := strings.ToLower(tx.Status)
+  switch {
+  case strings.Contains(status, bitwire.StatusCompleted):
+    return ExitCompleted, true
+  case strings.Contains(status, bitwire.StatusCanceled):
+    return ExitCanceled, true
+  case tx.IsExpired():
+    return ExitExpired, true
+  default:
+    return 0, false
+  }
+}
+
+// Status line of transfer watch. The expiry countdown is only shown on a terminal.
+func watchLine(tx bitwire.Transfer, now time.Time, countdown bool) string {
+  line := fmt.Sprintf("%s  %s", tx.Id, tx.Status)
+  if note := fundingNote(tx); note != "" {
+    line += "  " + strings.ToUpper(note[:1]) + note[1:]
+  }
+  if expires := tx.ExpiresAt(); countdown && !expires.IsZero() && !tx.IsFinal() {
+    line += "  expires " + expiresIn(expires, now)
+  }
+  return line
+}
+
+// Polls the transfer until it completes, expires or is canceled, or the timeout passes.
+// On a terminal the status line is rewritten in place, otherwise a line is printed per status change.
+func (r *runner) watchTransfer(client *bitwire.Client, id string, interval, timeout time.Duration) error {
+  if id == "" {
+    return errors.New("Missing transfer ID\nUsage: transfer watch id")
+  }
+  if interval < time.Second {
+    interval = time.Second
+  }
+  terminal := r.Terminal.IsTerminal(r.Stdout) && !r.plain
+  var deadline time.Time
+  if timeout > 0 {
+    deadline = time.Now().Add(timeout)
+  }
+  shown := ""
+  for poll := 1; ; poll++ {
+    tx, err := client.Transfers.Get(id)
+    if err != nil {
+      if terminal && shown != "" {
+        fmt.Fprintln(r.Stdout)
+      }
+      return err
+    }
+    r.progress(progressEvent{Event: progressStep, Step: "poll", Current: poll, Status: tx.Status, Message: fundingNote(tx)})
+    line := watchLine(tx, time.Now(), terminal)
+    if terminal {
+      fmt.Fprintf(r.Stdout, "\r\033[K%s", line)
+    } else if line != shown {
+      fmt.Fprintln(r.Stdout, line)
+    }
+    shown = line
+    if code, done := watchExitCode(tx); done {
+      if terminal {
+        fmt.Fprintln(r.Stdout)
+      }
+      if code == ExitCompleted {
+        return nil
+      }
+      return &exitError{code: code}
+    }
+    if !deadline.IsZero() && !time.Now().Add(interval).Before(deadline) {
+      if terminal {
+        fmt.Fprintln(r.Stdout)
+      }
+      return &exitError{ExitTimeout, errors.New("Timed out waiting for transfer " + id)}
+    }
+    time.Sleep(interval)
+  }
+}
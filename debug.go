@@ -0,0 +1,77 @@
+package bitwire
+
+import (
+  "bytes"
+  "io/ioutil"
+  "log/slog"
+  "net/http"
+  "net/url"
+  "regexp"
+  "strings"
+  "time"
+)
+
+// Form and JSON fields whose values are never logged
+var secretFields = []string{"password", "client_secret", "refresh_token", "access_token", "token", "username"}
+
+var secretJSON = regexp.MustCompile(`("(?:` + strings.Join(secretFields, "|") + `)"\s*:\s*)"[^"]*"`)
+
+// Replaces the values of secret fields in a form or JSON body, e.g. to log or store it
+func Redact(body []byte) string {
+  if len(body) == 0 {
+    return ""
+  }
+  if body[0] == '{' || body[0] == '[' {
+    return secretJSON.ReplaceAllString(string(body), `$1"[REDACTED]"`)
+  }
+  form, err := url.ParseQuery(string(body))
+  if err != nil {
+    return "[unparsed body]"
+  }
+  for _, f := range secretFields {
+    if _, ok := form[f]; ok {
+      form.Set(f, "[REDACTED]")
+    }
+  }
+  return form.Encode()
+}
+
+// Sends requests logging each one to the client's Logger at debug level
+type loggingDoer struct {
+  client *Client
+}
+
+func (d loggingDoer) Do(req *http.Request) (*http.Response, error) {
+  logger := d.client.Logger
+  var reqBody []byte
+  if d.client.LogBodies && req.GetBody != nil {
+    if body, err := req.GetBody(); err == nil {
+      reqBody, _ = ioutil.ReadAll(body)
+      body.Close()
+    }
+  }
+  start := time.Now()
+  resp, err := d.client.httpClient().Do(req)
+  attrs := []slog.Attr{
+    slog.String("method", req.Method),
+    slog.String("url", req.URL.String()),
+    slog.Duration("latency", time.Since(start)),
+  }
+  if err != nil {
+    attrs = append(attrs, slog.String("error", err.Error()))
+    logger.LogAttrs(req.Context(), slog.LevelDebug, "bitwire API call failed", attrs...)
+    return resp, err
+  }
+  attrs = append(attrs, slog.Int("status", resp.StatusCode))
+  if d.client.LogBodies {
+    respBody, readErr := ioutil.ReadAll(resp.Body)
+    resp.Body.Close()
+    resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+    if readErr != nil {
+      return resp, readErr
+    }
+    attrs = append(attrs, slog.String("request_body", Redact(reqBody)), slog.String("response_body", Redact(respBody)))
+  }
+  logger.LogAttrs(req.Context(), slog.LevelDebug, "bitwire API call", attrs...)
+  return resp, nil
+}
@@ -0,0 +1,151 @@
+package bitwire
+
+import (
+  "errors"
+  "math/big"
+  "strings"
+)
+
+// Exact decimal amount: a KRW or BTC amount, a limit or a rate.
+// Arithmetic uses math/big so it never loses precision. The zero value is 0.
+// Marshals to a JSON string and unmarshals from a JSON string or number.
+type Decimal struct {
+  rat   *big.Rat
+  scale int // Digits after the decimal point, kept so amounts print as the API returned them
+}
+
+var ErrInvalidDecimal = errors.New("Invalid decimal")
+
+// Returns value * 10^-scale, e.g. NewDecimal(1050, 2) is 10.50
+func NewDecimal(value int64, scale int) Decimal {
+  if scale < 0 {
+    scale = 0
+  }
+  denom := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+  return Decimal{new(big.Rat).SetFrac(big.NewInt(value), denom), scale}
+}
+
+// Parses a plain decimal number such as "100000", "-0.5" or "0.00012345"
+func ParseDecimal(s string) (Decimal, error) {
+  digits := strings.TrimPrefix(s, "-")
+  intPart, frac := digits, ""
+  point := strings.IndexByte(digits, '.')
+  if point >= 0 {
+    intPart, frac = digits[:point], digits[point+1:]
+    if frac == "" {
+      return Decimal{}, ErrInvalidDecimal
+    }
+  }
+  if intPart == "" || !isDigits(intPart) || !isDigits(frac) {
+    return Decimal{}, ErrInvalidDecimal
+  }
+  rat, ok := new(big.Rat).SetString(s)
+  if !ok {
+    return Decimal{}, ErrInvalidDecimal
+  }
+  return Decimal{rat, len(frac)}, nil
+}
+
+// Like ParseDecimal but panics on invalid input. Meant for constants and tests.
+func MustParseDecimal(s string) Decimal {
+  d, err := ParseDecimal(s)
+  if err != nil {
+    panic(err.Error() + ": " + s)
+  }
+  return d
+}
+
+func isDigits(s string) bool {
+  for _, c := range s {
+    if c < '0' || c > '9' {
+      return false
+    }
+  }
+  return true
+}
+
+// Returns the value as a big.Rat. The result is a copy and can be modified.
+func (d Decimal) Rat() *big.Rat {
+  if d.rat == nil {
+    return new(big.Rat)
+  }
+  return new(big.Rat).Set(d.rat)
+}
+
+func (d Decimal) String() string {
+  return d.Rat().FloatString(d.scale)
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+  return Decimal{new(big.Rat).Add(d.Rat(), e.Rat()), maxInt(d.scale, e.scale)}
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+  return Decimal{new(big.Rat).Sub(d.Rat(), e.Rat()), maxInt(d.scale, e.scale)}
+}
+
+func (d Decimal) Mul(e Decimal) Decimal {
+  return Decimal{new(big.Rat).Mul(d.Rat(), e.Rat()), d.scale + e.scale}
+}
+
+// Returns d / e rounded away from zero to scale digits after the point. Panics if e is zero.
+func (d Decimal) QuoUp(e Decimal, scale int) Decimal {
+  if scale < 0 {
+    scale = 0
+  }
+  q := new(big.Rat).Quo(d.Rat(), e.Rat())
+  unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+  q.Mul(q, new(big.Rat).SetInt(unit))
+  num := new(big.Int).Abs(q.Num())
+  n, rem := new(big.Int).QuoRem(num, q.Denom(), new(big.Int))
+  if rem.Sign() != 0 {
+    n.Add(n, big.NewInt(1))
+  }
+  if q.Sign() < 0 {
+    n.Neg(n)
+  }
+  return Decimal{new(big.Rat).SetFrac(n, unit), scale}
+}
+
+// Returns -1, 0 or +1 when d is less than, equal to or greater than e
+func (d Decimal) Cmp(e Decimal) int {
+  return d.Rat().Cmp(e.Rat())
+}
+
+// Returns -1, 0 or +1 when d is negative, zero or positive
+func (d Decimal) Sign() int {
+  if d.rat == nil {
+    return 0
+  }
+  return d.rat.Sign()
+}
+
+func (d Decimal) IsZero() bool {
+  return d.Sign() == 0
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+  return []byte(`"` + d.String() + `"`), nil
+}
+
+// Accepts "12.50", 12.50, "" and null. Empty values unmarshal to 0.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+  s := strings.Trim(string(data), `"`)
+  if s == "" || s == "null" {
+    *d = Decimal{}
+    return nil
+  }
+  parsed, err := ParseDecimal(s)
+  if err != nil {
+    return errors.New("Invalid decimal: " + string(data))
+  }
+  *d = parsed
+  return nil
+}
+
+func maxInt(a, b int) int {
+  if a > b {
+    return a
+  }
+  return b
+}
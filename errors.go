@@ -0,0 +1,129 @@
+package bitwire
+
+import (
+  "errors"
+  "net/http"
+  "sort"
+)
+
+// Sentinel errors matched by APIError with errors.Is
+var (
+  ErrUnauthorized = errors.New("unauthorized")
+  ErrTokenExpired = errors.New("token expired")
+  ErrRateLimited  = errors.New("rate limited")
+)
+
+// Returned by authenticated calls on a client with no token
+var ErrMissingToken = errors.New("Missing auth token")
+
+// Returned by PreflightToken when the token expires before the run ends and the client cannot refresh it
+var ErrTokenTooShort = errors.New("The token expires before the run ends and cannot be refreshed")
+
+// Error response returned by the API
+type APIError struct {
+  Code       int    // Code field of the response body
+  ErrorType  string // errorType field of the response body
+  Message    string
+  HTTPStatus int
+}
+
+func (e *APIError) Error() string {
+  return e.ErrorType + ": " + e.Message
+}
+
+// Matches the sentinel errors by HTTP status, error type and message
+func (e *APIError) Is(target error) bool {
+  switch target {
+  case ErrUnauthorized:
+    return e.HTTPStatus == http.StatusUnauthorized || e.ErrorType == "Unauthorized"
+  case ErrTokenExpired:
+    return e.Is(ErrUnauthorized) && e.Message == "Token expired."
+  case ErrRateLimited:
+    return e.HTTPStatus == http.StatusTooManyRequests
+  default:
+    return false
+  }
+}
+
+// Actionable explanation of an API error type
+type ErrorHint struct {
+  ErrorType string
+  Summary   string // What the error means
+  Action    string // What the user can do about it
+}
+
+// Known API error types and what to do about them.
+// Keys are the errorType values returned by the API.
+var errorHints = map[string]ErrorHint{
+  "Unauthorized": {
+    ErrorType: "Unauthorized",
+    Summary:   "the API token is invalid or expired and could not be refreshed",
+    Action:    "Run `bitwire config` to authenticate again",
+  },
+  "Rate limited": {
+    ErrorType: "Rate limited",
+    Summary:   "too many requests were sent to the API in a short time",
+    Action:    "Wait a minute and run the command again",
+  },
+  "invalid_grant": {
+    ErrorType: "invalid_grant",
+    Summary:   "the username, password or refresh token was rejected",
+    Action:    "Check your credentials and run `bitwire config` again",
+  },
+  "invalid_client": {
+    ErrorType: "invalid_client",
+    Summary:   "the API client ID or secret was rejected",
+    Action:    "Check the client ID and secret of your Bitwire API application and run `bitwire config` again",
+  },
+  "limit_exceeded": {
+    ErrorType: "limit_exceeded",
+    Summary:   "the transfer would exceed your KRW limits",
+    Action:    "Your daily KRW limit is exhausted; run `bitwire limits`, or `bitwire verification` to raise the limits",
+  },
+  "verification_required": {
+    ErrorType: "verification_required",
+    Summary:   "your verification level does not allow the transfer",
+    Action:    "Complete the next verification steps; run `bitwire verification`",
+  },
+  "pending_limit_exceeded": {
+    ErrorType: "pending_limit_exceeded",
+    Summary:   "you have too many pending transfers",
+    Action:    "Pay or cancel a pending transfer first; run `bitwire transfer list`",
+  },
+  "not_found": {
+    ErrorType: "not_found",
+    Summary:   "the transfer or recipient does not exist",
+    Action:    "Check the ID; run `bitwire transfer list` or `bitwire recipient list`",
+  },
+  "validation_error": {
+    ErrorType: "validation_error",
+    Summary:   "the request contains invalid values",
+    Action:    "Check the amount and recipient ID; run `bitwire limits` for the minimum amount",
+  },
+}
+
+// Returns the hint for an API error type
+func HintFor(errorType string) (ErrorHint, bool) {
+  hint, ok := errorHints[errorType]
+  return hint, ok
+}
+
+// Returns the hint for an error returned by the client, if its type is known
+func HintForError(err error) (ErrorHint, bool) {
+  var apiErr *APIError
+  if errors.As(err, &apiErr) {
+    return HintFor(apiErr.ErrorType)
+  } else {
+    return ErrorHint{}, false
+  }
+}
+
+// Returns all known hints sorted by error type
+func ErrorHints() []ErrorHint {
+  var hints []ErrorHint
+  for _, h := range errorHints {
+    hints = append(hints, h)
+  }
+  sort.Slice(hints, func(i, j int) bool { return hints[i].ErrorType < hints[j].ErrorType })
+  return hints
+}
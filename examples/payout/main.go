@@ -0,0 +1,157 @@
+// Example of a batch payout that is safe to run again, e.g. after a crash or a network failure: each payout has
+// a unique key, and an outbox file records the key before the transfer is created and its transfer ID after.
+// A key recorded without a transfer ID may or may not have made it to the API, so it is looked up by the memo,
+// which carries the key, before creating the transfer again. The client is configured from the environment,
+// see bitwire.InitFromEnv:
+//
+//	go run ./examples/payout -payouts payouts.json -outbox outbox.jsonl
+//
+// with payouts.json like [{"key": "rent-2026-10", "recipient": 42, "amount": "500000"}].
+package main
+
+import (
+  "bufio"
+  "context"
+  "encoding/json"
+  "flag"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "io"
+  "io/ioutil"
+  "os"
+  "time"
+)
+
+// Generous estimate of the time a payout takes, to check before the batch starts that the token lasts for it
+const payoutTime = 10 * time.Second
+
+// KRW payout to a recipient
+type payout struct {
+  Key       string          `json:"key"` // Unique, e.g. rent-2026-10, and sent as the memo of the transfer
+  Recipient int             `json:"recipient"`
+  Amount    bitwire.Decimal `json:"amount"`
+}
+
+// Line of the outbox file
+type outboxEntry struct {
+  Key        string `json:"key"`
+  TransferId string `json:"transfer_id,omitempty"` // Empty when recorded before creating the transfer
+}
+
+func main() {
+  payouts := flag.String("payouts", "payouts.json", "JSON file with the payouts")
+  outbox := flag.String("outbox", "outbox.jsonl", "outbox file, created if missing")
+  flag.Parse()
+  client, err := bitwire.InitFromEnv(context.Background(), nil)
+  if err == nil {
+    err = run(client, os.Stdout, *payouts, *outbox)
+  }
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+// Returns the transfer IDs of the keys in the outbox, empty for keys without one
+func readOutbox(path string) (map[string]string, error) {
+  ids := map[string]string{}
+  file, err := os.Open(path)
+  if os.IsNotExist(err) {
+    return ids, nil
+  } else if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+  scanner := bufio.NewScanner(file)
+  for scanner.Scan() {
+    entry := outboxEntry{}
+    if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+      return nil, err
+    }
+    if ids[entry.Key] == "" {
+      ids[entry.Key] = entry.TransferId
+    }
+  }
+  return ids, scanner.Err()
+}
+
+// Appends the entry to the outbox, synced to the disk before the transfer is created
+func appendOutbox(path string, entry outboxEntry) error {
+  file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  line, err := json.Marshal(entry)
+  if err != nil {
+    return err
+  }
+  if _, err := file.Write(append(line, '\n')); err != nil {
+    return err
+  }
+  return file.Sync()
+}
+
+// Returns the transfer to the recipient with the memo, if any
+func findTransfer(client *bitwire.Client, p payout) (bitwire.Transfer, bool, error) {
+  transfers, err := client.Transfers.List(&bitwire.TransferListOptions{RecipientId: p.Recipient, Type: bitwire.TypeBtcToBank})
+  if err != nil {
+    return bitwire.Transfer{}, false, err
+  }
+  for _, tx := range transfers {
+    if tx.Memo == p.Key {
+      return tx, true, nil
+    }
+  }
+  return bitwire.Transfer{}, false, nil
+}
+
+func run(client *bitwire.Client, out io.Writer, payoutsPath, outboxPath string) error {
+  data, err := ioutil.ReadFile(payoutsPath)
+  if err != nil {
+    return err
+  }
+  var payouts []payout
+  if err := json.Unmarshal(data, &payouts); err != nil {
+    return err
+  }
+  ids, err := readOutbox(outboxPath)
+  if err != nil {
+    return err
+  }
+  if _, err := client.PreflightToken(time.Duration(len(payouts)) * payoutTime); err != nil {
+    return err
+  }
+  for _, p := range payouts {
+    id, recorded := ids[p.Key]
+    if id != "" {
+      fmt.Fprintf(out, "%s: already sent as %s\n", p.Key, id)
+      continue
+    }
+    if recorded {
+      tx, found, err := findTransfer(client, p)
+      if err != nil {
+        return fmt.Errorf("%s: %s", p.Key, err)
+      }
+      if found {
+        fmt.Fprintf(out, "%s: found %s, created before the outbox recorded it\n", p.Key, tx.Id)
+        if err := appendOutbox(outboxPath, outboxEntry{p.Key, tx.Id}); err != nil {
+          return err
+        }
+        continue
+      }
+    } else if err := appendOutbox(outboxPath, outboxEntry{Key: p.Key}); err != nil {
+      return err
+    }
+    tx, err := client.Transfers.Create(bitwire.CreateTransfer{Amount: p.Amount, Currency: "KRW", RecipientId: p.Recipient,
+      Memo: p.Key, Type: bitwire.TypeBtcToBank})
+    if err != nil {
+      return fmt.Errorf("%s: %s", p.Key, err)
+    }
+    if err := appendOutbox(outboxPath, outboxEntry{p.Key, tx.Id}); err != nil {
+      return err
+    }
+    fmt.Fprintf(out, "%s: created %s, pay %s BTC\n", p.Key, tx.Id, tx.Amount)
+  }
+  return nil
+}
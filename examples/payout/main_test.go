@@ -0,0 +1,48 @@
+package main
+
+import (
+  "bytes"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/stretchr/testify/assert"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestPayout(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  dir, err := ioutil.TempDir("", "payout")
+  assert.Nil(t, err)
+  defer os.RemoveAll(dir)
+  payouts, outbox := filepath.Join(dir, "payouts.json"), filepath.Join(dir, "outbox.jsonl")
+  assert.Nil(t, ioutil.WriteFile(payouts, []byte(`[
+  {"key": "rent-2026-10", "recipient": 42, "amount": "500000"},
+  {"key": "allowance-2026-10", "recipient": 43, "amount": "100000"}
+]`), 0600))
+
+  // A crash after recording the key, with the transfer created or not
+  tx, err := client.Transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("500000"), Currency: "KRW",
+    RecipientId: 42, Memo: "rent-2026-10", Type: bitwire.TypeBtcToBank})
+  assert.Nil(t, err)
+  assert.Nil(t, appendOutbox(outbox, outboxEntry{Key: "rent-2026-10"}))
+  assert.Nil(t, appendOutbox(outbox, outboxEntry{Key: "allowance-2026-10"}))
+  transfers := len(server.Fake.Transfers.Data)
+
+  var out bytes.Buffer
+  assert.Nil(t, run(client, &out, payouts, outbox))
+  assert.Contains(t, out.String(), "rent-2026-10: found "+tx.Id+", created before the outbox recorded it\n")
+  assert.Contains(t, out.String(), "allowance-2026-10: created tx_")
+  assert.Len(t, server.Fake.Transfers.Data, transfers+1)
+
+  // Running again sends nothing
+  out.Reset()
+  assert.Nil(t, run(client, &out, payouts, outbox))
+  assert.Contains(t, out.String(), "rent-2026-10: already sent as "+tx.Id)
+  assert.Contains(t, out.String(), "allowance-2026-10: already sent as tx_")
+  assert.Len(t, server.Fake.Transfers.Data, transfers+1)
+}
@@ -0,0 +1,56 @@
+// Example of authenticating and creating a btc_to_bank transfer: estimates the BTC paying out the KRW amount
+// to the recipient, creates the transfer and prints the payment URI to pay it with from a wallet.
+// The client is configured from the environment, see bitwire.InitFromEnv:
+//
+//	BITWIRE_MODE=sandbox BITWIRE_CLIENT_ID=... BITWIRE_CLIENT_SECRET=... BITWIRE_USERNAME=... BITWIRE_PASSWORD=... \
+//	  go run ./examples/transfer -recipient 42 -amount 100000
+package main
+
+import (
+  "context"
+  "errors"
+  "flag"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "io"
+  "os"
+)
+
+func main() {
+  recipient := flag.Int("recipient", 0, "ID of the recipient")
+  amount := flag.String("amount", "", "KRW amount the recipient receives")
+  flag.Parse()
+  if err := run(os.Stdout, *recipient, *amount); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+func run(out io.Writer, recipientId int, amount string) error {
+  value, err := bitwire.ParseDecimal(amount)
+  if err != nil || value.Sign() <= 0 {
+    return errors.New("Invalid amount: " + amount)
+  }
+  client, err := bitwire.InitFromEnv(context.Background(), nil)
+  if err != nil {
+    return err
+  }
+  estimate, err := client.Transfers.Estimate(value, "KRW")
+  if err != nil {
+    return err
+  }
+  fmt.Fprintf(out, "Paying out %s KRW takes about %s BTC at %s KRW/BTC\n", value, estimate.BTC, estimate.Rate)
+  trans := bitwire.CreateTransfer{Amount: value, Currency: "KRW", RecipientId: recipientId, Type: bitwire.TypeBtcToBank}
+  if err := trans.Validate(client.Mode); err != nil {
+    return err
+  }
+  tx, err := client.Transfers.Create(trans)
+  if err != nil {
+    return err
+  }
+  fmt.Fprintf(out, "Created transfer %s to %s, pay %s BTC before it expires\n", tx.Id, tx.Recipient.Name, tx.Amount)
+  if uri := tx.PaymentURI(client.Mode); uri != "" {
+    fmt.Fprintln(out, uri)
+  }
+  return nil
+}
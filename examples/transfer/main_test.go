@@ -0,0 +1,42 @@
+package main
+
+import (
+  "bytes"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/stretchr/testify/assert"
+  "os"
+  "testing"
+)
+
+// Configures bitwire.InitFromEnv for the simulator
+func setEnv(server *bitwiretest.Server) func() {
+  env := map[string]string{bitwire.EnvMode: string(bitwire.SANDBOX), bitwire.EnvAPIURL: server.APIURL(),
+    bitwire.EnvClientId: server.ClientId, bitwire.EnvClientSecret: server.ClientSecret,
+    bitwire.EnvUsername: server.Username, bitwire.EnvPassword: server.Password}
+  for name, value := range env {
+    os.Setenv(name, value)
+  }
+  return func() {
+    for name := range env {
+      os.Unsetenv(name)
+    }
+  }
+}
+
+func TestTransfer(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  defer setEnv(server)()
+
+  var out bytes.Buffer
+  assert.Nil(t, run(&out, 42, "100000"))
+  assert.Contains(t, out.String(), "Paying out 100000 KRW takes about 0.1")
+  assert.Contains(t, out.String(), "at 1000000 KRW/BTC")
+  assert.Contains(t, out.String(), "Created transfer tx_")
+  assert.Contains(t, out.String(), "to Kim Minjun, pay 0.1")
+  assert.Len(t, server.Fake.Transfers.Data, 3)
+
+  assert.EqualError(t, run(&out, 42, "lots"), "Invalid amount: lots")
+  assert.NotNil(t, run(&out, 99, "100000"), "unknown recipient")
+}
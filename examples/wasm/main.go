@@ -0,0 +1,72 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Browser example of the client. Exposes two functions returning a Promise to JavaScript:
+// bitwireRates() resolves to the sandbox rates as JSON, and bitwireConvert(from, to, amount),
+// e.g. ("BTC", "KRW", "0.5"), to the amount converted at the sandbox rates.
+// Build it with `make wasm` and serve this directory, see index.html.
+package main
+
+import (
+  "encoding/json"
+  "github.com/dworznik/bitwire"
+  "syscall/js"
+)
+
+func main() {
+  client, err := bitwire.New(bitwire.SANDBOX)
+  if err != nil {
+    panic(err)
+  }
+  js.Global().Set("bitwireRates", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+    return promise(func() (string, error) {
+      rates, err := client.Rates.All()
+      if err != nil {
+        return "", err
+      }
+      b, err := json.Marshal(rates)
+      return string(b), err
+    })
+  }))
+  js.Global().Set("bitwireConvert", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+    if len(args) != 3 {
+      return rejected("Usage: bitwireConvert(from, to, amount)")
+    }
+    from, to, amount := args[0].String(), args[1].String(), args[2].String()
+    return promise(func() (string, error) {
+      value, err := bitwire.ParseDecimal(amount)
+      if err != nil {
+        return "", err
+      }
+      rates, err := client.Rates.All()
+      if err != nil {
+        return "", err
+      }
+      converted, err := rates.Convert(from, to, value)
+      return converted.String(), err
+    })
+  }))
+  // Keeps the functions callable
+  select {}
+}
+
+// Runs f in a goroutine and returns a Promise of its result. Calls blocking on HTTP requests
+// cannot run on the JavaScript event loop.
+func promise(f func() (string, error)) js.Value {
+  return js.Global().Get("Promise").New(js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+    resolve, reject := args[0], args[1]
+    go func() {
+      result, err := f()
+      if err != nil {
+        reject.Invoke(js.Global().Get("Error").New(err.Error()))
+      } else {
+        resolve.Invoke(result)
+      }
+    }()
+    return nil
+  }))
+}
+
+func rejected(message string) js.Value {
+  return js.Global().Get("Promise").Call("reject", js.Global().Get("Error").New(message))
+}
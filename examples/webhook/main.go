@@ -0,0 +1,56 @@
+// Example of a webhook consumer: verifies the notifications with the signing secret and handles each event type once,
+// as a notification is sent again until it is acknowledged with a 2xx response.
+//
+//	BITWIRE_WEBHOOK_SECRET=... go run ./examples/webhook -addr localhost:4242
+package main
+
+import (
+  "flag"
+  "fmt"
+  "github.com/dworznik/bitwire/webhook"
+  "io"
+  "net/http"
+  "os"
+  "sync"
+)
+
+func main() {
+  addr := flag.String("addr", "localhost:4242", "address to listen on")
+  flag.Parse()
+  secret := os.Getenv("BITWIRE_WEBHOOK_SECRET")
+  if secret == "" {
+    fmt.Fprintln(os.Stderr, "Missing BITWIRE_WEBHOOK_SECRET")
+    os.Exit(1)
+  }
+  if err := http.ListenAndServe(*addr, handler(os.Stdout, secret)); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+// Returns the handler of the notifications, printing what happened to each transfer
+func handler(out io.Writer, secret string) http.Handler {
+  var mu sync.Mutex
+  seen := map[string]bool{} // IDs of the handled events. Keep them in a database to survive restarts.
+  return webhook.Handler(secret, func(event webhook.Event) {
+    mu.Lock()
+    defer mu.Unlock()
+    if seen[event.Id] {
+      return
+    }
+    seen[event.Id] = true
+    tx := event.Transfer
+    switch event.Type {
+    case webhook.TransferPaid:
+      fmt.Fprintf(out, "%s paid, %s BTC received\n", tx.Id, tx.BTC.Received)
+    case webhook.TransferCompleted:
+      fmt.Fprintf(out, "%s completed, %s %s paid out to %s\n", tx.Id, tx.Recipient.Amount, tx.Recipient.Currency, tx.Recipient.Name)
+    case webhook.TransferUnderpaid:
+      fmt.Fprintf(out, "%s underpaid, %s BTC missing\n", tx.Id, tx.Missing())
+    case webhook.TransferOverpaid:
+      fmt.Fprintf(out, "%s overpaid, %s BTC to be refunded\n", tx.Id, tx.Excess())
+    default:
+      fmt.Fprintf(out, "%s %s\n", tx.Id, event.Type)
+    }
+  })
+}
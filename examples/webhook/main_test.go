@@ -0,0 +1,34 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/webhook"
+  "github.com/stretchr/testify/assert"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+func TestWebhook(t *testing.T) {
+  var out bytes.Buffer
+  server := httptest.NewServer(handler(&out, "secret"))
+  defer server.Close()
+  post := func(event webhook.Event, secret string) int {
+    payload, _ := json.Marshal(event)
+    req, _ := http.NewRequest("POST", server.URL, bytes.NewReader(payload))
+    req.Header.Set(webhook.SignatureHeader, webhook.Sign(payload, secret))
+    res, err := http.DefaultClient.Do(req)
+    assert.Nil(t, err)
+    res.Body.Close()
+    return res.StatusCode
+  }
+  tx := bitwire.Transfer{Id: "tx_1", Amount: bitwire.MustParseDecimal("0.1")}
+  tx.BTC.Received = bitwire.MustParseDecimal("0.1")
+  paid := webhook.Event{Id: "evt_1", Type: webhook.TransferPaid, Transfer: tx}
+  assert.Equal(t, http.StatusOK, post(paid, "secret"))
+  assert.Equal(t, http.StatusOK, post(paid, "secret"), "sent again")
+  assert.Equal(t, http.StatusUnauthorized, post(webhook.Event{Id: "evt_2", Type: webhook.TransferExpired, Transfer: tx}, "guess"))
+  assert.Equal(t, "tx_1 paid, 0.1 BTC received\n", out.String())
+}
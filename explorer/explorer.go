@@ -0,0 +1,114 @@
+// Looks up payments to transfer addresses on the Bitcoin blockchain
+// through an Esplora block explorer API, e.g. blockstream.info
+package explorer
+
+import (
+  "errors"
+  "fmt"
+  "github.com/dghubble/sling"
+  "github.com/dworznik/bitwire"
+  "net/http"
+)
+
+// Public Esplora APIs used by New
+const (
+  MainnetURL = "https://blockstream.info/api/"
+  TestnetURL = "https://blockstream.info/testnet/api/"
+)
+
+type Client struct {
+  BaseURL string
+}
+
+// Returns a client of the public explorer for the chain used by the API mode:
+// mainnet in production and testnet in sandbox
+func New(mode bitwire.Mode) *Client {
+  if mode == bitwire.SANDBOX {
+    return &Client{TestnetURL}
+  }
+  return &Client{MainnetURL}
+}
+
+// Bitcoin transaction paying to an address
+type Payment struct {
+  TxId          string
+  Amount        bitwire.Decimal // BTC paid to the address by the transaction
+  Confirmations int             // Zero while the transaction is in the mempool
+}
+
+type tx struct {
+  TxId string `json:"txid"`
+  Vout []struct {
+    Address string `json:"scriptpubkey_address"`
+    Value   int64  `json:"value"` // Satoshis
+  } `json:"vout"`
+  Status struct {
+    Confirmed   bool  `json:"confirmed"`
+    BlockHeight int64 `json:"block_height"`
+  } `json:"status"`
+}
+
+// Returns the payments to the address, the newest first
+func (c *Client) Payments(address string) ([]Payment, error) {
+  if address == "" {
+    return nil, errors.New("Missing address")
+  }
+  var txs []tx
+  if err := c.get("address/"+address+"/txs", &txs); err != nil {
+    return nil, err
+  }
+  var height int64
+  for _, t := range txs {
+    if t.Status.Confirmed {
+      if err := c.get("blocks/tip/height", &height); err != nil {
+        return nil, err
+      }
+      break
+    }
+  }
+  var payments []Payment
+  for _, t := range txs {
+    var sats int64
+    for _, out := range t.Vout {
+      if out.Address == address {
+        sats += out.Value
+      }
+    }
+    if sats == 0 {
+      continue
+    }
+    payment := Payment{TxId: t.TxId, Amount: bitwire.NewDecimal(sats, 8)}
+    if t.Status.Confirmed && height >= t.Status.BlockHeight {
+      payment.Confirmations = int(height-t.Status.BlockHeight) + 1
+    }
+    payments = append(payments, payment)
+  }
+  return payments, nil
+}
+
+// Returns the total paid to the address and the confirmations of the least confirmed payment.
+// The payment is nil if nothing was paid yet.
+func (c *Client) Funding(address string) (*Payment, error) {
+  payments, err := c.Payments(address)
+  if err != nil || len(payments) == 0 {
+    return nil, err
+  }
+  funding := payments[0]
+  for _, p := range payments[1:] {
+    funding.Amount = funding.Amount.Add(p.Amount)
+    if p.Confirmations < funding.Confirmations {
+      funding.Confirmations = p.Confirmations
+    }
+  }
+  return &funding, nil
+}
+
+func (c *Client) get(path string, res interface{}) error {
+  resp, err := sling.New().Base(c.BaseURL).Get(path).ReceiveSuccess(res)
+  if err != nil {
+    return err
+  } else if resp.StatusCode != http.StatusOK {
+    return fmt.Errorf("Explorer responded with %s", resp.Status)
+  }
+  return nil
+}
@@ -0,0 +1,47 @@
+package explorer
+
+import (
+  "fmt"
+  "github.com/stretchr/testify/assert"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+const address = "2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF"
+
+func TestFunding(t *testing.T) {
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    switch r.URL.Path {
+    case "/address/" + address + "/txs":
+      fmt.Fprintf(w, `[
+        {"txid":"b","vout":[{"scriptpubkey_address":"%s","value":2000}],"status":{"confirmed":false}},
+        {"txid":"a","vout":[{"scriptpubkey_address":"other","value":5},{"scriptpubkey_address":"%s","value":1234000}],
+         "status":{"confirmed":true,"block_height":100}}
+      ]`, address, address)
+    case "/blocks/tip/height":
+      fmt.Fprint(w, "102")
+    default:
+      http.NotFound(w, r)
+    }
+  }))
+  defer server.Close()
+  client := &Client{server.URL + "/"}
+
+  payments, err := client.Payments(address)
+  assert.Nil(t, err)
+  assert.Len(t, payments, 2)
+  assert.Equal(t, "a", payments[1].TxId)
+  assert.Equal(t, "0.01234000", payments[1].Amount.String())
+  assert.Equal(t, 3, payments[1].Confirmations)
+  assert.Equal(t, 0, payments[0].Confirmations)
+
+  funding, err := client.Funding(address)
+  assert.Nil(t, err)
+  assert.Equal(t, "0.01236000", funding.Amount.String())
+  assert.Equal(t, 0, funding.Confirmations)
+
+  funding, err = client.Funding("unknown")
+  assert.NotNil(t, err)
+  assert.Nil(t, funding)
+}
@@ -0,0 +1,41 @@
+package bitwire
+
+type FeesRes struct {
+  Res
+  Fees Fees `json:"fees"`
+}
+
+// Fee schedule of transfers
+type Fees struct {
+  Network         Decimal `json:"network"`           // BTC network fee of a transfer
+  ServicePercent  Decimal `json:"service_percent"`   // Service fee, in percent of the received amount
+  ServiceMin      Decimal `json:"service_min"`       // Minimum service fee, in Currency
+  FXSpreadPercent Decimal `json:"fx_spread_percent"` // Margin of the exchange rate over the mid-market rate, in percent
+  Currency        string  `json:"currency"`
+}
+
+// Fees charged for a transfer. Zero if the API does not report them.
+type TransferFees struct {
+  Network  Decimal `json:"network"`   // In BTC
+  Service  Decimal `json:"service"`   // In Currency
+  FXSpread Decimal `json:"fx_spread"` // In Currency, the cost of the exchange rate over the mid-market rate
+  Currency string  `json:"currency"`
+}
+
+// Tells if no fees were reported
+func (f TransferFees) IsZero() bool {
+  return f.Network.IsZero() && f.Service.IsZero() && f.FXSpread.IsZero()
+}
+
+// Handles the fee schedule
+type FeesService service
+
+func (s *FeesService) Get() (Fees, error) {
+  feesRes := new(FeesRes)
+  err := callApi(GET, "fees", nil, s.client, false, feesRes)
+  if err != nil {
+    return Fees{}, err
+  } else {
+    return feesRes.Fees, nil
+  }
+}
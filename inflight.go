@@ -0,0 +1,53 @@
+package bitwire
+
+import "strings"
+
+// Money in transfers that are not completed, expired or canceled yet
+type InFlight struct {
+  Pending     int     `json:"pending"`      // Transfers in flight
+  Unfunded    int     `json:"unfunded"`     // Transfers in flight not paid yet
+  KRW         Decimal `json:"krw"`          // KRW value of the transfers in flight
+  AwaitingBTC Decimal `json:"awaiting_btc"` // BTC paid to transfers in flight, waiting for confirmations and payout
+}
+
+// Adds up the transfers that are neither final nor past their payment window. Amounts in other currencies
+// are valued in KRW at the rates; an amount without a rate to KRW fails with an error matching ErrNoRate.
+func ComputeInFlight(txs []Transfer, rates AllRates) (InFlight, error) {
+  var f InFlight
+  for _, tx := range txs {
+    if tx.IsFinal() || tx.IsExpired() {
+      continue
+    }
+    amount, currency := tx.Recipient.Amount, tx.Recipient.Currency
+    if tx.IsBankToBtc() {
+      amount, currency = tx.Deposit.Amount, tx.Deposit.Currency
+    }
+    if currency != "" && !strings.EqualFold(currency, "KRW") && amount.Sign() > 0 {
+      var err error
+      if amount, err = rates.Convert(currency, "KRW", amount); err != nil {
+        return InFlight{}, err
+      }
+    }
+    f.Pending++
+    f.KRW = f.KRW.Add(amount)
+    if tx.Funding() == FundingUnpaid {
+      f.Unfunded++
+    } else if !tx.IsBankToBtc() {
+      f.AwaitingBTC = f.AwaitingBTC.Add(tx.BTC.Received)
+    }
+  }
+  return f, nil
+}
+
+// Returns the money in flight in the account's transfers, valued at the current rates
+func (s *TransfersService) InFlight() (InFlight, error) {
+  txs, err := s.List(nil)
+  if err != nil {
+    return InFlight{}, err
+  }
+  rates, err := s.client.Rates.All()
+  if err != nil {
+    return InFlight{}, err
+  }
+  return ComputeInFlight(txs, rates)
+}
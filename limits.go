@@ -0,0 +1,54 @@
+package bitwire
+
+type LimitsRes struct {
+  Res
+  Limits Limits `json:"limits"`
+}
+
+type Limits struct {
+  Transfers TransferLimits `json:"transfers"`
+  KRW       struct {
+    Min    Decimal   `json:"min"`
+    Daily  KrwLimits `json:"daily"`
+    Weekly KrwLimits `json:"weekly"`
+  } `json:"krw"`
+  BTC struct {
+    Min Decimal `json:"min"`
+  }
+}
+
+type KrwLimits struct {
+  Used  Decimal `json:"used"`
+  Left  Decimal `json:"left"`
+  Limit Decimal `json:"limit"`
+}
+
+type TransferLimits struct {
+  Pending struct {
+    Total struct {
+      Used  int `json:"used"`
+      Limit int `json:"limit"`
+    } `json:"total"`
+  } `json:"pending"`
+  Completed struct {
+    Daily struct {
+      Used  int `json:"used"`
+      Limit int `json:"limit"`
+    } `json:"daily"`
+  } `json:"completed"`
+}
+
+// Handles the authenticated user's KRW and transfer count limits
+// https://developers.bitwire.co/api/v1/#limits
+type LimitsService service
+
+func (s *LimitsService) Get() (Limits, error) {
+  limitsRes := new(LimitsRes)
+  err := callApi(GET, "users/limits", nil, s.client, true, limitsRes)
+  if err != nil {
+    return Limits{}, err
+  } else {
+    checkLimits(s.client, limitsRes.Limits)
+    return limitsRes.Limits, nil
+  }
+}
@@ -0,0 +1,106 @@
+package bitwire
+
+import (
+  "github.com/prometheus/client_golang/prometheus"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Prometheus collectors recording the API calls of the clients using them
+type Metrics struct {
+  requests  *prometheus.CounterVec
+  errors    *prometheus.CounterVec
+  latency   *prometheus.HistogramVec
+  refreshes *prometheus.CounterVec
+  inFlight  *prometheus.GaugeVec
+}
+
+// Creates the collectors and registers them with reg. Clients record their calls once
+// their Metrics field is set; several clients can share the same Metrics.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+  m := &Metrics{
+    requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "bitwire_requests_total",
+      Help: "Bitwire API calls by endpoint, method and HTTP status code.",
+    }, []string{"endpoint", "method", "code"}),
+    errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "bitwire_request_errors_total",
+      Help: "Bitwire API calls that failed, by endpoint and method.",
+    }, []string{"endpoint", "method"}),
+    latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+      Name:    "bitwire_request_duration_seconds",
+      Help:    "Duration of Bitwire API calls, including rate limit retries, by endpoint and method.",
+      Buckets: prometheus.DefBuckets,
+    }, []string{"endpoint", "method"}),
+    refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+      Name: "bitwire_token_refreshes_total",
+      Help: "Bitwire API token refreshes by result, success or error.",
+    }, []string{"result"}),
+    inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+      Name: "bitwire_in_flight",
+      Help: "Money in transfers not completed, expired or canceled yet, as set by SetInFlight: " +
+        "transfers and unfunded transfers, their KRW value and the BTC paid to them awaiting confirmation.",
+    }, []string{"measure"}),
+  }
+  for _, collector := range []prometheus.Collector{m.requests, m.errors, m.latency, m.refreshes, m.inFlight} {
+    if err := reg.Register(collector); err != nil {
+      return nil, err
+    }
+  }
+  return m, nil
+}
+
+// Records a finished API call
+func (m *Metrics) observeCall(method Method, path string, stats *callStats, duration time.Duration, err error) {
+  endpoint, httpMethod := endpointLabel(path), method.httpMethod()
+  code := "none"
+  if stats.Status != 0 {
+    code = strconv.Itoa(stats.Status)
+  }
+  m.requests.WithLabelValues(endpoint, httpMethod, code).Inc()
+  if err != nil {
+    m.errors.WithLabelValues(endpoint, httpMethod).Inc()
+  }
+  m.latency.WithLabelValues(endpoint, httpMethod).Observe(duration.Seconds())
+}
+
+// Records a token refresh
+func (m *Metrics) observeRefresh(err error) {
+  if err != nil {
+    m.refreshes.WithLabelValues("error").Inc()
+  } else {
+    m.refreshes.WithLabelValues("success").Inc()
+  }
+}
+
+// Returns the approximate value of the decimal for a gauge. Prometheus gauges are binary floating point,
+// so this is the one place amounts leave Decimal, exempted by name from TestNoFloatsInMoneyCode.
+func gaugeValue(value Decimal) float64 {
+  approx, _ := value.Rat().Float64()
+  return approx
+}
+
+// Sets the in flight gauges, e.g. from Transfers.InFlight polled by a long-running process.
+// The KRW and BTC values are approximate, see gaugeValue.
+func (m *Metrics) SetInFlight(f InFlight) {
+  set := func(measure string, value Decimal) {
+    m.inFlight.WithLabelValues(measure).Set(gaugeValue(value))
+  }
+  set("transfers", NewDecimal(int64(f.Pending), 0))
+  set("unfunded_transfers", NewDecimal(int64(f.Unfunded), 0))
+  set("krw", f.KRW)
+  set("awaiting_btc", f.AwaitingBTC)
+}
+
+// Replaces IDs in the endpoint path with ":id", keeping the label's cardinality low.
+// Path segments other than the first are taken as IDs unless they are lowercase words.
+func endpointLabel(path string) string {
+  segments := strings.Split(path, "/")
+  for i := 1; i < len(segments); i++ {
+    if strings.Trim(segments[i], "abcdefghijklmnopqrstuvwxyz") != "" {
+      segments[i] = ":id"
+    }
+  }
+  return strings.Join(segments, "/")
+}
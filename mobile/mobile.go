@@ -0,0 +1,234 @@
+// Package mobile is a facade of the client for iOS and Android apps, built with
+// `gomobile bind github.com/dworznik/bitwire/mobile`. Its signatures only use the types gomobile
+// binds: strings, ints, bools, errors and pointers to the structs below. Amounts are decimal strings,
+// and lists are read with Len and Get.
+package mobile
+
+import "github.com/dworznik/bitwire"
+
+// API client. Create it with NewClient.
+type Client struct {
+  client  *bitwire.Client
+  baseURL string
+}
+
+// OAuth token of the client. Apps store it, e.g. in the Keychain or Keystore, and restore it with SetToken.
+type Token struct {
+  TokenType    string
+  AccessToken  string
+  RefreshToken string
+  ExpiresIn    int
+  ValidUntil   int64 // Unix time
+}
+
+// Creates a client of the "sandbox" or "production" API, without a token
+func NewClient(mode string) (*Client, error) {
+  client, err := bitwire.New(bitwire.Mode(mode))
+  if err != nil {
+    return nil, err
+  }
+  return &Client{client: client}, nil
+}
+
+// Overrides the base URL of the mode, e.g. to call a fake server or a proxy
+func (c *Client) SetBaseURL(url string) {
+  c.baseURL = url
+  c.client.BaseURL = url
+}
+
+// Authenticates with the user's username and password and returns the token
+func (c *Client) Authenticate(clientId, clientSecret, username, password string) (*Token, error) {
+  credentials := bitwire.Credentials{ClientId: clientId, ClientSecret: clientSecret, GrantType: "password"}
+  token, err := c.client.Authenticate(bitwire.LoginCredentials{Credentials: credentials, Username: username, Password: password})
+  if err != nil {
+    return nil, err
+  }
+  return newToken(token), nil
+}
+
+// Restores a stored token. The client refreshes it with the client credentials when it expires.
+func (c *Client) SetToken(clientId, clientSecret string, token *Token) error {
+  config := bitwire.Config{Credentials: bitwire.Credentials{ClientId: clientId, ClientSecret: clientSecret, GrantType: "refresh_token"}}
+  if token != nil {
+    config.Token = bitwire.Token{TokenType: token.TokenType, AccessToken: token.AccessToken, RefreshToken: token.RefreshToken,
+      ExpiresIn: token.ExpiresIn, ValidUntil: token.ValidUntil}
+  }
+  client, err := bitwire.NewFromConfig(c.client.Mode, config)
+  if err != nil {
+    return err
+  }
+  client.BaseURL = c.baseURL
+  c.client = client
+  return nil
+}
+
+// Returns the current token, nil if the client has none. Store it again after calls, as they may refresh it.
+func (c *Client) Token() *Token {
+  token := c.client.Token()
+  if token == (bitwire.Token{}) {
+    return nil
+  }
+  return newToken(token)
+}
+
+func newToken(token bitwire.Token) *Token {
+  return &Token{token.TokenType, token.AccessToken, token.RefreshToken, token.ExpiresIn, token.ValidUntil}
+}
+
+// BTC and FX rates, fetched once by Client.Rates
+type Rates struct {
+  rates bitwire.AllRates
+}
+
+// Fetches the current rates
+func (c *Client) Rates() (*Rates, error) {
+  rates, err := c.client.Rates.All()
+  if err != nil {
+    return nil, err
+  }
+  return &Rates{rates}, nil
+}
+
+// Returns the price of one unit of from in to, e.g. Rate("BTC", "KRW"), derived through other currencies if needed
+func (r *Rates) Rate(from, to string) (string, error) {
+  rate, err := r.rates.Rate(from, to)
+  if err != nil {
+    return "", err
+  }
+  return rate.String(), nil
+}
+
+// Converts the amount of from into to, e.g. Convert("BTC", "KRW", "0.5")
+func (r *Rates) Convert(from, to, amount string) (string, error) {
+  value, err := bitwire.ParseDecimal(amount)
+  if err != nil {
+    return "", err
+  }
+  converted, err := r.rates.Convert(from, to, value)
+  if err != nil {
+    return "", err
+  }
+  return converted.String(), nil
+}
+
+// Bank recipient of btc_to_bank transfers
+type Recipient struct {
+  Id            int
+  Name          string // Romanized name
+  NameKo        string // Korean name, if the API returns it
+  Email         string
+  BankName      string
+  AccountNumber string
+  AccountName   string
+}
+
+func newRecipient(r bitwire.Recipient) *Recipient {
+  return &Recipient{r.Id, r.Name, r.NameKo, r.Email, r.Bank.DisplayName, r.Bank.AccountNumber, r.Bank.AccountName}
+}
+
+type RecipientList struct {
+  recipients []bitwire.Recipient
+}
+
+func (l *RecipientList) Len() int {
+  return len(l.recipients)
+}
+
+// Returns the recipient at the index, nil if it is out of range
+func (l *RecipientList) Get(i int) *Recipient {
+  if i < 0 || i >= len(l.recipients) {
+    return nil
+  }
+  return newRecipient(l.recipients[i])
+}
+
+// Lists the user's recipients
+func (c *Client) Recipients() (*RecipientList, error) {
+  recipients, err := c.client.Recipients.List()
+  if err != nil {
+    return nil, err
+  }
+  return &RecipientList{recipients}, nil
+}
+
+// Lists the recipients whose romanized or Korean name contains the query
+func (c *Client) FindRecipients(query string) (*RecipientList, error) {
+  recipients, err := c.client.Recipients.Find(query)
+  if err != nil {
+    return nil, err
+  }
+  return &RecipientList{recipients}, nil
+}
+
+// Transfer to create. Create it with NewTransferRequest and set the fields of its type.
+type TransferRequest struct {
+  Type        string // btc_to_bank or bank_to_btc
+  Amount      string // Amount the recipient receives, or deposited for bank_to_btc
+  Currency    string
+  RecipientId int    // Recipient of a btc_to_bank transfer
+  Address     string // BTC address receiving a bank_to_btc transfer
+  Memo        string
+}
+
+// Returns a btc_to_bank transfer request of a KRW amount
+func NewTransferRequest() *TransferRequest {
+  return &TransferRequest{Type: bitwire.TypeBtcToBank, Currency: "KRW"}
+}
+
+// Transfer, with the fields apps show
+type Transfer struct {
+  Id                string
+  Type              string
+  Status            string
+  Amount            string // BTC
+  Date              string
+  PayAddress        string // Address to pay, or receiving the BTC of a bank_to_btc transfer
+  PaymentURI        string // BIP 21 URI to pay, empty for bank_to_btc transfers
+  RecipientName     string
+  RecipientAmount   string
+  RecipientCurrency string
+  DepositBank       string // Bank account to deposit to, for bank_to_btc transfers
+  DepositAccount    string
+  DepositReference  string // Depositor name to enter
+  ExpiresAt         int64  // Unix time the payment address expires, 0 if it does not
+  Final             bool   // Completed, expired or canceled
+}
+
+func (c *Client) newTransfer(tx bitwire.Transfer) *Transfer {
+  t := &Transfer{Id: tx.Id, Type: tx.Type, Status: tx.Status, Amount: tx.Amount.String(), Date: tx.Date,
+    PayAddress: tx.BTC.Address, PaymentURI: tx.PaymentURI(c.client.Mode), RecipientName: tx.Recipient.Name,
+    RecipientAmount: tx.Recipient.Amount.String(), RecipientCurrency: tx.Recipient.Currency,
+    DepositBank: tx.Deposit.BankName, DepositAccount: tx.Deposit.AccountNumber, DepositReference: tx.Deposit.Reference,
+    Final: tx.IsFinal()}
+  if expires := tx.ExpiresAt(); !expires.IsZero() {
+    t.ExpiresAt = expires.Unix()
+  }
+  return t
+}
+
+// Validates and creates the transfer
+func (c *Client) CreateTransfer(request *TransferRequest) (*Transfer, error) {
+  amount, err := bitwire.ParseDecimal(request.Amount)
+  if err != nil {
+    return nil, err
+  }
+  create := bitwire.CreateTransfer{Amount: amount, Currency: request.Currency, RecipientId: request.RecipientId,
+    Memo: request.Memo, Type: request.Type, Address: request.Address}
+  if err := create.Validate(c.client.Mode); err != nil {
+    return nil, err
+  }
+  tx, err := c.client.Transfers.Create(create)
+  if err != nil {
+    return nil, err
+  }
+  return c.newTransfer(tx), nil
+}
+
+// Gets the transfer, e.g. to poll its status
+func (c *Client) Transfer(id string) (*Transfer, error) {
+  tx, err := c.client.Transfers.Get(id)
+  if err != nil {
+    return nil, err
+  }
+  return c.newTransfer(tx), nil
+}
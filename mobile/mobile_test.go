@@ -0,0 +1,71 @@
+package mobile
+
+import (
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/stretchr/testify/assert"
+  "testing"
+)
+
+func TestClient(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  _, err := NewClient("staging")
+  assert.EqualError(t, err, "Invalid mode")
+  client, err := NewClient("sandbox")
+  assert.Nil(t, err)
+  client.SetBaseURL(server.APIURL())
+  assert.Nil(t, client.Token())
+
+  rates, err := client.Rates()
+  assert.Nil(t, err)
+  rate, err := rates.Rate("BTC", "KRW")
+  assert.Nil(t, err)
+  assert.Equal(t, "1000000", rate)
+  krw, err := rates.Convert("BTC", "KRW", "0.5")
+  assert.Nil(t, err)
+  assert.Equal(t, "500000", krw)
+  _, err = rates.Convert("BTC", "KRW", "half")
+  assert.NotNil(t, err)
+
+  _, err = client.Recipients()
+  assert.NotNil(t, err)
+  token, err := client.Authenticate(server.ClientId, server.ClientSecret, server.Username, server.Password)
+  assert.Nil(t, err)
+  assert.NotEmpty(t, token.AccessToken)
+  assert.Equal(t, token, client.Token())
+
+  // A new client restores the stored token
+  client, err = NewClient("sandbox")
+  assert.Nil(t, err)
+  client.SetBaseURL(server.APIURL())
+  assert.Nil(t, client.SetToken(server.ClientId, server.ClientSecret, token))
+  recipients, err := client.Recipients()
+  assert.Nil(t, err)
+  assert.Equal(t, 2, recipients.Len())
+  assert.Equal(t, "Kim Minjun", recipients.Get(0).Name)
+  assert.Equal(t, "KB Kookmin Bank", recipients.Get(0).BankName)
+  assert.Nil(t, recipients.Get(2))
+  found, err := client.FindRecipients("서연")
+  assert.Nil(t, err)
+  assert.Equal(t, 1, found.Len())
+  assert.Equal(t, 43, found.Get(0).Id)
+
+  request := NewTransferRequest()
+  request.Amount = "100000"
+  _, err = client.CreateTransfer(request)
+  assert.EqualError(t, err, "A btc_to_bank transfer needs a recipient")
+  request.RecipientId = 42
+  tx, err := client.CreateTransfer(request)
+  assert.Nil(t, err)
+  assert.Equal(t, "0.10000000", tx.Amount)
+  assert.Equal(t, "Kim Minjun", tx.RecipientName)
+  assert.False(t, tx.Final)
+  got, err := client.Transfer(tx.Id)
+  assert.Nil(t, err)
+  assert.Equal(t, tx.Status, got.Status)
+
+  completed, err := client.Transfer("tx_completed")
+  assert.Nil(t, err)
+  assert.True(t, completed.Final)
+  assert.Contains(t, completed.PaymentURI, "bitcoin:2N8hwP1WmJrFF5QWABn38y63uYLhnJYJYTF?amount=0.10000000")
+}
@@ -0,0 +1,44 @@
+package bitwire
+
+import (
+  "github.com/dghubble/sling"
+  "io/ioutil"
+)
+
+// Returns the request payload of an API call exactly as the client sends it:
+// the JSON body for JSON_POST, the form body for POST and the query string otherwise.
+// Useful for dry runs, audit logs and reviewing requests before they are sent.
+func MarshalPayload(method Method, params interface{}) ([]byte, error) {
+  if params == nil {
+    return nil, nil
+  }
+  // The payload is encoded by sling the same way callApi does it
+  req := sling.New().Base(APIBaseURL(PRODUCTION, DefaultAPIVersion))
+  switch method {
+  case JSON_POST:
+    req = req.Post("").BodyJSON(params)
+  case POST:
+    req = req.Post("").BodyForm(params)
+  default:
+    r, err := req.Get("").QueryStruct(params).Request()
+    if err != nil {
+      return nil, err
+    } else {
+      return []byte(r.URL.RawQuery), nil
+    }
+  }
+  r, err := req.Request()
+  if err != nil {
+    return nil, err
+  } else if r.Body == nil {
+    return nil, nil
+  } else {
+    defer r.Body.Close()
+    return ioutil.ReadAll(r.Body)
+  }
+}
+
+// Returns the body sent by Transfers.Create
+func (t CreateTransfer) Payload() ([]byte, error) {
+  return MarshalPayload(JSON_POST, t)
+}
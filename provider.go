@@ -0,0 +1,33 @@
+package bitwire
+
+import (
+  "context"
+  "errors"
+)
+
+// Source of the API client credentials and the account's login, e.g. a secret manager,
+// so that server deployments do not keep them in config files.
+// The secrets package has implementations for HashiCorp Vault and AWS Secrets Manager.
+type CredentialProvider interface {
+  Credentials(ctx context.Context) (LoginCredentials, error)
+}
+
+// Creates a client authenticated with the credentials fetched from the provider
+func NewFromProvider(ctx context.Context, mode Mode, provider CredentialProvider) (*Client, error) {
+  creds, err := provider.Credentials(ctx)
+  if err != nil {
+    return nil, err
+  }
+  if creds.ClientId == "" || creds.ClientSecret == "" || creds.Username == "" || creds.Password == "" {
+    return nil, errors.New("Incomplete credentials from the provider")
+  }
+  client, err := New(mode)
+  if err != nil {
+    return nil, err
+  }
+  creds.GrantType = "password"
+  if _, err := client.Authenticate(creds); err != nil {
+    return nil, err
+  }
+  return client, nil
+}
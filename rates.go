@@ -0,0 +1,307 @@
+package bitwire
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "math/big"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+type AllRatesRes struct {
+  Res
+  Rates AllRates `json:"rates"`
+}
+
+type Rates map[string]Decimal
+
+// Returns the currency pairs sorted alphabetically, for a stable output order
+func (r Rates) Pairs() []string {
+  pairs := make([]string, 0, len(r))
+  for pair := range r {
+    pairs = append(pairs, pair)
+  }
+  sort.Strings(pairs)
+  return pairs
+}
+
+// Returns the currencies of the BTC pairs, e.g. KRW for BTCKRW, sorted alphabetically
+func (r Rates) Currencies() []string {
+  var currencies []string
+  for _, pair := range r.Pairs() {
+    if strings.HasPrefix(pair, "BTC") && len(pair) > 3 {
+      currencies = append(currencies, pair[3:])
+    }
+  }
+  return currencies
+}
+
+type BtcRatesRes struct {
+  Res
+  Rates Rates `json:"rates"`
+}
+
+type FxRatesRes struct {
+  Res
+  Rates Rates `json:"rates"`
+}
+
+type AllRates struct {
+  BTC Rates `json:"btc"`
+  FX  Rates `json:"fx"`
+}
+
+// Handles the public exchange rate endpoints
+// https://developers.bitwire.co/api/v1/#rates
+type RatesService service
+
+// Rates responses kept by WithRatesCache, by path
+type ratesCache struct {
+  mu      sync.Mutex // Held while fetching, so that concurrent misses make a single call
+  ttl     time.Duration
+  entries map[string]ratesCacheEntry
+  client  *Client // Warned when expired rates are served
+}
+
+type ratesCacheEntry struct {
+  fetched time.Time
+  rates   AllRates // Only BTC is set for rates/btc and FX for rates/fx
+}
+
+// Keeps the rates in memory for ttl, so that repeated calls within it, e.g. rendering the rates
+// on every page view, don't call the API. Failed calls are not cached: if refreshing expired rates fails,
+// they are served with a StaleCacheWarning. Call it before using the client from several goroutines.
+// A zero ttl disables the cache. Returns the client.
+func (c *Client) WithRatesCache(ttl time.Duration) *Client {
+  c.ratesCache = nil
+  if ttl > 0 {
+    c.ratesCache = &ratesCache{ttl: ttl, entries: map[string]ratesCacheEntry{}, client: c}
+  }
+  return c
+}
+
+// Returns the rates of the path cached within the TTL, or fetches and caches them, falling back
+// to the expired ones if fetching fails. Calls fetch every time if the cache is nil.
+// The result is a copy the caller can modify.
+func (c *ratesCache) get(path string, fetch func() (AllRates, error)) (AllRates, error) {
+  if c == nil {
+    return fetch()
+  }
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if e, ok := c.entries[path]; ok && time.Since(e.fetched) < c.ttl {
+    return e.rates.clone(), nil
+  }
+  rates, err := fetch()
+  if e, ok := c.entries[path]; ok && err != nil {
+    c.client.warn(StaleCacheWarning, "Serving the %s fetched %s ago, refreshing them failed: %s", path,
+      time.Since(e.fetched).Round(time.Second), err)
+    return e.rates.clone(), nil
+  } else if err != nil {
+    return AllRates{}, err
+  }
+  c.entries[path] = ratesCacheEntry{time.Now(), rates.clone()}
+  return rates, nil
+}
+
+func (r Rates) clone() Rates {
+  if r == nil {
+    return nil
+  }
+  clone := make(Rates, len(r))
+  for pair, rate := range r {
+    clone[pair] = rate
+  }
+  return clone
+}
+
+func (a AllRates) clone() AllRates {
+  return AllRates{BTC: a.BTC.clone(), FX: a.FX.clone()}
+}
+
+// Returns both BTC and FX rates
+func (s *RatesService) All() (AllRates, error) {
+  return s.client.ratesCache.get("rates", func() (AllRates, error) {
+    return s.all(context.Background())
+  })
+}
+
+func (s *RatesService) all(ctx context.Context) (AllRates, error) {
+  ratesRes := new(AllRatesRes)
+  err := callApiContext(ctx, GET, "rates", nil, s.client, false, ratesRes)
+  if err != nil {
+    return AllRates{}, err
+  } else {
+    return ratesRes.Rates, nil
+  }
+}
+
+func (s *RatesService) Fx() (Rates, error) {
+  rates, err := s.client.ratesCache.get("rates/fx", func() (AllRates, error) {
+    ratesRes := new(FxRatesRes)
+    err := callApi(GET, "rates/fx", nil, s.client, false, ratesRes)
+    return AllRates{FX: ratesRes.Rates}, err
+  })
+  if err != nil {
+    return nil, err
+  } else {
+    return rates.FX, nil
+  }
+}
+
+func (s *RatesService) Btc() (Rates, error) {
+  rates, err := s.client.ratesCache.get("rates/btc", func() (AllRates, error) {
+    ratesRes := new(BtcRatesRes)
+    err := callApi(GET, "rates/btc", nil, s.client, false, ratesRes)
+    return AllRates{BTC: ratesRes.Rates}, err
+  })
+  if err != nil {
+    return nil, err
+  } else {
+    return rates.BTC, nil
+  }
+}
+
+// Tells if both rates have the same pairs at the same values
+func (r Rates) Equal(other Rates) bool {
+  if len(r) != len(other) {
+    return false
+  }
+  for pair, rate := range r {
+    if o, ok := other[pair]; !ok || rate.Cmp(o) != 0 {
+      return false
+    }
+  }
+  return true
+}
+
+// Tells if both the BTC and FX rates are equal
+func (a AllRates) Equal(other AllRates) bool {
+  return a.BTC.Equal(other.BTC) && a.FX.Equal(other.FX)
+}
+
+// Returned by AllRates.Rate and Convert when no chain of pairs links the currencies
+var ErrNoRate = errors.New("No rate")
+
+// Decimals of derived rates
+const crossRateScale = 8
+
+// Returns the number of decimals amounts of the currency are shown with: 8 for BTC, 0 for KRW and JPY, 2 otherwise
+func CurrencyScale(currency string) int {
+  switch strings.ToUpper(currency) {
+  case "BTC":
+    return 8
+  case "KRW", "JPY":
+    return 0
+  default:
+    return 2
+  }
+}
+
+// Conversion of one unit of a currency to another one, from a quoted pair or its inverse
+type rateEdge struct {
+  to   string
+  rate *big.Rat
+}
+
+// Returns the conversions the pairs quote, FX pairs first so that cross rates prefer them to BTC ones
+func (a AllRates) edges() map[string][]rateEdge {
+  edges := map[string][]rateEdge{}
+  for _, rates := range []Rates{a.FX, a.BTC} {
+    for _, pair := range rates.Pairs() {
+      rate := rates[pair].Rat()
+      if len(pair) != 6 || rate.Sign() <= 0 {
+        continue
+      }
+      base, quote := pair[:3], pair[3:]
+      edges[base] = append(edges[base], rateEdge{quote, rate})
+      edges[quote] = append(edges[quote], rateEdge{base, new(big.Rat).Inv(rate)})
+    }
+  }
+  return edges
+}
+
+// Returns the price of one unit of from in to, e.g. Rate("BTC", "KRW") is the BTCKRW rate.
+// Inverse pairs are used for the other direction, and currencies without a pair are converted through
+// the fewest intermediate ones, e.g. USD to JPY through USDKRW and JPYKRW. Derived rates are exact
+// and print with 8 decimals. Returns ErrNoRate if no chain of pairs links the currencies.
+func (a AllRates) Rate(from, to string) (Decimal, error) {
+  from, to = strings.ToUpper(from), strings.ToUpper(to)
+  if from == to {
+    return NewDecimal(1, 0), nil
+  }
+  if rate, ok := a.BTC[from+to]; ok && rate.Sign() > 0 {
+    return rate, nil
+  }
+  if rate, ok := a.FX[from+to]; ok && rate.Sign() > 0 {
+    return rate, nil
+  }
+  // Breadth-first search for the shortest chain of pairs
+  edges := a.edges()
+  rates := map[string]*big.Rat{from: big.NewRat(1, 1)}
+  queue := []string{from}
+  for len(queue) > 0 {
+    currency := queue[0]
+    queue = queue[1:]
+    for _, e := range edges[currency] {
+      if _, seen := rates[e.to]; seen {
+        continue
+      }
+      rates[e.to] = new(big.Rat).Mul(rates[currency], e.rate)
+      if e.to == to {
+        return Decimal{rates[to], crossRateScale}, nil
+      }
+      queue = append(queue, e.to)
+    }
+  }
+  return Decimal{}, fmt.Errorf("%w: %s to %s", ErrNoRate, from, to)
+}
+
+// Converts the amount of from into to at Rate(from, to). The result is exact and prints with
+// the decimals of to given by CurrencyScale, e.g. Convert("BTC", "KRW", MustParseDecimal("0.5")).
+func (a AllRates) Convert(from, to string, amount Decimal) (Decimal, error) {
+  rate, err := a.Rate(from, to)
+  if err != nil {
+    return Decimal{}, err
+  }
+  return Decimal{new(big.Rat).Mul(amount.Rat(), rate.Rat()), CurrencyScale(to)}, nil
+}
+
+// Polls the rates every interval and sends them on the returned channel when they change,
+// starting with the first poll. Failed polls are passed to OnWarning, from the polling goroutine, and retried at the next interval.
+// Polls bypass WithRatesCache. The channel is closed when ctx is done.
+func (c *Client) SubscribeRates(ctx context.Context, interval time.Duration) <-chan AllRates {
+  updates := make(chan AllRates)
+  go func() {
+    defer close(updates)
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    var last AllRates
+    sent := false
+    for {
+      rates, err := c.Rates.all(ctx)
+      if ctx.Err() != nil {
+        return
+      }
+      if err != nil {
+        c.warn(RatePollWarning, "Polling the rates failed, retrying in %s: %s", interval, err)
+      } else if !sent || !rates.Equal(last) {
+        select {
+        case updates <- rates:
+          last, sent = rates, true
+        case <-ctx.Done():
+          return
+        }
+      }
+      select {
+      case <-ticker.C:
+      case <-ctx.Done():
+        return
+      }
+    }
+  }()
+  return updates
+}
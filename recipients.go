@@ -0,0 +1,72 @@
+package bitwire
+
+import "strings"
+
+type RecipientsRes struct {
+  Res
+  Recipients []Recipient `json:"recipients"`
+}
+
+type Recipient struct {
+  Id     int           `json:"id"`
+  Name   string        `json:"name"`    // Romanized name
+  NameKo string        `json:"name_ko"` // Korean name, if the API returns it
+  Email  string        `json:"email"`
+  Bank   RecipientBank `json:"bank"`
+}
+
+// Returns the Korean name for the "ko" language, if set, and the romanized name otherwise
+func (r Recipient) LocalName(lang string) string {
+  if lang == "ko" && r.NameKo != "" {
+    return r.NameKo
+  }
+  return r.Name
+}
+
+// Tells if either name contains the query, ignoring case and spaces
+func (r Recipient) Matches(query string) bool {
+  q := normalizeName(query)
+  if q == "" {
+    return false
+  }
+  return strings.Contains(normalizeName(r.Name), q) || strings.Contains(normalizeName(r.NameKo), q)
+}
+
+func normalizeName(name string) string {
+  return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+type RecipientBank struct {
+  Bank
+  AccountNumber string `json:"account_number"`
+  AccountName   string `json:"account_name"`
+}
+
+// Handles the authenticated user's recipients
+// https://developers.bitwire.co/api/v1/#recipients
+type RecipientsService service
+
+func (s *RecipientsService) List() ([]Recipient, error) {
+  recipientsRes := new(RecipientsRes)
+  err := callApi(GET, "recipients", nil, s.client, true, recipientsRes)
+  if err != nil {
+    return nil, err
+  } else {
+    return recipientsRes.Recipients, nil
+  }
+}
+
+// Returns the recipients whose romanized or Korean name contains the query
+func (s *RecipientsService) Find(query string) ([]Recipient, error) {
+  recipients, err := s.List()
+  if err != nil {
+    return nil, err
+  }
+  var found []Recipient
+  for _, r := range recipients {
+    if r.Matches(query) {
+      found = append(found, r)
+    }
+  }
+  return found, nil
+}
@@ -0,0 +1,17 @@
+package bitwire
+
+import (
+  "context"
+  "errors"
+  "time"
+)
+
+// Errors of reference rate providers that have no rate for the pair on or before the day match ErrNoReferenceRate with errors.Is
+var ErrNoReferenceRate = errors.New("No reference rate")
+
+// Source of official reference exchange rates, e.g. the Bank of Korea daily rate, shown next to the rates transfers
+// were executed at in reports. The refrates package has implementations.
+type ReferenceRateProvider interface {
+  // Returns the rate of one unit of base in quote on the day, or on the closest earlier day with a rate
+  ReferenceRate(ctx context.Context, base, quote string, day time.Time) (Decimal, error)
+}
@@ -0,0 +1,131 @@
+package refrates
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Bank of Korea Economic Statistics System API
+const ECOSURL = "https://ecos.bok.or.kr/api/"
+
+// Statistic of the daily KRW rates of major currencies, and its items by currency.
+// JPY is quoted per 100 yen.
+const ecosStatistic = "731Y001"
+
+var ecosItems = map[string]string{"USD": "0000001", "JPY": "0000002", "EUR": "0000003", "CNY": "0000053"}
+
+// Days looked back for the last published rate of a weekend or holiday
+const ecosLookback = 10
+
+// Bank of Korea daily KRW rates of USD, JPY, EUR and CNY, from the ECOS API.
+// Get an API key at https://ecos.bok.or.kr/api/. Rates are cached per day.
+type ECOS struct {
+  APIKey  string
+  BaseURL string
+
+  mu    sync.Mutex
+  cache map[string]bitwire.Decimal // By pair and day
+}
+
+var _ bitwire.ReferenceRateProvider = (*ECOS)(nil)
+
+func NewECOS(apiKey string) *ECOS {
+  return &ECOS{APIKey: apiKey, BaseURL: ECOSURL}
+}
+
+type ecosRes struct {
+  StatisticSearch struct {
+    Row []struct {
+      Time  string `json:"TIME"` // 20060102
+      Value string `json:"DATA_VALUE"`
+    } `json:"row"`
+  }
+  Result *struct {
+    Code    string `json:"CODE"`
+    Message string `json:"MESSAGE"`
+  } `json:"RESULT"`
+}
+
+// Returns the KRW rate of USD, JPY, EUR or CNY, or its inverse with KRW as the base
+func (e *ECOS) ReferenceRate(ctx context.Context, base, quote string, day time.Time) (bitwire.Decimal, error) {
+  base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+  if base == "KRW" && quote != "KRW" {
+    rate, err := e.ReferenceRate(ctx, quote, base, day)
+    if err != nil {
+      return rate, err
+    }
+    return bitwire.NewDecimal(1, 0).QuoUp(rate, 8), nil
+  }
+  if ecosItems[base] == "" || quote != "KRW" {
+    return bitwire.Decimal{}, fmt.Errorf("%w: %s to %s, the Bank of Korea rates are of USD, JPY, EUR and CNY in KRW",
+      bitwire.ErrNoReferenceRate, base, quote)
+  }
+  key := base + day.Format(dateLayout)
+  e.mu.Lock()
+  defer e.mu.Unlock()
+  if rate, ok := e.cache[key]; ok {
+    return rate, nil
+  }
+  rates, err := e.fetch(ctx, base, day)
+  if err != nil {
+    return bitwire.Decimal{}, err
+  }
+  rate, err := latestRate(rates, base, quote, day)
+  if err != nil {
+    return rate, err
+  }
+  if e.cache == nil {
+    e.cache = map[string]bitwire.Decimal{}
+  }
+  e.cache[key] = rate
+  return rate, nil
+}
+
+// Fetches the rates of the currency over the days up to the day, oldest first
+func (e *ECOS) fetch(ctx context.Context, currency string, day time.Time) ([]dayRate, error) {
+  const layout = "20060102"
+  url := fmt.Sprintf("%sStatisticSearch/%s/json/kr/1/%d/%s/D/%s/%s/%s", e.BaseURL, e.APIKey, ecosLookback+1, ecosStatistic,
+    day.AddDate(0, 0, -ecosLookback).Format(layout), day.Format(layout), ecosItems[currency])
+  req, err := http.NewRequest(http.MethodGet, url, nil)
+  if err != nil {
+    return nil, err
+  }
+  resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK {
+    return nil, fmt.Errorf("ECOS responded with %s", resp.Status)
+  }
+  res := ecosRes{}
+  if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+    return nil, err
+  }
+  // INFO-200 is no data for the days
+  if res.Result != nil && res.Result.Code != "INFO-200" {
+    return nil, fmt.Errorf("ECOS error %s: %s", res.Result.Code, res.Result.Message)
+  }
+  var rates []dayRate
+  for _, row := range res.StatisticSearch.Row {
+    d, err := time.Parse(layout, row.Time)
+    if err != nil {
+      return nil, fmt.Errorf("Invalid ECOS date: %s", row.Time)
+    }
+    rate, err := bitwire.ParseDecimal(strings.Replace(row.Value, ",", "", -1))
+    if err != nil {
+      return nil, fmt.Errorf("Invalid ECOS rate: %s", row.Value)
+    }
+    if currency == "JPY" {
+      rate = rate.QuoUp(bitwire.NewDecimal(100, 0), 4)
+    }
+    rates = append(rates, dayRate{d, rate})
+  }
+  return rates, nil
+}
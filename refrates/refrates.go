@@ -0,0 +1,108 @@
+// Reference exchange rate providers for reports: the Bank of Korea daily rates through its ECOS API,
+// and rates kept in a CSV file, e.g. exported from a tax authority or an accounting system
+package refrates
+
+import (
+  "context"
+  "encoding/csv"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "io"
+  "os"
+  "sort"
+  "strings"
+  "time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Returns the provider of a reference, "ecos:<API key>" or "file:<path to a CSV file>"
+func Open(ref string) (bitwire.ReferenceRateProvider, error) {
+  parts := strings.SplitN(ref, ":", 2)
+  if len(parts) != 2 || parts[1] == "" {
+    return nil, errors.New("Invalid reference rates: " + ref + "\nUse ecos:<API key> or file:<path>")
+  }
+  switch parts[0] {
+  case "ecos":
+    return NewECOS(parts[1]), nil
+  case "file":
+    return ReadFile(parts[1])
+  }
+  return nil, fmt.Errorf("Unknown reference rates provider: %s, expected ecos or file", parts[0])
+}
+
+// Rate of a pair on a day
+type dayRate struct {
+  Day  time.Time
+  Rate bitwire.Decimal
+}
+
+// Reference rates read from a CSV file with date (YYYY-MM-DD), base, quote and rate columns, after a header row
+type File struct {
+  rates map[string][]dayRate // By pair such as USDKRW, oldest first
+}
+
+var _ bitwire.ReferenceRateProvider = (*File)(nil)
+
+func ReadFile(path string) (*File, error) {
+  f, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+  file, err := ParseCSV(f)
+  if err != nil {
+    return nil, fmt.Errorf("%s: %s", path, err)
+  }
+  return file, nil
+}
+
+func ParseCSV(in io.Reader) (*File, error) {
+  reader := csv.NewReader(in)
+  reader.FieldsPerRecord = 4
+  reader.TrimLeadingSpace = true
+  if _, err := reader.Read(); err != nil && err != io.EOF {
+    return nil, err
+  }
+  file := &File{map[string][]dayRate{}}
+  for {
+    record, err := reader.Read()
+    if err == io.EOF {
+      break
+    } else if err != nil {
+      return nil, err
+    }
+    line, _ := reader.FieldPos(0)
+    day, err := time.Parse(dateLayout, record[0])
+    if err != nil {
+      return nil, fmt.Errorf("line %d: invalid date %s, expected YYYY-MM-DD", line, record[0])
+    }
+    rate, err := bitwire.ParseDecimal(record[3])
+    if err != nil || rate.Sign() <= 0 {
+      return nil, fmt.Errorf("line %d: invalid rate %s", line, record[3])
+    }
+    pair := strings.ToUpper(record[1] + record[2])
+    file.rates[pair] = append(file.rates[pair], dayRate{day, rate})
+  }
+  for _, rates := range file.rates {
+    sort.SliceStable(rates, func(i, j int) bool { return rates[i].Day.Before(rates[j].Day) })
+  }
+  return file, nil
+}
+
+// Returns the rate of the latest day on or before the day, so that weekends and holidays get the last published rate
+func (f *File) ReferenceRate(ctx context.Context, base, quote string, day time.Time) (bitwire.Decimal, error) {
+  return latestRate(f.rates[strings.ToUpper(base+quote)], base, quote, day)
+}
+
+// Returns the rate of the last of the rates, oldest first, on or before the day
+func latestRate(rates []dayRate, base, quote string, day time.Time) (bitwire.Decimal, error) {
+  date := day.Format(dateLayout)
+  for i := len(rates) - 1; i >= 0; i-- {
+    if rates[i].Day.Format(dateLayout) <= date {
+      return rates[i].Rate, nil
+    }
+  }
+  return bitwire.Decimal{}, fmt.Errorf("%w: %s to %s on %s", bitwire.ErrNoReferenceRate, base, quote, date)
+}
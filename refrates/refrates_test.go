@@ -0,0 +1,86 @@
+package refrates
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "github.com/stretchr/testify/assert"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+  "time"
+)
+
+func day(s string) time.Time {
+  d, _ := time.Parse(dateLayout, s)
+  return d
+}
+
+func TestFile(t *testing.T) {
+  file, err := ParseCSV(strings.NewReader("date,base,quote,rate\n2024-01-03,USD,KRW,1310.5\n2024-01-02,usd,krw,1300.5\n"))
+  assert.Nil(t, err)
+  ctx := context.Background()
+
+  rate, err := file.ReferenceRate(ctx, "USD", "KRW", day("2024-01-02"))
+  assert.Nil(t, err)
+  assert.Equal(t, "1300.5", rate.String())
+  // A weekend gets the rate of the last day before it
+  rate, err = file.ReferenceRate(ctx, "usd", "krw", day("2024-01-06"))
+  assert.Nil(t, err)
+  assert.Equal(t, "1310.5", rate.String())
+
+  _, err = file.ReferenceRate(ctx, "USD", "KRW", day("2024-01-01"))
+  assert.True(t, errors.Is(err, bitwire.ErrNoReferenceRate))
+  _, err = file.ReferenceRate(ctx, "EUR", "KRW", day("2024-01-02"))
+  assert.True(t, errors.Is(err, bitwire.ErrNoReferenceRate))
+
+  _, err = ParseCSV(strings.NewReader("date,base,quote,rate\n2024/01/02,USD,KRW,1300.5\n"))
+  assert.Equal(t, "line 2: invalid date 2024/01/02, expected YYYY-MM-DD", err.Error())
+}
+
+func TestECOS(t *testing.T) {
+  calls := 0
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    calls++
+    switch r.URL.Path {
+    case "/StatisticSearch/key/json/kr/1/11/731Y001/D/20231227/20240106/0000001":
+      fmt.Fprint(w, `{"StatisticSearch":{"list_total_count":2,"row":[
+        {"TIME":"20240104","DATA_VALUE":"1,309.8"},{"TIME":"20240105","DATA_VALUE":"1,312.1"}]}}`)
+    case "/StatisticSearch/key/json/kr/1/11/731Y001/D/20231227/20240106/0000002":
+      fmt.Fprint(w, `{"StatisticSearch":{"list_total_count":1,"row":[{"TIME":"20240105","DATA_VALUE":"905.12"}]}}`)
+    default:
+      fmt.Fprint(w, `{"RESULT":{"CODE":"INFO-100","MESSAGE":"인증키가 유효하지 않습니다."}}`)
+    }
+  }))
+  defer server.Close()
+  ecos := NewECOS("key")
+  ecos.BaseURL = server.URL + "/"
+  ctx := context.Background()
+
+  rate, err := ecos.ReferenceRate(ctx, "USD", "KRW", day("2024-01-06"))
+  assert.Nil(t, err)
+  assert.Equal(t, "1312.1", rate.String())
+  rate, err = ecos.ReferenceRate(ctx, "USD", "KRW", day("2024-01-06"))
+  assert.Nil(t, err)
+  assert.Equal(t, 1, calls, "cached")
+  rate, err = ecos.ReferenceRate(ctx, "JPY", "KRW", day("2024-01-06"))
+  assert.Nil(t, err)
+  assert.Equal(t, "9.0512", rate.String())
+
+  _, err = ecos.ReferenceRate(ctx, "BTC", "KRW", day("2024-01-06"))
+  assert.True(t, errors.Is(err, bitwire.ErrNoReferenceRate))
+  _, err = ecos.ReferenceRate(ctx, "EUR", "KRW", day("2024-01-06"))
+  assert.Contains(t, err.Error(), "ECOS error INFO-100")
+}
+
+func TestOpen(t *testing.T) {
+  provider, err := Open("ecos:key")
+  assert.Nil(t, err)
+  assert.Equal(t, "key", provider.(*ECOS).APIKey)
+  _, err = Open("fred:key")
+  assert.Contains(t, err.Error(), "Unknown reference rates provider: fred")
+  _, err = Open("ecos")
+  assert.Contains(t, err.Error(), "Invalid reference rates")
+}
@@ -0,0 +1,130 @@
+package bitwire
+
+import (
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Rate limit quota reported in the X-RateLimit-* response headers.
+// Fields are zero when the API does not send them.
+type RateLimit struct {
+  Limit     int
+  Remaining int
+  Reset     time.Time
+}
+
+// Deprecation of an endpoint announced by the Deprecation (RFC 9745) and Sunset (RFC 8594) response headers.
+// Zero when the API does not send them.
+type Deprecation struct {
+  Deprecated bool      // The Deprecation header was sent
+  Since      time.Time // When the endpoint was or will be deprecated, if the header has a date
+  Sunset     time.Time // When the endpoint will stop working, if announced
+  Link       string    // Documentation of the deprecation or sunset, from the Link header
+}
+
+func (d Deprecation) IsZero() bool {
+  return !d.Deprecated && d.Sunset.IsZero()
+}
+
+// Metadata of an API response
+type Response struct {
+  HTTPStatus  int
+  Header      http.Header
+  RateLimit   RateLimit
+  Deprecation Deprecation
+}
+
+// Returned when the API keeps responding with 429 and the call is not retried any more
+type RateLimitError struct {
+  APIError
+  RetryAfter time.Duration // Zero if the API did not send Retry-After
+  Reset      time.Time     // When the call can be made again, if known
+}
+
+func (e *RateLimitError) Unwrap() error {
+  return &e.APIError
+}
+
+func newResponse(resp *http.Response) Response {
+  return Response{resp.StatusCode, resp.Header, parseRateLimit(resp.Header), parseDeprecation(resp.Header)}
+}
+
+// Parses the Deprecation header, either "@" and a Unix time, an HTTP date or "true", the Sunset HTTP date,
+// and the Link with the deprecation or sunset relation
+func parseDeprecation(header http.Header) Deprecation {
+  d := Deprecation{}
+  if v := strings.TrimSpace(header.Get("Deprecation")); v != "" && v != "false" {
+    d.Deprecated = true
+    if secs, err := strconv.ParseInt(strings.TrimPrefix(v, "@"), 10, 64); err == nil && strings.HasPrefix(v, "@") {
+      d.Since = time.Unix(secs, 0)
+    } else if t, err := http.ParseTime(v); err == nil {
+      d.Since = t
+    }
+  }
+  if t, err := http.ParseTime(header.Get("Sunset")); err == nil {
+    d.Sunset = t
+  }
+  if d.IsZero() {
+    return d
+  }
+  for _, value := range header.Values("Link") {
+    for _, link := range strings.Split(value, ",") {
+      start, end := strings.Index(link, "<"), strings.Index(link, ">")
+      rel := strings.ToLower(link[end+1:])
+      if start >= 0 && end > start && (strings.Contains(rel, `"deprecation"`) || strings.Contains(rel, `"sunset"`)) {
+        d.Link = link[start+1 : end]
+        return d
+      }
+    }
+  }
+  return d
+}
+
+// Describes the deprecation of the endpoint, e.g. "GET rates/fx is deprecated since 2025-01-01 and
+// will stop working on 2025-06-30, see https://..."
+func (d Deprecation) describe(method Method, path string) string {
+  if method == JSON_POST {
+    method = POST
+  }
+  message := fmt.Sprintf("%s %s", method, path)
+  if d.Deprecated {
+    message += " is deprecated"
+    if !d.Since.IsZero() {
+      message += " since " + d.Since.UTC().Format("2006-01-02")
+    }
+    if !d.Sunset.IsZero() {
+      message += " and"
+    }
+  }
+  if !d.Sunset.IsZero() {
+    message += " will stop working on " + d.Sunset.UTC().Format("2006-01-02")
+  }
+  if d.Link != "" {
+    message += ", see " + d.Link
+  }
+  return message
+}
+
+func parseRateLimit(header http.Header) RateLimit {
+  rate := RateLimit{}
+  if v, err := strconv.Atoi(header.Get("X-RateLimit-Limit")); err == nil {
+    rate.Limit = v
+  }
+  if v, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil {
+    rate.Remaining = v
+  }
+  if v, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+    rate.Reset = time.Unix(v, 0)
+  }
+  return rate
+}
+
+// Returns the metadata of the last API response received by the client
+func (c *Client) LastResponse() Response {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  return c.lastResponse
+}
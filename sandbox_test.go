@@ -0,0 +1,216 @@
+package bitwire_test
+
+import (
+  "errors"
+  "github.com/dworznik/bitwire"
+  "github.com/dworznik/bitwire/bitwiretest"
+  "github.com/stretchr/testify/assert"
+  "testing"
+  "time"
+)
+
+// Calls of the API flows against the fake server, in place of the sandbox
+
+func TestAllRates(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client := server.APIClient()
+  rates, err := client.GetAllRates()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, rates)
+  assert.NotEmpty(t, rates.BTC)
+  assert.Contains(t, rates.BTC, "BTCKRW")
+  assert.NotEmpty(t, rates.FX)
+}
+
+func TestBtcRates(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  rates, err := server.APIClient().GetBtcRates()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, rates)
+  assert.Contains(t, rates, "BTCKRW")
+}
+
+func TestFxRates(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  rates, err := server.APIClient().GetFxRates()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, rates)
+}
+
+func TestBanks(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  banks, err := server.APIClient().GetBanks()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, banks)
+}
+
+func TestAuthenticate(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client := server.APIClient()
+  token, err := client.Authenticate(server.LoginCredentials())
+  assert.Nil(t, err)
+  assert.NotEmpty(t, token.AccessToken)
+  assert.Equal(t, token, client.Token())
+
+  creds := server.LoginCredentials()
+  creds.Password = "wrong"
+  _, err = client.Authenticate(creds)
+  assert.Equal(t, "invalid_grant: Invalid credentials.", err.Error())
+}
+
+func TestTransfers(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  transfers, err := client.GetTransfers()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, transfers)
+
+  pending, err := client.Transfers.List(&bitwire.TransferListOptions{Status: bitwire.StatusPending})
+  assert.Nil(t, err)
+  assert.Len(t, pending, 1)
+  assert.False(t, pending[0].CreatedAt.IsZero())
+
+  tx, err := client.Transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("100000"), Currency: "KRW", RecipientId: 42, Type: "btc_to_bank"})
+  assert.Nil(t, err)
+  assert.Equal(t, "0.10000000", tx.Amount.String())
+  got, err := client.Transfers.Get(tx.Id)
+  assert.Nil(t, err)
+  assert.Equal(t, tx.Id, got.Id)
+  canceled, err := client.Transfers.Cancel(tx.Id)
+  assert.Nil(t, err)
+  assert.Equal(t, bitwire.StatusCanceled, canceled.Status)
+
+  deposit, err := client.Transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("100000"), Currency: "KRW",
+    Type: bitwire.TypeBankToBtc, Address: "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"})
+  assert.Nil(t, err)
+  assert.True(t, deposit.IsBankToBtc())
+  assert.Equal(t, "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", deposit.BTC.Address)
+  assert.Equal(t, bitwiretest.DepositAccount.AccountNumber, deposit.Deposit.AccountNumber)
+  assert.Equal(t, "100000", deposit.Deposit.Amount.String())
+  assert.NotEmpty(t, deposit.Deposit.Reference)
+
+  _, err = client.Transfers.Get("missing")
+  hint, ok := bitwire.HintForError(err)
+  assert.True(t, ok)
+  assert.Equal(t, "not_found", hint.ErrorType)
+}
+
+func TestFees(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  fees, err := client.GetFees()
+  assert.Nil(t, err)
+  assert.Equal(t, "0.0001", fees.Network.String())
+  assert.Equal(t, "KRW", fees.Currency)
+
+  completed, err := client.Transfers.Get("tx_completed")
+  assert.Nil(t, err)
+  assert.Equal(t, "1000", completed.Fees.Service.String())
+  pending, err := client.Transfers.Get("tx_pending")
+  assert.Nil(t, err)
+  assert.True(t, pending.Fees.IsZero())
+
+  tx, err := client.Transfers.Create(bitwire.CreateTransfer{Amount: bitwire.MustParseDecimal("1000000"), Currency: "KRW", RecipientId: 42, Type: bitwire.TypeBtcToBank})
+  assert.Nil(t, err)
+  assert.Equal(t, 0, tx.Fees.Service.Cmp(bitwire.MustParseDecimal("5000")))
+  assert.Equal(t, 0, tx.Fees.FXSpread.Cmp(bitwire.MustParseDecimal("3000")))
+}
+
+func TestLimits(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  limits, err := client.GetLimits()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, limits)
+}
+
+func TestMe(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  user, err := client.GetMe()
+  assert.Nil(t, err)
+  assert.Equal(t, bitwiretest.Username, user.Email)
+  assert.NotEmpty(t, user.KYCStatus)
+}
+
+func TestVerificationStatus(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  verification, err := client.GetVerificationStatus()
+  assert.Nil(t, err)
+  assert.Equal(t, 1, verification.Level)
+  assert.Equal(t, 2, verification.NextLevel)
+  steps := verification.Outstanding()
+  assert.Len(t, steps, 2)
+  assert.Equal(t, "proof_of_address", steps[0].Type)
+  assert.Equal(t, bitwire.RequirementPending, steps[1].Status)
+}
+
+func TestLimitsAuthFailed(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  token := bitwire.Token{TokenType: "Bearer", AccessToken: "invalid", RefreshToken: "xxx", ExpiresIn: 3600, ValidUntil: time.Now().Unix() + 3600}
+  client, _ := bitwire.NewWithToken(bitwire.SANDBOX, token)
+  client.BaseURL = server.APIURL()
+  _, err := client.GetLimits()
+  assert.NotNil(t, err)
+  assert.Equal(t, err.Error(), "Unauthorized: Invalid token.")
+}
+
+func TestRecipients(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client, err := server.AuthenticatedClient()
+  assert.Nil(t, err)
+  recipients, err := client.GetRecipients()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, recipients)
+}
+
+func TestRefreshToken(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  client := server.APIClient()
+  token, err := client.Authenticate(server.LoginCredentials())
+  assert.Nil(t, err)
+  newToken, err := client.RefreshToken()
+  assert.Nil(t, err)
+  assert.NotEmpty(t, newToken.AccessToken)
+  assert.NotEqual(t, token.AccessToken, newToken.AccessToken)
+
+  _, err = client.GetLimits()
+  assert.Nil(t, err)
+}
+
+func TestRefreshTokenNoAuth(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  newToken, err := server.APIClient().RefreshToken()
+  assert.NotNil(t, err)
+  assert.Equal(t, newToken, (bitwire.Token{}))
+}
+
+func TestServerErr(t *testing.T) {
+  server := bitwiretest.NewServer()
+  defer server.Close()
+  server.Fake.SetErr(&bitwire.APIError{Code: 429, ErrorType: "Rate limited", Message: "Too many requests.", HTTPStatus: 429})
+  client := server.APIClient()
+  client.MaxRetryWait = 0
+  _, err := client.Banks.List()
+  assert.True(t, errors.Is(err, bitwire.ErrRateLimited))
+}
@@ -0,0 +1,46 @@
+//go:build aws
+// +build aws
+
+package secrets
+
+import (
+  "context"
+  "errors"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/config"
+  "github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+  "github.com/dworznik/bitwire"
+)
+
+func init() {
+  Register("aws", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return NewAWS(ctx, name)
+  })
+}
+
+// Reads the credentials from an AWS Secrets Manager JSON secret
+type AWS struct {
+  Client   *secretsmanager.Client
+  SecretId string // Name or ARN of the secret
+}
+
+// Returns a provider of the secret, using the default AWS configuration: the environment,
+// shared config files and instance roles
+func NewAWS(ctx context.Context, secretId string) (*AWS, error) {
+  cfg, err := config.LoadDefaultConfig(ctx)
+  if err != nil {
+    return nil, err
+  }
+  return &AWS{Client: secretsmanager.NewFromConfig(cfg), SecretId: secretId}, nil
+}
+
+func (a *AWS) Credentials(ctx context.Context) (bitwire.LoginCredentials, error) {
+  out, err := a.Client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(a.SecretId)})
+  if err != nil {
+    return bitwire.LoginCredentials{}, err
+  }
+  if out.SecretString == nil {
+    return bitwire.LoginCredentials{}, errors.New("AWS secret " + a.SecretId + " is not a JSON string")
+  }
+  return parseSecret([]byte(*out.SecretString))
+}
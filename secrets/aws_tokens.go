@@ -0,0 +1,112 @@
+//go:build aws
+// +build aws
+
+package secrets
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "github.com/aws/aws-sdk-go-v2/aws"
+  "github.com/aws/aws-sdk-go-v2/config"
+  "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+  ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+  "github.com/aws/aws-sdk-go-v2/service/ssm"
+  ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+  "github.com/dworznik/bitwire"
+)
+
+// Keeps the token as JSON in an SSM SecureString parameter
+type SSMTokenStore struct {
+  Client *ssm.Client
+  Name   string // Name of the parameter, created by the first save
+}
+
+// Returns a store of the parameter, using the default AWS configuration
+func NewSSMTokenStore(ctx context.Context, name string) (*SSMTokenStore, error) {
+  cfg, err := config.LoadDefaultConfig(ctx)
+  if err != nil {
+    return nil, err
+  }
+  return &SSMTokenStore{Client: ssm.NewFromConfig(cfg), Name: name}, nil
+}
+
+func (s *SSMTokenStore) LoadToken(ctx context.Context) (bitwire.Token, error) {
+  out, err := s.Client.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(s.Name), WithDecryption: aws.Bool(true)})
+  var notFound *ssmtypes.ParameterNotFound
+  if errors.As(err, &notFound) {
+    return bitwire.Token{}, nil
+  } else if err != nil {
+    return bitwire.Token{}, err
+  }
+  return parseToken(aws.ToString(out.Parameter.Value))
+}
+
+func (s *SSMTokenStore) SaveToken(ctx context.Context, token bitwire.Token) error {
+  data, err := json.Marshal(token)
+  if err != nil {
+    return err
+  }
+  _, err = s.Client.PutParameter(ctx, &ssm.PutParameterInput{
+    Name:      aws.String(s.Name),
+    Value:     aws.String(string(data)),
+    Type:      ssmtypes.ParameterTypeSecureString,
+    Overwrite: aws.Bool(true),
+  })
+  return err
+}
+
+// Keeps the token as JSON in the "token" attribute of a DynamoDB item
+type DynamoTokenStore struct {
+  Client *dynamodb.Client
+  Table  string
+  Key    string // Value of the table's "id" string partition key, e.g. the mode
+}
+
+// Returns a store of the item, using the default AWS configuration
+func NewDynamoTokenStore(ctx context.Context, table, key string) (*DynamoTokenStore, error) {
+  cfg, err := config.LoadDefaultConfig(ctx)
+  if err != nil {
+    return nil, err
+  }
+  return &DynamoTokenStore{Client: dynamodb.NewFromConfig(cfg), Table: table, Key: key}, nil
+}
+
+func (s *DynamoTokenStore) LoadToken(ctx context.Context) (bitwire.Token, error) {
+  out, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+    TableName:      aws.String(s.Table),
+    Key:            map[string]ddbtypes.AttributeValue{"id": &ddbtypes.AttributeValueMemberS{Value: s.Key}},
+    ConsistentRead: aws.Bool(true),
+  })
+  if err != nil {
+    return bitwire.Token{}, err
+  }
+  attr, ok := out.Item["token"].(*ddbtypes.AttributeValueMemberS)
+  if !ok {
+    return bitwire.Token{}, nil
+  }
+  return parseToken(attr.Value)
+}
+
+func (s *DynamoTokenStore) SaveToken(ctx context.Context, token bitwire.Token) error {
+  data, err := json.Marshal(token)
+  if err != nil {
+    return err
+  }
+  _, err = s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+    TableName: aws.String(s.Table),
+    Item: map[string]ddbtypes.AttributeValue{
+      "id":    &ddbtypes.AttributeValueMemberS{Value: s.Key},
+      "token": &ddbtypes.AttributeValueMemberS{Value: string(data)},
+    },
+  })
+  return err
+}
+
+func parseToken(data string) (bitwire.Token, error) {
+  token := bitwire.Token{}
+  if err := json.Unmarshal([]byte(data), &token); err != nil {
+    return token, errors.New("Invalid stored token: " + err.Error())
+  }
+  return token, nil
+}
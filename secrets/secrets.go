@@ -0,0 +1,88 @@
+// Credential providers fetching the Bitwire client credentials from secret managers, and token stores.
+// The Vault provider is built with `-tags vault`, and the AWS Secrets Manager provider and the DynamoDB
+// and SSM token stores with `-tags aws`, so that their SDKs are only needed by the builds using them.
+package secrets
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "github.com/dworznik/bitwire"
+  "sort"
+  "strings"
+  "sync"
+)
+
+// Opens the provider of a secret named in a reference like "vault:secret/data/bitwire"
+type Opener func(ctx context.Context, name string) (bitwire.CredentialProvider, error)
+
+var (
+  mu      sync.Mutex
+  openers = map[string]Opener{}
+)
+
+// Makes the provider of the scheme available to Open
+func Register(scheme string, opener Opener) {
+  mu.Lock()
+  defer mu.Unlock()
+  openers[scheme] = opener
+}
+
+// Returns the provider of a secret reference, "<scheme>:<name>", e.g. "vault:secret/data/bitwire"
+// or "aws:bitwire/production"
+func Open(ctx context.Context, ref string) (bitwire.CredentialProvider, error) {
+  parts := strings.SplitN(ref, ":", 2)
+  if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+    return nil, errors.New("Invalid secret reference: " + ref + "\nUse <scheme>:<name>, e.g. vault:secret/data/bitwire")
+  }
+  mu.Lock()
+  opener, ok := openers[parts[0]]
+  mu.Unlock()
+  if !ok {
+    return nil, fmt.Errorf("No %s secret provider in this build (available: %s)\nBuild with -tags %s to include it",
+      parts[0], strings.Join(Schemes(), ", "), parts[0])
+  }
+  return opener(ctx, parts[1])
+}
+
+// Returns the registered schemes, sorted
+func Schemes() []string {
+  mu.Lock()
+  defer mu.Unlock()
+  schemes := make([]string, 0, len(openers))
+  for scheme := range openers {
+    schemes = append(schemes, scheme)
+  }
+  sort.Strings(schemes)
+  return schemes
+}
+
+// Fields of a credentials secret
+type secret struct {
+  ClientId     string `json:"client_id"`
+  ClientSecret string `json:"client_secret"`
+  Username     string `json:"username"`
+  Password     string `json:"password"`
+}
+
+// Parses a JSON secret with the client_id, client_secret, username and password fields
+func parseSecret(data []byte) (bitwire.LoginCredentials, error) {
+  s := secret{}
+  if err := json.Unmarshal(data, &s); err != nil {
+    return bitwire.LoginCredentials{}, errors.New("Invalid credentials secret: " + err.Error())
+  }
+  var missing []string
+  for _, f := range []struct{ name, value string }{
+    {"client_id", s.ClientId}, {"client_secret", s.ClientSecret}, {"username", s.Username}, {"password", s.Password},
+  } {
+    if f.value == "" {
+      missing = append(missing, f.name)
+    }
+  }
+  if len(missing) > 0 {
+    return bitwire.LoginCredentials{}, errors.New("Credentials secret is missing " + strings.Join(missing, ", "))
+  }
+  creds := bitwire.Credentials{ClientId: s.ClientId, ClientSecret: s.ClientSecret, GrantType: "password"}
+  return bitwire.LoginCredentials{Credentials: creds, Username: s.Username, Password: s.Password}, nil
+}
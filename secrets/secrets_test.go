@@ -0,0 +1,39 @@
+package secrets
+
+import (
+  "context"
+  "github.com/dworznik/bitwire"
+  "github.com/stretchr/testify/assert"
+  "testing"
+)
+
+type static struct{ login bitwire.LoginCredentials }
+
+func (s static) Credentials(ctx context.Context) (bitwire.LoginCredentials, error) {
+  return s.login, nil
+}
+
+func TestOpen(t *testing.T) {
+  Register("test", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    creds, err := parseSecret([]byte(name))
+    return static{creds}, err
+  })
+  assert.Contains(t, Schemes(), "test")
+
+  provider, err := Open(context.Background(), `test:{"client_id":"id","client_secret":"s3cr3t","username":"alice","password":"hunter2"}`)
+  assert.Nil(t, err)
+  creds, err := provider.Credentials(context.Background())
+  assert.Nil(t, err)
+  assert.Equal(t, "id", creds.ClientId)
+  assert.Equal(t, "password", creds.GrantType)
+  assert.Equal(t, "hunter2", creds.Password)
+
+  _, err = Open(context.Background(), `test:{"client_id":"id","username":"alice"}`)
+  assert.Equal(t, "Credentials secret is missing client_secret, password", err.Error())
+  _, err = Open(context.Background(), "test:{")
+  assert.Contains(t, err.Error(), "Invalid credentials secret")
+  _, err = Open(context.Background(), "bitwire/production")
+  assert.Contains(t, err.Error(), "Invalid secret reference")
+  _, err = Open(context.Background(), "gcp:bitwire")
+  assert.Contains(t, err.Error(), "Build with -tags gcp")
+}
@@ -0,0 +1,53 @@
+//go:build vault
+// +build vault
+
+package secrets
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire"
+  vault "github.com/hashicorp/vault/api"
+)
+
+func init() {
+  Register("vault", func(ctx context.Context, name string) (bitwire.CredentialProvider, error) {
+    return NewVault(name)
+  })
+}
+
+// Reads the credentials from a Vault KV secret, version 1 or 2
+type Vault struct {
+  Client *vault.Client
+  Path   string // e.g. secret/data/bitwire for a KV version 2 engine mounted at secret
+}
+
+// Returns a provider of the secret at the path, using a client configured by VAULT_ADDR, VAULT_TOKEN
+// and the other Vault environment variables
+func NewVault(path string) (*Vault, error) {
+  client, err := vault.NewClient(vault.DefaultConfig())
+  if err != nil {
+    return nil, err
+  }
+  return &Vault{Client: client, Path: path}, nil
+}
+
+func (v *Vault) Credentials(ctx context.Context) (bitwire.LoginCredentials, error) {
+  s, err := v.Client.Logical().ReadWithContext(ctx, v.Path)
+  if err != nil {
+    return bitwire.LoginCredentials{}, err
+  }
+  if s == nil || s.Data == nil {
+    return bitwire.LoginCredentials{}, errors.New("No Vault secret at " + v.Path)
+  }
+  fields := s.Data
+  if data, ok := s.Data["data"].(map[string]interface{}); ok {
+    fields = data // KV version 2 nests the fields
+  }
+  data, err := json.Marshal(fields)
+  if err != nil {
+    return bitwire.LoginCredentials{}, err
+  }
+  return parseSecret(data)
+}
@@ -0,0 +1,63 @@
+package bitwire
+
+import (
+  "strings"
+  "time"
+)
+
+// Policy cancelling transfers still unfunded shortly before their payment address expires,
+// so that they stop counting against the pending transfer limit.
+// The API has no labels on transfers, so exclusion labels are matched against the memo, e.g. "#keep".
+type CancelPolicy struct {
+  Before            time.Duration // Cancel transfers unfunded this long before their address expires
+  ExcludeIds        []string      // Transfers never cancelled
+  ExcludeRecipients []int         // Recipients whose transfers are never cancelled
+  ExcludeLabels     []string      // Transfers whose memo contains any of these are never cancelled
+}
+
+// Tells if the policy cancels the transfer at the time: a BTC funded transfer that is not final,
+// was not paid anything and whose payment address expires within Before
+func (p CancelPolicy) Stale(tx Transfer, now time.Time) bool {
+  expires := tx.ExpiresAt()
+  if tx.IsBankToBtc() || tx.IsFinal() || tx.Funding() != FundingUnpaid || expires.IsZero() || now.Add(p.Before).Before(expires) {
+    return false
+  }
+  for _, id := range p.ExcludeIds {
+    if id == tx.Id {
+      return false
+    }
+  }
+  for _, id := range p.ExcludeRecipients {
+    if id == tx.Recipient.Id {
+      return false
+    }
+  }
+  for _, label := range p.ExcludeLabels {
+    if label != "" && strings.Contains(tx.Memo, label) {
+      return false
+    }
+  }
+  return true
+}
+
+// Cancels the account's transfers that are stale under the policy and returns them as cancelled.
+// Stops at the first failure, returning the transfers cancelled before it.
+func (s *TransfersService) CancelStale(policy CancelPolicy) ([]Transfer, error) {
+  txs, err := s.List(nil)
+  if err != nil {
+    return nil, err
+  }
+  var cancelled []Transfer
+  now := time.Now()
+  for _, tx := range txs {
+    if !policy.Stale(tx, now) {
+      continue
+    }
+    tx, err := s.Cancel(tx.Id)
+    if err != nil {
+      return cancelled, err
+    }
+    cancelled = append(cancelled, tx)
+  }
+  return cancelled, nil
+}
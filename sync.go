@@ -0,0 +1,106 @@
+package bitwire
+
+import (
+  "bytes"
+  "encoding/json"
+  "time"
+)
+
+// Transfers created this long before the cursor are listed again by the next sync,
+// so that the API's date filter granularity and clock skew cannot skip any
+const SyncOverlap = 24 * time.Hour
+
+// Position of an incremental transfer sync, to be persisted between syncs.
+// The API has no updated-since filter, so a sync lists the transfers created since the
+// previous one, and fetches again those that had not reached a final status.
+type SyncCursor struct {
+  Since   time.Time `json:"since"`   // When the previous sync started, zero before the first one
+  Pending []string  `json:"pending"` // IDs of the synced transfers that were not final
+}
+
+// Counts of a sync
+type SyncStats struct {
+  Fetched   int `json:"fetched"`   // Transfers received from the API
+  Added     int `json:"added"`     // Transfers not stored before
+  Updated   int `json:"updated"`   // Stored transfers that changed
+  Unchanged int `json:"unchanged"` // Fetched transfers equal to the stored ones, e.g. in the overlap
+}
+
+// Local copy of the transfers kept up to date by Sync
+type TransferStore interface {
+  // Returns the stored transfer with the ID, if any
+  Transfer(id string) (Transfer, bool, error)
+  // Stores a new or changed transfer
+  Put(transfer Transfer) error
+}
+
+// Brings the store up to date from the cursor, storing only new or changed transfers.
+// Returns the cursor of the next sync, which is unchanged if the sync fails.
+func (s *TransfersService) Sync(cursor SyncCursor, store TransferStore) (SyncCursor, SyncStats, error) {
+  started := time.Now()
+  stats := SyncStats{}
+  opts := &TransferListOptions{}
+  if !cursor.Since.IsZero() {
+    opts.Since = cursor.Since.Add(-SyncOverlap).UTC().Truncate(24 * time.Hour)
+  }
+  fetched, err := s.List(opts)
+  if err != nil {
+    return cursor, stats, err
+  }
+  listed := map[string]bool{}
+  for _, tx := range fetched {
+    listed[tx.Id] = true
+  }
+  for _, id := range cursor.Pending {
+    if listed[id] {
+      continue
+    }
+    tx, err := s.Get(id)
+    if err != nil {
+      return cursor, stats, err
+    }
+    fetched = append(fetched, tx)
+  }
+
+  next := SyncCursor{Since: started, Pending: []string{}}
+  for _, tx := range fetched {
+    stats.Fetched++
+    if !tx.IsFinal() {
+      next.Pending = append(next.Pending, tx.Id)
+    }
+    stored, ok, err := store.Transfer(tx.Id)
+    if err != nil {
+      return cursor, stats, err
+    }
+    if ok {
+      same, err := sameTransfer(stored, tx)
+      if err != nil {
+        return cursor, stats, err
+      }
+      if same {
+        stats.Unchanged++
+        continue
+      }
+      stats.Updated++
+    } else {
+      stats.Added++
+    }
+    if err := store.Put(tx); err != nil {
+      return cursor, stats, err
+    }
+  }
+  return next, stats, nil
+}
+
+// Compares the transfers as the API returns them
+func sameTransfer(a, b Transfer) (bool, error) {
+  dataA, err := json.Marshal(a)
+  if err != nil {
+    return false, err
+  }
+  dataB, err := json.Marshal(b)
+  if err != nil {
+    return false, err
+  }
+  return bytes.Equal(dataA, dataB), nil
+}
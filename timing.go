@@ -0,0 +1,26 @@
+package bitwire
+
+import (
+  "time"
+)
+
+// Finished API call passed to OnCall
+type CallTiming struct {
+  Method   string        // HTTP method
+  Endpoint string        // Path with IDs replaced by ":id", as in the metrics labels
+  Status   int           // HTTP status of the last response, zero if none was received
+  Duration time.Duration // Including rate limit retries
+  Err      error
+}
+
+// Passes the finished call to OnCall, and warns if it took longer than SlowCallThreshold.
+// Calls retried after being rate limited are not warned about, as they include the wait.
+func (c *Client) finishCall(method Method, path string, stats *callStats, duration time.Duration, err error) {
+  endpoint := endpointLabel(path)
+  if c.OnCall != nil {
+    c.OnCall(CallTiming{method.httpMethod(), endpoint, stats.Status, duration, err})
+  }
+  if c.SlowCallThreshold > 0 && duration > c.SlowCallThreshold && stats.Retries == 0 {
+    c.warn(SlowCallWarning, "%s took %s, the API may be degraded", endpoint, duration.Round(100*time.Millisecond))
+  }
+}
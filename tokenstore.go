@@ -0,0 +1,95 @@
+package bitwire
+
+import (
+  "context"
+  "errors"
+  "math"
+  "os"
+)
+
+// Shared storage of the client's token, e.g. in DynamoDB or SSM Parameter Store, so that short-lived
+// processes like serverless functions reuse the token instead of authenticating on every cold start.
+// The secrets package has implementations for DynamoDB and SSM.
+type TokenStore interface {
+  // Returns the stored token, zero if there is none
+  LoadToken(ctx context.Context) (Token, error)
+  SaveToken(ctx context.Context, token Token) error
+}
+
+// Environment variables read by InitFromEnv
+const (
+  EnvMode         = "BITWIRE_MODE" // production or sandbox, production if not set
+  EnvClientId     = "BITWIRE_CLIENT_ID"
+  EnvClientSecret = "BITWIRE_CLIENT_SECRET"
+  EnvUsername     = "BITWIRE_USERNAME" // Used only when there is no stored token
+  EnvPassword     = "BITWIRE_PASSWORD"
+  EnvAPIURL       = "BITWIRE_API_URL" // Overrides the base URL of the mode
+  EnvAccessToken  = "BITWIRE_ACCESS_TOKEN"
+  EnvRefreshToken = "BITWIRE_REFRESH_TOKEN"
+)
+
+// Returns the token of EnvAccessToken and EnvRefreshToken, zero if neither is set. The access token is used until
+// the API rejects it, as its lifetime is unknown. A refresh token alone is exchanged for an access token at the first call,
+// which needs the client credentials.
+func TokenFromEnv() Token {
+  token := Token{TokenType: "Bearer", AccessToken: os.Getenv(EnvAccessToken), RefreshToken: os.Getenv(EnvRefreshToken)}
+  switch {
+  case token.AccessToken != "":
+    token.ValidUntil = math.MaxInt64
+  case token.RefreshToken == "":
+    return Token{}
+  }
+  return token
+}
+
+// Creates a client configured from the environment, for serverless functions, CI jobs and other short-lived processes.
+// The token is loaded from the store, if not nil, or from the environment with TokenFromEnv, and the client
+// authenticates with the username and password only when there is none. The client credentials can be left out
+// with an access token, which is then never refreshed. Refreshed tokens are saved back to the store.
+// Create the client once per process, e.g. in init, so that warm invocations reuse it.
+func InitFromEnv(ctx context.Context, store TokenStore) (*Client, error) {
+  mode := Mode(os.Getenv(EnvMode))
+  if mode == "" {
+    mode = PRODUCTION
+  }
+  creds := Credentials{os.Getenv(EnvClientId), os.Getenv(EnvClientSecret), "refresh_token"}
+  if (creds.ClientId == "" || creds.ClientSecret == "") && os.Getenv(EnvAccessToken) == "" {
+    return nil, errors.New("Missing " + EnvClientId + " or " + EnvClientSecret)
+  }
+  token := Token{}
+  if store != nil {
+    var err error
+    if token, err = store.LoadToken(ctx); err != nil {
+      return nil, err
+    }
+  }
+  if token == (Token{}) {
+    token = TokenFromEnv()
+  }
+  client, err := NewFromConfig(mode, Config{creds, token})
+  if err != nil {
+    return nil, err
+  }
+  client.BaseURL = os.Getenv(EnvAPIURL)
+  client.TokenStore = store
+  if token == (Token{}) {
+    login := LoginCredentials{Credentials{creds.ClientId, creds.ClientSecret, "password"}, os.Getenv(EnvUsername), os.Getenv(EnvPassword)}
+    if login.Username == "" || login.Password == "" {
+      return nil, errors.New("No stored token, and missing " + EnvUsername + " or " + EnvPassword)
+    }
+    if _, err := client.Authenticate(login); err != nil {
+      return nil, err
+    }
+  }
+  return client, nil
+}
+
+// Saves a new token to the client's store, if any. A failure is a warning, the call using the token goes on.
+func (c *Client) saveToken(token Token) {
+  if c.TokenStore == nil {
+    return
+  }
+  if err := c.TokenStore.SaveToken(context.Background(), token); err != nil {
+    c.warn(TokenStoreWarning, "Could not save the token: %s", err)
+  }
+}
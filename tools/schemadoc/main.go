@@ -0,0 +1,258 @@
+// Generates the schema of the API responses from the Go definitions of the bitwire package,
+// as markdown and JSON, for the CLI's docs command. Run by `go generate` in the package directory.
+package main
+
+import (
+  "encoding/json"
+  "flag"
+  "fmt"
+  "go/ast"
+  "go/parser"
+  "go/token"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "reflect"
+  "sort"
+  "strings"
+)
+
+// Schema of the response types and the types they refer to
+type Schema struct {
+  Types []Type `json:"types"`
+}
+
+type Type struct {
+  Name        string  `json:"name"`
+  Response    bool    `json:"response"` // Body of an API response
+  Description string  `json:"description,omitempty"`
+  Fields      []Field `json:"fields"`
+}
+
+type Field struct {
+  Name        string `json:"name"` // JSON name
+  Type        string `json:"type"` // string, integer, boolean, decimal, date-time, a type name, "array of" or "map of" one of them
+  Description string `json:"description,omitempty"`
+}
+
+// Types marshaled differently than their Go definition
+var builtins = map[string]string{
+  "string": "string", "bool": "boolean", "int": "integer", "int64": "integer", "int32": "integer",
+  "Decimal": "decimal", "time.Time": "date-time",
+}
+
+// Struct types of a package, by name
+type generator struct {
+  specs map[string]*ast.TypeSpec
+  docs  map[string]string
+  used  map[string]bool
+}
+
+// Parses the package in dir, without its tests
+func parsePackage(dir string) (*generator, error) {
+  paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+  if err != nil {
+    return nil, err
+  }
+  g := &generator{specs: map[string]*ast.TypeSpec{}, docs: map[string]string{}, used: map[string]bool{}}
+  fset := token.NewFileSet()
+  for _, path := range paths {
+    if strings.HasSuffix(path, "_test.go") {
+      continue
+    }
+    file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+    if err != nil {
+      return nil, err
+    }
+    for _, decl := range file.Decls {
+      gen, ok := decl.(*ast.GenDecl)
+      if !ok || gen.Tok != token.TYPE {
+        continue
+      }
+      for _, spec := range gen.Specs {
+        spec := spec.(*ast.TypeSpec)
+        g.specs[spec.Name.Name] = spec
+        doc := spec.Doc
+        if doc == nil && len(gen.Specs) == 1 {
+          doc = gen.Doc
+        }
+        g.docs[spec.Name.Name] = text(doc)
+      }
+    }
+  }
+  return g, nil
+}
+
+func text(group *ast.CommentGroup) string {
+  return strings.Join(strings.Fields(group.Text()), " ")
+}
+
+// Returns the exported struct types named *Res, and the struct types their fields refer to
+func (g *generator) schema() Schema {
+  var queue []string
+  for name, spec := range g.specs {
+    if _, ok := spec.Type.(*ast.StructType); ok && ast.IsExported(name) && strings.HasSuffix(name, "Res") {
+      g.used[name] = true
+      queue = append(queue, name)
+    }
+  }
+  var types []Type
+  for len(queue) > 0 {
+    name := queue[0]
+    queue = queue[1:]
+    t := Type{Name: name, Response: strings.HasSuffix(name, "Res"), Description: g.docs[name]}
+    t.Fields = g.fields(g.specs[name].Type.(*ast.StructType), &queue)
+    types = append(types, t)
+  }
+  sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+  return Schema{types}
+}
+
+// Returns the JSON fields of the struct, with those of untagged embedded structs promoted
+// and those of inline structs named after their parent, e.g. krw.min. Struct types the fields
+// refer to are added to the queue.
+func (g *generator) fields(st *ast.StructType, queue *[]string) []Field {
+  var fields []Field
+  for _, f := range st.Fields.List {
+    name := ""
+    if f.Tag != nil {
+      tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`")).Get("json")
+      name = strings.Split(tag, ",")[0]
+    }
+    if name == "-" {
+      continue
+    }
+    if len(f.Names) == 0 && name == "" {
+      if ident, ok := f.Type.(*ast.Ident); ok {
+        if embedded, ok := g.specs[ident.Name].Type.(*ast.StructType); ok {
+          fields = append(fields, g.fields(embedded, queue)...)
+        }
+      }
+      continue
+    }
+    description := text(f.Doc)
+    if description == "" {
+      description = text(f.Comment)
+    }
+    names := []string{name}
+    if name == "" {
+      names = nil
+      for _, ident := range f.Names {
+        if ident.IsExported() {
+          names = append(names, ident.Name)
+        }
+      }
+    }
+    for _, n := range names {
+      if inline, ok := f.Type.(*ast.StructType); ok {
+        for _, field := range g.fields(inline, queue) {
+          field.Name = n + "." + field.Name
+          fields = append(fields, field)
+        }
+      } else {
+        fields = append(fields, Field{n, g.typeName(f.Type, queue), description})
+      }
+    }
+  }
+  return fields
+}
+
+// Describes the JSON type of the expression
+func (g *generator) typeName(expr ast.Expr, queue *[]string) string {
+  switch e := expr.(type) {
+  case *ast.Ident:
+    if builtin, ok := builtins[e.Name]; ok {
+      return builtin
+    }
+    spec, ok := g.specs[e.Name]
+    if !ok {
+      return e.Name
+    }
+    if _, ok := spec.Type.(*ast.StructType); !ok {
+      return g.typeName(spec.Type, queue)
+    }
+    if !g.used[e.Name] {
+      g.used[e.Name] = true
+      *queue = append(*queue, e.Name)
+    }
+    return e.Name
+  case *ast.SelectorExpr:
+    name := fmt.Sprintf("%s.%s", e.X, e.Sel)
+    if builtin, ok := builtins[name]; ok {
+      return builtin
+    }
+    return name
+  case *ast.StarExpr:
+    return g.typeName(e.X, queue)
+  case *ast.ArrayType:
+    return "array of " + g.typeName(e.Elt, queue)
+  case *ast.MapType:
+    return "map of " + g.typeName(e.Value, queue)
+  default:
+    return "any"
+  }
+}
+
+// Renders the schema as markdown, with a section per type
+func (s Schema) Markdown() string {
+  var b strings.Builder
+  b.WriteString("# API response schema\n\nGenerated from the Go definitions by `go generate`. Do not edit.\n\n")
+  b.WriteString("Decimals are JSON strings such as \"0.00012345\". Date-times are RFC 3339 strings.\n\n## Responses\n\n")
+  for _, t := range s.Types {
+    if t.Response {
+      fmt.Fprintf(&b, "- [%s](#%s)\n", t.Name, strings.ToLower(t.Name))
+    }
+  }
+  for _, t := range s.Types {
+    fmt.Fprintf(&b, "\n## %s\n\n", t.Name)
+    if t.Description != "" {
+      b.WriteString(t.Description + "\n\n")
+    }
+    b.WriteString("| Field | Type | Description |\n|-------|------|-------------|\n")
+    for _, f := range t.Fields {
+      fmt.Fprintf(&b, "| `%s` | %s | %s |\n", f.Name, s.link(f.Type), f.Description)
+    }
+  }
+  return b.String()
+}
+
+// Links the type name at the end of a field type to its section
+func (s Schema) link(typeName string) string {
+  words := strings.Fields(typeName)
+  last := words[len(words)-1]
+  for _, t := range s.Types {
+    if t.Name == last {
+      words[len(words)-1] = fmt.Sprintf("[%s](#%s)", last, strings.ToLower(last))
+    }
+  }
+  return strings.Join(words, " ")
+}
+
+func main() {
+  dir := flag.String("dir", ".", "directory of the bitwire package")
+  md := flag.String("md", "", "markdown file to write")
+  jsonPath := flag.String("json", "", "JSON file to write")
+  flag.Parse()
+  g, err := parsePackage(*dir)
+  if err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+  schema := g.schema()
+  if *md != "" {
+    if err := ioutil.WriteFile(*md, []byte(schema.Markdown()), 0644); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  }
+  if *jsonPath != "" {
+    b, err := json.MarshalIndent(schema, "", "  ")
+    if err == nil {
+      err = ioutil.WriteFile(*jsonPath, append(b, '\n'), 0644)
+    }
+    if err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+  }
+}
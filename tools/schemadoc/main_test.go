@@ -0,0 +1,43 @@
+package main
+
+import (
+  "encoding/json"
+  "github.com/stretchr/testify/assert"
+  "io/ioutil"
+  "testing"
+)
+
+func TestSchema(t *testing.T) {
+  g, err := parsePackage("../..")
+  assert.Nil(t, err)
+  schema := g.schema()
+  types := map[string]Type{}
+  for _, t := range schema.Types {
+    types[t.Name] = t
+  }
+  assert.True(t, types["TransferRes"].Response)
+  assert.False(t, types["Transfer"].Response)
+  assert.Contains(t, types["TransferRecipient"].Fields, Field{"name_ko", "string", "Korean name, if the API returns it"})
+  assert.Contains(t, types["Transfer"].Fields, Field{"amount", "decimal", ""})
+  assert.Contains(t, types["BanksRes"].Fields, Field{"banks", "array of Bank", ""})
+  assert.Contains(t, types["Limits"].Fields, Field{"krw.daily", "KrwLimits", ""})
+  for _, f := range types["Transfer"].Fields {
+    assert.NotEqual(t, "CreatedAt", f.Name) // json:"-"
+  }
+  assert.Contains(t, schema.Markdown(), "| `banks` | array of [Bank](#bank) |")
+}
+
+// The schema the CLI embeds must be regenerated with go generate when the response types change
+func TestGeneratedUpToDate(t *testing.T) {
+  g, err := parsePackage("../..")
+  assert.Nil(t, err)
+  schema := g.schema()
+  md, err := ioutil.ReadFile("../../cmd/schema.md")
+  assert.Nil(t, err)
+  assert.Equal(t, schema.Markdown(), string(md), "run go generate")
+  b, err := json.MarshalIndent(schema, "", "  ")
+  assert.Nil(t, err)
+  generated, err := ioutil.ReadFile("../../cmd/schema.json")
+  assert.Nil(t, err)
+  assert.Equal(t, string(b)+"\n", string(generated), "run go generate")
+}
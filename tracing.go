@@ -0,0 +1,43 @@
+package bitwire
+
+import (
+  "context"
+  "go.opentelemetry.io/otel/attribute"
+  "go.opentelemetry.io/otel/codes"
+  "go.opentelemetry.io/otel/trace"
+)
+
+// Name of the tracer the client's spans are started with
+const tracerName = "github.com/dworznik/bitwire"
+
+// HTTP method an API call is sent with
+func (m Method) httpMethod() string {
+  if m == JSON_POST {
+    return string(POST)
+  }
+  return string(m)
+}
+
+// Starts the client span of an API call, named after the method and the endpoint path
+func (c *Client) startSpan(ctx context.Context, method Method, path string) (context.Context, trace.Span) {
+  return c.TracerProvider.Tracer(tracerName).Start(ctx, "bitwire "+method.httpMethod()+" "+path,
+    trace.WithSpanKind(trace.SpanKindClient),
+    trace.WithAttributes(
+      attribute.String("http.request.method", method.httpMethod()),
+      attribute.String("bitwire.endpoint", path),
+      attribute.String("bitwire.mode", string(c.Mode)),
+    ))
+}
+
+// Records the outcome of the call on the span and ends it
+func endSpan(span trace.Span, stats *callStats, err error) {
+  if stats.Status != 0 {
+    span.SetAttributes(attribute.Int("http.response.status_code", stats.Status))
+  }
+  span.SetAttributes(attribute.Int("bitwire.retries", stats.Retries))
+  if err != nil {
+    span.RecordError(err)
+    span.SetStatus(codes.Error, err.Error())
+  }
+  span.End()
+}
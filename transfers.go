@@ -0,0 +1,415 @@
+package bitwire
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+)
+
+type TransferRes struct {
+  Res
+  Transfer Transfer
+}
+
+type TransfersRes struct {
+  Res
+  Transfers []Transfer
+}
+
+type Transfer struct {
+  Id        string            `json:"id"`
+  Sender    Sender            `json:"sender"`
+  Type      string            `json:"type"`
+  Memo      string            `json:"memo"`
+  Amount    Decimal           `json:"amount"`
+  Currency  string            `json:"currency"`
+  Status    string            `json:"status"`
+  Date      string            `json:"date"`
+  BTC       BTC               `json:"btc"`
+  Recipient TransferRecipient `json:"recipient"`
+  Payout    Payout            `json:"payout"`
+  Deposit   Deposit           `json:"deposit"`
+  Fees      TransferFees      `json:"fees"`
+
+  CreatedAt time.Time `json:"-"` // Parsed from Date, zero if Date is empty or malformed
+}
+
+type CreateTransfer struct {
+  Amount      Decimal `json:"amount"`
+  Currency    string  `json:"currency"`
+  RecipientId int     `json:"recipient_id,omitempty"` // Bank recipient of a btc_to_bank transfer
+  Memo        string  `json:"memo"`
+  Type        string  `json:"type"`
+  Address     string  `json:"address,omitempty"` // BTC address receiving a bank_to_btc transfer
+}
+
+// Values of the transfer type
+const (
+  TypeBtcToBank = "btc_to_bank" // BTC paid to a payment address, KRW paid out to a recipient's bank account
+  TypeBankToBtc = "bank_to_btc" // KRW deposited to Bitwire's bank account, BTC sent to an address
+)
+
+// Checks the fields the transfer type needs, and that the address of a bank_to_btc transfer is valid in the mode.
+// Transfers without a type are left to the API to check.
+func (t CreateTransfer) Validate(mode Mode) error {
+  switch t.Type {
+  case TypeBtcToBank:
+    if t.RecipientId == 0 {
+      return errors.New("A btc_to_bank transfer needs a recipient")
+    }
+    if t.Address != "" {
+      return errors.New("A btc_to_bank transfer is paid out to the recipient, not to an address")
+    }
+  case TypeBankToBtc:
+    if t.Address == "" {
+      return errors.New("A bank_to_btc transfer needs the BTC address to send to")
+    }
+    return ValidateAddress(t.Address, mode)
+  }
+  return nil
+}
+
+type Sender struct {
+  Amount   Decimal `json:"amount"`
+  Currency string  `json:"currency"`
+}
+
+type TransferRecipient struct {
+  Recipient
+  Currency string  `json:"currency"`
+  Amount   Decimal `json:"amount"`
+}
+
+type BTC struct {
+  Address    string  `json:"address"` // Payment address, or the address receiving the BTC of a bank_to_btc transfer
+  Link       string  `json:"link"`
+  Expiration int     `json:"expiration"`
+  Received   Decimal `json:"received"` // BTC received on the address so far, if reported
+
+  ExpiresAt time.Time `json:"-"` // Parsed from Expiration, zero if there is none
+}
+
+// Payout to the recipient's bank account. Empty until the transfer is paid out,
+// or if the API does not report it.
+type Payout struct {
+  Reference string `json:"reference"`  // Bank transaction reference
+  Date      string `json:"date"`       // When the bank transfer was made
+  PayerName string `json:"payer_name"` // Sender name shown on the recipient's bank statement
+
+  PaidAt time.Time `json:"-"` // Parsed from Date
+}
+
+// Bank account to deposit the KRW of a bank_to_btc transfer to. Empty for btc_to_bank transfers.
+type Deposit struct {
+  BankName      string  `json:"bank_name"`
+  AccountNumber string  `json:"account_number"`
+  AccountHolder string  `json:"account_holder"`
+  Reference     string  `json:"reference"` // Depositor name to enter, so that the deposit is matched to the transfer
+  Amount        Decimal `json:"amount"`
+  Currency      string  `json:"currency"`
+}
+
+// Tells if KRW is deposited for the transfer and BTC sent to BTC.Address, rather than BTC paid to it
+func (t Transfer) IsBankToBtc() bool {
+  return t.Type == TypeBankToBtc
+}
+
+// Layouts of the dates returned by the API, tried in order
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+// Parses CreatedAt and BTC.ExpiresAt after decoding the API fields
+func (t *Transfer) UnmarshalJSON(data []byte) error {
+  type transfer Transfer // Same fields without the UnmarshalJSON method
+  if err := json.Unmarshal(data, (*transfer)(t)); err != nil {
+    return err
+  }
+  t.CreatedAt = parseDate(t.Date)
+  t.BTC.ExpiresAt = expirationTime(t.BTC.Expiration, t.CreatedAt)
+  t.Payout.PaidAt = parseDate(t.Payout.Date)
+  return nil
+}
+
+// Parses an API date given in one of dateLayouts, in UTC if it has no zone, or as a Unix timestamp
+func parseDate(date string) time.Time {
+  if date == "" {
+    return time.Time{}
+  }
+  if secs, err := strconv.ParseInt(date, 10, 64); err == nil {
+    return unixTime(secs)
+  }
+  for _, layout := range dateLayouts {
+    if t, err := time.Parse(layout, date); err == nil {
+      return t
+    }
+  }
+  return time.Time{}
+}
+
+// Expiration is a Unix timestamp, in seconds or milliseconds.
+// Small values are taken as seconds after the transfer was created.
+func expirationTime(expiration int, created time.Time) time.Time {
+  switch {
+  case expiration <= 0:
+    return time.Time{}
+  case expiration < 1e9:
+    if created.IsZero() {
+      return time.Time{}
+    }
+    return created.Add(time.Duration(expiration) * time.Second)
+  default:
+    return unixTime(int64(expiration))
+  }
+}
+
+func unixTime(secs int64) time.Time {
+  if secs >= 1e12 {
+    return time.Unix(0, secs*int64(time.Millisecond))
+  }
+  return time.Unix(secs, 0)
+}
+
+// Tells if the transfer reached a final status: completed, expired or canceled
+func (t Transfer) IsFinal() bool {
+  status := strings.ToLower(t.Status)
+  return strings.Contains(status, StatusCompleted) || strings.Contains(status, StatusExpired) ||
+    strings.Contains(status, StatusCanceled)
+}
+
+// Statuses of a transfer paid a different amount than asked for
+const (
+  StatusUnderpaid = "underpaid"
+  StatusOverpaid  = "overpaid"
+)
+
+// How the BTC payment of a transfer compares to the amount asked for
+type FundingState string
+
+const (
+  FundingUnpaid    FundingState = "unpaid"
+  FundingUnderpaid FundingState = "underpaid"
+  FundingPaid      FundingState = "paid"
+  FundingOverpaid  FundingState = "overpaid"
+)
+
+// Returns the funding state from the status, or from BTC.Received if the status does not tell.
+// A transfer completed without a reported received amount counts as paid.
+func (t Transfer) Funding() FundingState {
+  status := strings.ToLower(t.Status)
+  switch {
+  case strings.Contains(status, StatusUnderpaid):
+    return FundingUnderpaid
+  case strings.Contains(status, StatusOverpaid):
+    return FundingOverpaid
+  case t.BTC.Received.Sign() > 0:
+    switch t.BTC.Received.Cmp(t.Amount) {
+    case -1:
+      return FundingUnderpaid
+    case 1:
+      return FundingOverpaid
+    }
+    return FundingPaid
+  case strings.Contains(status, StatusCompleted):
+    return FundingPaid
+  default:
+    return FundingUnpaid
+  }
+}
+
+// Returns the BTC still to be paid, zero unless the transfer is underpaid and the received amount is known
+func (t Transfer) Missing() Decimal {
+  if t.Funding() != FundingUnderpaid || t.BTC.Received.Sign() <= 0 {
+    return Decimal{}
+  }
+  return t.Amount.Sub(t.BTC.Received)
+}
+
+// Returns the BTC paid over the amount, zero unless the transfer is overpaid and the received amount is known.
+// Overpayments are refunded by Bitwire, not by the API.
+func (t Transfer) Excess() Decimal {
+  if t.Funding() != FundingOverpaid || t.BTC.Received.Sign() <= 0 {
+    return Decimal{}
+  }
+  return t.BTC.Received.Sub(t.Amount)
+}
+
+// Returns when the payment address expires, zero if unknown
+func (t Transfer) ExpiresAt() time.Time {
+  return t.BTC.ExpiresAt
+}
+
+// Tells if the transfer expired, or if its payment address did while it is not final.
+// An expired address must not be paid to.
+func (t Transfer) IsExpired() bool {
+  if strings.Contains(strings.ToLower(t.Status), StatusExpired) {
+    return true
+  }
+  return !t.IsFinal() && !t.BTC.ExpiresAt.IsZero() && !time.Now().Before(t.BTC.ExpiresAt)
+}
+
+// Returns a BIP 21 URI paying the transfer amount to its address, empty if it has no address or is bank_to_btc.
+// Sandbox transfers are paid with testnet coins to a testnet address, and labeled TESTNET
+// so that wallets show them apart from real payments.
+func (t Transfer) PaymentURI(mode Mode) string {
+  if t.BTC.Address == "" || t.IsBankToBtc() {
+    return ""
+  }
+  params := url.Values{}
+  if t.Amount.Sign() > 0 && (t.Currency == "" || strings.EqualFold(t.Currency, "BTC")) {
+    params.Set("amount", t.Amount.String())
+  }
+  label := "Bitwire transfer " + t.Id
+  if mode == SANDBOX {
+    label = "TESTNET " + label
+  }
+  params.Set("label", label)
+  return "bitcoin:" + t.BTC.Address + "?" + strings.Replace(params.Encode(), "+", "%20", -1)
+}
+
+// Handles the authenticated user's transfers
+// https://developers.bitwire.co/api/v1/#transfers
+type TransfersService service
+
+// Values of the TransferListOptions status filter
+const (
+  StatusPending   = "pending"
+  StatusCompleted = "completed"
+  StatusExpired   = "expired"
+  StatusCanceled  = "canceled"
+)
+
+// Server side filters of Transfers.List. Zero fields are not sent.
+type TransferListOptions struct {
+  Status      string    `json:"status,omitempty" url:"status,omitempty"`
+  Since       time.Time `json:"since,omitempty" url:"since,omitempty"`
+  Until       time.Time `json:"until,omitempty" url:"until,omitempty"`
+  RecipientId int       `json:"recipient_id,omitempty" url:"recipient_id,omitempty"`
+  Type        string    `json:"type,omitempty" url:"type,omitempty"`
+}
+
+// Tells if the transfer passes the filters, the way the API applies them
+func (o TransferListOptions) Matches(t Transfer) bool {
+  switch {
+  case o.Status != "" && !strings.Contains(strings.ToLower(t.Status), o.Status):
+    return false
+  case o.RecipientId != 0 && t.Recipient.Id != o.RecipientId:
+    return false
+  case o.Type != "" && t.Type != o.Type:
+    return false
+  case !o.Since.IsZero() && t.CreatedAt.Before(o.Since):
+    return false
+  case !o.Until.IsZero() && !t.CreatedAt.Before(o.Until):
+    return false
+  }
+  return true
+}
+
+// Estimated cost of a transfer paid in BTC, computed from the current BTC rate.
+// The API does not publish fees or the payment window before a transfer is created,
+// so the estimate is the BTC amount at the current rate only.
+type Estimate struct {
+  Amount   Decimal `json:"amount"` // Received by the recipient
+  Currency string  `json:"currency"`
+  Pair     string  `json:"pair"`
+  Rate     Decimal `json:"rate"` // Price of 1 BTC in Currency
+  BTC      Decimal `json:"btc"`  // Rounded up to a satoshi
+}
+
+// Returns the BTC needed for the recipient to receive the amount in the currency,
+// without creating a transfer
+func (s *TransfersService) Estimate(amount Decimal, currency string) (Estimate, error) {
+  if amount.Sign() <= 0 {
+    return Estimate{}, errors.New("Amount must be positive")
+  }
+  rates, err := s.client.Rates.Btc()
+  if err != nil {
+    return Estimate{}, err
+  }
+  pair := "BTC" + strings.ToUpper(currency)
+  rate, ok := rates[pair]
+  if !ok || rate.Sign() <= 0 {
+    return Estimate{}, errors.New("No BTC rate for " + currency)
+  }
+  return Estimate{amount, strings.ToUpper(currency), pair, rate, amount.QuoUp(rate, 8)}, nil
+}
+
+// Returned by ValidateCurrency for a currency transfers cannot be created in
+var ErrUnsupportedCurrency = errors.New("Unsupported currency")
+
+// Returns the currencies transfers can be created in, those the API has a BTC rate for
+func (s *TransfersService) Currencies() ([]string, error) {
+  rates, err := s.client.Rates.Btc()
+  if err != nil {
+    return nil, err
+  }
+  return rates.Currencies(), nil
+}
+
+// Checks that transfers can be created in the currency. Errors match ErrUnsupportedCurrency with errors.Is.
+func (s *TransfersService) ValidateCurrency(currency string) error {
+  currencies, err := s.Currencies()
+  if err != nil {
+    return err
+  }
+  return checkCurrency(currency, currencies)
+}
+
+func checkCurrency(currency string, supported []string) error {
+  for _, c := range supported {
+    if strings.EqualFold(c, currency) {
+      return nil
+    }
+  }
+  return fmt.Errorf("%w: %s (supported: %s)", ErrUnsupportedCurrency, currency, strings.Join(supported, ", "))
+}
+
+// Lists transfers matching the options. Lists all transfers if opts is nil.
+func (s *TransfersService) List(opts *TransferListOptions) ([]Transfer, error) {
+  var params interface{}
+  if opts != nil {
+    params = opts
+  }
+  transfersRes := new(TransfersRes)
+  err := callApi(GET, "transfers", params, s.client, true, transfersRes)
+  if err != nil {
+    return nil, err
+  } else {
+    return transfersRes.Transfers, nil
+  }
+}
+
+func (s *TransfersService) Get(id string) (Transfer, error) {
+  transferRes := new(TransferRes)
+  err := callApi(GET, "transfers/"+id, nil, s.client, true, transferRes)
+  if err != nil {
+    return Transfer{}, err
+  } else {
+    return transferRes.Transfer, nil
+  }
+}
+
+func (s *TransfersService) Create(transfer CreateTransfer) (Transfer, error) {
+  if err := transfer.Validate(s.client.Mode); err != nil {
+    return Transfer{}, err
+  }
+  transferRes := new(TransferRes)
+  err := callApi(JSON_POST, "transfers", transfer, s.client, true, transferRes)
+  if err != nil {
+    return Transfer{}, err
+  } else {
+    return transferRes.Transfer, nil
+  }
+}
+
+func (s *TransfersService) Cancel(id string) (Transfer, error) {
+  transferRes := new(TransferRes)
+  err := callApi(DELETE, "transfers/"+id, nil, s.client, true, transferRes)
+  if err != nil {
+    return Transfer{}, err
+  } else {
+    return transferRes.Transfer, nil
+  }
+}
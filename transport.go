@@ -0,0 +1,9 @@
+//go:build !js
+// +build !js
+
+package bitwire
+
+import "net/http"
+
+// Sends the requests of clients without an HTTPClient
+var defaultHTTPClient = http.DefaultClient
@@ -0,0 +1,40 @@
+//go:build js
+// +build js
+
+package bitwire
+
+import "net/http"
+
+// Request headers the js/wasm net/http transport turns into fetch() options instead of sending them
+const (
+  jsFetchMode        = "js.fetch:mode"
+  jsFetchCredentials = "js.fetch:credentials"
+)
+
+// In the browser, requests go through fetch() in CORS mode without cookies
+var defaultHTTPClient = &http.Client{Transport: &FetchTransport{}}
+
+// Sends the requests with the browser's fetch(). The API authenticates with the bearer token,
+// so the requests are cross-origin and never need the page's cookies.
+type FetchTransport struct {
+  Mode        string            // fetch() mode, cors if empty
+  Credentials string            // fetch() credentials, omit if empty
+  Base        http.RoundTripper // Sends the requests, http.DefaultTransport if nil
+}
+
+func (t *FetchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+  mode, credentials, base := t.Mode, t.Credentials, t.Base
+  if mode == "" {
+    mode = "cors"
+  }
+  if credentials == "" {
+    credentials = "omit"
+  }
+  if base == nil {
+    base = http.DefaultTransport
+  }
+  req = req.Clone(req.Context())
+  req.Header.Set(jsFetchMode, mode)
+  req.Header.Set(jsFetchCredentials, credentials)
+  return base.RoundTrip(req)
+}
@@ -0,0 +1,80 @@
+package bitwire
+
+type UserRes struct {
+  Res
+  User User `json:"user"`
+}
+
+// Profile of the authenticated user
+type User struct {
+  Id        int    `json:"id"`
+  Email     string `json:"email"`
+  Name      string `json:"name"`
+  Level     int    `json:"level"`      // Verification level, which sets the limits
+  KYCStatus string `json:"kyc_status"` // Identity verification status, e.g. pending or verified
+}
+
+// Handles the authenticated user's profile
+type UsersService service
+
+// Returns the profile of the user the token belongs to
+func (s *UsersService) Me() (User, error) {
+  userRes := new(UserRes)
+  err := callApi(GET, "users/me", nil, s.client, true, userRes)
+  if err != nil {
+    return User{}, err
+  } else {
+    return userRes.User, nil
+  }
+}
+
+type VerificationRes struct {
+  Res
+  Verification Verification `json:"verification"`
+}
+
+// Statuses of a verification requirement
+const (
+  RequirementRequired = "required" // Not submitted yet
+  RequirementPending  = "pending"  // Submitted and under review
+  RequirementRejected = "rejected" // Submitted and rejected, to be submitted again
+  RequirementApproved = "approved"
+)
+
+// Step of the identity verification, e.g. an ID document or a proof of address
+type Requirement struct {
+  Type        string `json:"type"`
+  Description string `json:"description"`
+  Status      string `json:"status"`
+  Level       int    `json:"level"` // Verification level the requirement unlocks
+}
+
+// Verification level of the user and the requirements of the next levels
+type Verification struct {
+  Level        int           `json:"level"`
+  KYCStatus    string        `json:"kyc_status"`
+  NextLevel    int           `json:"next_level"` // Zero at the highest level
+  Requirements []Requirement `json:"requirements"`
+}
+
+// Returns the requirements that are not approved, i.e. the user's next steps
+func (v Verification) Outstanding() []Requirement {
+  var outstanding []Requirement
+  for _, req := range v.Requirements {
+    if req.Status != RequirementApproved {
+      outstanding = append(outstanding, req)
+    }
+  }
+  return outstanding
+}
+
+// Returns the verification level and requirements of the user the token belongs to
+func (s *UsersService) Verification() (Verification, error) {
+  verificationRes := new(VerificationRes)
+  err := callApi(GET, "users/verification", nil, s.client, true, verificationRes)
+  if err != nil {
+    return Verification{}, err
+  } else {
+    return verificationRes.Verification, nil
+  }
+}
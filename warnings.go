@@ -0,0 +1,71 @@
+package bitwire
+
+import (
+  "fmt"
+  "math/big"
+)
+
+type WarningKind string
+
+const (
+  NearLimitWarning  WarningKind = "near_limit"
+  TokenStoreWarning WarningKind = "token_store"
+  RatePollWarning   WarningKind = "rate_poll"
+  StaleCacheWarning WarningKind = "stale_cache"
+  DeprecatedWarning WarningKind = "deprecated"
+  SlowCallWarning   WarningKind = "slow_call"
+)
+
+// Non-fatal condition detected by the client while serving a call
+type Warning struct {
+  Kind    WarningKind
+  Message string
+}
+
+func (w Warning) String() string {
+  return w.Message
+}
+
+// Share of a limit left below which a near-limit warning is issued
+var nearLimitShare = big.NewRat(1, 10)
+
+// Passes the warning to the OnWarning callback, if set
+func (c *Client) warn(kind WarningKind, format string, v ...interface{}) {
+  if c.OnWarning != nil {
+    c.OnWarning(Warning{kind, fmt.Sprintf(format, v...)})
+  }
+}
+
+// Warns that the endpoint is deprecated or will be removed, once per client and announcement
+func (c *Client) warnDeprecation(method Method, path string, d Deprecation) {
+  message := d.describe(method, path)
+  c.mu.Lock()
+  warned := c.deprecations[message]
+  if c.deprecations == nil {
+    c.deprecations = map[string]bool{}
+  }
+  c.deprecations[message] = true
+  c.mu.Unlock()
+  if !warned {
+    c.warn(DeprecatedWarning, "%s", message)
+  }
+}
+
+// Warns when less than a tenth of a KRW limit, or no pending transfer slot, is left
+func checkLimits(c *Client, limits Limits) {
+  checkKrwLimit(c, "daily", limits.KRW.Daily)
+  checkKrwLimit(c, "weekly", limits.KRW.Weekly)
+  pending := limits.Transfers.Pending.Total
+  if pending.Limit > 0 && pending.Used >= pending.Limit {
+    c.warn(NearLimitWarning, "All %d pending transfer slots are used", pending.Limit)
+  }
+}
+
+func checkKrwLimit(c *Client, period string, limits KrwLimits) {
+  if limits.Limit.Sign() <= 0 {
+    return
+  }
+  if new(big.Rat).Quo(limits.Left.Rat(), limits.Limit.Rat()).Cmp(nearLimitShare) < 0 {
+    c.warn(NearLimitWarning, "Only %s KRW of the %s KRW %s limit is left", limits.Left, limits.Limit, period)
+  }
+}
@@ -0,0 +1,116 @@
+// Parsing and verification of Bitwire webhook notifications
+package webhook
+
+import (
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "github.com/dworznik/bitwire"
+  "io/ioutil"
+  "net/http"
+  "strings"
+)
+
+// Header carrying the hex encoded HMAC-SHA256 of the request body
+const SignatureHeader = "X-Bitwire-Signature"
+
+// Largest webhook body accepted by the handler
+const maxBodySize = 1 << 20
+
+type EventType string
+
+const (
+  TransferCreated   EventType = "transfer.created"
+  TransferPaid      EventType = "transfer.paid"
+  TransferCompleted EventType = "transfer.completed"
+  TransferExpired   EventType = "transfer.expired"
+  TransferCanceled  EventType = "transfer.canceled"
+  TransferUnderpaid EventType = "transfer.underpaid" // Less BTC than asked for was received
+  TransferOverpaid  EventType = "transfer.overpaid"  // More BTC than asked for was received, the excess is refunded
+)
+
+var (
+  ErrMissingSignature = errors.New("Missing webhook signature")
+  ErrInvalidSignature = errors.New("Invalid webhook signature")
+)
+
+// Webhook notification of a transfer status change
+type Event struct {
+  Id       string           `json:"id"`
+  Type     EventType        `json:"type"`
+  Created  int64            `json:"created"`
+  Transfer bitwire.Transfer `json:"transfer"`
+}
+
+// Computes the signature of the payload
+func Sign(payload []byte, secret string) string {
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write(payload)
+  return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checks the signature of the payload. The signature may have a "sha256=" prefix.
+func Verify(payload []byte, signature string, secret string) error {
+  if signature == "" {
+    return ErrMissingSignature
+  }
+  expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+  if err != nil {
+    return ErrInvalidSignature
+  }
+  mac := hmac.New(sha256.New, []byte(secret))
+  mac.Write(payload)
+  if !hmac.Equal(expected, mac.Sum(nil)) {
+    return ErrInvalidSignature
+  }
+  return nil
+}
+
+// Parses the payload without verifying it
+func Parse(payload []byte) (Event, error) {
+  event := Event{}
+  err := json.Unmarshal(payload, &event)
+  if err != nil {
+    return Event{}, err
+  } else if event.Type == "" {
+    return Event{}, errors.New("Missing webhook event type")
+  } else {
+    return event, nil
+  }
+}
+
+// Reads, verifies and parses a webhook request
+func ParseRequest(r *http.Request, secret string) (Event, []byte, error) {
+  payload, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+  if err != nil {
+    return Event{}, nil, err
+  }
+  if err := Verify(payload, r.Header.Get(SignatureHeader), secret); err != nil {
+    return Event{}, payload, err
+  }
+  event, err := Parse(payload)
+  return event, payload, err
+}
+
+// Returns a handler that verifies webhook requests and passes their events to fn.
+// Responds with 401 to requests with a missing or invalid signature and with 400 to malformed ones.
+func Handler(secret string, fn func(Event)) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+      http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+      return
+    }
+    event, _, err := ParseRequest(r, secret)
+    switch {
+    case err == ErrMissingSignature || err == ErrInvalidSignature:
+      http.Error(w, err.Error(), http.StatusUnauthorized)
+    case err != nil:
+      http.Error(w, err.Error(), http.StatusBadRequest)
+    default:
+      fn(event)
+      w.WriteHeader(http.StatusOK)
+    }
+  })
+}
@@ -0,0 +1,57 @@
+package webhook
+
+import (
+  "bytes"
+  "github.com/stretchr/testify/assert"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+)
+
+const secret = "whsec"
+
+var payload = []byte(`{"id":"evt_1","type":"transfer.completed","created":1484800000,"transfer":{"id":"tx_1","status":"PAID_COMPLETED","amount":"100000","currency":"KRW"}}`)
+
+func TestVerify(t *testing.T) {
+  signature := Sign(payload, secret)
+  assert.Nil(t, Verify(payload, signature, secret))
+  assert.Nil(t, Verify(payload, "sha256="+signature, secret))
+  assert.Equal(t, ErrInvalidSignature, Verify(payload, signature, "other"))
+  assert.Equal(t, ErrInvalidSignature, Verify(payload, "zz", secret))
+  assert.Equal(t, ErrMissingSignature, Verify(payload, "", secret))
+}
+
+func TestParse(t *testing.T) {
+  event, err := Parse(payload)
+  assert.Nil(t, err)
+  assert.Equal(t, TransferCompleted, event.Type)
+  assert.Equal(t, "tx_1", event.Transfer.Id)
+  assert.Equal(t, "PAID_COMPLETED", event.Transfer.Status)
+
+  _, err = Parse([]byte(`{"id":"evt_2"}`))
+  assert.NotNil(t, err)
+  _, err = Parse([]byte(`not json`))
+  assert.NotNil(t, err)
+}
+
+func TestHandler(t *testing.T) {
+  var events []Event
+  handler := Handler(secret, func(e Event) {
+    events = append(events, e)
+  })
+  send := func(method string, body []byte, signature string) int {
+    req := httptest.NewRequest(method, "/webhook", bytes.NewReader(body))
+    if signature != "" {
+      req.Header.Set(SignatureHeader, signature)
+    }
+    rec := httptest.NewRecorder()
+    handler.ServeHTTP(rec, req)
+    return rec.Code
+  }
+  assert.Equal(t, http.StatusOK, send("POST", payload, Sign(payload, secret)))
+  assert.Equal(t, http.StatusUnauthorized, send("POST", payload, Sign(payload, "other")))
+  assert.Equal(t, http.StatusUnauthorized, send("POST", payload, ""))
+  assert.Equal(t, http.StatusBadRequest, send("POST", []byte("{}"), Sign([]byte("{}"), secret)))
+  assert.Equal(t, http.StatusMethodNotAllowed, send("GET", nil, ""))
+  assert.Len(t, events, 1)
+}